@@ -19,9 +19,35 @@ import (
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 )
 
+// errOutputIgnored is returned when connecting an output failed and the
+// output is ignored according to its startup error behavior.
+var errOutputIgnored = errors.New("output ignored")
+
+// Delays between the attempts to connect an output in the background
+var (
+	reconnectMinDelay = 5 * time.Second
+	reconnectMaxDelay = 5 * time.Minute
+)
+
 // Agent runs a set of plugins.
 type Agent struct {
 	Config *config.Config
+
+	initialized bool
+
+	// Function to request a configuration reload via the management API
+	reloadFunc func()
+
+	// Settings of the plugins reported by the management API by running plugin
+	settings map[interface{}]map[string]interface{}
+
+	// Plugin units of the running agent used for in-place reloads
+	reloading sync.Mutex // serializes reloads
+	reloadMu  sync.Mutex
+	iu        *inputUnit
+	pu        []*processorUnit
+	apu       []*processorUnit
+	ou        *outputUnit
 }
 
 // NewAgent returns an Agent for the given Config.
@@ -46,6 +72,29 @@ func NewAgent(cfg *config.Config) *Agent {
 type inputUnit struct {
 	dst    chan<- telegraf.Metric
 	inputs []*models.RunningInput
+
+	// State of the gather loops protected by the mutex
+	sync.Mutex
+	ctx       context.Context
+	startTime time.Time
+	running   map[*models.RunningInput]*pluginHandle
+	wg        sync.WaitGroup
+	stopped   bool
+}
+
+// pluginHandle allows to stop the loop of a single plugin and to wait for
+// the loop to finish.
+type pluginHandle struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// flush requests an immediate flush of an output
+	flush chan os.Signal
+}
+
+func (h *pluginHandle) stop() {
+	h.cancel()
+	<-h.done
 }
 
 //  ______     ┌───────────┐     ______
@@ -56,6 +105,12 @@ type processorUnit struct {
 	src       <-chan telegraf.Metric
 	dst       chan<- telegraf.Metric
 	processor *models.RunningProcessor
+
+	// The processor might be replaced while running so protect it and its
+	// accumulator
+	sync.Mutex
+	acc     telegraf.Accumulator
+	stopped bool
 }
 
 // aggregatorUnit is a group of Aggregators and their source and sink channels.
@@ -97,6 +152,13 @@ type aggregatorUnit struct {
 type outputUnit struct {
 	src     <-chan telegraf.Metric
 	outputs []*models.RunningOutput
+
+	// State of the flush loops protected by the mutex
+	sync.RWMutex
+	ctx     context.Context
+	running map[*models.RunningOutput]*pluginHandle
+	wg      sync.WaitGroup
+	stopped bool
 }
 
 // Run starts and runs the Agent until the context is done.
@@ -106,8 +168,7 @@ func (a *Agent) Run(ctx context.Context) error {
 		time.Duration(a.Config.Agent.Interval), a.Config.Agent.Quiet,
 		a.Config.Agent.Hostname, time.Duration(a.Config.Agent.FlushInterval))
 
-	log.Printf("D! [agent] Initializing plugins")
-	if err := a.initPlugins(); err != nil {
+	if err := a.InitPlugins(); err != nil {
 		return err
 	}
 
@@ -124,6 +185,24 @@ func (a *Agent) Run(ctx context.Context) error {
 		}
 	}
 
+	if err := a.openRecorder(); err != nil {
+		return err
+	}
+	defer a.closeRecorder()
+
+	if a.Config.Agent.ManagementAddress != "" {
+		stopAPI, err := a.startManagementAPI(a.Config.Agent.ManagementAddress, &a.Config.Agent.ManagementToken)
+		if err != nil {
+			return err
+		}
+		defer stopAPI()
+	}
+
+	for _, path := range models.OrphanedDiskBuffers(a.Config.Outputs) {
+		log.Printf("W! [agent] Disk buffer %q is not used by any output and might contain unsent metrics", path)
+	}
+	a.checkDeliveryTimeouts()
+
 	startTime := time.Now()
 
 	log.Printf("D! [agent] Connecting outputs")
@@ -132,6 +211,31 @@ func (a *Agent) Run(ctx context.Context) error {
 		return err
 	}
 
+	// Checkpoint the plugin states periodically and on request until all
+	// plugins are stopped
+	var persisterWg sync.WaitGroup
+	persisterCtx, persisterCancel := context.WithCancel(context.Background())
+	defer persisterCancel()
+	if a.Config.Persister != nil {
+		persisterWg.Add(1)
+		go func() {
+			defer persisterWg.Done()
+			a.Config.Persister.Run(persisterCtx)
+		}()
+	}
+
+	// Refresh changed secrets until all plugins are stopped
+	var secretsWg sync.WaitGroup
+	secretsCtx, secretsCancel := context.WithCancel(context.Background())
+	defer secretsCancel()
+	if interval := time.Duration(a.Config.Agent.SecretRefreshInterval); interval > 0 {
+		secretsWg.Add(1)
+		go func() {
+			defer secretsWg.Done()
+			a.refreshSecrets(secretsCtx, interval)
+		}()
+	}
+
 	var apu []*processorUnit
 	var au *aggregatorUnit
 	if len(a.Config.Aggregators) != 0 {
@@ -159,6 +263,15 @@ func (a *Agent) Run(ctx context.Context) error {
 		return err
 	}
 
+	a.reloadMu.Lock()
+	a.iu, a.pu, a.apu, a.ou = iu, pu, apu, ou
+	a.reloadMu.Unlock()
+	defer func() {
+		a.reloadMu.Lock()
+		a.iu, a.pu, a.apu, a.ou = nil, nil, nil, nil
+		a.reloadMu.Unlock()
+	}()
+
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
@@ -196,6 +309,12 @@ func (a *Agent) Run(ctx context.Context) error {
 
 	wg.Wait()
 
+	secretsCancel()
+	secretsWg.Wait()
+
+	persisterCancel()
+	persisterWg.Wait()
+
 	if a.Config.Persister != nil {
 		log.Printf("D! [agent] Persisting plugin states")
 		if err := a.Config.Persister.Store(); err != nil {
@@ -207,43 +326,114 @@ func (a *Agent) Run(ctx context.Context) error {
 	return err
 }
 
+// InitPlugins runs the Init function on plugins. The plugins are initialized
+// only once, so the function can be used to validate the configuration before
+// running the agent.
+func (a *Agent) InitPlugins() error {
+	if a.initialized {
+		return nil
+	}
+
+	log.Printf("D! [agent] Initializing plugins")
+	if err := a.initPlugins(); err != nil {
+		return err
+	}
+	a.snapshotSettings()
+	a.initialized = true
+	return nil
+}
+
 // initPlugins runs the Init function on plugins.
 func (a *Agent) initPlugins() error {
+	if errs := a.initEachPlugin(true); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// CheckPlugins initializes all plugins without starting them and returns the
+// errors of all plugins failing to initialize, e.g. to validate the
+// configuration.
+func (a *Agent) CheckPlugins() []error {
+	return a.initEachPlugin(false)
+}
+
+// initEachPlugin initializes the plugins in the order of the pipeline and
+// returns the errors encountered, stopping at the first one if requested.
+func (a *Agent) initEachPlugin(stopOnError bool) []error {
+	var errs []error
+	failed := func(err error) bool {
+		errs = append(errs, err)
+		return stopOnError
+	}
+
 	for _, input := range a.Config.Inputs {
 		// Share the snmp translator setting with plugins that need it.
 		if tp, ok := input.Input.(snmp.TranslatorPlugin); ok {
 			tp.SetTranslator(a.Config.Agent.SnmpTranslator)
 		}
 		err := input.Init()
-		if err != nil {
-			return fmt.Errorf("could not initialize input %s: %w", input.LogName(), err)
+		if err != nil && failed(fmt.Errorf("could not initialize input %s: %w", input.LogName(), err)) {
+			return errs
 		}
 	}
 	for _, processor := range a.Config.Processors {
 		err := processor.Init()
-		if err != nil {
-			return fmt.Errorf("could not initialize processor %s: %w", processor.LogName(), err)
+		if err != nil && failed(fmt.Errorf("could not initialize processor %s: %w", processor.LogName(), err)) {
+			return errs
 		}
 	}
 	for _, aggregator := range a.Config.Aggregators {
 		err := aggregator.Init()
-		if err != nil {
-			return fmt.Errorf("could not initialize aggregator %s: %w", aggregator.LogName(), err)
+		if err != nil && failed(fmt.Errorf("could not initialize aggregator %s: %w", aggregator.LogName(), err)) {
+			return errs
 		}
 	}
 	for _, processor := range a.Config.AggProcessors {
 		err := processor.Init()
-		if err != nil {
-			return fmt.Errorf("could not initialize processor %s: %w", processor.LogName(), err)
+		if err != nil && failed(fmt.Errorf("could not initialize processor %s: %w", processor.LogName(), err)) {
+			return errs
 		}
 	}
 	for _, output := range a.Config.Outputs {
 		err := output.Init()
-		if err != nil {
-			return fmt.Errorf("could not initialize output %s: %w", output.LogName(), err)
+		if err != nil && failed(fmt.Errorf("could not initialize output %s: %w", output.LogName(), err)) {
+			return errs
 		}
 	}
-	return nil
+	return errs
+}
+
+// openRecorder opens the capture file for recording the data passed to the
+// parsers of the inputs if configured.
+func (a *Agent) openRecorder() error {
+	agentConfig := a.Config.Agent
+	if agentConfig.RecordFile == "" {
+		if agentConfig.Record {
+			return errors.New("recording all inputs requires the record_file setting")
+		}
+		for _, input := range a.Config.Inputs {
+			if input.Config.Record {
+				return fmt.Errorf("recording input %s requires the record_file setting", input.LogName())
+			}
+		}
+		return nil
+	}
+
+	log.Printf("I! [agent] Recording parser data to %q", agentConfig.RecordFile)
+	return a.Config.Recorder.Open(models.RecorderConfig{
+		Filename:            agentConfig.RecordFile,
+		All:                 agentConfig.Record,
+		RotationInterval:    time.Duration(agentConfig.RecordRotationInterval),
+		RotationMaxSize:     int64(agentConfig.RecordRotationMaxSize),
+		RotationMaxArchives: agentConfig.RecordRotationMaxArchives,
+	})
+}
+
+func (a *Agent) closeRecorder() {
+	if err := a.Config.Recorder.Close(); err != nil {
+		log.Printf("E! [agent] Closing capture file failed: %v", err)
+	}
 }
 
 // initPersister initializes the persister and registers the plugins.
@@ -331,27 +521,9 @@ func (a *Agent) startInputs(
 	}
 
 	for _, input := range inputs {
-		if si, ok := input.Input.(telegraf.ServiceInput); ok {
-			// Service input plugins are not normally subject to timestamp
-			// rounding except for when precision is set on the input plugin.
-			//
-			// This only applies to the accumulator passed to Start(), the
-			// Gather() accumulator does apply rounding according to the
-			// precision and interval agent/plugin settings.
-			var interval time.Duration
-			var precision time.Duration
-			if input.Config.Precision != 0 {
-				precision = input.Config.Precision
-			}
-
-			acc := NewAccumulator(input, dst)
-			acc.SetPrecision(getPrecision(precision, interval))
-
-			err := si.Start(acc)
-			if err != nil {
-				stopServiceInputs(unit.inputs)
-				return nil, fmt.Errorf("starting input %s: %w", input.LogName(), err)
-			}
+		if err := startServiceInput(input, dst); err != nil {
+			stopServiceInputs(unit.inputs)
+			return nil, err
 		}
 		unit.inputs = append(unit.inputs, input)
 	}
@@ -359,6 +531,34 @@ func (a *Agent) startInputs(
 	return unit, nil
 }
 
+// startServiceInput calls Start on the input if it is a service input.
+func startServiceInput(input *models.RunningInput, dst chan<- telegraf.Metric) error {
+	si, ok := input.Input.(telegraf.ServiceInput)
+	if !ok {
+		return nil
+	}
+
+	// Service input plugins are not normally subject to timestamp
+	// rounding except for when precision is set on the input plugin.
+	//
+	// This only applies to the accumulator passed to Start(), the
+	// Gather() accumulator does apply rounding according to the
+	// precision and interval agent/plugin settings.
+	var interval time.Duration
+	var precision time.Duration
+	if input.Config.Precision != 0 {
+		precision = input.Config.Precision
+	}
+
+	acc := NewAccumulator(input, dst)
+	acc.SetPrecision(getPrecision(precision, interval))
+
+	if err := si.Start(acc); err != nil {
+		return fmt.Errorf("starting input %s: %w", input.LogName(), err)
+	}
+	return nil
+}
+
 // runInputs starts and triggers the periodic gather for Inputs.
 //
 // When the context is done the timers are stopped and this function returns
@@ -368,58 +568,79 @@ func (a *Agent) runInputs(
 	startTime time.Time,
 	unit *inputUnit,
 ) {
-	var wg sync.WaitGroup
-	tickers := make([]Ticker, 0, len(unit.inputs))
+	unit.Lock()
+	unit.ctx = ctx
+	unit.startTime = startTime
+	unit.running = make(map[*models.RunningInput]*pluginHandle, len(unit.inputs))
 	for _, input := range unit.inputs {
-		// Overwrite agent interval if this plugin has its own.
-		interval := time.Duration(a.Config.Agent.Interval)
-		if input.Config.Interval != 0 {
-			interval = input.Config.Interval
-		}
+		a.runInput(unit, input)
+	}
+	unit.Unlock()
 
-		// Overwrite agent precision if this plugin has its own.
-		precision := time.Duration(a.Config.Agent.Precision)
-		if input.Config.Precision != 0 {
-			precision = input.Config.Precision
-		}
+	<-ctx.Done()
 
-		// Overwrite agent collection_jitter if this plugin has its own.
-		jitter := time.Duration(a.Config.Agent.CollectionJitter)
-		if input.Config.CollectionJitter != 0 {
-			jitter = input.Config.CollectionJitter
-		}
+	// Prevent inputs from being added while shutting down
+	unit.Lock()
+	unit.stopped = true
+	unit.Unlock()
+	unit.wg.Wait()
 
-		// Overwrite agent collection_offset if this plugin has its own.
-		offset := time.Duration(a.Config.Agent.CollectionOffset)
-		if input.Config.CollectionOffset != 0 {
-			offset = input.Config.CollectionOffset
-		}
+	log.Printf("D! [agent] Stopping service inputs")
+	stopServiceInputs(unit.inputs)
+	discardPendingGathers(unit.inputs)
 
-		var ticker Ticker
-		if a.Config.Agent.RoundInterval {
-			ticker = NewAlignedTicker(startTime, interval, jitter, offset)
-		} else {
-			ticker = NewUnalignedTicker(interval, jitter, offset)
-		}
-		tickers = append(tickers, ticker)
+	close(unit.dst)
+	log.Printf("D! [agent] Input channel closed")
+}
 
-		acc := NewAccumulator(input, unit.dst)
-		acc.SetPrecision(getPrecision(precision, interval))
+// runInput starts the periodic gather loop for a single input. The unit's
+// lock must be held by the caller.
+func (a *Agent) runInput(unit *inputUnit, input *models.RunningInput) {
+	// Overwrite agent interval if this plugin has its own.
+	interval := time.Duration(a.Config.Agent.Interval)
+	if input.Config.Interval != 0 {
+		interval = input.Config.Interval
+	}
 
-		wg.Add(1)
-		go func(input *models.RunningInput) {
-			defer wg.Done()
-			a.gatherLoop(ctx, acc, input, ticker, interval)
-		}(input)
+	// Overwrite agent precision if this plugin has its own.
+	precision := time.Duration(a.Config.Agent.Precision)
+	if input.Config.Precision != 0 {
+		precision = input.Config.Precision
 	}
-	defer stopTickers(tickers)
-	wg.Wait()
 
-	log.Printf("D! [agent] Stopping service inputs")
-	stopServiceInputs(unit.inputs)
+	// Overwrite agent collection_jitter if this plugin has its own.
+	jitter := time.Duration(a.Config.Agent.CollectionJitter)
+	if input.Config.CollectionJitter != 0 {
+		jitter = input.Config.CollectionJitter
+	}
 
-	close(unit.dst)
-	log.Printf("D! [agent] Input channel closed")
+	// Overwrite agent collection_offset if this plugin has its own.
+	offset := time.Duration(a.Config.Agent.CollectionOffset)
+	if input.Config.CollectionOffset != 0 {
+		offset = input.Config.CollectionOffset
+	}
+
+	var ticker Ticker
+	if a.Config.Agent.RoundInterval {
+		ticker = NewAlignedTicker(unit.startTime, interval, jitter, offset)
+	} else {
+		ticker = NewUnalignedTicker(interval, jitter, offset)
+	}
+
+	acc := NewAccumulator(input, unit.dst)
+	acc.SetPrecision(getPrecision(precision, interval))
+
+	ctx, cancel := context.WithCancel(unit.ctx)
+	handle := &pluginHandle{cancel: cancel, done: make(chan struct{})}
+	unit.running[input] = handle
+
+	unit.wg.Add(1)
+	go func() {
+		defer unit.wg.Done()
+		defer close(handle.done)
+		defer ticker.Stop()
+		a.gatherLoop(ctx, acc, input, ticker, interval)
+	}()
 }
 
 // testStartInputs is a variation of startInputs for use in --test and --once
@@ -495,7 +716,7 @@ func (a *Agent) testRunInputs(
 			case "cpu", "mongodb", "procstat":
 				nulAcc := NewAccumulator(input, nul)
 				nulAcc.SetPrecision(getPrecision(precision, interval))
-				if err := input.Input.Gather(nulAcc); err != nil {
+				if err := input.GatherContext(ctx, nulAcc); err != nil {
 					nulAcc.AddError(err)
 				}
 
@@ -505,7 +726,7 @@ func (a *Agent) testRunInputs(
 			acc := NewAccumulator(input, unit.dst)
 			acc.SetPrecision(getPrecision(precision, interval))
 
-			if err := input.Input.Gather(acc); err != nil {
+			if err := input.GatherContext(ctx, acc); err != nil {
 				acc.AddError(err)
 			}
 		}(input)
@@ -518,11 +739,20 @@ func (a *Agent) testRunInputs(
 
 	log.Printf("D! [agent] Stopping service inputs")
 	stopServiceInputs(unit.inputs)
+	discardPendingGathers(unit.inputs)
 
 	close(unit.dst)
 	log.Printf("D! [agent] Input channel closed")
 }
 
+// discardPendingGathers drops the metrics of gathers still running after
+// exceeding their timeout instead of sending them to the closed channel.
+func discardPendingGathers(inputs []*models.RunningInput) {
+	for _, input := range inputs {
+		input.DiscardPending()
+	}
+}
+
 // stopServiceInputs stops all service inputs.
 func stopServiceInputs(inputs []*models.RunningInput) {
 	for _, input := range inputs {
@@ -553,7 +783,7 @@ func (a *Agent) gatherLoop(
 	for {
 		select {
 		case <-ticker.Elapsed():
-			err := a.gatherOnce(acc, input, ticker, interval)
+			err := a.gatherOnce(ctx, acc, input, ticker, interval)
 			if err != nil {
 				acc.AddError(err)
 			}
@@ -566,6 +796,7 @@ func (a *Agent) gatherLoop(
 // gatherOnce runs the input's Gather function once, logging a warning each
 // interval it fails to complete before.
 func (a *Agent) gatherOnce(
+	ctx context.Context,
 	acc telegraf.Accumulator,
 	input *models.RunningInput,
 	ticker Ticker,
@@ -573,7 +804,7 @@ func (a *Agent) gatherOnce(
 ) error {
 	done := make(chan error)
 	go func() {
-		done <- input.Gather(acc)
+		done <- input.GatherContext(ctx, acc)
 	}()
 
 	// Only warn after interval seconds, even if the interval is started late.
@@ -645,15 +876,24 @@ func (a *Agent) runProcessors(
 		go func(unit *processorUnit) {
 			defer wg.Done()
 
-			acc := NewAccumulator(unit.processor, unit.dst)
+			unit.Lock()
+			unit.acc = NewAccumulator(unit.processor, unit.dst)
+			unit.Unlock()
+
 			for m := range unit.src {
-				if err := unit.processor.Add(m, acc); err != nil {
-					acc.AddError(err)
+				unit.Lock()
+				if err := unit.processor.Add(m, unit.acc); err != nil {
+					unit.acc.AddError(err)
 					m.Drop()
 				}
+				unit.Unlock()
 			}
+
+			unit.Lock()
+			unit.stopped = true
 			unit.processor.Stop()
 			close(unit.dst)
+			unit.Unlock()
 			log.Printf("D! [agent] Processor channel closed")
 		}(unit)
 	}
@@ -784,6 +1024,9 @@ func (a *Agent) startOutputs(
 	unit := &outputUnit{src: src}
 	for _, output := range outputs {
 		err := a.connectOutput(ctx, output)
+		if errors.Is(err, errOutputIgnored) {
+			continue
+		}
 		if err != nil {
 			for _, output := range unit.outputs {
 				output.Close()
@@ -797,11 +1040,26 @@ func (a *Agent) startOutputs(
 	return src, unit, nil
 }
 
-// connectOutputs connects to all outputs.
+// connectOutput connects to the output. If this fails, the startup error
+// behavior of the output decides whether to retry once and return an error,
+// to connect the output in the background when running it or to ignore the
+// output by returning errOutputIgnored.
 func (a *Agent) connectOutput(ctx context.Context, output *models.RunningOutput) error {
 	log.Printf("D! [agent] Attempting connection to [%s]", output.LogName())
-	err := output.Output.Connect()
+	err := output.Connect()
 	if err != nil {
+		switch output.Config.StartupErrorBehavior {
+		case "retry":
+			log.Printf("E! [agent] Failed to connect to [%s], retrying in the background, "+
+				"error was %q", output.LogName(), err)
+			return nil
+		case "ignore":
+			log.Printf("E! [agent] Failed to connect to [%s], ignoring the output, "+
+				"error was %q", output.LogName(), err)
+			output.Close()
+			return errOutputIgnored
+		}
+
 		log.Printf("E! [agent] Failed to connect to [%s], retrying in 15s, "+
 			"error was %q", output.LogName(), err)
 
@@ -810,7 +1068,7 @@ func (a *Agent) connectOutput(ctx context.Context, output *models.RunningOutput)
 			return err
 		}
 
-		err = output.Output.Connect()
+		err = output.Connect()
 		if err != nil {
 			return fmt.Errorf("error connecting to output %q: %w", output.LogName(), err)
 		}
@@ -819,68 +1077,177 @@ func (a *Agent) connectOutput(ctx context.Context, output *models.RunningOutput)
 	return nil
 }
 
+// reconnectOutput tries to connect the output with an exponential backoff
+// until it succeeds or the context is done. The output keeps buffering
+// metrics in the meantime. Returns false if the output was not connected.
+func (a *Agent) reconnectOutput(ctx context.Context, output *models.RunningOutput) bool {
+	delay := reconnectMinDelay
+	for {
+		if err := internal.SleepContext(ctx, delay); err != nil {
+			log.Printf("W! [agent] Output %s was never connected, %d buffered metrics were not written",
+				output.LogName(), output.BufferLength())
+			return false
+		}
+
+		err := output.Connect()
+		if err == nil {
+			log.Printf("I! [agent] Successfully connected to %s", output.LogName())
+			return true
+		}
+
+		delay *= 2
+		if delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+		log.Printf("E! [agent] Failed to connect to [%s], retrying in %s, error was %q",
+			output.LogName(), delay, err)
+	}
+}
+
 // runOutputs begins processing metrics and returns until the source channel is
 // closed and all metrics have been written.  On shutdown metrics will be
 // written one last time and dropped if unsuccessful.
 func (a *Agent) runOutputs(
 	unit *outputUnit,
 ) {
-	var wg sync.WaitGroup
-
-	// Start flush loop
-	interval := time.Duration(a.Config.Agent.FlushInterval)
-	jitter := time.Duration(a.Config.Agent.FlushJitter)
-
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// Start flush loop
+	unit.Lock()
+	unit.ctx = ctx
+	unit.running = make(map[*models.RunningOutput]*pluginHandle, len(unit.outputs))
 	for _, output := range unit.outputs {
-		interval := interval
-		// Overwrite agent flush_interval if this plugin has its own.
-		if output.Config.FlushInterval != 0 {
-			interval = output.Config.FlushInterval
-		}
-
-		jitter := jitter
-		// Overwrite agent flush_jitter if this plugin has its own.
-		if output.Config.FlushJitter != 0 {
-			jitter = output.Config.FlushJitter
-		}
-
-		wg.Add(1)
-		go func(output *models.RunningOutput) {
-			defer wg.Done()
-
-			ticker := NewRollingTicker(interval, jitter)
-			defer ticker.Stop()
-
-			a.flushLoop(ctx, output, ticker)
-		}(output)
+		a.runOutput(unit, output)
 	}
+	unit.Unlock()
 
 	for metric := range unit.src {
-		for i, output := range unit.outputs {
-			if i == len(a.Config.Outputs)-1 {
+		unit.RLock()
+		outputs := models.RouteMetric(unit.outputs, metric)
+		if len(outputs) == 0 {
+			metric.Drop()
+		}
+		for i, output := range outputs {
+			if i == len(outputs)-1 {
 				output.AddMetric(metric)
 			} else {
 				output.AddMetric(metric.Copy())
 			}
 		}
+		unit.RUnlock()
 	}
 
 	log.Println("I! [agent] Hang on, flushing any cached metrics before shutdown")
+	unit.Lock()
+	unit.stopped = true
+	unit.Unlock()
 	cancel()
-	wg.Wait()
+	unit.wg.Wait()
 
 	log.Println("I! [agent] Stopping running outputs")
 	stopRunningOutputs(unit.outputs)
 }
 
+// flushSettings returns the flush interval and jitter of the output.
+func (a *Agent) flushSettings(output *models.RunningOutput) (interval, jitter time.Duration) {
+	// Overwrite agent flush_interval if this plugin has its own.
+	interval = time.Duration(a.Config.Agent.FlushInterval)
+	if output.Config.FlushInterval != 0 {
+		interval = output.Config.FlushInterval
+	}
+
+	// Overwrite agent flush_jitter if this plugin has its own.
+	jitter = time.Duration(a.Config.Agent.FlushJitter)
+	if output.Config.FlushJitter != 0 {
+		jitter = output.Config.FlushJitter
+	}
+	return interval, jitter
+}
+
+// deliveryWaiter is implemented by inputs waiting for the delivery of their
+// metrics by the outputs before acknowledging them.
+type deliveryWaiter interface {
+	DeliveryWait() (timeout time.Duration, enabled bool)
+}
+
+// checkDeliveryTimeouts warns about inputs waiting for delivery shorter than
+// the outputs take to flush. Unless the outputs' batch is filled earlier, the
+// delivery of those inputs' metrics times out.
+func (a *Agent) checkDeliveryTimeouts() {
+	var longest time.Duration
+	for _, output := range a.Config.Outputs {
+		interval, jitter := a.flushSettings(output)
+		if interval+jitter > longest {
+			longest = interval + jitter
+		}
+	}
+	if longest == 0 {
+		return
+	}
+
+	for _, input := range a.Config.Inputs {
+		waiter, ok := input.Input.(deliveryWaiter)
+		if !ok {
+			continue
+		}
+		if timeout, enabled := waiter.DeliveryWait(); enabled && timeout <= longest {
+			log.Printf("W! [agent] Input %s waits %s for delivery but outputs flush up to every %s; "+
+				"deliveries time out unless the output batches are full earlier",
+				input.LogName(), timeout, longest)
+		}
+	}
+}
+
+// runOutput starts the flush loop for a single output. The unit's lock must
+// be held by the caller.
+func (a *Agent) runOutput(unit *outputUnit, output *models.RunningOutput) {
+	interval, jitter := a.flushSettings(output)
+
+	ctx, cancel := context.WithCancel(unit.ctx)
+	handle := &pluginHandle{
+		cancel: cancel,
+		done:   make(chan struct{}),
+		flush:  make(chan os.Signal, 1),
+	}
+	unit.running[output] = handle
+
+	unit.wg.Add(1)
+	go func() {
+		defer unit.wg.Done()
+		defer close(handle.done)
+
+		// Outputs failing to connect on startup are connected here to not
+		// delay the other outputs. The first output of a failover group
+		// flushes the group's buffer, so it connects in the background to
+		// let the other members write in the meantime.
+		if !output.Connected() {
+			if output.FailoverGroup() == "" || output.IsFailoverStandby() {
+				if !a.reconnectOutput(ctx, output) {
+					return
+				}
+			} else {
+				unit.wg.Add(1)
+				go func() {
+					defer unit.wg.Done()
+					a.reconnectOutput(ctx, output)
+				}()
+			}
+		}
+
+		ticker := NewRollingTicker(interval, jitter)
+		defer ticker.Stop()
+
+		a.flushLoop(ctx, output, ticker, handle.flush)
+	}()
+}
+
 // flushLoop runs an output's flush function periodically until the context is
 // done.
 func (a *Agent) flushLoop(
 	ctx context.Context,
 	output *models.RunningOutput,
 	ticker Ticker,
+	flushRequested chan os.Signal,
 ) {
 	logError := func(err error) {
 		if err != nil {
@@ -889,7 +1256,6 @@ func (a *Agent) flushLoop(
 	}
 
 	// watch for flush requests
-	flushRequested := make(chan os.Signal, 1)
 	watchForFlushSignal(flushRequested)
 	defer stopListeningForFlushSignal(flushRequested)
 
@@ -897,14 +1263,14 @@ func (a *Agent) flushLoop(
 		// Favor shutdown over other methods.
 		select {
 		case <-ctx.Done():
-			logError(a.flushOnce(output, ticker, output.Write))
+			logError(a.flushOnce(output, ticker, output.WriteFinal))
 			return
 		default:
 		}
 
 		select {
 		case <-ctx.Done():
-			logError(a.flushOnce(output, ticker, output.Write))
+			logError(a.flushOnce(output, ticker, output.WriteFinal))
 			return
 		case <-ticker.Elapsed():
 			logError(a.flushOnce(output, ticker, output.Write))
@@ -988,12 +1354,16 @@ func (a *Agent) Test(ctx context.Context, wait time.Duration) error {
 // outputC. After gathering pauses for the wait duration to allow service
 // inputs to run.
 func (a *Agent) runTest(ctx context.Context, wait time.Duration, outputC chan<- telegraf.Metric) error {
-	log.Printf("D! [agent] Initializing plugins")
-	err := a.initPlugins()
+	err := a.InitPlugins()
 	if err != nil {
 		return err
 	}
 
+	if err := a.openRecorder(); err != nil {
+		return err
+	}
+	defer a.closeRecorder()
+
 	startTime := time.Now()
 
 	next := outputC
@@ -1083,12 +1453,16 @@ func (a *Agent) Once(ctx context.Context, wait time.Duration) error {
 // outputC. After gathering pauses for the wait duration to allow service
 // inputs to run.
 func (a *Agent) runOnce(ctx context.Context, wait time.Duration) error {
-	log.Printf("D! [agent] Initializing plugins")
-	err := a.initPlugins()
+	err := a.InitPlugins()
 	if err != nil {
 		return err
 	}
 
+	if err := a.openRecorder(); err != nil {
+		return err
+	}
+	defer a.closeRecorder()
+
 	startTime := time.Now()
 
 	log.Printf("D! [agent] Connecting outputs")
@@ -1,12 +1,21 @@
 package agent
 
 import (
+	"bytes"
+	"context"
+	"log"
+	"math"
+	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	_ "github.com/influxdata/telegraf/plugins/inputs/all"
 	_ "github.com/influxdata/telegraf/plugins/outputs/all"
 )
@@ -165,3 +174,151 @@ func TestWindow(t *testing.T) {
 		})
 	}
 }
+
+func TestStartupErrorBehavior(t *testing.T) {
+	minDelay, maxDelay := reconnectMinDelay, reconnectMaxDelay
+	reconnectMinDelay, reconnectMaxDelay = 10*time.Millisecond, 20*time.Millisecond
+	defer func() {
+		reconnectMinDelay, reconnectMaxDelay = minDelay, maxDelay
+	}()
+
+	healthy := &reloadOutput{}
+	retried := &reloadOutput{}
+	retried.failures.Store(3)
+	ignored := &reloadOutput{}
+	ignored.failures.Store(math.MaxInt32)
+	unreachable := &reloadOutput{}
+	unreachable.failures.Store(math.MaxInt32)
+
+	newOutput := func(output *reloadOutput, id, behavior string) *models.RunningOutput {
+		cfg := &models.OutputConfig{Name: "reload", ID: id, StartupErrorBehavior: behavior}
+		return models.NewRunningOutput(output, cfg, 0, 0)
+	}
+
+	c := newReloadConfig()
+	c.Inputs = append(c.Inputs, newReloadInput(&reloadInput{name: "a"}, "a"))
+	c.Outputs = append(c.Outputs,
+		newOutput(healthy, "healthy", ""),
+		newOutput(retried, "retried", "retry"),
+		newOutput(ignored, "ignored", "ignore"),
+		newOutput(unreachable, "unreachable", "retry"),
+	)
+
+	a := NewAgent(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, a.Run(ctx))
+	}()
+
+	// The failing outputs do not keep the healthy output from running and
+	// the retried output delivers the metrics buffered before connecting
+	require.Eventually(t, func() bool {
+		return healthy.received("a") > 0 && retried.received("a") > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.True(t, a.Config.Outputs[1].Connected())
+	require.False(t, a.Config.Outputs[2].Connected())
+
+	cancel()
+	wg.Wait()
+	require.Zero(t, ignored.received("a"))
+
+	// Outputs never connected are not closed on shutdown
+	require.True(t, retried.closed.Load())
+	require.False(t, ignored.closed.Load())
+	require.False(t, unreachable.closed.Load())
+
+	// Failing outputs abort the agent by default
+	c = newReloadConfig()
+	failing := &reloadOutput{}
+	failing.failures.Store(math.MaxInt32)
+	c.Outputs = append(c.Outputs, newOutput(failing, "failing", "error"))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	require.ErrorContains(t, NewAgent(c).Run(ctx), "connecting output")
+}
+
+func TestInvalidStartupErrorBehavior(t *testing.T) {
+	cfg := &models.OutputConfig{Name: "reload", StartupErrorBehavior: "panic"}
+	output := models.NewRunningOutput(&reloadOutput{}, cfg, 0, 0)
+	require.ErrorContains(t, output.Init(), `invalid startup error behavior "panic"`)
+}
+
+func TestDeliveryTimeoutWarning(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	c := newReloadConfig()
+	c.Agent.FlushInterval = config.Duration(10 * time.Second)
+	c.Outputs = append(c.Outputs, newReloadOutput(&reloadOutput{}, "output"))
+	waiting := &deliveryInput{Config: delivery.Config{WaitForDelivery: true}}
+	require.NoError(t, waiting.ValidateDelivery(0))
+	c.Inputs = append(c.Inputs, models.NewRunningInput(waiting, &models.InputConfig{Name: "waiting"}))
+	c.Inputs = append(c.Inputs, models.NewRunningInput(&deliveryInput{}, &models.InputConfig{Name: "disabled"}))
+
+	// The default delivery timeout is shorter than the flush interval
+	NewAgent(c).checkDeliveryTimeouts()
+	require.Contains(t, buf.String(), "Input inputs.waiting waits 5s for delivery but outputs flush up to every 10s")
+	require.NotContains(t, buf.String(), "inputs.disabled")
+
+	// The outputs flush before the delivery times out
+	buf.Reset()
+	waiting.DeliveryTimeout = config.Duration(15 * time.Second)
+	NewAgent(c).checkDeliveryTimeouts()
+	require.Empty(t, buf.String())
+}
+
+func TestShutdownWithTimedOutGather(t *testing.T) {
+	slow := &slowInput{release: make(chan struct{}), done: make(chan struct{})}
+	c := newReloadConfig()
+	c.Inputs = append(c.Inputs, models.NewRunningInput(slow,
+		&models.InputConfig{Name: "slow", GatherTimeout: 20 * time.Millisecond}))
+	c.Outputs = append(c.Outputs, newReloadOutput(&reloadOutput{}, "output"))
+
+	a := NewAgent(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- a.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return c.Inputs[0].GatherTimeouts.Get() > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The gather still running after the shutdown must not send its metrics
+	// to the closed input channel
+	cancel()
+	require.NoError(t, <-stopped)
+	close(slow.release)
+	<-slow.done
+}
+
+// slowInput blocks the first gather until released
+type slowInput struct {
+	reloadInput
+	release chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+func (i *slowInput) Gather(acc telegraf.Accumulator) error {
+	i.once.Do(func() {
+		defer close(i.done)
+		<-i.release
+		acc.AddFields("slow", map[string]interface{}{"value": 42}, nil)
+	})
+	return nil
+}
+
+type deliveryInput struct {
+	reloadInput
+	delivery.Config
+}
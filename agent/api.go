@@ -0,0 +1,557 @@
+package agent
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+)
+
+// SetReloadFunc sets the function called when a configuration reload is
+// requested via the management API. The function must not block.
+func (a *Agent) SetReloadFunc(f func()) {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+	a.reloadFunc = f
+}
+
+// flushRequest is passed to the flush loop of an output to request a flush
+// in the same way as the flush signal.
+type flushRequest struct{}
+
+func (flushRequest) String() string { return "flush request" }
+func (flushRequest) Signal()        {}
+
+// apiPlugin describes a plugin in the management API.
+type apiPlugin struct {
+	Type   string                 `json:"type"`
+	Name   string                 `json:"name"`
+	Alias  string                 `json:"alias,omitempty"`
+	ID     string                 `json:"id"`
+	Config map[string]interface{} `json:"config"`
+	Status interface{}            `json:"status,omitempty"`
+}
+
+type apiInputStatus struct {
+	LastGather         *time.Time `json:"last_gather,omitempty"`
+	LastGatherDuration string     `json:"last_gather_duration,omitempty"`
+	LastGatherError    string     `json:"last_gather_error,omitempty"`
+}
+
+type apiOutputStatus struct {
+	Connected         bool       `json:"connected"`
+	BufferSize        int        `json:"buffer_size"`
+	BufferLimit       int        `json:"buffer_limit"`
+	LastWrite         *time.Time `json:"last_write,omitempty"`
+	LastWriteDuration string     `json:"last_write_duration,omitempty"`
+	LastWriteMetrics  int        `json:"last_write_metrics,omitempty"`
+	LastWriteError    string     `json:"last_write_error,omitempty"`
+}
+
+// maxAPIErrorLength is the maximum length of error messages reported in the
+// status of plugins.
+const maxAPIErrorLength = 512
+
+// apiURLRe matches URLs contained in error messages.
+var apiURLRe = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// startManagementAPI starts serving the management API on the given address.
+// The returned function stops the server.
+func (a *Agent) startManagementAPI(address string, token *config.Secret) (func(), error) {
+	var expected []byte
+	if !token.Empty() {
+		var err error
+		expected, err = token.Get()
+		if err != nil {
+			return nil, fmt.Errorf("getting management API token failed: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", managementListenAddress(address))
+	if err != nil {
+		if expected != nil {
+			config.ReleaseSecret(expected)
+		}
+		return nil, fmt.Errorf("starting management API failed: %w", err)
+	}
+	if token.Empty() {
+		if addr, ok := listener.Addr().(*net.TCPAddr); ok && !addr.IP.IsLoopback() {
+			log.Printf("W! [agent] Management API listening on %s without a token", listener.Addr())
+		}
+	}
+
+	server := &http.Server{
+		Handler:           a.managementHandler(expected),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("E! [agent] Management API failed: %v", err)
+		}
+	}()
+	log.Printf("I! [agent] Management API listening on %s", listener.Addr())
+
+	stop := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("E! [agent] Stopping management API failed: %v", err)
+		}
+		if expected != nil {
+			config.ReleaseSecret(expected)
+		}
+	}
+	return stop, nil
+}
+
+// managementHandler returns the handler serving the management API. If the
+// token is not empty, requests must provide it as bearer token.
+func (a *Agent) managementHandler(token []byte) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/plugins", a.handlePlugins)
+	mux.HandleFunc("/api/v1/reload", a.handleReload)
+	mux.HandleFunc("/api/v1/inputs/gather", a.handleGather)
+	mux.HandleFunc("/api/v1/outputs/flush", a.handleFlush)
+	if len(token) == 0 {
+		return mux
+	}
+	return requireToken(mux, token)
+}
+
+// managementListenAddress returns the address to listen on for the given
+// management address, defaulting to the loopback interface if no host is set.
+func managementListenAddress(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || host != "" {
+		return address
+	}
+	return net.JoinHostPort("localhost", port)
+}
+
+// requireToken rejects all requests not providing the expected bearer token.
+func requireToken(next http.Handler, expected []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="telegraf"`)
+			writeAPIError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Agent) handlePlugins(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	a.reloadMu.Lock()
+	plugins := make([]apiPlugin, 0, len(a.Config.Inputs)+len(a.Config.Processors)+
+		len(a.Config.Aggregators)+len(a.Config.AggProcessors)+len(a.Config.Outputs))
+	for _, input := range a.Config.Inputs {
+		var status apiInputStatus
+		if last := input.LastGather(); !last.Time.IsZero() {
+			status.LastGather = &last.Time
+			status.LastGatherDuration = last.Duration.String()
+			if last.Err != nil {
+				status.LastGatherError = apiErrorString(last.Err)
+			}
+		}
+		plugins = append(plugins, apiPlugin{
+			Type:   "inputs",
+			Name:   input.Config.Name,
+			Alias:  input.Config.Alias,
+			ID:     input.ID(),
+			Config: a.settings[input],
+			Status: status,
+		})
+	}
+	for _, processor := range a.Config.Processors {
+		plugins = append(plugins, apiPlugin{
+			Type:   "processors",
+			Name:   processor.Config.Name,
+			Alias:  processor.Config.Alias,
+			ID:     processor.ID(),
+			Config: a.settings[processor],
+		})
+	}
+	for _, aggregator := range a.Config.Aggregators {
+		plugins = append(plugins, apiPlugin{
+			Type:   "aggregators",
+			Name:   aggregator.Config.Name,
+			Alias:  aggregator.Config.Alias,
+			ID:     aggregator.ID(),
+			Config: a.settings[aggregator],
+		})
+	}
+	for _, processor := range a.Config.AggProcessors {
+		plugins = append(plugins, apiPlugin{
+			Type:   "processors",
+			Name:   processor.Config.Name,
+			Alias:  processor.Config.Alias,
+			ID:     processor.ID(),
+			Config: a.settings[processor],
+		})
+	}
+	for _, output := range a.Config.Outputs {
+		status := apiOutputStatus{
+			Connected:   output.Connected(),
+			BufferSize:  output.BufferLength(),
+			BufferLimit: output.MetricBufferLimit,
+		}
+		if last := output.LastWrite(); !last.Time.IsZero() {
+			status.LastWrite = &last.Time
+			status.LastWriteDuration = last.Duration.String()
+			status.LastWriteMetrics = last.Metrics
+			if last.Err != nil {
+				status.LastWriteError = apiErrorString(last.Err)
+			}
+		}
+		plugins = append(plugins, apiPlugin{
+			Type:   "outputs",
+			Name:   output.Config.Name,
+			Alias:  output.Config.Alias,
+			ID:     output.ID(),
+			Config: a.settings[output],
+			Status: status,
+		})
+	}
+	a.reloadMu.Unlock()
+
+	writeAPIResponse(w, http.StatusOK, plugins)
+}
+
+func (a *Agent) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	a.reloadMu.Lock()
+	reload := a.reloadFunc
+	a.reloadMu.Unlock()
+	if reload == nil {
+		writeAPIError(w, http.StatusNotImplemented, errors.New("reloading is not supported"))
+		return
+	}
+
+	log.Printf("I! [agent] Reload requested via management API")
+	reload()
+	writeAPIResponse(w, http.StatusAccepted, map[string]string{"status": "reload requested"})
+}
+
+func (a *Agent) handleGather(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	a.reloadMu.Lock()
+	unit := a.iu
+	a.reloadMu.Unlock()
+	if unit == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, errors.New("inputs are not running"))
+		return
+	}
+
+	unit.Lock()
+	if unit.stopped || unit.running == nil {
+		unit.Unlock()
+		writeAPIError(w, http.StatusServiceUnavailable, errors.New("inputs are not running"))
+		return
+	}
+	input, err := findPlugin(unit.inputs, r.URL.Query().Get("plugin"), func(i *models.RunningInput) []string {
+		return []string{i.ID(), i.Config.Alias, i.Config.Name}
+	})
+	if err != nil {
+		unit.Unlock()
+		writeAPIError(w, http.StatusNotFound, err)
+		return
+	}
+	// Keep the input channel open until gathering finished
+	unit.wg.Add(1)
+	unit.Unlock()
+	defer unit.wg.Done()
+
+	// Overwrite agent precision if this plugin has its own.
+	precision := time.Duration(a.Config.Agent.Precision)
+	if input.Config.Precision != 0 {
+		precision = input.Config.Precision
+	}
+	interval := time.Duration(a.Config.Agent.Interval)
+	if input.Config.Interval != 0 {
+		interval = input.Config.Interval
+	}
+	acc := NewAccumulator(input, unit.dst)
+	acc.SetPrecision(getPrecision(precision, interval))
+
+	log.Printf("I! [agent] Gather of %s requested via management API", input.LogName())
+	if err := input.GatherContext(r.Context(), acc); err != nil {
+		acc.AddError(err)
+	}
+
+	var status apiInputStatus
+	last := input.LastGather()
+	status.LastGather = &last.Time
+	status.LastGatherDuration = last.Duration.String()
+	if last.Err != nil {
+		status.LastGatherError = apiErrorString(last.Err)
+	}
+	writeAPIResponse(w, http.StatusOK, status)
+}
+
+func (a *Agent) handleFlush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	a.reloadMu.Lock()
+	unit := a.ou
+	a.reloadMu.Unlock()
+	if unit == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, errors.New("outputs are not running"))
+		return
+	}
+
+	unit.RLock()
+	defer unit.RUnlock()
+	output, err := findPlugin(unit.outputs, r.URL.Query().Get("plugin"), func(o *models.RunningOutput) []string {
+		return []string{o.ID(), o.Config.Alias, o.Config.Name}
+	})
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err)
+		return
+	}
+	handle := unit.running[output]
+	if unit.stopped || handle == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, errors.New("output is not running"))
+		return
+	}
+
+	log.Printf("I! [agent] Flush of %s requested via management API", output.LogName())
+	select {
+	case handle.flush <- flushRequest{}:
+	default:
+		// A flush is already pending
+	}
+	writeAPIResponse(w, http.StatusAccepted, map[string]string{"status": "flush requested"})
+}
+
+// findPlugin returns the plugin matching the given identifier by ID, alias or
+// name in this order. An error is returned if no or multiple plugins match.
+func findPlugin[T any](plugins []T, identifier string, keys func(T) []string) (T, error) {
+	var zero T
+	if identifier == "" {
+		return zero, errors.New("missing plugin parameter")
+	}
+
+	for k := 0; k < 3; k++ {
+		var matches []T
+		for _, p := range plugins {
+			if keys(p)[k] == identifier {
+				matches = append(matches, p)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			continue
+		case 1:
+			return matches[0], nil
+		default:
+			return zero, fmt.Errorf("plugin %q is ambiguous, use the plugin ID instead", identifier)
+		}
+	}
+	return zero, fmt.Errorf("plugin %q not found", identifier)
+}
+
+func writeAPIResponse(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("E! [agent] Writing management API response failed: %v", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, code int, err error) {
+	writeAPIResponse(w, code, map[string]string{"error": err.Error()})
+}
+
+// apiErrorString returns the message of the error reported in the status of
+// plugins with the user information of contained URLs removed and truncated
+// to a sensible length.
+func apiErrorString(err error) string {
+	msg := apiURLRe.ReplaceAllStringFunc(err.Error(), redactURL)
+	if len(msg) <= maxAPIErrorLength {
+		return msg
+	}
+	// Avoid cutting a multi-byte character
+	cut := maxAPIErrorLength
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return msg[:cut] + "..."
+}
+
+// snapshotSettings takes the settings of all plugins reported by the
+// management API. This must happen before starting the plugins as they might
+// modify their fields while running.
+func (a *Agent) snapshotSettings() {
+	a.settings = make(map[interface{}]map[string]interface{})
+	for _, input := range a.Config.Inputs {
+		a.settings[input] = pluginConfig(input.Input)
+	}
+	for _, processor := range a.Config.Processors {
+		a.settings[processor] = pluginConfig(processor.Processor)
+	}
+	for _, aggregator := range a.Config.Aggregators {
+		a.settings[aggregator] = pluginConfig(aggregator.Aggregator)
+	}
+	for _, processor := range a.Config.AggProcessors {
+		a.settings[processor] = pluginConfig(processor.Processor)
+	}
+	for _, output := range a.Config.Outputs {
+		a.settings[output] = pluginConfig(output.Output)
+	}
+}
+
+// pluginConfig returns the settings of the plugin. Secrets, settings with
+// names indicating credentials, header values and the user information of
+// URLs are redacted.
+func pluginConfig(plugin interface{}) map[string]interface{} {
+	settings := make(map[string]interface{})
+	collectSettings(reflect.ValueOf(plugin), settings)
+	return settings
+}
+
+func collectSettings(v reflect.Value, settings map[string]interface{}) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			collectSettings(v.Field(i), settings)
+			continue
+		}
+		if name == "" {
+			name = snakeCase(field.Name)
+		}
+
+		value := v.Field(i)
+		if value.IsZero() {
+			continue
+		}
+		if isSensitiveSetting(name, value) {
+			settings[name] = "<redacted>"
+			continue
+		}
+		setting := redactSetting(name, value)
+
+		// Skip everything that cannot be represented in the response
+		if _, err := json.Marshal(setting); err != nil {
+			continue
+		}
+		settings[name] = setting
+	}
+}
+
+// redactSetting returns the value of the setting with the values of header
+// maps and the user information of URLs redacted.
+func redactSetting(name string, value reflect.Value) interface{} {
+	switch {
+	case strings.HasSuffix(name, "headers") && value.Kind() == reflect.Map && value.Type().Key().Kind() == reflect.String:
+		redacted := make(map[string]string, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			redacted[iter.Key().String()] = "<redacted>"
+		}
+		return redacted
+	case value.Kind() == reflect.String:
+		return redactURL(value.String())
+	case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String:
+		redacted := make([]string, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			redacted = append(redacted, redactURL(value.Index(i).String()))
+		}
+		return redacted
+	}
+	return value.Interface()
+}
+
+// redactURL removes the user information from the given string if it is a
+// URL containing any.
+func redactURL(s string) string {
+	if !strings.Contains(s, "@") || !strings.Contains(s, "://") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	u.User = nil
+	return u.String()
+}
+
+func isSensitiveSetting(name string, value reflect.Value) bool {
+	if value.Type() == reflect.TypeOf(config.Secret{}) || value.Type() == reflect.TypeOf(&config.Secret{}) {
+		return true
+	}
+	for _, keyword := range []string{"password", "passwd", "token", "secret", "credential", "api_key"} {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// snakeCase converts the Go field name to its snake-case form, e.g.
+// "MetricBatchSize" to "metric_batch_size".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := unicode.IsLower(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (nextLower && unicode.IsUpper(runes[i-1])) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,524 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+)
+
+// ErrRestartRequired is returned by Reload if the new configuration cannot be
+// applied to the running agent and the agent must be restarted instead.
+var ErrRestartRequired = errors.New("restart required")
+
+// pluginDiff contains the plugins to keep, add and remove when switching
+// from the current to the next configuration.
+type pluginDiff[T comparable] struct {
+	merged  []T
+	added   []T
+	removed []T
+}
+
+// diffPlugins matches the current and next plugins by their configuration ID.
+// Plugins with an unchanged configuration are kept in the merged list while
+// all others are added or removed. The order of the next plugins is preserved.
+func diffPlugins[T comparable](current, next []T, id func(T) string) pluginDiff[T] {
+	available := make(map[string][]T, len(current))
+	for _, p := range current {
+		available[id(p)] = append(available[id(p)], p)
+	}
+
+	var diff pluginDiff[T]
+	kept := make(map[T]bool, len(current))
+	for _, p := range next {
+		if candidates := available[id(p)]; len(candidates) > 0 {
+			diff.merged = append(diff.merged, candidates[0])
+			kept[candidates[0]] = true
+			available[id(p)] = candidates[1:]
+			continue
+		}
+		diff.merged = append(diff.merged, p)
+		diff.added = append(diff.added, p)
+	}
+
+	for _, p := range current {
+		if !kept[p] {
+			diff.removed = append(diff.removed, p)
+		}
+	}
+
+	return diff
+}
+
+// ErrReloadIncomplete is returned by Reload if applying the next configuration
+// failed after the running agent was modified. The agent must be restarted
+// with a new instance of the configuration as the plugins of the next agent
+// might already be in use.
+var ErrReloadIncomplete = errors.New("reload incomplete")
+
+// reloadPlan contains the changes required to switch the running agent to the
+// next configuration.
+type reloadPlan struct {
+	inputs        pluginDiff[*models.RunningInput]
+	outputs       pluginDiff[*models.RunningOutput]
+	processors    []processorChange
+	aggProcessors []processorChange
+}
+
+func (p *reloadPlan) empty() bool {
+	return len(p.inputs.added)+len(p.inputs.removed)+len(p.outputs.added)+len(p.outputs.removed)+
+		len(p.processors)+len(p.aggProcessors) == 0
+}
+
+// Reload applies the configuration of the next agent to the running agent.
+// The plugins of the next agent are initialized and the new outputs are
+// connected first, the running agent is not modified if this fails. Only
+// inputs, processors and outputs with a changed configuration are stopped and
+// replaced by their new instances, all other plugins keep running
+// uninterrupted. Inputs are stopped before starting their replacements to
+// release resources such as listening ports. Outputs replacing an output with
+// the same disk buffer open the buffer after the replaced output is closed. Changed log levels are applied to
+// the running plugins directly. If the next configuration contains changes
+// that cannot be applied in place, e.g. to the agent settings or aggregators,
+// an error wrapping ErrRestartRequired is returned, the running agent is not
+// modified and the next agent can be run instead. If applying the changes
+// fails after modifying the running agent, an error wrapping
+// ErrReloadIncomplete is returned.
+func (a *Agent) Reload(ctx context.Context, nextAgent *Agent) error {
+	// Validate the new configuration before touching the running agent
+	if err := nextAgent.InitPlugins(); err != nil {
+		return err
+	}
+	next := nextAgent.Config
+
+	// Reloads are serialized while the reload lock, also blocking the
+	// management API, is only held when accessing the running plugins
+	a.reloading.Lock()
+	defer a.reloading.Unlock()
+
+	a.reloadMu.Lock()
+	plan, err := a.planReload(next)
+	a.reloadMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Connect the new outputs before touching the running plugins to be
+	// able to bail out without modifying the agent. Outputs taking over the
+	// disk buffer of a removed output keep their metrics in memory until the
+	// removed output is flushed and closed.
+	deferred := sharedDiskBuffers(plan.outputs)
+	connected := make([]*models.RunningOutput, 0, len(plan.outputs.added))
+	for _, output := range plan.outputs.added {
+		if deferred[output] {
+			output.DeferBuffer()
+		}
+		err := a.connectOutput(ctx, output)
+		if errors.Is(err, errOutputIgnored) {
+			continue
+		}
+		if err != nil {
+			closeOutputs(connected)
+			return fmt.Errorf("connecting output %s: %w", output.LogName(), err)
+		}
+		connected = append(connected, output)
+	}
+
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	if a.iu == nil || a.ou == nil {
+		closeOutputs(connected)
+		return errors.New("agent stopped while reloading")
+	}
+
+	// Log levels are not part of the plugin ID so they are applied to the
+	// running plugins directly
+	levels := a.Config.UpdateLogLevels(next)
+	for _, name := range levels {
+		log.Printf("I! [agent] Changed log level of %s", name)
+	}
+
+	if plan.empty() {
+		if len(levels) == 0 {
+			log.Printf("I! [agent] Configuration unchanged")
+		}
+		return nil
+	}
+
+	// Switch the outputs first so the new outputs are ready to receive
+	// metrics of the new inputs
+	if err := a.addOutputs(connected); err != nil {
+		closeOutputs(connected)
+		return err
+	}
+	a.removeInputs(plan.inputs.removed)
+	for _, p := range plan.processors {
+		if err := a.replaceProcessor(a.pu[p.index], p.processor); err != nil {
+			return fmt.Errorf("%v: %w", err, ErrReloadIncomplete)
+		}
+	}
+	for _, p := range plan.aggProcessors {
+		if err := a.replaceProcessor(a.apu[p.index], p.processor); err != nil {
+			return fmt.Errorf("%v: %w", err, ErrReloadIncomplete)
+		}
+	}
+	if err := a.addInputs(plan.inputs.added); err != nil {
+		return fmt.Errorf("%v: %w", err, ErrReloadIncomplete)
+	}
+	a.removeOutputs(plan.outputs.removed)
+	for _, output := range connected {
+		if !deferred[output] {
+			continue
+		}
+		if err := output.OpenBuffer(); err != nil {
+			return fmt.Errorf("output %s: %v: %w", output.LogName(), err, ErrReloadIncomplete)
+		}
+	}
+
+	// Restore the configured order of the outputs as routes are evaluated in
+	// this order, leaving out ignored outputs
+	a.ou.Lock()
+	if a.ou.running != nil {
+		running := make([]*models.RunningOutput, 0, len(plan.outputs.merged))
+		for _, output := range plan.outputs.merged {
+			if _, found := a.ou.running[output]; found {
+				running = append(running, output)
+			}
+		}
+		a.ou.outputs = running
+	}
+	a.ou.Unlock()
+
+	// Kept outputs might refer to a replaced dead-letter output or be part of
+	// a changed failover group. The new configuration was already checked so
+	// linking cannot fail.
+	if err := models.LinkDeadLetters(plan.outputs.merged); err != nil {
+		log.Printf("E! [agent] Linking dead-letter outputs failed: %v", err)
+	}
+	if err := models.LinkFailoverGroups(plan.outputs.merged); err != nil {
+		log.Printf("E! [agent] Linking failover groups failed: %v", err)
+	}
+
+	a.updatePersister(plan)
+	a.updateSettings(plan, nextAgent)
+
+	// Keep the configuration in sync with the running plugins
+	a.Config.Inputs = plan.inputs.merged
+	a.Config.Outputs = plan.outputs.merged
+	for _, p := range plan.processors {
+		a.Config.Processors[p.index] = p.processor
+	}
+	for _, p := range plan.aggProcessors {
+		a.Config.AggProcessors[p.index] = p.processor
+	}
+	a.Config.UpdateSecrets(next)
+	a.Config.UpdateSources(next)
+
+	log.Printf("I! [agent] Reloaded configuration: %d input(s) added, %d input(s) removed, "+
+		"%d processor(s) replaced, %d output(s) added, %d output(s) removed",
+		len(plan.inputs.added), len(plan.inputs.removed), len(plan.processors)+len(plan.aggProcessors),
+		len(plan.outputs.added), len(plan.outputs.removed))
+
+	return nil
+}
+
+// planReload determines the plugins to replace for switching to the next
+// configuration or returns an error wrapping ErrRestartRequired if the
+// changes cannot be applied in place.
+func (a *Agent) planReload(next *config.Config) (*reloadPlan, error) {
+	if a.iu == nil || a.ou == nil {
+		return nil, fmt.Errorf("agent not running: %w", ErrRestartRequired)
+	}
+	if reason, required := a.Config.RestartRequired(next); required {
+		return nil, fmt.Errorf("%s: %w", reason, ErrRestartRequired)
+	}
+
+	// Aggregators keep a state bound to their period so we do not replace
+	// them in place
+	currentAggregators := make([]string, 0, len(a.Config.Aggregators))
+	for _, aggregator := range a.Config.Aggregators {
+		currentAggregators = append(currentAggregators, aggregator.Config.ID)
+	}
+	nextAggregators := make([]string, 0, len(next.Aggregators))
+	for _, aggregator := range next.Aggregators {
+		nextAggregators = append(nextAggregators, aggregator.Config.ID)
+	}
+	if !slices.Equal(currentAggregators, nextAggregators) {
+		return nil, fmt.Errorf("aggregators changed: %w", ErrRestartRequired)
+	}
+
+	// Processors are chained so we can only replace them at their position
+	sortProcessors(next.Processors)
+	sortProcessors(next.AggProcessors)
+	if len(a.pu) != len(next.Processors) || len(a.apu) != len(next.AggProcessors) {
+		return nil, fmt.Errorf("number of processors changed: %w", ErrRestartRequired)
+	}
+
+	plan := &reloadPlan{
+		inputs: diffPlugins(a.Config.Inputs, next.Inputs, func(input *models.RunningInput) string {
+			return input.Config.ID
+		}),
+		outputs: diffPlugins(a.Config.Outputs, next.Outputs, func(output *models.RunningOutput) string {
+			return output.Config.ID
+		}),
+		processors:    changedProcessors(a.pu, next.Processors),
+		aggProcessors: changedProcessors(a.apu, next.AggProcessors),
+	}
+
+	// The parsers of added inputs record to the capture file of the running
+	// agent as the recorder settings are part of the unchanged agent settings
+	next.Recorder.Redirect(a.Config.Recorder)
+
+	return plan, nil
+}
+
+// sharedDiskBuffers returns the added outputs using the same disk buffer as
+// one of the removed outputs.
+func sharedDiskBuffers(diff pluginDiff[*models.RunningOutput]) map[*models.RunningOutput]bool {
+	removed := make(map[string]bool, len(diff.removed))
+	for _, output := range diff.removed {
+		if path := output.DiskBufferPath(); path != "" {
+			removed[path] = true
+		}
+	}
+
+	shared := make(map[*models.RunningOutput]bool)
+	for _, output := range diff.added {
+		if path := output.DiskBufferPath(); path != "" && removed[path] {
+			shared[output] = true
+		}
+	}
+	return shared
+}
+
+// updatePersister updates the states registered with the persister for the
+// replaced plugins.
+func (a *Agent) updatePersister(plan *reloadPlan) {
+	if a.Config.Persister == nil {
+		return
+	}
+
+	for _, input := range plan.inputs.removed {
+		a.Config.Persister.Unregister(input.ID())
+	}
+	for _, p := range plan.processors {
+		a.Config.Persister.Unregister(a.Config.Processors[p.index].ID())
+	}
+	for _, p := range plan.aggProcessors {
+		a.Config.Persister.Unregister(a.Config.AggProcessors[p.index].ID())
+	}
+	for _, output := range plan.outputs.removed {
+		a.Config.Persister.Unregister(output.ID())
+	}
+
+	for _, input := range plan.inputs.added {
+		if plugin, ok := input.Input.(telegraf.StatefulPlugin); ok {
+			if err := a.Config.Persister.Register(input.ID(), plugin); err != nil {
+				log.Printf("E! [agent] Could not register input %s: %v", input.LogName(), err)
+			}
+		}
+	}
+	for _, p := range plan.processors {
+		a.registerProcessorState(p.processor)
+	}
+	for _, p := range plan.aggProcessors {
+		a.registerProcessorState(p.processor)
+	}
+	for _, output := range plan.outputs.added {
+		if plugin, ok := output.Output.(telegraf.StatefulPlugin); ok {
+			if err := a.Config.Persister.Register(output.ID(), plugin); err != nil {
+				log.Printf("E! [agent] Could not register output %s: %v", output.LogName(), err)
+			}
+		}
+	}
+}
+
+// updateSettings takes over the settings of the added plugins reported by the
+// management API from the next agent.
+func (a *Agent) updateSettings(plan *reloadPlan, nextAgent *Agent) {
+	for _, input := range plan.inputs.removed {
+		delete(a.settings, input)
+	}
+	for _, input := range plan.inputs.added {
+		a.settings[input] = nextAgent.settings[input]
+	}
+	for _, p := range plan.processors {
+		delete(a.settings, a.Config.Processors[p.index])
+		a.settings[p.processor] = nextAgent.settings[p.processor]
+	}
+	for _, p := range plan.aggProcessors {
+		delete(a.settings, a.Config.AggProcessors[p.index])
+		a.settings[p.processor] = nextAgent.settings[p.processor]
+	}
+	for _, output := range plan.outputs.removed {
+		delete(a.settings, output)
+	}
+	for _, output := range plan.outputs.added {
+		a.settings[output] = nextAgent.settings[output]
+	}
+}
+
+func (a *Agent) registerProcessorState(processor *models.RunningProcessor) {
+	if plugin, ok := processor.Processor.(telegraf.StatefulPlugin); ok {
+		if err := a.Config.Persister.Register(processor.ID(), plugin); err != nil {
+			log.Printf("E! [agent] Could not register processor %s: %v", processor.LogName(), err)
+		}
+	}
+}
+
+// processorChange describes a processor to be replaced at the given position
+// of the processor chain.
+type processorChange struct {
+	index     int
+	processor *models.RunningProcessor
+}
+
+func sortProcessors(processors models.RunningProcessors) {
+	// Use the same order as startProcessors to allow comparing by position
+	sort.SliceStable(processors, func(i, j int) bool {
+		return processors[i].Config.Order > processors[j].Config.Order
+	})
+}
+
+func changedProcessors(units []*processorUnit, next models.RunningProcessors) []processorChange {
+	var changes []processorChange
+	for i, unit := range units {
+		unit.Lock()
+		id := unit.processor.Config.ID
+		unit.Unlock()
+
+		if id != next[i].Config.ID {
+			changes = append(changes, processorChange{index: i, processor: next[i]})
+		}
+	}
+	return changes
+}
+
+// addOutputs starts the flush loops of the given, already connected outputs
+// and adds them to the fan-out of the output unit.
+func (a *Agent) addOutputs(outputs []*models.RunningOutput) error {
+	if len(outputs) == 0 {
+		return nil
+	}
+
+	unit := a.ou
+	unit.Lock()
+	defer unit.Unlock()
+
+	if unit.stopped || unit.running == nil {
+		return errors.New("outputs are not running")
+	}
+	for _, output := range outputs {
+		log.Printf("I! [agent] Adding output %s", output.LogName())
+		unit.outputs = append(unit.outputs, output)
+		a.runOutput(unit, output)
+	}
+	return nil
+}
+
+// removeOutputs removes the given outputs from the fan-out, flushes their
+// remaining metrics and closes them.
+func (a *Agent) removeOutputs(outputs []*models.RunningOutput) {
+	unit := a.ou
+	for _, output := range outputs {
+		log.Printf("I! [agent] Removing output %s", output.LogName())
+
+		unit.Lock()
+		if i := slices.Index(unit.outputs, output); i >= 0 {
+			unit.outputs = slices.Delete(unit.outputs, i, i+1)
+		}
+		handle := unit.running[output]
+		delete(unit.running, output)
+		unit.Unlock()
+
+		if handle != nil {
+			handle.stop()
+		}
+		output.Close()
+	}
+}
+
+// replaceProcessor starts the new processor and swaps it with the one
+// currently running in the given unit.
+func (a *Agent) replaceProcessor(unit *processorUnit, processor *models.RunningProcessor) error {
+	unit.Lock()
+	defer unit.Unlock()
+
+	if unit.stopped {
+		return errors.New("processors are not running")
+	}
+
+	acc := NewAccumulator(processor, unit.dst)
+	if err := processor.Start(acc); err != nil {
+		return fmt.Errorf("starting processor %s: %w", processor.LogName(), err)
+	}
+
+	log.Printf("I! [agent] Replacing processor %s", unit.processor.LogName())
+	previous := unit.processor
+	unit.processor = processor
+	unit.acc = acc
+	previous.Stop()
+
+	return nil
+}
+
+// addInputs starts the given inputs and their gather loops.
+func (a *Agent) addInputs(inputs []*models.RunningInput) error {
+	if len(inputs) == 0 {
+		return nil
+	}
+
+	unit := a.iu
+	unit.Lock()
+	defer unit.Unlock()
+
+	if unit.stopped || unit.running == nil {
+		return errors.New("inputs are not running")
+	}
+	for _, input := range inputs {
+		log.Printf("I! [agent] Adding input %s", input.LogName())
+		if err := startServiceInput(input, unit.dst); err != nil {
+			return err
+		}
+		unit.inputs = append(unit.inputs, input)
+		a.runInput(unit, input)
+	}
+	return nil
+}
+
+// removeInputs stops the gather loops of the given inputs and stops the
+// service inputs among them.
+func (a *Agent) removeInputs(inputs []*models.RunningInput) {
+	unit := a.iu
+	for _, input := range inputs {
+		log.Printf("I! [agent] Removing input %s", input.LogName())
+
+		unit.Lock()
+		if i := slices.Index(unit.inputs, input); i >= 0 {
+			unit.inputs = slices.Delete(unit.inputs, i, i+1)
+		}
+		handle := unit.running[input]
+		delete(unit.running, input)
+		unit.Unlock()
+
+		if handle != nil {
+			handle.stop()
+		}
+		stopServiceInputs([]*models.RunningInput{input})
+		input.DiscardPending()
+	}
+}
+
+func closeOutputs(outputs []*models.RunningOutput) {
+	for _, output := range outputs {
+		output.Close()
+	}
+}
@@ -0,0 +1,387 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+)
+
+func TestReloadInPlace(t *testing.T) {
+	inputA := &reloadInput{name: "a"}
+	outputX := &reloadOutput{}
+
+	c := newReloadConfig()
+	c.Inputs = append(c.Inputs, newReloadInput(inputA, "a"))
+	c.Outputs = append(c.Outputs, newReloadOutput(outputX, "x"))
+
+	a := NewAgent(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, a.Run(ctx))
+	}()
+	require.Eventually(t, func() bool {
+		return outputX.received("a") > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Keep the unchanged input and output and add new instances
+	inputB := &reloadInput{name: "b"}
+	outputY := &reloadOutput{}
+	next := newReloadConfig()
+	next.Inputs = append(next.Inputs,
+		newReloadInput(&reloadInput{name: "a"}, "a"),
+		newReloadInput(inputB, "b"),
+	)
+	next.Outputs = append(next.Outputs,
+		newReloadOutput(&reloadOutput{}, "x"),
+		newReloadOutput(outputY, "y"),
+	)
+	require.NoError(t, a.Reload(ctx, NewAgent(next)))
+
+	require.Len(t, a.Config.Inputs, 2)
+	require.Same(t, inputA, a.Config.Inputs[0].Input)
+	require.Same(t, inputB, a.Config.Inputs[1].Input)
+	require.Len(t, a.Config.Outputs, 2)
+	require.Same(t, outputX, a.Config.Outputs[0].Output)
+	require.Same(t, outputY, a.Config.Outputs[1].Output)
+
+	require.Eventually(t, func() bool {
+		return outputX.received("b") > 0 && outputY.received("a") > 0 && outputY.received("b") > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.False(t, outputX.closed.Load())
+
+	// Remove the first input and output
+	next = newReloadConfig()
+	next.Inputs = append(next.Inputs, newReloadInput(&reloadInput{name: "b"}, "b"))
+	next.Outputs = append(next.Outputs, newReloadOutput(&reloadOutput{}, "y"))
+	require.NoError(t, a.Reload(ctx, NewAgent(next)))
+
+	require.Len(t, a.Config.Inputs, 1)
+	require.Same(t, inputB, a.Config.Inputs[0].Input)
+	require.Len(t, a.Config.Outputs, 1)
+	require.Same(t, outputY, a.Config.Outputs[0].Output)
+	require.True(t, outputX.closed.Load())
+	require.False(t, outputY.closed.Load())
+
+	// Changing the log level is applied to the running output
+	next = newReloadConfig()
+	next.Inputs = append(next.Inputs, newReloadInput(&reloadInput{name: "b"}, "b"))
+	next.Outputs = append(next.Outputs, models.NewRunningOutput(&reloadOutput{},
+		&models.OutputConfig{Name: "reload", ID: "y", LogLevel: "debug"}, 0, 0))
+	require.NoError(t, a.Reload(ctx, NewAgent(next)))
+	require.Same(t, outputY, a.Config.Outputs[0].Output)
+	require.Equal(t, "debug", a.Config.Outputs[0].LogLevel())
+
+	// An invalid configuration is rejected without touching the agent
+	next = newReloadConfig()
+	next.Inputs = append(next.Inputs,
+		newReloadInput(&reloadInput{name: "b"}, "b"),
+		newReloadInput(&reloadInput{name: "c", invalid: true}, "c"),
+	)
+	next.Outputs = append(next.Outputs, newReloadOutput(&reloadOutput{}, "z"))
+	err := a.Reload(ctx, NewAgent(next))
+	require.ErrorContains(t, err, "invalid input")
+	require.NotErrorIs(t, err, ErrRestartRequired)
+	require.Len(t, a.Config.Inputs, 1)
+	require.Same(t, inputB, a.Config.Inputs[0].Input)
+	require.Len(t, a.Config.Outputs, 1)
+	require.Same(t, outputY, a.Config.Outputs[0].Output)
+
+	// Changing the agent settings requires a restart
+	next = newReloadConfig()
+	next.Agent.FlushInterval = config.Duration(time.Second)
+	require.ErrorIs(t, a.Reload(ctx, NewAgent(next)), ErrRestartRequired)
+
+	cancel()
+	wg.Wait()
+	require.True(t, outputY.closed.Load())
+
+	// Reloading a stopped agent requires a restart
+	require.ErrorIs(t, a.Reload(ctx, NewAgent(newReloadConfig())), ErrRestartRequired)
+}
+
+func TestReloadServiceInputOnFixedPort(t *testing.T) {
+	// Find a free port to be used by both instances of the listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	outputX := &reloadOutput{}
+	c := newReloadConfig()
+	c.Inputs = append(c.Inputs, models.NewRunningInput(&reloadListener{name: "a", addr: addr},
+		&models.InputConfig{Name: "reload_listener", ID: "a"}))
+	c.Outputs = append(c.Outputs, newReloadOutput(outputX, "x"))
+
+	a := NewAgent(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, a.Run(ctx))
+	}()
+	require.Eventually(t, func() bool {
+		return outputX.received("a") > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Replace the listener by a changed instance on the same port and
+	// connect a new output to make sure it is connected without holding the
+	// lock of the management API
+	listener := &reloadListener{name: "b", addr: addr}
+	outputY := &reloadOutput{connecting: make(chan struct{}), release: make(chan struct{})}
+	next := newReloadConfig()
+	next.Inputs = append(next.Inputs, models.NewRunningInput(listener,
+		&models.InputConfig{Name: "reload_listener", ID: "b"}))
+	next.Outputs = append(next.Outputs,
+		newReloadOutput(&reloadOutput{}, "x"),
+		newReloadOutput(outputY, "y"),
+	)
+
+	reloaded := make(chan error, 1)
+	go func() {
+		reloaded <- a.Reload(ctx, NewAgent(next))
+	}()
+	<-outputY.connecting
+	locked := make(chan struct{})
+	go func() {
+		a.reloadMu.Lock()
+		defer a.reloadMu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "reload lock held while connecting outputs")
+	}
+	close(outputY.release)
+
+	require.NoError(t, <-reloaded)
+	require.Len(t, a.Config.Inputs, 1)
+	require.Same(t, listener, a.Config.Inputs[0].Input)
+	require.Eventually(t, func() bool {
+		return outputX.received("b") > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	wg.Wait()
+}
+
+func TestReloadOutputSharingDiskBuffer(t *testing.T) {
+	dir := t.TempDir()
+	newDiskOutput := func(output *reloadOutput, id string) *models.RunningOutput {
+		return models.NewRunningOutput(output, &models.OutputConfig{
+			Name:            "reload",
+			ID:              id,
+			BufferStrategy:  "disk",
+			BufferDirectory: dir,
+		}, 0, 0)
+	}
+	segments := func() []string {
+		files, err := filepath.Glob(filepath.Join(dir, "reload", "*.seg"))
+		require.NoError(t, err)
+		return files
+	}
+
+	// Keep the metrics in the disk buffer by failing all writes
+	outputX := &reloadOutput{}
+	outputX.failWrites.Store(true)
+	c := newReloadConfig()
+	c.Inputs = append(c.Inputs, newReloadInput(&reloadInput{name: "a"}, "a"))
+	c.Outputs = append(c.Outputs, newDiskOutput(outputX, "x"))
+
+	a := NewAgent(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, a.Run(ctx))
+	}()
+	require.Eventually(t, func() bool {
+		return c.Outputs[0].BufferLength() > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	buffered := c.Outputs[0].BufferLength()
+	before := segments()
+
+	// Replace the output by a changed instance using the same disk buffer
+	outputY := &reloadOutput{connecting: make(chan struct{}), release: make(chan struct{})}
+	next := newReloadConfig()
+	next.Inputs = append(next.Inputs, newReloadInput(&reloadInput{name: "a"}, "a"))
+	next.Outputs = append(next.Outputs, newDiskOutput(outputY, "y"))
+
+	reloaded := make(chan error, 1)
+	go func() {
+		reloaded <- a.Reload(ctx, NewAgent(next))
+	}()
+
+	// The buffer must not be opened while the previous instance uses it
+	<-outputY.connecting
+	require.Equal(t, before, segments())
+	close(outputY.release)
+
+	require.NoError(t, <-reloaded)
+	require.True(t, outputX.closed.Load())
+	require.Same(t, outputY, a.Config.Outputs[0].Output)
+
+	// The new instance writes the metrics left by the previous instance
+	require.Eventually(t, func() bool {
+		return outputY.received("a") >= buffered
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	wg.Wait()
+}
+
+func TestDiffPlugins(t *testing.T) {
+	type plugin struct{ id string }
+	a1, a2, b, c := &plugin{"a"}, &plugin{"a"}, &plugin{"b"}, &plugin{"c"}
+	na, nc, nd := &plugin{"a"}, &plugin{"c"}, &plugin{"d"}
+
+	diff := diffPlugins([]*plugin{a1, a2, b, c}, []*plugin{nc, na, nd}, func(p *plugin) string {
+		return p.id
+	})
+	require.Equal(t, []*plugin{c, a1, nd}, diff.merged)
+	require.Equal(t, []*plugin{nd}, diff.added)
+	require.Equal(t, []*plugin{a2, b}, diff.removed)
+}
+
+func newReloadConfig() *config.Config {
+	c := config.NewConfig()
+	c.Agent.Interval = config.Duration(10 * time.Millisecond)
+	c.Agent.FlushInterval = config.Duration(10 * time.Millisecond)
+	c.Agent.RoundInterval = false
+	return c
+}
+
+func newReloadInput(input *reloadInput, id string) *models.RunningInput {
+	return models.NewRunningInput(input, &models.InputConfig{Name: "reload", ID: id})
+}
+
+func newReloadOutput(output *reloadOutput, id string) *models.RunningOutput {
+	return models.NewRunningOutput(output, &models.OutputConfig{Name: "reload", ID: id}, 0, 0)
+}
+
+type reloadInput struct {
+	name    string
+	invalid bool
+}
+
+func (*reloadInput) SampleConfig() string {
+	return ""
+}
+
+func (i *reloadInput) Init() error {
+	if i.invalid {
+		return errors.New("invalid input")
+	}
+	return nil
+}
+
+func (i *reloadInput) Gather(acc telegraf.Accumulator) error {
+	acc.AddFields("reload", map[string]interface{}{"value": 42}, map[string]string{"input": i.name})
+	return nil
+}
+
+type reloadOutput struct {
+	sync.Mutex
+	metrics    []telegraf.Metric
+	closed     atomic.Bool
+	failures   atomic.Int32
+	failWrites atomic.Bool
+
+	// Connect signals connecting and blocks until released if set
+	connecting chan struct{}
+	release    chan struct{}
+}
+
+func (*reloadOutput) SampleConfig() string {
+	return ""
+}
+
+// reloadListener is a service input listening on a fixed address
+type reloadListener struct {
+	name     string
+	addr     string
+	listener net.Listener
+}
+
+func (*reloadListener) SampleConfig() string {
+	return ""
+}
+
+func (l *reloadListener) Start(telegraf.Accumulator) error {
+	listener, err := net.Listen("tcp", l.addr)
+	if err != nil {
+		return err
+	}
+	l.listener = listener
+	return nil
+}
+
+func (l *reloadListener) Stop() {
+	l.listener.Close()
+}
+
+func (l *reloadListener) Gather(acc telegraf.Accumulator) error {
+	acc.AddFields("reload", map[string]interface{}{"value": 42}, map[string]string{"input": l.name})
+	return nil
+}
+
+func (o *reloadOutput) Connect() error {
+	if o.connecting != nil {
+		close(o.connecting)
+		<-o.release
+	}
+	if o.failures.Add(-1) >= 0 {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (o *reloadOutput) Close() error {
+	o.closed.Store(true)
+	return nil
+}
+
+func (o *reloadOutput) Write(metrics []telegraf.Metric) error {
+	if o.failWrites.Load() {
+		return errors.New("write failed")
+	}
+	o.Lock()
+	defer o.Unlock()
+	o.metrics = append(o.metrics, metrics...)
+	return nil
+}
+
+func (o *reloadOutput) received(input string) int {
+	o.Lock()
+	defer o.Unlock()
+
+	var count int
+	for _, m := range o.metrics {
+		if v, found := m.GetTag("input"); found && v == input {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,183 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/models"
+)
+
+// Replay feeds the given metrics through the processors and aggregators of
+// the configuration and returns the resulting metrics instead of sending them
+// to the outputs. Inputs and outputs are neither initialized nor started.
+//
+// Time is simulated using the timestamps of the metrics, i.e. a metric is
+// considered to arrive at its timestamp. The aggregation windows start at the
+// time of the first metric and are pushed once a metric passes their end, so
+// the result only depends on the metrics and not on the wall clock. Metrics
+// created by aggregators without timestamp use the end of the aggregation
+// window as their time. The metrics are replayed in the order of their
+// timestamps and the result is ordered the same way.
+func (a *Agent) Replay(metrics []telegraf.Metric) ([]telegraf.Metric, error) {
+	if err := a.initReplayPlugins(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(metrics, func(i, j int) bool {
+		return metrics[i].Time().Before(metrics[j].Time())
+	})
+
+	processed, err := a.replayProcessors(a.Config.Processors, metrics)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.Config.Aggregators) == 0 {
+		return processed, nil
+	}
+
+	aggregated, passed := a.replayAggregators(processed)
+	aggregated, err = a.replayProcessors(a.Config.AggProcessors, aggregated)
+	if err != nil {
+		return nil, err
+	}
+
+	// Aggregates are placed after the original metrics of the same time
+	result := append(passed, aggregated...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time().Before(result[j].Time())
+	})
+
+	log.Printf("D! [agent] Replayed %d metrics resulting in %d metrics", len(metrics), len(result))
+	return result, nil
+}
+
+// initReplayPlugins initializes the plugins used for replaying metrics.
+func (a *Agent) initReplayPlugins() error {
+	for _, processor := range a.Config.Processors {
+		if err := processor.Init(); err != nil {
+			return fmt.Errorf("could not initialize processor %s: %w", processor.LogName(), err)
+		}
+	}
+	for _, aggregator := range a.Config.Aggregators {
+		if aggregator.Period() <= 0 {
+			return fmt.Errorf("period of aggregator %s must be positive", aggregator.LogName())
+		}
+		if err := aggregator.Init(); err != nil {
+			return fmt.Errorf("could not initialize aggregator %s: %w", aggregator.LogName(), err)
+		}
+	}
+	for _, processor := range a.Config.AggProcessors {
+		if err := processor.Init(); err != nil {
+			return fmt.Errorf("could not initialize processor %s: %w", processor.LogName(), err)
+		}
+	}
+	return nil
+}
+
+// replayProcessors runs the metrics through the processor chain in the same
+// way as the agent and collects the resulting metrics.
+func (a *Agent) replayProcessors(processors models.RunningProcessors, metrics []telegraf.Metric) ([]telegraf.Metric, error) {
+	if len(processors) == 0 {
+		return metrics, nil
+	}
+
+	dst := make(chan telegraf.Metric, 100)
+	src, units, err := a.startProcessors(dst, processors)
+	if err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		a.runProcessors(units)
+	}()
+	go func() {
+		defer wg.Done()
+		for _, m := range metrics {
+			src <- m
+		}
+		close(src)
+	}()
+
+	result := make([]telegraf.Metric, 0, len(metrics))
+	for m := range dst {
+		result = append(result, m)
+	}
+	wg.Wait()
+
+	return result, nil
+}
+
+// replayAggregators adds the metrics to the aggregators while simulating the
+// time and returns the aggregated metrics as well as the original metrics not
+// dropped by any aggregator.
+func (a *Agent) replayAggregators(metrics []telegraf.Metric) (aggregated, passed []telegraf.Metric) {
+	if len(metrics) == 0 {
+		return nil, nil
+	}
+
+	aggC := make(chan telegraf.Metric, 100)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for m := range aggC {
+			aggregated = append(aggregated, m)
+		}
+	}()
+
+	// Metrics of aggregators use the simulated time at which they are pushed
+	var clock time.Time
+	interval := time.Duration(a.Config.Agent.Interval)
+	precision := getPrecision(time.Duration(a.Config.Agent.Precision), interval)
+	accs := make([]*accumulator, 0, len(a.Config.Aggregators))
+	for _, agg := range a.Config.Aggregators {
+		since, until := updateWindow(metrics[0].Time(), a.Config.Agent.RoundInterval, agg.Period())
+		agg.UpdateWindow(since, until)
+
+		accs = append(accs, &accumulator{
+			maker:     agg,
+			metrics:   aggC,
+			precision: precision,
+			now:       func() time.Time { return clock },
+		})
+	}
+
+	passed = make([]telegraf.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		// Push all windows ending before the arrival of the metric
+		for i, agg := range a.Config.Aggregators {
+			for !m.Time().Before(agg.EndPeriod()) {
+				clock = agg.EndPeriod()
+				agg.Push(accs[i])
+			}
+		}
+
+		var dropOriginal bool
+		for _, agg := range a.Config.Aggregators {
+			if ok := agg.Add(m); ok {
+				dropOriginal = true
+			}
+		}
+		if dropOriginal {
+			m.Drop()
+			continue
+		}
+		passed = append(passed, m)
+	}
+
+	// Push the last window as the agent does when stopping
+	for i, agg := range a.Config.Aggregators {
+		clock = agg.EndPeriod()
+		agg.Push(accs[i])
+	}
+	close(aggC)
+	<-done
+
+	return aggregated, passed
+}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	_ "github.com/influxdata/telegraf/plugins/aggregators/minmax"
+	_ "github.com/influxdata/telegraf/plugins/processors/override"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestReplay(t *testing.T) {
+	cfg := `
+[agent]
+  omit_hostname = true
+
+[[processors.override]]
+  [processors.override.tags]
+    pipeline = "test"
+
+[[aggregators.minmax]]
+  period = "10s"
+  namepass = ["cpu"]
+  drop_original = true
+`
+	c := config.NewConfig()
+	c.Agent.Quiet = true
+	require.NoError(t, c.LoadConfigData([]byte(cfg)))
+
+	start := time.Unix(1700000000, 0)
+	input := []telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 3.0}, start.Add(12*time.Second)),
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 1.0}, start),
+		metric.New("mem", map[string]string{}, map[string]interface{}{"used": 42}, start.Add(3*time.Second)),
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 2.0}, start.Add(5*time.Second)),
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("mem", map[string]string{"pipeline": "test"}, map[string]interface{}{"used": 42}, start.Add(3*time.Second)),
+		metric.New("cpu", map[string]string{"pipeline": "test"},
+			map[string]interface{}{"value_min": 1.0, "value_max": 2.0}, start.Add(10*time.Second)),
+		metric.New("cpu", map[string]string{"pipeline": "test"},
+			map[string]interface{}{"value_min": 3.0, "value_max": 3.0}, start.Add(20*time.Second)),
+	}
+
+	actual, err := NewAgent(c).Replay(input)
+	require.NoError(t, err)
+	testutil.RequireMetricsEqual(t, expected, actual)
+}
+
+func TestReplayInvalidPeriod(t *testing.T) {
+	cfg := `
+[[aggregators.minmax]]
+  period = "0s"
+`
+	c := config.NewConfig()
+	c.Agent.Quiet = true
+	require.NoError(t, c.LoadConfigData([]byte(cfg)))
+
+	_, err := NewAgent(c).Replay(nil)
+	require.ErrorContains(t, err, "period of aggregator aggregators.minmax must be positive")
+}
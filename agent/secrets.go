@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// refreshSecrets periodically checks the secret-stores for changed secrets
+// until the context is done.
+func (a *Agent) refreshSecrets(ctx context.Context, interval time.Duration) {
+	failed := selfstat.Register("agent", "secrets_refresh_failed", map[string]string{})
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.refreshSecretsOnce(failed)
+		}
+	}
+}
+
+// refreshSecretsOnce refreshes the secrets referencing the keys changed in
+// the secret-stores and counts the secrets failing to refresh.
+func (a *Agent) refreshSecretsOnce(failed selfstat.Stat) {
+	// Do not interfere with replacing plugins
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	ids := make([]string, 0, len(a.Config.SecretStores))
+	for id := range a.Config.SecretStores {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		store, ok := a.Config.SecretStores[id].(telegraf.SecretStoreNotifier)
+		if !ok {
+			continue
+		}
+		keys, err := store.ChangedKeys()
+		if err != nil {
+			log.Printf("E! [agent] Checking secret-store %q for changed secrets failed: %v", id, err)
+			failed.Incr(1)
+			continue
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		log.Printf("D! [agent] Secrets %v of secret-store %q changed", keys, id)
+		for _, err := range a.Config.RefreshSecrets(id, keys) {
+			log.Printf("E! [agent] %v", err)
+			failed.Incr(1)
+		}
+	}
+}
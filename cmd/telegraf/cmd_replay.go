@@ -0,0 +1,216 @@
+// Command handling for the "replay" command
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+)
+
+type ReplayFlags struct {
+	files        []string
+	dataFormat   string
+	parserConfig string
+	expected     string
+	capture      bool
+}
+
+func getReplayCommands(m App, w io.Writer) []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:  "replay",
+			Usage: "feed recorded metrics through the processors and aggregators and print the result",
+			Description: `
+The 'replay' command parses the given files containing recorded metrics and
+feeds them through the processors and aggregators of the configuration
+instead of gathering metrics from the inputs. The resulting metrics are
+printed in InfluxDB line protocol instead of being written to the outputs.
+Inputs and outputs of the configuration are ignored.
+
+Time is simulated using the timestamps of the recorded metrics, so the
+aggregation periods are determined by the metrics instead of the wall clock
+and the result is reproducible. The recorded metrics should therefore
+contain timestamps.
+
+To replay a file in InfluxDB line protocol through the processors and
+aggregators of your configuration, run
+
+> telegraf --config telegraf.conf replay recorded.influx
+
+Files in other data formats can be replayed by specifying the data format and,
+if required, a TOML file with the parser settings as used in input plugins
+
+> telegraf --config telegraf.conf replay --data-format csv --parser-config csv.toml recorded.csv
+
+To use the command for regression testing, pass a file in InfluxDB line
+protocol containing the expected metrics. The command prints the differences
+and fails if the resulting metrics differ. The order of the metrics is not
+compared.
+
+> telegraf --config telegraf.conf replay --expected expected.influx recorded.influx
+
+Capture files written by the 'record' option of the agent or the inputs can
+be replayed by passing the '--capture' flag. Each recorded payload is parsed
+with the data format it was recorded with, unless the data format is given.
+Tags added by the inputs, e.g. the MQTT topic, are not part of the capture.
+
+> telegraf --config telegraf.conf replay --capture --parser-config csv.toml capture.jsonl
+`,
+			ArgsUsage: "<file>...<file>",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "data-format",
+					Usage: "data format of the recorded files, overrides the data_format of the parser settings",
+				},
+				&cli.StringFlag{
+					Name:  "parser-config",
+					Usage: "TOML file with the settings of the parser for the recorded files",
+				},
+				&cli.BoolFlag{
+					Name:  "capture",
+					Usage: "the files are capture files written by the record option",
+				},
+				&cli.StringFlag{
+					Name:  "expected",
+					Usage: "file with the expected metrics in InfluxDB line protocol to compare the result against",
+				},
+			},
+			Action: func(cCtx *cli.Context) error {
+				if !cCtx.Args().Present() {
+					return errors.New("no recorded files given")
+				}
+
+				g := GlobalFlags{
+					config:     cCtx.StringSlice("config"),
+					configDir:  cCtx.StringSlice("config-directory"),
+					configExt:  cCtx.StringSlice("config-directory-extension"),
+					plugindDir: cCtx.String("plugin-directory"),
+					password:   cCtx.String("password"),
+					debug:      cCtx.Bool("debug"),
+					quiet:      cCtx.Bool("quiet"),
+				}
+				m.Init(nil, Filters{}, g, WindowFlags{})
+
+				r := ReplayFlags{
+					files:        cCtx.Args().Slice(),
+					dataFormat:   cCtx.String("data-format"),
+					parserConfig: cCtx.String("parser-config"),
+					expected:     cCtx.String("expected"),
+					capture:      cCtx.Bool("capture"),
+				}
+				return m.Replay(w, r)
+			},
+		},
+	}
+}
+
+// readRecordedMetrics parses the recorded files with the parser configured by
+// the flags.
+func readRecordedMetrics(c *config.Config, r ReplayFlags) ([]telegraf.Metric, error) {
+	var settings []byte
+	if r.parserConfig != "" {
+		var err error
+		if settings, err = os.ReadFile(r.parserConfig); err != nil {
+			return nil, fmt.Errorf("reading parser settings failed: %w", err)
+		}
+	}
+
+	// Parsers by data format, the capture records may use different formats
+	parsers := make(map[string]*models.RunningParser)
+	parse := func(dataformat string, buf []byte) ([]telegraf.Metric, error) {
+		parser, found := parsers[dataformat]
+		if !found {
+			var err error
+			if parser, err = c.NewParser(dataformat, settings); err != nil {
+				return nil, fmt.Errorf("creating parser failed: %w", err)
+			}
+			parsers[dataformat] = parser
+		}
+		return parser.Parse(buf)
+	}
+
+	var metrics []telegraf.Metric
+	for _, fn := range r.files {
+		buf, err := os.ReadFile(fn)
+		if err != nil {
+			return nil, fmt.Errorf("reading recorded metrics failed: %w", err)
+		}
+
+		if !r.capture {
+			parsed, err := parse(r.dataFormat, buf)
+			if err != nil {
+				return nil, fmt.Errorf("parsing %q failed: %w", fn, err)
+			}
+			metrics = append(metrics, parsed...)
+			continue
+		}
+
+		records, err := models.ReadCaptureRecords(bytes.NewReader(buf))
+		if err != nil {
+			return nil, fmt.Errorf("reading capture file %q failed: %w", fn, err)
+		}
+		for i, record := range records {
+			dataformat := r.dataFormat
+			if dataformat == "" {
+				dataformat = record.DataFormat
+			}
+			parsed, err := parse(dataformat, record.Data)
+			if err != nil {
+				return nil, fmt.Errorf("parsing record %d of %q failed: %w", i+1, fn, err)
+			}
+			metrics = append(metrics, parsed...)
+		}
+	}
+	return metrics, nil
+}
+
+// serializeMetrics returns the metrics in InfluxDB line protocol with sorted
+// fields, one line per metric.
+func serializeMetrics(metrics []telegraf.Metric) ([]string, error) {
+	serializer := &influx.Serializer{SortFields: true}
+	lines := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		octets, err := serializer.Serialize(m)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, strings.TrimSuffix(string(octets), "\n"))
+	}
+	return lines, nil
+}
+
+// diffLines returns the lines only present in the expected and only present
+// in the actual lines, ignoring the order but not the number of occurrences.
+func diffLines(expected, actual []string) (missing, unexpected []string) {
+	counts := make(map[string]int, len(expected))
+	for _, line := range expected {
+		counts[line]++
+	}
+	for _, line := range actual {
+		if counts[line] > 0 {
+			counts[line]--
+			continue
+		}
+		unexpected = append(unexpected, line)
+	}
+	for _, line := range expected {
+		if counts[line] > 0 {
+			counts[line]--
+			missing = append(missing, line)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	return missing, unexpected
+}
@@ -78,6 +78,7 @@ To also reveal the actual secret, i.e. the value, you can pass the
 						g := GlobalFlags{
 							config:     cCtx.StringSlice("config"),
 							configDir:  cCtx.StringSlice("config-directory"),
+							configExt:  cCtx.StringSlice("config-directory-extension"),
 							plugindDir: cCtx.String("plugin-directory"),
 							password:   cCtx.String("password"),
 							debug:      cCtx.Bool("debug"),
@@ -156,6 +157,7 @@ with the ID 'mystore'.
 						g := GlobalFlags{
 							config:     cCtx.StringSlice("config"),
 							configDir:  cCtx.StringSlice("config-directory"),
+							configExt:  cCtx.StringSlice("config-directory-extension"),
 							plugindDir: cCtx.String("plugin-directory"),
 							password:   cCtx.String("password"),
 							debug:      cCtx.Bool("debug"),
@@ -220,6 +222,7 @@ you will be prompted to enter the value of the secret.
 						g := GlobalFlags{
 							config:     cCtx.StringSlice("config"),
 							configDir:  cCtx.StringSlice("config-directory"),
+							configExt:  cCtx.StringSlice("config-directory-extension"),
 							plugindDir: cCtx.String("plugin-directory"),
 							password:   cCtx.String("password"),
 							debug:      cCtx.Bool("debug"),
@@ -221,6 +221,7 @@ func runApp(args []string, outputBuffer io.Writer, pprof Server, c TelegrafConfi
 		g := GlobalFlags{
 			config:      cCtx.StringSlice("config"),
 			configDir:   cCtx.StringSlice("config-directory"),
+			configExt:   cCtx.StringSlice("config-directory-extension"),
 			testWait:    cCtx.Int("test-wait"),
 			watchConfig: cCtx.String("watch-config"),
 			pidFile:     cCtx.String("pidfile"),
@@ -260,6 +261,10 @@ func runApp(args []string, outputBuffer io.Writer, pprof Server, c TelegrafConfi
 					Name:  "config-directory",
 					Usage: "directory containing additional *.conf files",
 				},
+				&cli.StringSliceFlag{
+					Name:  "config-directory-extension",
+					Usage: "additional extension of files to load from config directories, e.g. '.yaml'",
+				},
 				// Int flags
 				&cli.IntFlag{
 					Name:  "test-wait",
@@ -353,6 +358,35 @@ func runApp(args []string, outputBuffer io.Writer, pprof Server, c TelegrafConfi
 					printSampleConfig(outputBuffer, filters)
 					return nil
 				},
+				Subcommands: []*cli.Command{
+					{
+						Name:  "check",
+						Usage: "check the configuration for errors and deprecated settings without running it",
+						Description: `
+The 'check' command loads the given configuration files and directories,
+or the default configuration, and initializes all plugins without
+starting them. In contrast to running Telegraf, all problems are
+reported, including unknown options, invalid values, unresolvable
+secrets, deprecated plugins and options and suspicious filter settings.
+The command exits with an error if any problem, including a warning, was
+found.
+
+> telegraf --config telegraf.conf --config-directory telegraf.d config check
+`,
+						Action: func(cCtx *cli.Context) error {
+							g := GlobalFlags{
+								config:     cCtx.StringSlice("config"),
+								configDir:  cCtx.StringSlice("config-directory"),
+								configExt:  cCtx.StringSlice("config-directory-extension"),
+								plugindDir: cCtx.String("plugin-directory"),
+								password:   cCtx.String("password"),
+								debug:      cCtx.Bool("debug"),
+							}
+							m.Init(nil, Filters{}, g, WindowFlags{})
+							return m.CheckConfig(outputBuffer)
+						},
+					},
+				},
 			},
 			{
 				Name:  "version",
@@ -363,7 +397,7 @@ func runApp(args []string, outputBuffer io.Writer, pprof Server, c TelegrafConfi
 				},
 			},
 		},
-			getSecretStoreCommands(m)...,
+			append(getSecretStoreCommands(m), getReplayCommands(m, outputBuffer)...)...,
 		),
 	}
 
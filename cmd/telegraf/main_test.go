@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -65,6 +66,19 @@ func (m *MockTelegraf) ListSecretStores() ([]string, error) {
 	return ids, nil
 }
 
+func (m *MockTelegraf) CheckConfig(w io.Writer) error {
+	if len(m.config) == 0 {
+		return errors.New("no configuration")
+	}
+	fmt.Fprintf(w, "checked %s\n", strings.Join(m.config, ","))
+	return nil
+}
+
+func (m *MockTelegraf) Replay(w io.Writer, r ReplayFlags) error {
+	fmt.Fprintf(w, "replayed %s as %q\n", strings.Join(r.files, ","), r.dataFormat)
+	return nil
+}
+
 func (m *MockTelegraf) GetSecretStore(id string) (telegraf.SecretStore, error) {
 	v, found := secrets[id]
 	if !found {
@@ -418,6 +432,68 @@ func TestCommandConfig(t *testing.T) {
 	}
 }
 
+func TestCommandConfigCheck(t *testing.T) {
+	buf := new(bytes.Buffer)
+	args := os.Args[0:1]
+	args = append(args, "--config", "a.conf", "--config", "b.conf", "config", "check")
+	m := NewMockTelegraf()
+	err := runApp(args, buf, NewMockServer(), NewMockConfig(buf), m)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.conf", "b.conf"}, m.config)
+	require.Equal(t, "checked a.conf,b.conf\n", buf.String())
+
+	buf.Reset()
+	args = append(os.Args[0:1], "config", "check")
+	err = runApp(args, buf, NewMockServer(), NewMockConfig(buf), NewMockTelegraf())
+	require.ErrorContains(t, err, "no configuration")
+}
+
+func TestCommandReplay(t *testing.T) {
+	buf := new(bytes.Buffer)
+	args := os.Args[0:1]
+	args = append(args, "--config", "pipeline.conf", "replay", "--data-format", "csv", "a.csv", "b.csv")
+	m := NewMockTelegraf()
+	err := runApp(args, buf, NewMockServer(), NewMockConfig(buf), m)
+	require.NoError(t, err)
+	require.Equal(t, []string{"pipeline.conf"}, m.config)
+	require.Equal(t, "replayed a.csv,b.csv as \"csv\"\n", buf.String())
+
+	args = append(os.Args[0:1], "replay")
+	err = runApp(args, buf, NewMockServer(), NewMockConfig(buf), NewMockTelegraf())
+	require.ErrorContains(t, err, "no recorded files given")
+}
+
+func TestReadRecordedMetricsCapture(t *testing.T) {
+	capture := filepath.Join(t.TempDir(), "capture.jsonl")
+	records := `{"time":"2023-11-14T22:13:20Z","plugin":"mqtt_consumer","data_format":"influx",` +
+		`"metadata":{"topic":"sensors/cpu"},"data":"Y3B1IHZhbHVlPTEgMTcwMDAwMDAwMDAwMDAwMDAwMAo="}
+{"time":"2023-11-14T22:13:25Z","plugin":"http","data_format":"json","data":"eyJ2YWx1ZSI6IDJ9"}
+`
+	require.NoError(t, os.WriteFile(capture, []byte(records), 0600))
+
+	c := config.NewConfig()
+	metrics, err := readRecordedMetrics(c, ReplayFlags{files: []string{capture}, capture: true})
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+	require.Equal(t, "cpu", metrics[0].Name())
+	require.Equal(t, map[string]interface{}{"value": 1.0}, metrics[0].Fields())
+	require.Equal(t, "replay", metrics[1].Name())
+	require.Equal(t, map[string]interface{}{"value": 2.0}, metrics[1].Fields())
+}
+
+func TestDiffLines(t *testing.T) {
+	expected := []string{"cpu value=1 0", "cpu value=1 0", "mem used=2 0"}
+	actual := []string{"mem used=2 0", "cpu value=1 0", "disk free=3 0"}
+
+	missing, unexpected := diffLines(expected, actual)
+	require.Equal(t, []string{"cpu value=1 0"}, missing)
+	require.Equal(t, []string{"disk free=3 0"}, unexpected)
+
+	missing, unexpected = diffLines(expected, expected)
+	require.Empty(t, missing)
+	require.Empty(t, unexpected)
+}
+
 func TestCommandVersion(t *testing.T) {
 	tests := []struct {
 		Version        string
@@ -537,6 +613,7 @@ func TestFlagsAreSet(t *testing.T) {
 	commands := []string{
 		"--config", expectedString,
 		"--config-directory", expectedString,
+		"--config-directory-extension", expectedString,
 		"--debug",
 		"--test",
 		"--quiet",
@@ -555,6 +632,7 @@ func TestFlagsAreSet(t *testing.T) {
 
 	require.Equal(t, []string{expectedString}, m.config)
 	require.Equal(t, []string{expectedString}, m.configDir)
+	require.Equal(t, []string{expectedString}, m.configExt)
 	require.Equal(t, true, m.debug)
 	require.Equal(t, true, m.test)
 	require.Equal(t, true, m.once)
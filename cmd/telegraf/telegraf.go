@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -27,13 +28,20 @@ import (
 	"github.com/influxdata/telegraf/plugins/parsers"
 	"github.com/influxdata/telegraf/plugins/processors"
 	"github.com/influxdata/telegraf/plugins/secretstores"
+	"github.com/influxdata/telegraf/selfstat"
 )
 
 var stop chan struct{}
 
+var (
+	configReloads      = selfstat.Register("agent", "config_reloads", map[string]string{})
+	configReloadErrors = selfstat.Register("agent", "config_reload_errors", map[string]string{})
+)
+
 type GlobalFlags struct {
 	config      []string
 	configDir   []string
+	configExt   []string
 	testWait    int
 	watchConfig string
 	pidFile     string
@@ -61,6 +69,10 @@ type App interface {
 	// Secret store commands
 	ListSecretStores() ([]string, error)
 	GetSecretStore(string) (telegraf.SecretStore, error)
+
+	// Configuration commands
+	CheckConfig(io.Writer) error
+	Replay(io.Writer, ReplayFlags) error
 }
 
 type Telegraf struct {
@@ -117,11 +129,15 @@ func (t *Telegraf) GetSecretStore(id string) (telegraf.SecretStore, error) {
 }
 
 func (t *Telegraf) reloadLoop() error {
-	reloadConfig := false
 	cfg, err := t.loadConfiguration()
 	if err != nil {
 		return err
 	}
+	ag := agent.NewAgent(cfg)
+
+	// Configuration of the agent replaced by a restart to fall back to if
+	// the new agent fails to run
+	var fallback []config.Source
 
 	reload := make(chan bool, 1)
 	reload <- true
@@ -132,44 +148,151 @@ func (t *Telegraf) reloadLoop() error {
 		signals := make(chan os.Signal, 1)
 		signal.Notify(signals, os.Interrupt, syscall.SIGHUP,
 			syscall.SIGTERM, syscall.SIGINT)
-		if t.watchConfig != "" {
-			for _, fConfig := range t.configFiles {
-				if _, err := os.Stat(fConfig); err == nil {
-					go t.watchLocalConfig(signals, fConfig)
-				} else {
-					log.Printf("W! Cannot watch config %s: %s", fConfig, err)
-				}
-			}
-		}
-		go func() {
+		ag.SetReloadFunc(func() {
 			select {
-			case sig := <-signals:
-				if sig == syscall.SIGHUP {
-					log.Printf("I! Reloading Telegraf config")
-					<-reload
-					reload <- true
+			case signals <- syscall.SIGHUP:
+			default:
+				// A reload is already pending
+			}
+		})
+		stopWatching := t.watchConfigFiles(ctx, signals)
+		done := make(chan struct{})
+		go func(current *agent.Agent) {
+			defer close(done)
+			for {
+				select {
+				case sig := <-signals:
+					if sig == syscall.SIGHUP {
+						log.Printf("I! Reloading Telegraf config")
+						stopWatching()
+						next := t.reloadConfiguration(ctx, current)
+						if next == nil {
+							stopWatching = t.watchConfigFiles(ctx, signals)
+							continue
+						}
+						fallback = current.Config.Sources()
+						ag = next
+						<-reload
+						reload <- true
+					}
+					cancel()
+				case err := <-t.pprofErr:
+					log.Printf("E! pprof server failed: %v", err)
+					cancel()
+				case <-stop:
+					cancel()
+				case <-ctx.Done():
 				}
-				cancel()
-			case err := <-t.pprofErr:
-				log.Printf("E! pprof server failed: %v", err)
-				cancel()
-			case <-stop:
-				cancel()
+				return
 			}
-		}()
+		}(ag)
 
-		err := t.runAgent(ctx, cfg, reloadConfig)
+		err := t.runAgent(ctx, ag)
+		cancel()
+		<-done
 		if err != nil && !errors.Is(err, context.Canceled) {
-			return fmt.Errorf("[telegraf] Error running agent: %w", err)
+			if fallback == nil {
+				return fmt.Errorf("[telegraf] Error running agent: %w", err)
+			}
+
+			// Restart with the configuration running before instead of
+			// exiting, the new configuration is retried on the next reload
+			log.Printf("E! Running agent with new config failed, restarting with previous config: %v", err)
+			c := t.newConfig()
+			if lerr := c.LoadSources(fallback); lerr != nil {
+				log.Printf("E! Loading previous config failed: %v", lerr)
+				return fmt.Errorf("[telegraf] Error running agent: %w", err)
+			}
+			configReloadErrors.Incr(1)
+			ag = agent.NewAgent(c)
+			fallback = nil
+			<-reload
+			reload <- true
 		}
-		reloadConfig = true
 	}
 
 	return nil
 }
 
-func (t *Telegraf) watchLocalConfig(signals chan os.Signal, fConfig string) {
+// reloadConfiguration loads and validates the configuration and applies it
+// to the current agent without restarting unchanged plugins. If the
+// configuration cannot be applied in place or applying it failed halfway, the
+// new agent to restart with is returned. On failure, the current agent keeps
+// running with the last good configuration and nil is returned.
+func (t *Telegraf) reloadConfiguration(ctx context.Context, current *agent.Agent) *agent.Agent {
+	c, err := t.loadConfiguration()
+	if err == nil {
+		err = t.checkConfiguration(c)
+	}
+	if err != nil {
+		configReloadErrors.Incr(1)
+		log.Printf("E! Reloading config failed, keeping last good config: %v", err)
+		return nil
+	}
+
+	next := agent.NewAgent(c)
+	if err := current.Reload(ctx, next); err != nil {
+		if errors.Is(err, agent.ErrRestartRequired) {
+			configReloads.Incr(1)
+			log.Printf("I! Restarting agent to apply config: %v", err)
+			return next
+		}
+		if errors.Is(err, agent.ErrReloadIncomplete) {
+			// The plugins of the next agent might already be in use, so
+			// restart with new instances
+			configReloadErrors.Incr(1)
+			log.Printf("E! Applying config failed, restarting agent: %v", err)
+			restarted := t.newConfig()
+			if err := restarted.LoadSources(c.Sources()); err != nil {
+				log.Printf("E! Loading config failed, running with incomplete config: %v", err)
+				return nil
+			}
+			return agent.NewAgent(restarted)
+		}
+		configReloadErrors.Incr(1)
+		log.Printf("E! Reloading config failed, keeping last good config: %v", err)
+		return nil
+	}
+
+	configReloads.Incr(1)
+	log.Printf("I! Config reloaded")
+	return nil
+}
+
+// watchConfigFiles watches the local configuration files and directories for
+// changes until the context is done or the returned function is called. On
+// change, a SIGHUP signal is sent once.
+func (t *Telegraf) watchConfigFiles(ctx context.Context, signals chan os.Signal) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+	if t.watchConfig == "" {
+		return cancel
+	}
+	for _, fConfig := range t.configFiles {
+		if _, err := os.Stat(fConfig); err == nil {
+			go t.watchLocalConfig(ctx, signals, fConfig)
+		} else {
+			log.Printf("W! Cannot watch config %s: %s", fConfig, err)
+		}
+	}
+	for _, fConfigDirectory := range t.configDir {
+		if _, err := os.Stat(fConfigDirectory); err == nil {
+			go t.watchConfigDirectory(ctx, signals, fConfigDirectory)
+		} else {
+			log.Printf("W! Cannot watch config directory %s: %s", fConfigDirectory, err)
+		}
+	}
+	return cancel
+}
+
+func (t *Telegraf) watchLocalConfig(ctx context.Context, signals chan os.Signal, fConfig string) {
 	var mytomb tomb.Tomb
+	go func() {
+		select {
+		case <-ctx.Done():
+			mytomb.Kill(nil)
+		case <-mytomb.Dying():
+		}
+	}()
 	var watcher watch.FileWatcher
 	if t.watchConfig == "poll" {
 		watcher = watch.NewPollingFileWatcher(fConfig)
@@ -205,24 +328,86 @@ func (t *Telegraf) watchLocalConfig(signals chan os.Signal, fConfig string) {
 		return
 	}
 	mytomb.Done()
-	signals <- syscall.SIGHUP
+	triggerReload(ctx, signals)
+}
+
+// watchConfigDirectory watches the directory and all its subdirectories for
+// config files being created, deleted or renamed until the context is done.
+// Changes are debounced so a burst of changes sends a single SIGHUP signal.
+func (t *Telegraf) watchConfigDirectory(ctx context.Context, signals chan os.Signal, dir string) {
+	var changes <-chan string
+	var err error
+	if t.watchConfig == "poll" {
+		changes, err = pollConfigDirectory(ctx, dir, t.configExt, configWatchPollInterval)
+	} else {
+		changes, err = notifyConfigDirectory(ctx, dir, t.configExt)
+	}
+	if err != nil {
+		log.Printf("E! Error watching config directory: %s", err)
+		return
+	}
+	log.Printf("I! Config directory watcher started for %s", dir)
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("I! Config directory watcher ended")
+			return
+		case name := <-changes:
+			log.Printf("D! Config directory change detected for %s", name)
+			debounce = time.After(configWatchDebounce)
+		case <-debounce:
+			log.Printf("I! Config directory %s changed", dir)
+			triggerReload(ctx, signals)
+			return
+		}
+	}
+}
+
+// triggerReload sends a SIGHUP signal unless the context is done.
+func triggerReload(ctx context.Context, signals chan os.Signal) {
+	select {
+	case signals <- syscall.SIGHUP:
+	case <-ctx.Done():
+	}
 }
 
 func (t *Telegraf) loadConfiguration() (*config.Config, error) {
 	// If no other options are specified, load the config file and run.
+	c := t.newConfig()
+
+	configFiles, err := t.collectConfigFiles()
+	if err != nil {
+		return c, err
+	}
+
+	t.configFiles = configFiles
+	if err := c.LoadAll(configFiles...); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+func (t *Telegraf) newConfig() *config.Config {
 	c := config.NewConfig()
 	c.Agent.Quiet = t.quiet
 	c.OutputFilters = t.outputFilters
 	c.InputFilters = t.inputFilters
 	c.SecretStoreFilters = t.secretstoreFilters
+	return c
+}
 
+// collectConfigFiles returns the configuration files given on the command
+// line and those found in the configuration directories.
+func (t *Telegraf) collectConfigFiles() ([]string, error) {
 	var configFiles []string
 
 	configFiles = append(configFiles, t.config...)
 	for _, fConfigDirectory := range t.configDir {
-		files, err := config.WalkDirectory(fConfigDirectory)
+		files, err := config.WalkDirectory(fConfigDirectory, t.configExt...)
 		if err != nil {
-			return c, err
+			return nil, err
 		}
 		configFiles = append(configFiles, files...)
 	}
@@ -232,22 +417,112 @@ func (t *Telegraf) loadConfiguration() (*config.Config, error) {
 	if len(configFiles) == 0 {
 		configFiles = append(configFiles, "")
 	}
+	return configFiles, nil
+}
 
-	t.configFiles = configFiles
-	if err := c.LoadAll(configFiles...); err != nil {
-		return c, err
+// CheckConfig validates the configuration without running the agent. All
+// files are loaded and all plugins are initialized, reporting every problem
+// found instead of stopping at the first one.
+func (t *Telegraf) CheckConfig(w io.Writer) error {
+	configFiles, err := t.collectConfigFiles()
+	if err != nil {
+		return err
 	}
-	return c, nil
+
+	c := t.newConfig()
+	c.Agent.Quiet = true
+	issues := c.Check(configFiles...)
+
+	var failed int
+	for _, issue := range issues {
+		if !issue.Warning {
+			failed++
+		}
+	}
+	// Initializing the plugins only makes sense if they could be loaded
+	if failed == 0 {
+		if err := t.checkConfiguration(c); err != nil {
+			issues = append(issues, config.Issue{Message: err.Error()})
+		}
+		for _, err := range agent.NewAgent(c).CheckPlugins() {
+			issues = append(issues, config.Issue{Message: err.Error()})
+		}
+	}
+
+	for _, issue := range issues {
+		fmt.Fprintln(w, issue.String())
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("found %d problem(s) in the configuration", len(issues))
+	}
+	fmt.Fprintf(w, "Configuration OK, loaded %d input(s), %d processor(s), %d aggregator(s) and %d output(s)\n",
+		len(c.Inputs), len(c.Processors)+len(c.AggProcessors), len(c.Aggregators), len(c.Outputs))
+	return nil
 }
 
-func (t *Telegraf) runAgent(ctx context.Context, c *config.Config, reloadConfig bool) error {
-	var err error
-	if reloadConfig {
-		if c, err = t.loadConfiguration(); err != nil {
-			return err
+// Replay feeds the metrics of the recorded files through the processors and
+// aggregators and prints the result or compares it to the expected metrics.
+func (t *Telegraf) Replay(w io.Writer, r ReplayFlags) error {
+	c, err := t.loadConfiguration()
+	if err != nil {
+		return err
+	}
+
+	metrics, err := readRecordedMetrics(c, r)
+	if err != nil {
+		return err
+	}
+
+	result, err := agent.NewAgent(c).Replay(metrics)
+	if err != nil {
+		return err
+	}
+	actual, err := serializeMetrics(result)
+	if err != nil {
+		return fmt.Errorf("serializing result failed: %w", err)
+	}
+
+	if r.expected == "" {
+		for _, line := range actual {
+			fmt.Fprintln(w, line)
 		}
+		return nil
+	}
+
+	buf, err := os.ReadFile(r.expected)
+	if err != nil {
+		return fmt.Errorf("reading expected metrics failed: %w", err)
+	}
+	influxParser, err := c.NewParser("influx", nil)
+	if err != nil {
+		return err
+	}
+	parsed, err := influxParser.Parse(buf)
+	if err != nil {
+		return fmt.Errorf("parsing %q failed: %w", r.expected, err)
 	}
+	expected, err := serializeMetrics(parsed)
+	if err != nil {
+		return fmt.Errorf("serializing expected metrics failed: %w", err)
+	}
+
+	missing, unexpected := diffLines(expected, actual)
+	for _, line := range missing {
+		fmt.Fprintln(w, "-", line)
+	}
+	for _, line := range unexpected {
+		fmt.Fprintln(w, "+", line)
+	}
+	if len(missing) > 0 || len(unexpected) > 0 {
+		return fmt.Errorf("result differs from expected metrics: %d missing, %d unexpected", len(missing), len(unexpected))
+	}
+	fmt.Fprintf(w, "Result matches the %d expected metric(s)\n", len(expected))
+	return nil
+}
 
+// checkConfiguration checks the loaded configuration for settings preventing
+// the agent from running.
+func (t *Telegraf) checkConfiguration(c *config.Config) error {
 	if !(t.test || t.testWait != 0) && len(c.Outputs) == 0 {
 		return errors.New("no outputs found, did you provide a valid config file?")
 	}
@@ -263,6 +538,15 @@ func (t *Telegraf) runAgent(ctx context.Context, c *config.Config, reloadConfig
 		return fmt.Errorf("agent flush_interval must be positive; found %v", c.Agent.Interval)
 	}
 
+	return nil
+}
+
+func (t *Telegraf) runAgent(ctx context.Context, ag *agent.Agent) error {
+	c := ag.Config
+	if err := t.checkConfiguration(c); err != nil {
+		return err
+	}
+
 	// Setup logging as configured.
 	telegraf.Debug = c.Agent.Debug || t.debug
 	logConfig := logger.LogConfig{
@@ -274,6 +558,7 @@ func (t *Telegraf) runAgent(ctx context.Context, c *config.Config, reloadConfig
 		RotationMaxSize:     c.Agent.LogfileRotationMaxSize,
 		RotationMaxArchives: c.Agent.LogfileRotationMaxArchives,
 		LogWithTimezone:     c.Agent.LogWithTimezone,
+		LogFormat:           c.Agent.LogFormat,
 	}
 
 	if err := logger.SetupLogging(logConfig); err != nil {
@@ -328,8 +613,6 @@ func (t *Telegraf) runAgent(ctx context.Context, c *config.Config, reloadConfig
 		log.Printf("W! " + color.RedString(msg))
 	}
 
-	ag := agent.NewAgent(c)
-
 	// Notify systemd that telegraf is ready
 	// SdNotify() only tries to notify if the NOTIFY_SOCKET environment is set, so it's safe to call when systemd isn't present.
 	// Ignore the return values here because they're not valid for platforms that don't use systemd.
@@ -4,16 +4,37 @@ package main
 
 import (
 	"log"
+	"os"
+	"os/signal"
 	"syscall"
 
 	"github.com/urfave/cli/v2"
+
+	"github.com/influxdata/telegraf/logger"
 )
 
 func (t *Telegraf) Run() error {
 	stop = make(chan struct{})
+	watchDebugSignal()
 	return t.reloadLoop()
 }
 
+// watchDebugSignal toggles debug logging for all plugins without their own
+// log level whenever SIGUSR2 is received.
+func watchDebugSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR2)
+	go func() {
+		for range signals {
+			if logger.ToggleDebug() {
+				log.Printf("I! Debug logging enabled")
+			} else {
+				log.Printf("I! Debug logging disabled")
+			}
+		}
+	}()
+}
+
 func cliFlags() []cli.Flag {
 	return []cli.Flag{}
 }
@@ -134,6 +134,9 @@ func (t *Telegraf) runAsWindowsService() error {
 		for _, fConfigDirectory := range t.configDir {
 			svcConfig.Arguments = append(svcConfig.Arguments, "--config-directory", fConfigDirectory)
 		}
+		for _, fConfigExtension := range t.configExt {
+			svcConfig.Arguments = append(svcConfig.Arguments, "--config-directory-extension", fConfigExtension)
+		}
 
 		//set servicename to service cmd line, to have a custom name after relaunch as a service
 		svcConfig.Arguments = append(svcConfig.Arguments, "--service-name", t.serviceName)
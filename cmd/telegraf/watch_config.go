@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/fsnotify.v1"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+var (
+	// configWatchDebounce is the time to wait for further changes in a
+	// config directory before triggering a reload.
+	configWatchDebounce = time.Second
+
+	// configWatchPollInterval is the interval for checking config
+	// directories for changes when polling.
+	configWatchPollInterval = time.Second
+)
+
+// listConfigDirectory returns the subdirectories and config files found below
+// the given directory in the same way as config.WalkDirectory.
+func listConfigDirectory(dir string, extensions []string) (dirs, files []string) {
+	walkfn := func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			// Skip Kubernetes mounts in the same way as when loading
+			if strings.HasPrefix(d.Name(), "..") {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, path)
+			return nil
+		}
+		if config.IsConfigFile(path, extensions...) {
+			files = append(files, path)
+		}
+		return nil
+	}
+	_ = filepath.WalkDir(dir, walkfn)
+
+	return dirs, files
+}
+
+// notifyConfigDirectory reports the names of config files and directories
+// created, deleted or renamed below the given directory using filesystem
+// notifications until the context is done.
+func notifyConfigDirectory(ctx context.Context, dir string, extensions []string) (<-chan string, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	watched := make(map[string]bool)
+	watch := func(root string) {
+		dirs, _ := listConfigDirectory(root, extensions)
+		for _, d := range dirs {
+			if watched[d] {
+				continue
+			}
+			if err := watcher.Add(d); err != nil {
+				log.Printf("W! Cannot watch config directory %s: %s", d, err)
+				continue
+			}
+			watched[d] = true
+		}
+	}
+	watch(dir)
+	if !watched[dir] {
+		watcher.Close()
+		return nil, os.ErrNotExist
+	}
+
+	changes := make(chan string, 1)
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-watcher.Errors:
+				log.Printf("E! Error watching config directory %s: %s", dir, err)
+			case event := <-watcher.Events:
+				const mask = fsnotify.Create | fsnotify.Remove | fsnotify.Rename
+				if event.Op&mask == 0 {
+					continue
+				}
+
+				// Watch new subdirectories and forget about removed ones
+				if event.Op&fsnotify.Create != 0 {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						watch(event.Name)
+						notifyChange(ctx, changes, event.Name)
+						continue
+					}
+				}
+				if watched[event.Name] && event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					delete(watched, event.Name)
+					notifyChange(ctx, changes, event.Name)
+					continue
+				}
+
+				if config.IsConfigFile(event.Name, extensions...) {
+					notifyChange(ctx, changes, event.Name)
+				}
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// pollConfigDirectory reports the names of config files created, deleted or
+// renamed below the given directory by periodically listing the directory
+// until the context is done.
+func pollConfigDirectory(ctx context.Context, dir string, extensions []string, interval time.Duration) (<-chan string, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	list := func() map[string]bool {
+		_, files := listConfigDirectory(dir, extensions)
+		known := make(map[string]bool, len(files))
+		for _, f := range files {
+			known[f] = true
+		}
+		return known
+	}
+	known := list()
+
+	changes := make(chan string, 1)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current := list()
+			for f := range current {
+				if !known[f] {
+					notifyChange(ctx, changes, f)
+				}
+			}
+			for f := range known {
+				if !current[f] {
+					notifyChange(ctx, changes, f)
+				}
+			}
+			known = current
+		}
+	}()
+
+	return changes, nil
+}
+
+func notifyChange(ctx context.Context, changes chan<- string, name string) {
+	select {
+	case changes <- name:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchConfigDirectory(t *testing.T) {
+	configWatchDebounce = 200 * time.Millisecond
+	configWatchPollInterval = 50 * time.Millisecond
+
+	for _, mode := range []string{"notify", "poll"} {
+		t.Run(mode, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "existing.conf"), nil, 0600))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			tg := &Telegraf{GlobalFlags: GlobalFlags{watchConfig: mode}}
+			signals := make(chan os.Signal, 10)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				tg.watchConfigDirectory(ctx, signals, dir)
+			}()
+			// Wait for the watcher to be set up
+			time.Sleep(100 * time.Millisecond)
+
+			// Files not being config files should be ignored
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), nil, 0600))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "data.json"), nil, 0600))
+			time.Sleep(3 * configWatchDebounce)
+			require.Empty(t, signals)
+
+			// A burst of changes in nested directories triggers a single reload
+			sub := filepath.Join(dir, "sub")
+			require.NoError(t, os.Mkdir(sub, 0750))
+			time.Sleep(100 * time.Millisecond)
+			for _, name := range []string{"a.conf", "b.conf", "c.conf"} {
+				require.NoError(t, os.WriteFile(filepath.Join(sub, name), nil, 0600))
+			}
+			require.NoError(t, os.Rename(filepath.Join(sub, "a.conf"), filepath.Join(sub, "d.conf")))
+			require.NoError(t, os.Remove(filepath.Join(dir, "existing.conf")))
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "watcher did not trigger")
+			}
+			require.Len(t, signals, 1)
+			require.Equal(t, syscall.SIGHUP, <-signals)
+		})
+	}
+}
+
+func TestWatchConfigDirectoryNestedFile(t *testing.T) {
+	configWatchDebounce = 100 * time.Millisecond
+
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "a", "b")
+	require.NoError(t, os.MkdirAll(sub, 0750))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tg := &Telegraf{GlobalFlags: GlobalFlags{watchConfig: "notify"}}
+	signals := make(chan os.Signal, 1)
+	go tg.watchConfigDirectory(ctx, signals, dir)
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "nested.conf"), nil, 0600))
+	select {
+	case sig := <-signals:
+		require.Equal(t, syscall.SIGHUP, sig)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "watcher did not trigger")
+	}
+}
+
+func TestWatchConfigDirectoryExtension(t *testing.T) {
+	configWatchDebounce = 100 * time.Millisecond
+
+	dir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tg := &Telegraf{GlobalFlags: GlobalFlags{watchConfig: "notify", configExt: []string{".yaml"}}}
+	signals := make(chan os.Signal, 1)
+	go tg.watchConfigDirectory(ctx, signals, dir)
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data.json"), nil, 0600))
+	time.Sleep(3 * configWatchDebounce)
+	require.Empty(t, signals)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "extra.yaml"), nil, 0600))
+	select {
+	case sig := <-signals:
+		require.Equal(t, syscall.SIGHUP, sig)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "watcher did not trigger")
+	}
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/influxdata/toml/ast"
+
+	"github.com/influxdata/telegraf/models"
+)
+
+// lineRe extracts the line number from the errors of the configuration parser
+var lineRe = regexp.MustCompile(`line (\d+)`)
+
+// Issue is a problem found when checking the configuration.
+type Issue struct {
+	// File and Line locate the problem if known
+	File string
+	Line int
+	// Message describes the problem
+	Message string
+	// Warning is set for problems not preventing Telegraf from running,
+	// e.g. deprecated options
+	Warning bool
+}
+
+func (i Issue) String() string {
+	level := "error"
+	if i.Warning {
+		level = "warning"
+	}
+
+	switch {
+	case i.File != "" && i.Line > 0:
+		return fmt.Sprintf("%s:%d: %s: %s", i.File, i.Line, level, i.Message)
+	case i.File != "":
+		return fmt.Sprintf("%s: %s: %s", i.File, level, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", level, i.Message)
+}
+
+// Check loads the given configuration files in the same way as LoadAll, but
+// continues after errors to report all problems found. Besides errors, the
+// usage of deprecated plugins and options as well as suspicious filter
+// settings are reported as warnings. All secrets are linked, resolving their
+// references in the secret-stores. The plugins are not initialized.
+func (c *Config) Check(files ...string) []Issue {
+	c.checking = true
+	defer func() { c.checking = false }()
+
+	issues := make([]Issue, 0)
+	for _, file := range files {
+		err := c.LoadConfig(file)
+		if err == nil {
+			continue
+		}
+
+		// Strip the file name added when loading the file
+		if inner := errors.Unwrap(err); inner != nil && file != "" {
+			err = inner
+		}
+		issue := Issue{File: file, Message: err.Error()}
+		if match := lineRe.FindStringSubmatch(issue.Message); match != nil {
+			issue.Line, _ = strconv.Atoi(match[1])
+		}
+		issues = append(issues, issue)
+		c.resetLoadErrors()
+	}
+
+	// Warnings collected while loading ordered by their location
+	order := make(map[string]int, len(files))
+	for i, file := range files {
+		order[file] = i
+	}
+	sort.SliceStable(c.issues, func(i, j int) bool {
+		if c.issues[i].File != c.issues[j].File {
+			return order[c.issues[i].File] < order[c.issues[j].File]
+		}
+		return c.issues[i].Line < c.issues[j].Line
+	})
+	issues = append(issues, c.issues...)
+
+	c.completeLoading()
+	if err := models.LinkDeadLetters(c.Outputs); err != nil {
+		issues = append(issues, Issue{Message: err.Error()})
+	}
+	if err := models.LinkFailoverGroups(c.Outputs); err != nil {
+		issues = append(issues, Issue{Message: err.Error()})
+	}
+	if err := models.CheckDiskBuffers(c.Outputs); err != nil {
+		issues = append(issues, Issue{Message: err.Error()})
+	}
+
+	for _, s := range unlinkedSecrets {
+		if err := c.linkSecret(s); err != nil {
+			issues = append(issues, Issue{Message: err.Error()})
+		}
+	}
+	unlinkedSecrets = make([]*Secret, 0)
+
+	return issues
+}
+
+// resetLoadErrors clears the state left by a configuration file failing to
+// load, so the next file can be loaded.
+func (c *Config) resetLoadErrors() {
+	c.errs = nil
+	c.UnusedFields = make(map[string]bool)
+	c.resetMissingTomlFieldTracker()
+}
+
+// checkPlugin handles the outcome of loading the given plugin table. When
+// checking the configuration, the error and the unused fields of the table
+// are recorded as issues and nil is returned to continue with the next table.
+// Otherwise the error is returned.
+func (c *Config) checkPlugin(category, name string, tbl *ast.Table, err error) error {
+	if !c.checking {
+		return err
+	}
+
+	if err != nil {
+		line := tbl.Line
+		if match := lineRe.FindStringSubmatch(err.Error()); match != nil {
+			line, _ = strconv.Atoi(match[1])
+		}
+		c.addIssue(line, false, "plugin %s.%s: %v", category, name, err)
+	}
+	unused := keys(c.UnusedFields)
+	sort.Strings(unused)
+	for _, key := range unused {
+		c.addIssue(fieldLine(tbl, key), false,
+			"plugin %s.%s: configuration specified the field %q, but it wasn't used", category, name, key)
+	}
+	c.resetLoadErrors()
+	return nil
+}
+
+// addIssue records a problem of the file currently loaded to be reported by
+// Check.
+func (c *Config) addIssue(line int, warning bool, format string, args ...interface{}) {
+	c.issues = append(c.issues, Issue{
+		File:    c.currentFile,
+		Line:    line,
+		Message: fmt.Sprintf(format, args...),
+		Warning: warning,
+	})
+}
+
+// fieldLine returns the line of the given field of the table falling back
+// to the line of the table.
+func fieldLine(tbl *ast.Table, key string) int {
+	switch v := tbl.Fields[key].(type) {
+	case *ast.KeyValue:
+		return v.Line
+	case *ast.Table:
+		return v.Line
+	case []*ast.Table:
+		if len(v) > 0 {
+			return v[0].Line
+		}
+	}
+	return tbl.Line
+}
+
+// Options of the metric filtering
+var filterOptions = []string{
+	"namepass", "namedrop",
+	"fieldpass", "fielddrop", "pass", "drop",
+	"tagpass", "tagdrop",
+	"taginclude", "tagexclude",
+	"metricpass",
+}
+
+// checkFilter records common mistakes in the filter settings of the plugin
+// table.
+func (c *Config) checkFilter(tbl *ast.Table) {
+	// In TOML, all options following a sub-table belong to the sub-table, so
+	// filters placed after e.g. the tags are silently used as tags
+	for _, name := range []string{"tagpass", "tagdrop", "tags"} {
+		sub, ok := tbl.Fields[name].(*ast.Table)
+		if !ok {
+			continue
+		}
+		for _, option := range filterOptions {
+			if _, found := sub.Fields[option]; found {
+				c.addIssue(fieldLine(sub, option), true,
+					"filter option %q is part of the %q table, move it before the table to apply it", option, name)
+			}
+		}
+	}
+
+	// Patterns passed and dropped at the same time never pass
+	pairs := [][3]string{
+		{"namepass", "namedrop", "metrics"},
+		{"fieldpass", "fielddrop", "fields"},
+		{"pass", "drop", "fields"},
+		{"taginclude", "tagexclude", "tags"},
+	}
+	for _, pair := range pairs {
+		dropped := make(map[string]bool)
+		for _, pattern := range stringValues(tbl, pair[1]) {
+			dropped[pattern] = true
+		}
+		for _, pattern := range stringValues(tbl, pair[0]) {
+			if dropped[pattern] {
+				c.addIssue(fieldLine(tbl, pair[1]), true,
+					"pattern %q is used in both %q and %q, matching %s are always dropped", pattern, pair[0], pair[1], pair[2])
+			}
+		}
+	}
+
+	for _, option := range []string{"namepass", "namedrop", "fieldpass", "fielddrop", "pass", "drop", "taginclude", "tagexclude"} {
+		for _, pattern := range stringValues(tbl, option) {
+			if pattern == "" {
+				c.addIssue(fieldLine(tbl, option), true, "empty pattern in %q only matches empty names", option)
+			}
+		}
+	}
+	for _, name := range []string{"tagpass", "tagdrop"} {
+		sub, ok := tbl.Fields[name].(*ast.Table)
+		if !ok {
+			continue
+		}
+		for key := range sub.Fields {
+			if values := stringValues(sub, key); len(values) == 0 {
+				c.addIssue(fieldLine(sub, key), true, "tag %q of %q has no values and never matches", key, name)
+			}
+		}
+	}
+}
+
+// stringValues returns the strings of the array field of the table.
+func stringValues(tbl *ast.Table, key string) []string {
+	kv, ok := tbl.Fields[key].(*ast.KeyValue)
+	if !ok {
+		return nil
+	}
+	ary, ok := kv.Value.(*ast.Array)
+	if !ok {
+		return nil
+	}
+
+	values := make([]string, 0, len(ary.Value))
+	for _, elem := range ary.Value {
+		if str, ok := elem.(*ast.String); ok {
+			values = append(values, str.Value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Check(t *testing.T) {
+	c := NewConfig()
+	c.Agent.Quiet = true
+	issues := c.Check("./testdata/invalid_field.toml", "./testdata/check_filters.toml")
+
+	expected := []Issue{
+		{
+			File:    "./testdata/invalid_field.toml",
+			Line:    2,
+			Message: `plugin inputs.http_listener_v2: configuration specified the field "not_a_field", but it wasn't used`,
+		},
+		{
+			File:    "./testdata/check_filters.toml",
+			Line:    4,
+			Message: `pattern "mem" is used in both "namepass" and "namedrop", matching metrics are always dropped`,
+			Warning: true,
+		},
+		{
+			File:    "./testdata/check_filters.toml",
+			Line:    8,
+			Message: `filter option "fieldpass" is part of the "tagpass" table, move it before the table to apply it`,
+			Warning: true,
+		},
+		{
+			File:    "./testdata/check_filters.toml",
+			Line:    12,
+			Message: `empty pattern in "fielddrop" only matches empty names`,
+			Warning: true,
+		},
+		{
+			File:    "./testdata/check_filters.toml",
+			Line:    15,
+			Message: `tag "host" of "tagpass" has no values and never matches`,
+			Warning: true,
+		},
+	}
+	require.Equal(t, expected, issues)
+
+	// The plugins of the valid file are loaded despite the broken one
+	names := make([]string, 0, len(c.Inputs))
+	for _, input := range c.Inputs {
+		names = append(names, input.Config.Name)
+	}
+	require.Subset(t, names, []string{"memcached", "file"})
+}
+
+func TestConfig_CheckContinuesAfterPluginErrors(t *testing.T) {
+	c := NewConfig()
+	c.Agent.Quiet = true
+	issues := c.Check("./testdata/check_plugins.toml")
+
+	// All broken plugin tables are reported at their location
+	lines := make([]int, 0, len(issues))
+	for _, issue := range issues {
+		require.False(t, issue.Warning, issue.String())
+		lines = append(lines, issue.Line)
+	}
+	require.ElementsMatch(t, []int{2, 7, 9}, lines)
+
+	// The valid plugins of the file are loaded
+	names := make([]string, 0, len(c.Inputs))
+	for _, input := range c.Inputs {
+		names = append(names, input.Config.Name)
+	}
+	require.Subset(t, names, []string{"memcached"})
+}
+
+func TestConfig_CheckDeadLetterFailoverStandby(t *testing.T) {
+	c := NewConfig()
+	c.Agent.Quiet = true
+	issues := c.Check("./testdata/check_dead_letter.toml")
+	require.Equal(t, []Issue{
+		{Message: `dead-letter output "standby" of outputs.http must not be a standby of failover group "influx"`},
+	}, issues)
+}
+
+func TestIssueString(t *testing.T) {
+	require.Equal(t, "a.conf:3: warning: deprecated", Issue{File: "a.conf", Line: 3, Message: "deprecated", Warning: true}.String())
+	require.Equal(t, "a.conf: error: broken", Issue{File: "a.conf", Message: "broken"}.String())
+	require.Equal(t, "error: unknown secret-store", Issue{Message: "unknown secret-store"}.String())
+}
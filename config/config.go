@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
@@ -56,6 +57,13 @@ var (
 	Password Secret
 )
 
+// Supported formats of config files
+const (
+	FormatTOML = "toml"
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
 // Config specifies the URL/user/password for the database that telegraf
 // will be logging to, as well as all the plugins that the user has
 // specified
@@ -71,6 +79,27 @@ type Config struct {
 	SecretStoreFilters []string
 
 	SecretStores map[string]telegraf.SecretStore
+	// Generated IDs of the secret-store configurations by store ID
+	secretStoreConfigIDs map[string]string
+	// Loggers of the secret-stores by store ID
+	secretStoreLoggers map[string]*models.Logger
+	// Secrets referencing secret-stores with the plugins they belong to
+	secrets []ownedSecret
+
+	// Configuration snippets by name and defaults by plugin type, e.g.
+	// "outputs.http", applied to the plugin tables before building them
+	templates      map[string]*ast.Table
+	pluginDefaults map[string]*ast.Table
+
+	// File currently loaded and the problems found by the checks while
+	// loading, reported by Check. When checking, loading continues with the
+	// next plugin table after an error.
+	currentFile string
+	issues      []Issue
+	checking    bool
+
+	// Data of all loaded files to recreate the configuration
+	sources []Source
 
 	Agent       *AgentConfig
 	Inputs      []*models.RunningInput
@@ -90,9 +119,20 @@ type Config struct {
 
 	Persister *persister.Persister
 
+	// Recorder capturing the data passed to the parsers of the inputs, opened
+	// by the agent if a capture file is configured
+	Recorder *models.Recorder
+
 	NumberSecrets uint64
 }
 
+// Source is the data of a configuration file or URL as loaded.
+type Source struct {
+	Path   string
+	Data   []byte
+	Format string
+}
+
 // Ordered plugins used to keep the order in which they appear in a file
 type OrderedPlugin struct {
 	Line   int
@@ -119,20 +159,26 @@ func NewConfig() *Config {
 			FlushInterval:              Duration(10 * time.Second),
 			LogTarget:                  "file",
 			LogfileRotationMaxArchives: 5,
+			RecordRotationMaxArchives:  5,
 		},
 
-		Tags:               make(map[string]string),
-		Inputs:             make([]*models.RunningInput, 0),
-		Outputs:            make([]*models.RunningOutput, 0),
-		Processors:         make([]*models.RunningProcessor, 0),
-		AggProcessors:      make([]*models.RunningProcessor, 0),
-		SecretStores:       make(map[string]telegraf.SecretStore),
-		fileProcessors:     make([]*OrderedPlugin, 0),
-		fileAggProcessors:  make([]*OrderedPlugin, 0),
-		InputFilters:       make([]string, 0),
-		OutputFilters:      make([]string, 0),
-		SecretStoreFilters: make([]string, 0),
-		Deprecations:       make(map[string][]int64),
+		Tags:                 make(map[string]string),
+		Inputs:               make([]*models.RunningInput, 0),
+		Outputs:              make([]*models.RunningOutput, 0),
+		Processors:           make([]*models.RunningProcessor, 0),
+		AggProcessors:        make([]*models.RunningProcessor, 0),
+		SecretStores:         make(map[string]telegraf.SecretStore),
+		secretStoreConfigIDs: make(map[string]string),
+		secretStoreLoggers:   make(map[string]*models.Logger),
+		templates:            make(map[string]*ast.Table),
+		pluginDefaults:       make(map[string]*ast.Table),
+		fileProcessors:       make([]*OrderedPlugin, 0),
+		fileAggProcessors:    make([]*OrderedPlugin, 0),
+		Recorder:             models.NewRecorder(),
+		InputFilters:         make([]string, 0),
+		OutputFilters:        make([]string, 0),
+		SecretStoreFilters:   make([]string, 0),
+		Deprecations:         make(map[string][]int64),
 	}
 
 	// Handle unknown version
@@ -205,6 +251,21 @@ type AgentConfig struct {
 	// not be less than 2 times MetricBatchSize.
 	MetricBufferLimit int
 
+	// BufferStrategy is the type of buffer used by outputs to keep unsent
+	// metrics. Can be "memory" (default) or "disk" to persist unsent metrics
+	// across restarts in the BufferDirectory.
+	BufferStrategy string `toml:"buffer_strategy"`
+
+	// BufferDirectory is the directory used by the "disk" buffer strategy
+	// to store unsent metrics. Each output uses a sub-directory named after
+	// its plugin ID.
+	BufferDirectory string `toml:"buffer_directory"`
+
+	// BufferSizeLimit is the maximum size in bytes of each output's disk
+	// buffer. When exceeded, the oldest metrics are dropped. A value of zero
+	// disables the size limit.
+	BufferSizeLimit Size `toml:"buffer_size_limit"`
+
 	// FlushBufferWhenFull tells Telegraf to flush the metric buffer whenever
 	// it fills up, regardless of FlushInterval. Setting this option to true
 	// does _not_ deactivate FlushInterval.
@@ -245,6 +306,9 @@ type AgentConfig struct {
 	// Pick a timezone to use when logging or type 'local' for local time.
 	LogWithTimezone string `toml:"log_with_timezone"`
 
+	// Format of the log messages, can be "text", "json" or "logfmt".
+	LogFormat string `toml:"log_format"`
+
 	Hostname     string
 	OmitHostname bool
 
@@ -257,6 +321,44 @@ type AgentConfig struct {
 	// stateful plugins on termination of Telegraf. If the file exists on start,
 	// the state in the file will be restored for the plugins.
 	Statefile string `toml:"statefile"`
+
+	// Interval for periodically storing the state of plugins to the statefile
+	// in addition to storing the state on termination. This limits the loss
+	// of state in case Telegraf crashes or is killed. When set to 0 no
+	// periodic checkpoints are performed.
+	StatefileInterval Duration `toml:"statefile_interval"`
+
+	// Address of the management API to inspect and control the running
+	// agent, e.g. "localhost:8180". The API is disabled if empty and only
+	// listens on the loopback interface if no host is given.
+	ManagementAddress string `toml:"management_address"`
+
+	// Bearer token required for all requests to the management API.
+	ManagementToken Secret `toml:"management_token"`
+
+	// Name of the file capturing the raw data passed to the parsers of the
+	// inputs along with metadata such as the MQTT topic. Only inputs with
+	// the "record" option are captured unless Record is set.
+	RecordFile string `toml:"record_file"`
+
+	// Capture the data of all inputs to the RecordFile.
+	Record bool `toml:"record"`
+
+	// The capture file will be rotated after the time interval specified.
+	// When set to 0 no time based rotation is performed.
+	RecordRotationInterval Duration `toml:"record_rotation_interval"`
+
+	// The capture file will be rotated when it becomes larger than the
+	// specified size. When set to 0 no size based rotation is performed.
+	RecordRotationMaxSize Size `toml:"record_rotation_max_size"`
+
+	// Maximum number of rotated capture files to keep, any older files are
+	// deleted. If set to -1, no archives are removed.
+	RecordRotationMaxArchives int `toml:"record_rotation_max_archives"`
+
+	// Interval for checking the secret-stores for changed secrets, e.g.
+	// rotated credentials. When set to 0 secrets are not refreshed.
+	SecretRefreshInterval Duration `toml:"secret_refresh_interval"`
 }
 
 // InputNames returns a list of strings of the configured inputs.
@@ -346,8 +448,9 @@ func sliceContains(name string, list []string) bool {
 	return false
 }
 
-// WalkDirectory collects all toml files that need to be loaded
-func WalkDirectory(path string) ([]string, error) {
+// WalkDirectory collects all config files that need to be loaded, i.e. the
+// ".conf" files and the files with one of the given additional extensions.
+func WalkDirectory(path string, extensions ...string) ([]string, error) {
 	var files []string
 	walkfn := func(thispath string, info os.FileInfo, _ error) error {
 		if info == nil {
@@ -363,8 +466,7 @@ func WalkDirectory(path string) ([]string, error) {
 
 			return nil
 		}
-		name := info.Name()
-		if len(name) < 6 || name[len(name)-5:] != ".conf" {
+		if !IsConfigFile(thispath, extensions...) {
 			return nil
 		}
 		files = append(files, thispath)
@@ -373,6 +475,26 @@ func WalkDirectory(path string) ([]string, error) {
 	return files, filepath.Walk(path, walkfn)
 }
 
+// IsConfigFile returns true if the file is loaded from config directories,
+// i.e. if it has a ".conf" extension or one of the given additional
+// extensions, e.g. ".yaml", and a non-empty name besides the extension.
+func IsConfigFile(path string, extensions ...string) bool {
+	name := filepath.Base(path)
+	ext := filepath.Ext(name)
+	if len(name) == len(ext) {
+		return false
+	}
+	if ext == ".conf" {
+		return true
+	}
+	for _, e := range extensions {
+		if strings.EqualFold(ext, "."+strings.TrimPrefix(e, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
 // Try to find a default config file at these locations (in order):
 //  1. $TELEGRAF_CONFIG_PATH
 //  2. $HOME/.telegraf/telegraf.conf
@@ -447,26 +569,86 @@ func (c *Config) LoadConfig(path string) error {
 			log.Printf("I! Loading config: %s", path)
 		}
 
-		data, err := LoadConfigFile(path)
+		data, format, err := loadConfigFile(path)
 		if err != nil {
 			return fmt.Errorf("error loading config file %s: %w", path, err)
 		}
 
-		if err = c.LoadConfigData(data); err != nil {
-			return fmt.Errorf("error loading config file %s: %w", path, err)
+		if err := c.loadSource(Source{Path: path, Data: data, Format: format}); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+func (c *Config) loadSource(source Source) error {
+	c.currentFile = source.Path
+	if err := c.LoadConfigDataWithFormat(source.Data, source.Format); err != nil {
+		return fmt.Errorf("error loading config file %s: %w", source.Path, err)
+	}
+	c.sources = append(c.sources, source)
+	return nil
+}
+
 func (c *Config) LoadAll(configFiles ...string) error {
 	for _, fConfig := range configFiles {
 		if err := c.LoadConfig(fConfig); err != nil {
+			// Forget about the secrets of the broken configuration to not
+			// link them on the next attempt, e.g. when reloading
+			unlinkedSecrets = make([]*Secret, 0)
 			return err
 		}
 	}
+	return c.finishLoading()
+}
+
+// LoadSources loads the configuration from the sources of a previously loaded
+// configuration, e.g. to create new instances of its plugins without reading
+// the files again.
+func (c *Config) LoadSources(sources []Source) error {
+	for _, source := range sources {
+		if err := c.loadSource(source); err != nil {
+			unlinkedSecrets = make([]*Secret, 0)
+			return err
+		}
+	}
+	return c.finishLoading()
+}
+
+// Sources returns the data of all files the configuration was loaded from.
+func (c *Config) Sources() []Source {
+	return c.sources
+}
+
+// UpdateSources takes over the sources of the next configuration after
+// applying it to the running plugins.
+func (c *Config) UpdateSources(next *Config) {
+	c.sources = next.sources
+}
 
+// finishLoading completes the configuration after loading all files and links
+// the plugins to each other and the secrets to their stores.
+func (c *Config) finishLoading() error {
+	c.completeLoading()
+
+	// Connect the outputs to their dead-letter outputs and failover groups
+	if err := models.LinkDeadLetters(c.Outputs); err != nil {
+		return err
+	}
+	if err := models.LinkFailoverGroups(c.Outputs); err != nil {
+		return err
+	}
+	if err := models.CheckDiskBuffers(c.Outputs); err != nil {
+		return err
+	}
+
+	// Let's link all secrets to their secret-stores
+	return c.LinkSecrets()
+}
+
+// completeLoading finalizes the configuration after loading all files.
+func (c *Config) completeLoading() {
 	// Sort the processors according to their `order` setting while
 	// using a stable sort to keep the file loading / file position order.
 	sort.Stable(c.Processors)
@@ -479,14 +661,27 @@ func (c *Config) LoadAll(configFiles ...string) error {
 
 	// Check if there is enough lockable memory for the secret
 	c.NumberSecrets = uint64(secretCount.Load())
-
-	// Let's link all secrets to their secret-stores
-	return c.LinkSecrets()
 }
 
 // LoadConfigData loads TOML-formatted config data
 func (c *Config) LoadConfigData(data []byte) error {
-	tbl, err := parseConfig(data)
+	return c.LoadConfigDataWithFormat(data, FormatTOML)
+}
+
+// LoadConfigDataWithFormat loads config data in the given format, i.e.
+// FormatTOML, FormatYAML or FormatJSON. All formats describe the same tables
+// as the TOML format.
+func (c *Config) LoadConfigDataWithFormat(data []byte, format string) error {
+	var tbl *ast.Table
+	var err error
+	switch format {
+	case FormatTOML:
+		tbl, err = parseConfig(data)
+	case FormatYAML, FormatJSON:
+		tbl, err = parseYAMLConfig(data)
+	default:
+		return fmt.Errorf("unsupported config format %q", format)
+	}
 	if err != nil {
 		return fmt.Errorf("error parsing data: %w", err)
 	}
@@ -541,6 +736,7 @@ func (c *Config) LoadConfigData(data []byte) error {
 	if c.Agent.Statefile != "" {
 		c.Persister = &persister.Persister{
 			Filename: c.Agent.Statefile,
+			Interval: time.Duration(c.Agent.StatefileInterval),
 		}
 	}
 
@@ -548,30 +744,45 @@ func (c *Config) LoadConfigData(data []byte) error {
 		return fmt.Errorf("line %d: configuration specified the fields %q, but they weren't used", tbl.Line, keys(c.UnusedFields))
 	}
 
+	// Register the templates and plugin defaults before building any plugin
+	if val, ok := tbl.Fields["templates"]; ok {
+		if err := c.addTemplates(val); err != nil {
+			return err
+		}
+	}
+	if val, ok := tbl.Fields["defaults"]; ok {
+		if err := c.addPluginDefaults(val); err != nil {
+			return err
+		}
+	}
+
 	// Initialize the file-sorting slices
 	c.fileProcessors = make(OrderedPlugins, 0)
 	c.fileAggProcessors = make(OrderedPlugins, 0)
 
 	// Parse all the rest of the plugins:
 	for name, val := range tbl.Fields {
+		if name == "templates" {
+			continue
+		}
 		subTable, ok := val.(*ast.Table)
 		if !ok {
 			return fmt.Errorf("invalid configuration, error parsing field %q as table", name)
 		}
 
 		switch name {
-		case "agent", "global_tags", "tags":
+		case "agent", "global_tags", "tags", "defaults":
 		case "outputs":
 			for pluginName, pluginVal := range subTable.Fields {
 				switch pluginSubTable := pluginVal.(type) {
 				// legacy [outputs.influxdb] support
 				case *ast.Table:
-					if err = c.addOutput(pluginName, pluginSubTable); err != nil {
+					if err = c.checkPlugin(name, pluginName, pluginSubTable, c.addOutput(pluginName, pluginSubTable)); err != nil {
 						return fmt.Errorf("error parsing %s, %w", pluginName, err)
 					}
 				case []*ast.Table:
 					for _, t := range pluginSubTable {
-						if err = c.addOutput(pluginName, t); err != nil {
+						if err = c.checkPlugin(name, pluginName, t, c.addOutput(pluginName, t)); err != nil {
 							return fmt.Errorf("error parsing %s array, %w", pluginName, err)
 						}
 					}
@@ -589,12 +800,12 @@ func (c *Config) LoadConfigData(data []byte) error {
 				switch pluginSubTable := pluginVal.(type) {
 				// legacy [inputs.cpu] support
 				case *ast.Table:
-					if err = c.addInput(pluginName, pluginSubTable); err != nil {
+					if err = c.checkPlugin(name, pluginName, pluginSubTable, c.addInput(pluginName, pluginSubTable)); err != nil {
 						return fmt.Errorf("error parsing %s, %w", pluginName, err)
 					}
 				case []*ast.Table:
 					for _, t := range pluginSubTable {
-						if err = c.addInput(pluginName, t); err != nil {
+						if err = c.checkPlugin(name, pluginName, t, c.addInput(pluginName, t)); err != nil {
 							return fmt.Errorf("error parsing %s, %w", pluginName, err)
 						}
 					}
@@ -612,7 +823,7 @@ func (c *Config) LoadConfigData(data []byte) error {
 				switch pluginSubTable := pluginVal.(type) {
 				case []*ast.Table:
 					for _, t := range pluginSubTable {
-						if err = c.addProcessor(pluginName, t); err != nil {
+						if err = c.checkPlugin(name, pluginName, t, c.addProcessor(pluginName, t)); err != nil {
 							return fmt.Errorf("error parsing %s, %w", pluginName, err)
 						}
 					}
@@ -635,7 +846,7 @@ func (c *Config) LoadConfigData(data []byte) error {
 				switch pluginSubTable := pluginVal.(type) {
 				case []*ast.Table:
 					for _, t := range pluginSubTable {
-						if err = c.addAggregator(pluginName, t); err != nil {
+						if err = c.checkPlugin(name, pluginName, t, c.addAggregator(pluginName, t)); err != nil {
 							return fmt.Errorf("error parsing %s, %w", pluginName, err)
 						}
 					}
@@ -653,7 +864,7 @@ func (c *Config) LoadConfigData(data []byte) error {
 				switch pluginSubTable := pluginVal.(type) {
 				case []*ast.Table:
 					for _, t := range pluginSubTable {
-						if err = c.addSecretStore(pluginName, t); err != nil {
+						if err = c.checkPlugin(name, pluginName, t, c.addSecretStore(pluginName, t)); err != nil {
 							return fmt.Errorf("error parsing %s, %w", pluginName, err)
 						}
 					}
@@ -669,7 +880,7 @@ func (c *Config) LoadConfigData(data []byte) error {
 		// Assume it's an input for legacy config file support if no other
 		// identifiers are present
 		default:
-			if err = c.addInput(name, subTable); err != nil {
+			if err = c.checkPlugin("inputs", name, subTable, c.addInput(name, subTable)); err != nil {
 				return fmt.Errorf("error parsing %s, %w", name, err)
 			}
 		}
@@ -703,67 +914,107 @@ func escapeEnv(value string) string {
 }
 
 func LoadConfigFile(config string) ([]byte, error) {
+	data, _, err := loadConfigFile(config)
+	return data, err
+}
+
+// loadConfigFile reads the config from the given file or URL and returns the
+// data along with its format.
+func loadConfigFile(config string) ([]byte, string, error) {
 	if fetchURLRe.MatchString(config) {
 		u, err := url.Parse(config)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		switch u.Scheme {
 		case "https", "http":
 			return fetchConfig(u)
 		default:
-			return nil, fmt.Errorf("scheme %q not supported", u.Scheme)
+			return nil, "", fmt.Errorf("scheme %q not supported", u.Scheme)
 		}
 	}
 
 	// If it isn't a https scheme, try it as a file
 	buffer, err := os.ReadFile(config)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
+	format := formatFromExtension(config)
 	mimeType := http.DetectContentType(buffer)
 	if !strings.Contains(mimeType, "text/plain") {
-		return nil, fmt.Errorf("provided config is not a TOML file: %s", config)
+		return nil, "", fmt.Errorf("provided config is not a %s file: %s", strings.ToUpper(format), config)
 	}
 
-	return buffer, nil
+	return buffer, format, nil
 }
 
-func fetchConfig(u *url.URL) ([]byte, error) {
+// formatFromExtension returns the config format for the extension of the
+// given path defaulting to TOML.
+func formatFromExtension(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	}
+	return FormatTOML
+}
+
+// formatFromContentType returns the config format for the given content-type
+// of a remote config. Generic content-types fall back to the extension of the
+// URL path.
+func formatFromContentType(contentType string, u *url.URL) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return formatFromExtension(u.Path)
+	}
+
+	switch mediaType {
+	case "application/toml":
+		return FormatTOML
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return FormatYAML
+	case "application/json":
+		return FormatJSON
+	}
+	return formatFromExtension(u.Path)
+}
+
+func fetchConfig(u *url.URL) ([]byte, string, error) {
 	req, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	if v, exists := os.LookupEnv("INFLUX_TOKEN"); exists {
 		req.Header.Add("Authorization", "Token "+v)
 	}
-	req.Header.Add("Accept", "application/toml")
+	req.Header.Add("Accept", "application/toml, application/yaml;q=0.9, application/json;q=0.9")
 	req.Header.Set("User-Agent", internal.ProductToken())
 
 	retries := 3
 	for i := 0; i <= retries; i++ {
-		body, err, retry := func() ([]byte, error, bool) {
+		body, format, err, retry := func() ([]byte, string, error, bool) {
 			resp, err := http.DefaultClient.Do(req)
 			if err != nil {
-				return nil, fmt.Errorf("retry %d of %d failed connecting to HTTP config server: %w", i, retries, err), false
+				return nil, "", fmt.Errorf("retry %d of %d failed connecting to HTTP config server: %w", i, retries, err), false
 			}
 			defer resp.Body.Close()
 			if resp.StatusCode != http.StatusOK {
 				if i < retries {
 					log.Printf("Error getting HTTP config.  Retry %d of %d in %s.  Status=%d", i, retries, httpLoadConfigRetryInterval, resp.StatusCode)
-					return nil, nil, true
+					return nil, "", nil, true
 				}
-				return nil, fmt.Errorf("retry %d of %d failed to retrieve remote config: %s", i, retries, resp.Status), false
+				return nil, "", fmt.Errorf("retry %d of %d failed to retrieve remote config: %s", i, retries, resp.Status), false
 			}
 			body, err := io.ReadAll(resp.Body)
-			return body, err, false
+			return body, formatFromContentType(resp.Header.Get("Content-Type"), u), err, false
 		}()
 
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		if retry {
@@ -771,10 +1022,10 @@ func fetchConfig(u *url.URL) ([]byte, error) {
 			continue
 		}
 
-		return body, err
+		return body, format, err
 	}
 
-	return nil, nil
+	return nil, "", nil
 }
 
 // parseConfig loads a TOML configuration from a provided path and
@@ -809,6 +1060,11 @@ func parseConfig(contents []byte) (*ast.Table, error) {
 }
 
 func (c *Config) addAggregator(name string, table *ast.Table) error {
+	table, err := c.applyTemplates("aggregators", name, table)
+	if err != nil {
+		return err
+	}
+
 	creator, ok := aggregators.Aggregators[name]
 	if !ok {
 		// Handle removed, deprecated plugins
@@ -819,6 +1075,7 @@ func (c *Config) addAggregator(name string, table *ast.Table) error {
 		return fmt.Errorf("undefined but requested aggregator: %s", name)
 	}
 	aggregator := creator()
+	mark := len(unlinkedSecrets)
 
 	conf, err := c.buildAggregator(name, table)
 	if err != nil {
@@ -829,11 +1086,13 @@ func (c *Config) addAggregator(name string, table *ast.Table) error {
 		return err
 	}
 
-	if err := c.printUserDeprecation("aggregators", name, aggregator); err != nil {
+	if err := c.printUserDeprecation("aggregators", name, aggregator, table); err != nil {
 		return err
 	}
 
-	c.Aggregators = append(c.Aggregators, models.NewRunningAggregator(aggregator, conf))
+	ra := models.NewRunningAggregator(aggregator, conf)
+	c.trackSecrets(mark, ra.Aggregator, ra.LogName())
+	c.Aggregators = append(c.Aggregators, ra)
 	return nil
 }
 
@@ -842,6 +1101,11 @@ func (c *Config) addSecretStore(name string, table *ast.Table) error {
 		return nil
 	}
 
+	table, err := c.applyTemplates("secretstores", name, table)
+	if err != nil {
+		return err
+	}
+
 	var storeid string
 	c.getFieldString(table, "id", &storeid)
 	if storeid == "" {
@@ -861,14 +1125,32 @@ func (c *Config) addSecretStore(name string, table *ast.Table) error {
 		return fmt.Errorf("undefined but requested secretstores: %s", name)
 	}
 	store := creator(storeid)
+	mark := len(unlinkedSecrets)
 
 	if err := c.toml.UnmarshalTable(table, store); err != nil {
 		return err
 	}
 
-	if err := c.printUserDeprecation("secretstores", name, store); err != nil {
+	if err := c.printUserDeprecation("secretstores", name, store, table); err != nil {
+		return err
+	}
+
+	configID, err := generatePluginID("secretstores."+name, table)
+	if err != nil {
+		return err
+	}
+
+	var level string
+	c.getFieldLogLevel(table, "log_level", &level)
+	if c.hasErrs() {
+		return c.firstErr()
+	}
+	logger := models.NewLogger("secretstores", name, "")
+	logger.Attributes.ID = configID
+	if err := logger.SetLevel(level); err != nil {
 		return err
 	}
+	models.SetLoggerOnPlugin(store, logger)
 
 	if err := store.Init(); err != nil {
 		return fmt.Errorf("error initializing secret-store %q: %w", storeid, err)
@@ -878,29 +1160,46 @@ func (c *Config) addSecretStore(name string, table *ast.Table) error {
 		return fmt.Errorf("duplicate ID %q for secretstore %q", storeid, name)
 	}
 	c.SecretStores[storeid] = store
+	c.trackSecrets(mark, store, logger.Name)
+	c.secretStoreConfigIDs[storeid] = configID
+	c.secretStoreLoggers[storeid] = logger
 	return nil
 }
 
 func (c *Config) LinkSecrets() error {
+	// The secrets are linked only once, a subsequently loaded configuration
+	// must not touch them anymore.
+	defer func() {
+		unlinkedSecrets = make([]*Secret, 0)
+	}()
+
 	for _, s := range unlinkedSecrets {
-		resolvers := make(map[string]telegraf.ResolveFunc)
-		for _, ref := range s.GetUnlinked() {
-			// Split the reference and lookup the resolver
-			storeid, key := splitLink(ref)
-			store, found := c.SecretStores[storeid]
-			if !found {
-				return fmt.Errorf("unknown secret-store for %q", ref)
-			}
-			resolver, err := store.GetResolver(key)
-			if err != nil {
-				return fmt.Errorf("retrieving resolver for %q failed: %w", ref, err)
-			}
-			resolvers[ref] = resolver
+		if err := c.linkSecret(s); err != nil {
+			return err
 		}
-		// Inject the resolver list into the secret
-		if err := s.Link(resolvers); err != nil {
-			return fmt.Errorf("retrieving resolver failed: %w", err)
+	}
+	return nil
+}
+
+// linkSecret links the references of the secret to their secret-stores.
+func (c *Config) linkSecret(s *Secret) error {
+	resolvers := make(map[string]telegraf.ResolveFunc)
+	for _, ref := range s.GetUnlinked() {
+		// Split the reference and lookup the resolver
+		storeid, key := splitLink(ref)
+		store, found := c.SecretStores[storeid]
+		if !found {
+			return fmt.Errorf("unknown secret-store for %q", ref)
+		}
+		resolver, err := store.GetResolver(key)
+		if err != nil {
+			return fmt.Errorf("retrieving resolver for %q failed: %w", ref, err)
 		}
+		resolvers[ref] = resolver
+	}
+	// Inject the resolver list into the secret
+	if err := s.Link(resolvers); err != nil {
+		return fmt.Errorf("retrieving resolver failed: %w", err)
 	}
 	return nil
 }
@@ -961,10 +1260,42 @@ func (c *Config) addParser(parentcategory, parentname string, table *ast.Table)
 		DataFormat: dataformat,
 	}
 	running := models.NewRunningParser(parser, conf)
+	if parentcategory == "inputs" {
+		c.getFieldBool(table, "record", &conf.Record)
+		running.Recorder = c.Recorder
+	}
 	err := running.Init()
 	return running, err
 }
 
+// NewParser creates a parser from TOML settings containing the "data_format"
+// and the parser options as given in input plugins, e.g. to parse recorded
+// data outside of an input. Metrics without name are named "replay". A
+// non-empty data format given as argument overrides the one of the settings.
+func (c *Config) NewParser(dataformat string, settings []byte) (*models.RunningParser, error) {
+	tbl, err := parseConfig(settings)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing data: %w", err)
+	}
+	if dataformat != "" {
+		tbl.Fields["data_format"] = &ast.KeyValue{
+			Key:   "data_format",
+			Value: &ast.String{Value: dataformat, Data: []rune(quoteTOMLString(dataformat))},
+			Line:  tbl.Line,
+		}
+	}
+
+	c.resetLoadErrors()
+	parser, err := c.addParser("inputs", "replay", tbl)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.UnusedFields) > 0 {
+		return nil, fmt.Errorf("parser settings specified the fields %q, but they weren't used", keys(c.UnusedFields))
+	}
+	return parser, nil
+}
+
 func (c *Config) addSerializer(parentname string, table *ast.Table) (*models.RunningSerializer, error) {
 	var dataformat string
 	c.getFieldString(table, "data_format", &dataformat)
@@ -992,6 +1323,11 @@ func (c *Config) addSerializer(parentname string, table *ast.Table) (*models.Run
 }
 
 func (c *Config) addProcessor(name string, table *ast.Table) error {
+	table, err := c.applyTemplates("processors", name, table)
+	if err != nil {
+		return err
+	}
+
 	creator, ok := processors.Processors[name]
 	if !ok {
 		// Handle removed, deprecated plugins
@@ -1018,11 +1354,13 @@ func (c *Config) addProcessor(name string, table *ast.Table) error {
 	if err != nil {
 		return err
 	}
+	mark := len(unlinkedSecrets)
 	processorBefore, hasParser, err := c.setupProcessor(processorBeforeConfig.Name, creator, table)
 	if err != nil {
 		return err
 	}
 	rf := models.NewRunningProcessor(processorBefore, processorBeforeConfig)
+	c.trackSecrets(mark, rf.Processor, rf.LogName())
 	c.fileProcessors = append(c.fileProcessors, &OrderedPlugin{table.Line, rf})
 
 	// Setup another (new) processor instance running after the aggregator
@@ -1030,11 +1368,13 @@ func (c *Config) addProcessor(name string, table *ast.Table) error {
 	if err != nil {
 		return err
 	}
+	mark = len(unlinkedSecrets)
 	processorAfter, _, err := c.setupProcessor(processorAfterConfig.Name, creator, table)
 	if err != nil {
 		return err
 	}
 	rf = models.NewRunningProcessor(processorAfter, processorAfterConfig)
+	c.trackSecrets(mark, rf.Processor, rf.LogName())
 	c.fileAggProcessors = append(c.fileAggProcessors, &OrderedPlugin{table.Line, rf})
 
 	// Check the number of misses against the threshold
@@ -1091,7 +1431,7 @@ func (c *Config) setupProcessor(name string, creator processors.StreamingCreator
 		return nil, hasParser, fmt.Errorf("unmarshalling failed: %w", err)
 	}
 
-	err := c.printUserDeprecation("processors", name, processor)
+	err := c.printUserDeprecation("processors", name, processor, table)
 	return streamingProcessor, hasParser, err
 }
 
@@ -1100,6 +1440,11 @@ func (c *Config) addOutput(name string, table *ast.Table) error {
 		return nil
 	}
 
+	table, err := c.applyTemplates("outputs", name, table)
+	if err != nil {
+		return err
+	}
+
 	// For inputs with parsers we need to compute the set of
 	// options that is not covered by both, the parser and the input.
 	// We achieve this by keeping a local book of missing entries
@@ -1121,6 +1466,7 @@ func (c *Config) addOutput(name string, table *ast.Table) error {
 		return fmt.Errorf("undefined but requested output: %s", name)
 	}
 	output := creator()
+	mark := len(unlinkedSecrets)
 
 	// If the output has a SetSerializer function, then this means it can write
 	// arbitrary types of output, so build the serializer and set it.
@@ -1163,7 +1509,7 @@ func (c *Config) addOutput(name string, table *ast.Table) error {
 		return err
 	}
 
-	if err := c.printUserDeprecation("outputs", name, output); err != nil {
+	if err := c.printUserDeprecation("outputs", name, output, table); err != nil {
 		return err
 	}
 
@@ -1184,6 +1530,7 @@ func (c *Config) addOutput(name string, table *ast.Table) error {
 	}
 
 	ro := models.NewRunningOutput(output, outputConfig, c.Agent.MetricBatchSize, c.Agent.MetricBufferLimit)
+	c.trackSecrets(mark, ro.Output, ro.LogName())
 	c.Outputs = append(c.Outputs, ro)
 
 	return nil
@@ -1194,6 +1541,11 @@ func (c *Config) addInput(name string, table *ast.Table) error {
 		return nil
 	}
 
+	table, err := c.applyTemplates("inputs", name, table)
+	if err != nil {
+		return err
+	}
+
 	// For inputs with parsers we need to compute the set of
 	// options that is not covered by both, the parser and the input.
 	// We achieve this by keeping a local book of missing entries
@@ -1216,6 +1568,7 @@ func (c *Config) addInput(name string, table *ast.Table) error {
 		return fmt.Errorf("undefined but requested input: %s", name)
 	}
 	input := creator()
+	mark := len(unlinkedSecrets)
 
 	// If the input has a SetParser or SetParserFunc function, it can accept
 	// arbitrary data-formats, so build the requested parser and set it.
@@ -1269,7 +1622,7 @@ func (c *Config) addInput(name string, table *ast.Table) error {
 		return err
 	}
 
-	if err := c.printUserDeprecation("inputs", name, input); err != nil {
+	if err := c.printUserDeprecation("inputs", name, input, table); err != nil {
 		return err
 	}
 
@@ -1291,6 +1644,7 @@ func (c *Config) addInput(name string, table *ast.Table) error {
 
 	rp := models.NewRunningInput(input, pluginConfig)
 	rp.SetDefaultTags(c.Tags)
+	c.trackSecrets(mark, rp.Input, rp.LogName())
 	c.Inputs = append(c.Inputs, rp)
 
 	return nil
@@ -1315,6 +1669,7 @@ func (c *Config) buildAggregator(name string, tbl *ast.Table) (*models.Aggregato
 	c.getFieldString(tbl, "name_suffix", &conf.MeasurementSuffix)
 	c.getFieldString(tbl, "name_override", &conf.NameOverride)
 	c.getFieldString(tbl, "alias", &conf.Alias)
+	c.getFieldLogLevel(tbl, "log_level", &conf.LogLevel)
 
 	conf.Tags = make(map[string]string)
 	if node, ok := tbl.Fields["tags"]; ok {
@@ -1348,6 +1703,7 @@ func (c *Config) buildProcessor(category, name string, tbl *ast.Table) (*models.
 
 	c.getFieldInt64(tbl, "order", &conf.Order)
 	c.getFieldString(tbl, "alias", &conf.Alias)
+	c.getFieldLogLevel(tbl, "log_level", &conf.LogLevel)
 
 	if c.hasErrs() {
 		return nil, c.firstErr()
@@ -1369,6 +1725,8 @@ func (c *Config) buildProcessor(category, name string, tbl *ast.Table) (*models.
 // be inserted into the models.OutputConfig/models.InputConfig
 // to be used for glob filtering on tags and measurements
 func (c *Config) buildFilter(tbl *ast.Table) (models.Filter, error) {
+	c.checkFilter(tbl)
+
 	f := models.Filter{}
 
 	c.getFieldStringSlice(tbl, "namepass", &f.NamePass)
@@ -1408,10 +1766,14 @@ func (c *Config) buildInput(name string, tbl *ast.Table) (*models.InputConfig, e
 	c.getFieldDuration(tbl, "precision", &cp.Precision)
 	c.getFieldDuration(tbl, "collection_jitter", &cp.CollectionJitter)
 	c.getFieldDuration(tbl, "collection_offset", &cp.CollectionOffset)
+	c.getFieldDuration(tbl, "gather_timeout", &cp.GatherTimeout)
+	c.getCardinalityConfig(tbl, &cp.Cardinality)
+	c.getFieldBool(tbl, "record", &cp.Record)
 	c.getFieldString(tbl, "name_prefix", &cp.MeasurementPrefix)
 	c.getFieldString(tbl, "name_suffix", &cp.MeasurementSuffix)
 	c.getFieldString(tbl, "name_override", &cp.NameOverride)
 	c.getFieldString(tbl, "alias", &cp.Alias)
+	c.getFieldLogLevel(tbl, "log_level", &cp.LogLevel)
 
 	cp.Tags = make(map[string]string)
 	if node, ok := tbl.Fields["tags"]; ok {
@@ -1426,6 +1788,10 @@ func (c *Config) buildInput(name string, tbl *ast.Table) (*models.InputConfig, e
 		return nil, c.firstErr()
 	}
 
+	if err := cp.Cardinality.Validate(); err != nil {
+		return nil, err
+	}
+
 	var err error
 	cp.Filter, err = c.buildFilter(tbl)
 	if err != nil {
@@ -1503,8 +1869,11 @@ func (c *Config) buildOutput(name string, tbl *ast.Table) (*models.OutputConfig,
 		return nil, err
 	}
 	oc := &models.OutputConfig{
-		Name:   name,
-		Filter: filter,
+		Name:            name,
+		Filter:          filter,
+		BufferStrategy:  c.Agent.BufferStrategy,
+		BufferDirectory: c.Agent.BufferDirectory,
+		BufferSizeLimit: int64(c.Agent.BufferSizeLimit),
 	}
 
 	// TODO: support FieldPass/FieldDrop on outputs
@@ -1514,15 +1883,42 @@ func (c *Config) buildOutput(name string, tbl *ast.Table) (*models.OutputConfig,
 
 	c.getFieldInt(tbl, "metric_buffer_limit", &oc.MetricBufferLimit)
 	c.getFieldInt(tbl, "metric_batch_size", &oc.MetricBatchSize)
+	c.getFieldString(tbl, "buffer_strategy", &oc.BufferStrategy)
+	c.getFieldString(tbl, "buffer_directory", &oc.BufferDirectory)
+	c.getFieldSize(tbl, "buffer_size_limit", &oc.BufferSizeLimit)
+	c.getFieldString(tbl, "buffer_id", &oc.BufferID)
 	c.getFieldString(tbl, "alias", &oc.Alias)
+	c.getFieldLogLevel(tbl, "log_level", &oc.LogLevel)
 	c.getFieldString(tbl, "name_override", &oc.NameOverride)
 	c.getFieldString(tbl, "name_suffix", &oc.NameSuffix)
 	c.getFieldString(tbl, "name_prefix", &oc.NamePrefix)
+	c.getFieldString(tbl, "route", &oc.Route.Condition)
+	c.getFieldBool(tbl, "route_default", &oc.Route.Default)
+	c.getFieldString(tbl, "startup_error_behavior", &oc.StartupErrorBehavior)
+	c.getFieldInt(tbl, "retry_max_attempts", &oc.RetryPolicy.MaxAttempts)
+	c.getFieldDuration(tbl, "retry_backoff", &oc.RetryPolicy.Backoff)
+	c.getFieldDuration(tbl, "retry_backoff_max", &oc.RetryPolicy.BackoffMax)
+	c.getFieldInt(tbl, "circuit_breaker_threshold", &oc.RetryPolicy.BreakerThreshold)
+	c.getFieldDuration(tbl, "circuit_breaker_timeout", &oc.RetryPolicy.BreakerTimeout)
+	c.getFieldString(tbl, "dead_letter", &oc.DeadLetter)
+	c.getFieldString(tbl, "failover_group", &oc.FailoverGroup)
+	c.getFieldInt(tbl, "concurrency", &oc.Concurrency)
+	c.getCardinalityConfig(tbl, &oc.Cardinality)
 
 	if c.hasErrs() {
 		return nil, c.firstErr()
 	}
 
+	if err := oc.Route.Compile(); err != nil {
+		return nil, err
+	}
+	if err := oc.RetryPolicy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := oc.Cardinality.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Generate an ID for the plugin
 	oc.ID, err = generatePluginID("outputs."+name, tbl)
 	return oc, err
@@ -1532,16 +1928,24 @@ func (c *Config) missingTomlField(_ reflect.Type, key string) error {
 	switch key {
 	// General options to ignore
 	case "alias",
-		"collection_jitter", "collection_offset",
-		"data_format", "delay", "drop", "drop_original",
-		"fielddrop", "fieldpass", "flush_interval", "flush_jitter",
-		"grace",
+		"buffer_directory", "buffer_id", "buffer_size_limit", "buffer_strategy",
+		"cardinality_action", "cardinality_fold_value", "cardinality_limit", "cardinality_reset_interval",
+		"circuit_breaker_threshold", "circuit_breaker_timeout",
+		"collection_jitter", "collection_offset", "concurrency",
+		"data_format", "dead_letter", "delay", "drop", "drop_original",
+		"failover_group", "fielddrop", "fieldpass", "flush_interval", "flush_jitter",
+		"gather_timeout", "grace",
 		"interval",
+		"log_level",
 		"lvm", // What is this used for?
 		"metric_batch_size", "metric_buffer_limit", "metricpass",
 		"name_override", "name_prefix", "name_suffix", "namedrop", "namepass",
 		"order",
 		"pass", "period", "precision",
+		"record",
+		"retry_backoff", "retry_backoff_max", "retry_max_attempts",
+		"route", "route_default",
+		"startup_error_behavior",
 		"tagdrop", "tagexclude", "taginclude", "tagpass", "tags":
 
 	// Secret-store options to ignore
@@ -1614,6 +2018,16 @@ func (c *Config) getFieldString(tbl *ast.Table, fieldName string, target *string
 	}
 }
 
+func (c *Config) getFieldLogLevel(tbl *ast.Table, fieldName string, target *string) {
+	var level string
+	c.getFieldString(tbl, fieldName, &level)
+	if err := models.ValidateLogLevel(level); err != nil {
+		c.addError(tbl, err)
+		return
+	}
+	*target = level
+}
+
 func (c *Config) getFieldDuration(tbl *ast.Table, fieldName string, target interface{}) {
 	if node, ok := tbl.Fields[fieldName]; ok {
 		if kv, ok := node.(*ast.KeyValue); ok {
@@ -1630,6 +2044,15 @@ func (c *Config) getFieldDuration(tbl *ast.Table, fieldName string, target inter
 	}
 }
 
+// getCardinalityConfig reads the generic cardinality limiter options of inputs
+// and outputs.
+func (c *Config) getCardinalityConfig(tbl *ast.Table, cfg *models.CardinalityConfig) {
+	c.getFieldInt(tbl, "cardinality_limit", &cfg.Limit)
+	c.getFieldString(tbl, "cardinality_action", &cfg.Action)
+	c.getFieldString(tbl, "cardinality_fold_value", &cfg.FoldValue)
+	c.getFieldDuration(tbl, "cardinality_reset_interval", &cfg.ResetInterval)
+}
+
 func (c *Config) getFieldBool(tbl *ast.Table, fieldName string, target *bool) {
 	var err error
 	if node, ok := tbl.Fields[fieldName]; ok {
@@ -1687,6 +2110,30 @@ func (c *Config) getFieldInt64(tbl *ast.Table, fieldName string, target *int64)
 	}
 }
 
+func (c *Config) getFieldSize(tbl *ast.Table, fieldName string, target *int64) {
+	if node, ok := tbl.Fields[fieldName]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			var size Size
+			switch v := kv.Value.(type) {
+			case *ast.Integer:
+				if err := size.UnmarshalText([]byte(v.Value)); err != nil {
+					c.addError(tbl, fmt.Errorf("error parsing size: %w", err))
+					return
+				}
+			case *ast.String:
+				if err := size.UnmarshalText([]byte(v.Value)); err != nil {
+					c.addError(tbl, fmt.Errorf("error parsing size: %w", err))
+					return
+				}
+			default:
+				c.addError(tbl, fmt.Errorf("found unexpected format while parsing %q, expecting size", fieldName))
+				return
+			}
+			*target = int64(size)
+		}
+	}
+}
+
 func (c *Config) getFieldStringSlice(tbl *ast.Table, fieldName string, target *[]string) {
 	if node, ok := tbl.Fields[fieldName]; ok {
 		if kv, ok := node.(*ast.KeyValue); ok {
@@ -146,6 +146,86 @@ func TestConfig_LoadSingleInput(t *testing.T) {
 	require.Equal(t, inputConfig, c.Inputs[0].Config, "Testdata did not produce correct memcached metadata.")
 }
 
+func TestConfig_LoadSingleInputYAMLJSON(t *testing.T) {
+	expected := NewConfig()
+	require.NoError(t, expected.LoadConfig("./testdata/single_plugin.toml"))
+	require.Len(t, expected.Inputs, 1)
+	expected.Inputs[0].Config.ID = ""
+
+	for _, filename := range []string{"./testdata/single_plugin.yaml", "./testdata/single_plugin.json"} {
+		t.Run(filepath.Ext(filename), func(t *testing.T) {
+			c := NewConfig()
+			require.NoError(t, c.LoadConfig(filename))
+			require.Len(t, c.Inputs, 1)
+
+			// Ignore Log, Parser and ID
+			c.Inputs[0].Input.(*MockupInputPlugin).Log = nil
+			c.Inputs[0].Input.(*MockupInputPlugin).parser = nil
+			c.Inputs[0].Config.ID = ""
+			expected.Inputs[0].Input.(*MockupInputPlugin).Log = nil
+			expected.Inputs[0].Input.(*MockupInputPlugin).parser = nil
+			require.Equal(t, expected.Inputs[0].Input, c.Inputs[0].Input)
+			require.Equal(t, expected.Inputs[0].Config, c.Inputs[0].Config)
+		})
+	}
+}
+
+func TestConfig_LoadSources(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadAll("./testdata/single_plugin.toml", "./testdata/single_plugin.yaml"))
+	require.Len(t, c.Sources(), 2)
+	require.Equal(t, "./testdata/single_plugin.yaml", c.Sources()[1].Path)
+	require.Equal(t, FormatYAML, c.Sources()[1].Format)
+
+	// Recreating the configuration creates new instances of the same plugins
+	restored := NewConfig()
+	require.NoError(t, restored.LoadSources(c.Sources()))
+	require.Len(t, restored.Inputs, 2)
+	for i, input := range restored.Inputs {
+		require.Equal(t, c.Inputs[i].Config.ID, input.Config.ID)
+		require.NotSame(t, c.Inputs[i].Input, input.Input)
+	}
+	require.Equal(t, c.Sources(), restored.Sources())
+}
+
+func TestConfig_IsConfigFile(t *testing.T) {
+	for _, path := range []string{"a.conf", "dir/a.conf"} {
+		require.True(t, IsConfigFile(path), path)
+	}
+	for _, path := range []string{".conf", "a.yaml", "a.json", "a.md", "a.conf.bak", "conf"} {
+		require.False(t, IsConfigFile(path), path)
+	}
+
+	// Other formats are only loaded if enabled explicitly
+	for _, path := range []string{"a.conf", "a.yaml", "a.yml", "A.YAML", "a.json"} {
+		require.True(t, IsConfigFile(path, ".yaml", "yml", ".json"), path)
+	}
+	for _, path := range []string{"dir/.yaml", "a.md", "a.yaml.bak"} {
+		require.False(t, IsConfigFile(path, ".yaml", "yml", ".json"), path)
+	}
+}
+
+func TestConfig_LoadDirectoryIgnoresOtherFormats(t *testing.T) {
+	dir := t.TempDir()
+	cfg := []byte("[[inputs.memcached]]\n  servers = [\"localhost\"]\n")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "telegraf.conf"), cfg, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data.json"), []byte(`{"unrelated": true}`), 0600))
+
+	// Unrelated JSON files are not loaded by default
+	files, err := WalkDirectory(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "telegraf.conf")}, files)
+
+	c := NewConfig()
+	require.NoError(t, c.LoadAll(files...))
+	require.Len(t, c.Inputs, 1)
+
+	// Enabling the extension loads the file
+	files, err = WalkDirectory(dir, ".json")
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "data.json"), filepath.Join(dir, "telegraf.conf")}, files)
+}
+
 func TestConfig_LoadDirectory(t *testing.T) {
 	c := NewConfig()
 
@@ -289,6 +369,31 @@ func TestConfig_DefaultExecParser(t *testing.T) {
 	require.NoError(t, c.LoadConfig("./testdata/default_parser_exec.toml"))
 }
 
+func TestConfig_NewParser(t *testing.T) {
+	c := NewConfig()
+	settings := []byte(`
+data_format = "influx"
+csv_header_row_count = 1
+csv_timestamp_column = "time"
+csv_timestamp_format = "unix"
+`)
+	parser, err := c.NewParser("csv", settings)
+	require.NoError(t, err)
+	require.Equal(t, "csv", parser.Config.DataFormat)
+
+	metrics, err := parser.Parse([]byte("time,value\n1700000000,42\n"))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	require.Equal(t, "replay", metrics[0].Name())
+	require.Equal(t, int64(1700000000), metrics[0].Time().Unix())
+
+	_, err = c.NewParser("influx", []byte("not_a_field = true"))
+	require.ErrorContains(t, err, `parser settings specified the fields ["not_a_field"], but they weren't used`)
+
+	_, err = c.NewParser("unknown", nil)
+	require.ErrorContains(t, err, "undefined but requested parser: unknown")
+}
+
 func TestConfig_LoadSpecialTypes(t *testing.T) {
 	c := NewConfig()
 	require.NoError(t, c.LoadConfig("./testdata/special_types.toml"))
@@ -364,6 +469,16 @@ func TestConfig_FieldNotDefined(t *testing.T) {
 			filename: "./testdata/invalid_field_processor_in_parserfunc_table.toml",
 			expected: `line 1: configuration specified the fields ["not_a_field"], but they weren't used`,
 		},
+		{
+			name:     "in input plugin of YAML file",
+			filename: "./testdata/invalid_field.yaml",
+			expected: `line 1: configuration specified the fields ["not_a_field"], but they weren't used`,
+		},
+		{
+			name:     "in input plugin of JSON file",
+			filename: "./testdata/invalid_field.json",
+			expected: `line 2: configuration specified the fields ["not_a_field"], but they weren't used`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -397,6 +512,85 @@ func TestConfig_WrongFieldType(t *testing.T) {
 	)
 }
 
+func TestConfig_Templates(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfig("./testdata/templates.toml"))
+	require.Len(t, c.Inputs, 1)
+	require.Len(t, c.Outputs, 2)
+
+	// Settings of the plugin override those of the templates
+	input := c.Inputs[0].Input.(*MockupInputPlugin)
+	require.Equal(t, []string{"localhost"}, input.Servers)
+	require.Equal(t, []models.TagFilter{{Name: "env", Values: []string{"prod"}}}, stripTagFilters(c.Inputs[0].Config.Filter.TagPassFilters))
+
+	// Defaults apply to all plugins of the type and are merged with the
+	// tables of the plugin
+	output := c.Outputs[0].Output.(*MockupOuputPlugin)
+	require.Equal(t, "http://example.org", output.URL)
+	require.Equal(t, map[string]string{"X-Org": "acme", "Content-Type": "text/plain"}, output.Headers)
+	require.Equal(t, "acme", output.NamespacePrefix)
+	require.Equal(t, []string{"read", "write"}, output.Scopes)
+	require.Empty(t, c.Outputs[0].Config.Filter.TagPassFilters)
+
+	output = c.Outputs[1].Output.(*MockupOuputPlugin)
+	require.Equal(t, "http://example.com", output.URL)
+	require.Equal(t, map[string]string{"X-Org": "acme", "Content-Type": "application/json"}, output.Headers)
+	require.Equal(t, "other", output.NamespacePrefix)
+	require.Equal(t, []string{"read", "write"}, output.Scopes)
+	require.Equal(t, []models.TagFilter{{Name: "env", Values: []string{"prod"}}}, stripTagFilters(c.Outputs[1].Config.Filter.TagPassFilters))
+
+	// Templates are available in the files loaded afterwards
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  use_templates = ["prod_only"]
+  servers = ["other"]
+`)))
+	require.Len(t, c.Inputs, 2)
+	require.Len(t, c.Inputs[1].Config.Filter.TagPassFilters, 1)
+}
+
+func TestConfig_TemplatesErrors(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfig("./testdata/templates_unknown.toml")
+	require.ErrorContains(t, err, `line 2: template "missing" not found`)
+
+	// Unknown settings in templates are reported like those of the plugin
+	c = NewConfig()
+	err = c.LoadConfigData([]byte(`
+[[templates]]
+  name = "broken"
+  not_a_field = true
+
+[[inputs.memcached]]
+  use_templates = ["broken"]
+`))
+	require.ErrorContains(t, err, `configuration specified the fields ["not_a_field"], but they weren't used`)
+
+	c = NewConfig()
+	err = c.LoadConfigData([]byte(`
+[[templates]]
+  name = "twice"
+[[templates]]
+  name = "twice"
+`))
+	require.ErrorContains(t, err, `template "twice" is defined more than once`)
+
+	c = NewConfig()
+	err = c.LoadConfigData([]byte(`
+[defaults.sinks.http]
+  url = "http://example.org"
+`))
+	require.ErrorContains(t, err, `invalid plugin type "sinks" for defaults`)
+}
+
+func stripTagFilters(filters []models.TagFilter) []models.TagFilter {
+	stripped := make([]models.TagFilter, 0, len(filters))
+	for _, f := range filters {
+		stripped = append(stripped, models.TagFilter{Name: f.Name, Values: f.Values})
+	}
+	return stripped
+}
+
 func TestConfig_InlineTables(t *testing.T) {
 	// #4098
 	c := NewConfig()
@@ -434,6 +628,155 @@ func TestConfig_BadOrdering(t *testing.T) {
 	)
 }
 
+func TestConfig_LogLevel(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+  log_level = "debug"
+`)))
+	require.Len(t, c.Inputs, 2)
+	require.Empty(t, c.Inputs[0].LogLevel())
+	require.Equal(t, "debug", c.Inputs[1].LogLevel())
+
+	// The log level is not part of the plugin ID
+	require.Equal(t, c.Inputs[0].Config.ID, c.Inputs[1].Config.ID)
+
+	c = NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  log_level = "verbose"
+`))
+	require.ErrorContains(t, err, `invalid log level "verbose"`)
+}
+
+func TestConfig_OutputRoute(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[outputs.http]]
+  alias = "tenant_a"
+  route = 'tags.tenant == "a"'
+
+[[outputs.http]]
+  alias = "other"
+  route_default = true
+`)))
+	require.Len(t, c.Outputs, 2)
+	require.Equal(t, `tags.tenant == "a"`, c.Outputs[0].Config.Route.Condition)
+	require.False(t, c.Outputs[0].Config.Route.Default)
+	require.Empty(t, c.Outputs[1].Config.Route.Condition)
+	require.True(t, c.Outputs[1].Config.Route.Default)
+
+	c = NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[outputs.http]]
+  route = 'tags.tenant'
+`))
+	require.ErrorContains(t, err, "compiling route failed")
+}
+
+func TestConfig_OutputRetryPolicy(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[outputs.http]]
+  retry_max_attempts = 3
+  retry_backoff = "1s"
+  circuit_breaker_threshold = 5
+  circuit_breaker_timeout = "2m"
+`)))
+	require.Len(t, c.Outputs, 1)
+	expected := models.RetryPolicy{
+		MaxAttempts:      3,
+		Backoff:          time.Second,
+		BackoffMax:       5 * time.Minute,
+		BreakerThreshold: 5,
+		BreakerTimeout:   2 * time.Minute,
+	}
+	require.Equal(t, expected, c.Outputs[0].Config.RetryPolicy)
+
+	c = NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[outputs.http]]
+  retry_backoff = "1m"
+  retry_backoff_max = "1s"
+`))
+	require.ErrorContains(t, err, "must not be shorter than retry_backoff")
+}
+
+func TestConfig_OutputDeadLetter(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[outputs.http]]
+  dead_letter = "dlq"
+
+[[outputs.http]]
+  alias = "dlq"
+`)))
+	require.Len(t, c.Outputs, 2)
+	require.Equal(t, "dlq", c.Outputs[0].Config.DeadLetter)
+	require.NoError(t, models.LinkDeadLetters(c.Outputs))
+
+	c = NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[outputs.http]]
+  dead_letter = "unknown"
+`)))
+	require.ErrorContains(t, models.LinkDeadLetters(c.Outputs), `dead-letter output "unknown" of outputs.http not found`)
+}
+
+func TestConfig_OutputFailoverGroup(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[outputs.http]]
+  alias = "primary"
+  failover_group = "influx"
+
+[[outputs.http]]
+  alias = "standby"
+  failover_group = "influx"
+`)))
+	require.Len(t, c.Outputs, 2)
+	require.NoError(t, models.LinkFailoverGroups(c.Outputs))
+	require.Equal(t, "influx", c.Outputs[0].FailoverGroup())
+	require.False(t, c.Outputs[0].IsFailoverStandby())
+	require.True(t, c.Outputs[1].IsFailoverStandby())
+}
+
+func TestConfig_CardinalityLimit(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[inputs.memcached]]
+  cardinality_limit = 100
+  cardinality_action = "fold"
+  cardinality_reset_interval = "1h"
+
+[[outputs.http]]
+  cardinality_limit = 10
+`)))
+	require.Len(t, c.Inputs, 1)
+	expected := models.CardinalityConfig{
+		Limit:         100,
+		Action:        "fold",
+		FoldValue:     "other",
+		ResetInterval: time.Hour,
+	}
+	require.Equal(t, expected, c.Inputs[0].Config.Cardinality)
+	require.Len(t, c.Outputs, 1)
+	require.Equal(t, 10, c.Outputs[0].Config.Cardinality.Limit)
+	require.Equal(t, "drop", c.Outputs[0].Config.Cardinality.Action)
+
+	c = NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[outputs.http]]
+  cardinality_limit = 10
+  cardinality_action = "foo"
+`))
+	require.ErrorContains(t, err, `invalid cardinality action "foo"`)
+}
+
 func TestConfig_AzureMonitorNamespacePrefix(t *testing.T) {
 	// #8256 Cannot use empty string as the namespace prefix
 	c := NewConfig()
@@ -448,6 +791,41 @@ func TestConfig_AzureMonitorNamespacePrefix(t *testing.T) {
 	}
 }
 
+func TestConfig_OutputBufferStrategy(t *testing.T) {
+	cfg := []byte(`
+[agent]
+  buffer_strategy = "disk"
+  buffer_directory = "/var/lib/telegraf/buffer"
+
+[[outputs.http]]
+
+[[outputs.http]]
+  buffer_strategy = "memory"
+
+[[outputs.http]]
+  buffer_directory = "/tmp/buffer"
+  buffer_size_limit = "10MiB"
+`)
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData(cfg))
+	require.Len(t, c.Outputs, 3)
+
+	expected := []struct {
+		strategy  string
+		directory string
+		sizeLimit int64
+	}{
+		{"disk", "/var/lib/telegraf/buffer", 0},
+		{"memory", "/var/lib/telegraf/buffer", 0},
+		{"disk", "/tmp/buffer", 10 * 1024 * 1024},
+	}
+	for i, output := range c.Outputs {
+		require.Equal(t, expected[i].strategy, output.Config.BufferStrategy)
+		require.Equal(t, expected[i].directory, output.Config.BufferDirectory)
+		require.Equal(t, expected[i].sizeLimit, output.Config.BufferSizeLimit)
+	}
+}
+
 func TestConfig_URLRetries3Fails(t *testing.T) {
 	httpLoadConfigRetryInterval = 0 * time.Second
 	responseCounter := 0
@@ -484,6 +862,43 @@ func TestConfig_URLRetries3FailsThenPasses(t *testing.T) {
 	require.Equal(t, 4, responseCounter)
 }
 
+func TestConfig_URLContentType(t *testing.T) {
+	yamlConfig, err := os.ReadFile("./testdata/single_plugin.yaml")
+	require.NoError(t, err)
+	jsonConfig, err := os.ReadFile("./testdata/single_plugin.json")
+	require.NoError(t, err)
+
+	var accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		switch r.URL.Path {
+		case "/yaml":
+			w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+			_, _ = w.Write(yamlConfig)
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(jsonConfig)
+		case "/config.yml":
+			// Generic content-types fall back to the extension of the path
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write(yamlConfig)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	for _, path := range []string{"/yaml", "/json", "/config.yml"} {
+		t.Run(path, func(t *testing.T) {
+			c := NewConfig()
+			require.NoError(t, c.LoadConfig(ts.URL+path))
+			require.Len(t, c.Inputs, 1)
+			require.Equal(t, []string{"localhost"}, c.Inputs[0].Input.(*MockupInputPlugin).Servers)
+			require.Contains(t, accept, "application/yaml")
+		})
+	}
+}
+
 func TestConfig_getDefaultConfigPathFromEnvURL(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -1750,3 +2165,26 @@ func init() {
 		return &MockupOutputPluginSerializerOld{}
 	})
 }
+
+func TestConfig_RestartRequiredManagementToken(t *testing.T) {
+	load := func(token string) *Config {
+		c := NewConfig()
+		require.NoError(t, c.LoadConfigData([]byte(`
+[agent]
+  management_address = ":8180"
+  management_token = "`+token+`"
+`)))
+		return c
+	}
+
+	c := load("secret")
+	_, required := c.RestartRequired(load("secret"))
+	require.False(t, required)
+
+	reason, required := c.RestartRequired(load("other"))
+	require.True(t, required)
+	require.Equal(t, "agent settings changed", reason)
+
+	_, required = c.RestartRequired(load(""))
+	require.True(t, required)
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/coreos/go-semver/semver"
 	"github.com/fatih/color"
+	"github.com/influxdata/toml/ast"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/models"
@@ -166,20 +167,28 @@ func (c *Config) collectDeprecationInfo(category, name string, plugin interface{
 	return info
 }
 
-func (c *Config) printUserDeprecation(category, name string, plugin interface{}) error {
+func (c *Config) printUserDeprecation(category, name string, plugin interface{}, table *ast.Table) error {
 	info := c.collectDeprecationInfo(category, name, plugin, false)
 	models.PrintPluginDeprecationNotice(info.LogLevel, info.Name, info.info)
 
 	if info.LogLevel == telegraf.Error {
 		return fmt.Errorf("plugin deprecated")
 	}
+	if info.LogLevel == telegraf.Warn {
+		c.addIssue(table.Line, true, "plugin %s is deprecated since version %s: %s",
+			info.Name, info.info.Since, info.info.Notice)
+	}
 
 	// Print deprecated options
 	deprecatedOptions := make([]string, 0)
 	for _, option := range info.Options {
 		models.PrintOptionDeprecationNotice(option.LogLevel, info.Name, option.Name, option.info)
-		if option.LogLevel == telegraf.Error {
+		switch option.LogLevel {
+		case telegraf.Error:
 			deprecatedOptions = append(deprecatedOptions, option.Name)
+		case telegraf.Warn:
+			c.addIssue(fieldLine(table, option.Name), true, "option %q of plugin %s is deprecated since version %s: %s",
+				option.Name, info.Name, option.info.Since, option.info.Notice)
 		}
 	}
 
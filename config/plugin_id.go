@@ -66,6 +66,15 @@ func generatePluginID(prefix string, table *ast.Table) (string, error) {
 	}
 	sort.SliceStable(cfg, func(i, j int) bool { return cfg[i].Key < cfg[j].Key })
 
+	// The log level does not affect the plugin and can be changed at runtime,
+	// so exclude it to keep the ID stable when only the level changes.
+	for i, kv := range cfg {
+		if kv.Key == "log_level" {
+			cfg = append(cfg[:i], cfg[i+1:]...)
+			break
+		}
+	}
+
 	// Hash the config options to get the ID. We also prefix the ID with
 	// the plugin name to prevent overlap with other plugin types.
 	hash := sha256.New()
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"bytes"
+	"log"
+	"reflect"
+)
+
+// RestartRequired checks if the next configuration differs from the current
+// one in settings that cannot be applied to a running agent, i.e. the agent
+// settings, the global tags or the secret-stores. If so, the function returns
+// the reason and true.
+func (c *Config) RestartRequired(next *Config) (string, bool) {
+	if !agentSettingsEqual(c.Agent, next.Agent) {
+		return "agent settings changed", true
+	}
+	if !reflect.DeepEqual(c.Tags, next.Tags) {
+		return "global tags changed", true
+	}
+	if !reflect.DeepEqual(c.secretStoreConfigIDs, next.secretStoreConfigIDs) {
+		return "secret-stores changed", true
+	}
+	return "", false
+}
+
+// agentSettingsEqual compares the agent settings. Secrets are compared by
+// their content as their protected representation differs between loads.
+func agentSettingsEqual(current, next *AgentConfig) bool {
+	if current == nil || next == nil {
+		return current == next
+	}
+	a, b := *current, *next
+	a.ManagementToken, b.ManagementToken = Secret{}, Secret{}
+	if !reflect.DeepEqual(&a, &b) {
+		return false
+	}
+	if current.ManagementToken.Empty() || next.ManagementToken.Empty() {
+		return current.ManagementToken.Empty() == next.ManagementToken.Empty()
+	}
+	currentToken, err := current.ManagementToken.Get()
+	if err != nil {
+		return false
+	}
+	defer ReleaseSecret(currentToken)
+	nextToken, err := next.ManagementToken.Get()
+	if err != nil {
+		return false
+	}
+	defer ReleaseSecret(nextToken)
+	return bytes.Equal(currentToken, nextToken)
+}
+
+// leveledPlugin is a running plugin with an adjustable log level.
+type leveledPlugin interface {
+	ID() string
+	LogName() string
+	LogLevel() string
+	SetLogLevel(level string) error
+}
+
+// UpdateLogLevels applies the log levels of the next configuration to the
+// running plugins and secret-stores with the same configuration and returns
+// the names of the plugins with a changed log level. Plugins with an
+// identical configuration are matched in order.
+func (c *Config) UpdateLogLevels(next *Config) []string {
+	var changed []string
+	changed = append(changed, updateLogLevels(c.Inputs, next.Inputs)...)
+	changed = append(changed, updateLogLevels(c.Processors, next.Processors)...)
+	changed = append(changed, updateLogLevels(c.AggProcessors, next.AggProcessors)...)
+	changed = append(changed, updateLogLevels(c.Aggregators, next.Aggregators)...)
+	changed = append(changed, updateLogLevels(c.Outputs, next.Outputs)...)
+
+	for storeid, logger := range c.secretStoreLoggers {
+		nextLogger, found := next.secretStoreLoggers[storeid]
+		if !found || c.secretStoreConfigIDs[storeid] != next.secretStoreConfigIDs[storeid] {
+			continue
+		}
+		if level := nextLogger.Level(); level != logger.Level() {
+			// The level is validated when loading the configuration
+			_ = logger.SetLevel(level)
+			changed = append(changed, logger.Name)
+		}
+	}
+
+	return changed
+}
+
+func updateLogLevels[T leveledPlugin](current, next []T) []string {
+	levels := make(map[string][]string, len(next))
+	for _, p := range next {
+		levels[p.ID()] = append(levels[p.ID()], p.LogLevel())
+	}
+
+	var changed []string
+	for _, p := range current {
+		candidates := levels[p.ID()]
+		if len(candidates) == 0 {
+			continue
+		}
+		levels[p.ID()] = candidates[1:]
+
+		if p.LogLevel() == candidates[0] {
+			continue
+		}
+		if err := p.SetLogLevel(candidates[0]); err != nil {
+			log.Printf("E! [agent] Changing log level of %s failed: %v", p.LogName(), err)
+			continue
+		}
+		changed = append(changed, p.LogName())
+	}
+	return changed
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/awnumar/memguard"
@@ -28,6 +29,9 @@ var secretPattern = regexp.MustCompile(`@\{(\w+:\w+)\}`)
 
 var secretCount atomic.Int64
 
+// secretsLock protects the content of secrets being refreshed while in use
+var secretsLock sync.RWMutex
+
 // Secret safely stores sensitive data such as a password or token
 type Secret struct {
 	enclave   *memguard.Enclave
@@ -36,6 +40,12 @@ type Secret struct {
 	// linked to the corresponding secret store.
 	unlinked []string
 
+	// source contains the secret with all references as configured and
+	// links the resolvers of all references, to refresh the secret after
+	// the static references changed.
+	source *memguard.Enclave
+	links  map[string]telegraf.ResolveFunc
+
 	// Denotes if the secret is completely empty
 	notempty bool
 }
@@ -75,6 +85,8 @@ func (s *Secret) init(secret []byte) {
 	// Setup the enclave
 	s.enclave = memguard.NewEnclave(secret)
 	s.resolvers = nil
+	s.source = nil
+	s.links = nil
 }
 
 // Destroy the secret content
@@ -82,6 +94,14 @@ func (s *Secret) Destroy() {
 	s.resolvers = nil
 	s.unlinked = nil
 	s.notempty = false
+	s.links = nil
+
+	if s.source != nil {
+		if lockbuf, err := s.source.Open(); err == nil {
+			lockbuf.Destroy()
+		}
+		s.source = nil
+	}
 
 	if s.enclave == nil {
 		return
@@ -105,16 +125,17 @@ func (s *Secret) Empty() bool {
 
 // EqualTo performs a constant-time comparison of the secret to the given reference
 func (s *Secret) EqualTo(ref []byte) (bool, error) {
-	if s.enclave == nil {
+	enclave, _, unlinked := s.state()
+	if enclave == nil {
 		return false, nil
 	}
 
-	if len(s.unlinked) > 0 {
-		return false, fmt.Errorf("unlinked parts in secret: %v", strings.Join(s.unlinked, ";"))
+	if len(unlinked) > 0 {
+		return false, fmt.Errorf("unlinked parts in secret: %v", strings.Join(unlinked, ";"))
 	}
 
 	// Get a locked-buffer of the secret to perform the comparison
-	lockbuf, err := s.enclave.Open()
+	lockbuf, err := enclave.Open()
 	if err != nil {
 		return false, fmt.Errorf("opening enclave failed: %w", err)
 	}
@@ -125,23 +146,24 @@ func (s *Secret) EqualTo(ref []byte) (bool, error) {
 
 // Get return the string representation of the secret
 func (s *Secret) Get() ([]byte, error) {
-	if s.enclave == nil {
+	enclave, resolvers, unlinked := s.state()
+	if enclave == nil {
 		return nil, nil
 	}
 
-	if len(s.unlinked) > 0 {
-		return nil, fmt.Errorf("unlinked parts in secret: %v", strings.Join(s.unlinked, ";"))
+	if len(unlinked) > 0 {
+		return nil, fmt.Errorf("unlinked parts in secret: %v", strings.Join(unlinked, ";"))
 	}
 
 	// Decrypt the secret so we can return it
-	lockbuf, err := s.enclave.Open()
+	lockbuf, err := enclave.Open()
 	if err != nil {
 		return nil, fmt.Errorf("opening enclave failed: %w", err)
 	}
 	defer lockbuf.Destroy()
 	secret := lockbuf.Bytes()
 
-	if len(s.resolvers) == 0 {
+	if len(resolvers) == 0 {
 		// Make a copy as we cannot access lockbuf after Destroy, i.e.
 		// after this function finishes.
 		newsecret := append([]byte{}, secret...)
@@ -150,7 +172,7 @@ func (s *Secret) Get() ([]byte, error) {
 
 	replaceErrs := make([]string, 0)
 	newsecret := secretPattern.ReplaceAllFunc(secret, func(match []byte) []byte {
-		resolver, found := s.resolvers[string(match)]
+		resolver, found := resolvers[string(match)]
 		if !found {
 			replaceErrs = append(replaceErrs, fmt.Sprintf("no resolver for %q", match))
 			return match
@@ -173,7 +195,8 @@ func (s *Secret) Get() ([]byte, error) {
 
 // Set overwrites the secret's value with a new one. Please note, the secret
 // is not linked again, so only references to secret-stores can be used, e.g. by
-// adding more clear-text or reordering secrets.
+// adding more clear-text or reordering secrets. The secret is not refreshed
+// anymore if the referenced secrets change.
 func (s *Secret) Set(value []byte) error {
 	// Link the new value can be resolved
 	secret, res, replaceErrs := resolve(value, s.resolvers)
@@ -184,6 +207,8 @@ func (s *Secret) Set(value []byte) error {
 	// Set the new secret
 	s.enclave = memguard.NewEnclave(secret)
 	s.resolvers = res
+	s.source = nil
+	s.links = nil
 
 	return nil
 }
@@ -214,9 +239,12 @@ func (s *Secret) Link(resolvers map[string]telegraf.ResolveFunc) error {
 		return fmt.Errorf("linking secrets failed: %s", strings.Join(replaceErrs, ";"))
 	}
 	s.resolvers = res
+	s.links = resolvers
 
-	// Store the secret if it has changed
+	// Store the secret if it has changed and keep the configured secret to
+	// be able to refresh the static parts
 	if string(secret) != string(newsecret) {
+		s.source = memguard.NewEnclave(append([]byte{}, secret...))
 		s.enclave = memguard.NewEnclave(newsecret)
 	}
 
@@ -226,6 +254,72 @@ func (s *Secret) Link(resolvers map[string]telegraf.ResolveFunc) error {
 	return nil
 }
 
+// state returns the current content of the secret
+func (s *Secret) state() (*memguard.Enclave, map[string]telegraf.ResolveFunc, []string) {
+	secretsLock.RLock()
+	defer secretsLock.RUnlock()
+	return s.enclave, s.resolvers, s.unlinked
+}
+
+// refresh resolves the references of a linked secret again if it references
+// any of the given secrets. It returns true if the content of the secret
+// changed or if one of the secrets is referenced dynamically, so users of
+// the secret might need to update their state.
+func (s *Secret) refresh(refs map[string]bool) (bool, error) {
+	secretsLock.RLock()
+	enclave, source, links, resolvers := s.enclave, s.source, s.links, s.resolvers
+	secretsLock.RUnlock()
+
+	var affected, dynamic bool
+	for ref := range links {
+		if refs[ref] {
+			affected = true
+			_, found := resolvers[ref]
+			dynamic = dynamic || found
+		}
+	}
+	if !affected || enclave == nil {
+		return false, nil
+	}
+	// Without static parts, the enclave contains the configured secret
+	if source == nil {
+		source = enclave
+	}
+
+	lockbuf, err := source.Open()
+	if err != nil {
+		return false, fmt.Errorf("opening enclave failed: %w", err)
+	}
+	defer lockbuf.Destroy()
+
+	newsecret, res, replaceErrs := resolve(lockbuf.Bytes(), links)
+	if len(replaceErrs) > 0 {
+		memguard.WipeBytes(newsecret)
+		return false, fmt.Errorf("refreshing secrets failed: %s", strings.Join(replaceErrs, ";"))
+	}
+
+	current, err := enclave.Open()
+	if err != nil {
+		memguard.WipeBytes(newsecret)
+		return false, fmt.Errorf("opening enclave failed: %w", err)
+	}
+	changed := !current.EqualTo(newsecret)
+	current.Destroy()
+	if !changed {
+		memguard.WipeBytes(newsecret)
+		return dynamic, nil
+	}
+
+	secretsLock.Lock()
+	defer secretsLock.Unlock()
+	if s.source == nil {
+		s.source = memguard.NewEnclave(lockbuf.Bytes())
+	}
+	s.enclave = memguard.NewEnclave(newsecret)
+	s.resolvers = res
+	return true, nil
+}
+
 func resolve(secret []byte, resolvers map[string]telegraf.ResolveFunc) ([]byte, map[string]telegraf.ResolveFunc, []string) {
 	// Iterate through the parts and try to resolve them. For static parts
 	// we directly replace them, while for dynamic ones we store the resolver.
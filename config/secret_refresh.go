@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/models"
+)
+
+// ownedSecret is a secret referencing secret-stores together with the
+// plugin it belongs to
+type ownedSecret struct {
+	secret *Secret
+	plugin interface{}
+	name   string
+}
+
+// trackSecrets assigns the secrets referencing secret-stores, unmarshalled
+// since the given mark of the unlinked secrets, to the plugin.
+func (c *Config) trackSecrets(mark int, plugin interface{}, name string) {
+	if mark > len(unlinkedSecrets) {
+		return
+	}
+	for _, s := range unlinkedSecrets[mark:] {
+		c.secrets = append(c.secrets, ownedSecret{secret: s, plugin: plugin, name: name})
+	}
+}
+
+// RefreshSecrets resolves the secrets referencing the given keys of the
+// secret-store again, e.g. after the store signaled changed keys. Plugins
+// implementing telegraf.SecretUpdateHandler are notified if their secrets
+// changed, other outputs are reconnected to use the changed secrets. An error
+// is returned for each secret failing to refresh, the secret keeps its
+// previous content in this case.
+func (c *Config) RefreshSecrets(storeid string, keys []string) []error {
+	refs := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		refs["@{"+storeid+":"+key+"}"] = true
+	}
+
+	var errs []error
+	var updated []ownedSecret
+	notified := make(map[interface{}]bool)
+	for _, s := range c.secrets {
+		changed, err := s.secret.refresh(refs)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("refreshing secret of %s failed: %w", s.name, err))
+			continue
+		}
+		if changed && !notified[s.plugin] {
+			notified[s.plugin] = true
+			updated = append(updated, s)
+		}
+	}
+
+	for _, s := range updated {
+		plugin := s.plugin
+		if p, ok := plugin.(unwrappable); ok {
+			plugin = p.Unwrap()
+		}
+		handler, ok := plugin.(telegraf.SecretUpdateHandler)
+		if !ok {
+			if output := c.runningOutput(s.plugin); output != nil {
+				log.Printf("I! [%s] Reconnecting output with changed secrets", s.name)
+				if err := output.Reconnect(); err != nil {
+					log.Printf("E! [%s] Reconnecting output failed: %v", s.name, err)
+				}
+				continue
+			}
+			log.Printf("D! [%s] Secrets changed", s.name)
+			continue
+		}
+		log.Printf("I! [%s] Updating changed secrets", s.name)
+		if err := handler.SecretsUpdated(); err != nil {
+			log.Printf("E! [%s] Updating secrets failed: %v", s.name, err)
+		}
+	}
+
+	return errs
+}
+
+// runningOutput returns the running output of the given output plugin or nil
+// if the plugin is no output.
+func (c *Config) runningOutput(plugin interface{}) *models.RunningOutput {
+	for _, output := range c.Outputs {
+		if output.Output == plugin {
+			return output
+		}
+	}
+	return nil
+}
+
+// UpdateSecrets keeps track of the secrets of the plugins running after
+// reloading the configuration, i.e. the secrets of the plugins kept from the
+// current and the plugins added from the next configuration.
+func (c *Config) UpdateSecrets(next *Config) {
+	running := make(map[interface{}]bool)
+	for _, input := range c.Inputs {
+		running[input.Input] = true
+	}
+	for _, processor := range c.Processors {
+		running[processor.Processor] = true
+	}
+	for _, processor := range c.AggProcessors {
+		running[processor.Processor] = true
+	}
+	for _, aggregator := range c.Aggregators {
+		running[aggregator.Aggregator] = true
+	}
+	for _, output := range c.Outputs {
+		running[output.Output] = true
+	}
+	for _, store := range c.SecretStores {
+		running[store] = true
+	}
+
+	secrets := make([]ownedSecret, 0, len(c.secrets))
+	for _, s := range c.secrets {
+		if running[s.plugin] {
+			secrets = append(secrets, s)
+		}
+	}
+	for _, s := range next.secrets {
+		if running[s.plugin] {
+			secrets = append(secrets, s)
+		}
+	}
+	c.secrets = secrets
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/awnumar/memguard"
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/secretstores"
 	"github.com/stretchr/testify/require"
 )
@@ -557,6 +558,211 @@ func TestSecretStoreDynamic(t *testing.T) {
 	}
 }
 
+func TestSecretStoreRefresh(t *testing.T) {
+	defer func() { unlinkedSecrets = make([]*Secret, 0) }()
+
+	cfg := []byte(
+		`
+[[inputs.mockup_rotation]]
+	secret = "password=@{mock:secret};token=@{mock:token}"
+[[inputs.mockup_rotation]]
+	secret = "@{mock:other}"
+`)
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData(cfg))
+	require.Len(t, c.Inputs, 2)
+
+	// Create a mockup secretstore
+	store := &MockupSecretStore{
+		Secrets: map[string][]byte{
+			"secret": []byte("Ood Bnar"),
+			"token":  []byte("Thon"),
+			"other":  []byte("Arca Jeth"),
+		},
+	}
+	require.NoError(t, store.Init())
+	c.SecretStores["mock"] = store
+	require.NoError(t, c.LinkSecrets())
+
+	plugin := c.Inputs[0].Input.(*MockupSecretRotationPlugin)
+	other := c.Inputs[1].Input.(*MockupSecretRotationPlugin)
+	secret, err := plugin.Secret.Get()
+	require.NoError(t, err)
+	require.EqualValues(t, "password=Ood Bnar;token=Thon", secret)
+	ReleaseSecret(secret)
+
+	// Static secrets are only changed when refreshing them
+	store.Secrets["secret"] = []byte("Obi-Wan Kenobi")
+	secret, err = plugin.Secret.Get()
+	require.NoError(t, err)
+	require.EqualValues(t, "password=Ood Bnar;token=Thon", secret)
+	ReleaseSecret(secret)
+
+	require.Empty(t, c.RefreshSecrets("mock", []string{"secret"}))
+	secret, err = plugin.Secret.Get()
+	require.NoError(t, err)
+	require.EqualValues(t, "password=Obi-Wan Kenobi;token=Thon", secret)
+	ReleaseSecret(secret)
+	require.Equal(t, 1, plugin.Updated)
+	require.Zero(t, other.Updated)
+
+	// Unchanged secrets do not notify the plugin
+	require.Empty(t, c.RefreshSecrets("mock", []string{"secret", "token"}))
+	require.Equal(t, 1, plugin.Updated)
+
+	// Secrets failing to refresh keep their content
+	delete(store.Secrets, "token")
+	errs := c.RefreshSecrets("mock", []string{"token"})
+	require.Len(t, errs, 1)
+	require.ErrorContains(t, errs[0], "refreshing secret of inputs.mockup_rotation failed")
+	secret, err = plugin.Secret.Get()
+	require.NoError(t, err)
+	require.EqualValues(t, "password=Obi-Wan Kenobi;token=Thon", secret)
+	ReleaseSecret(secret)
+	require.Equal(t, 1, plugin.Updated)
+
+	// Secrets of other stores are not affected
+	require.Empty(t, c.RefreshSecrets("foo", []string{"other"}))
+	require.Zero(t, other.Updated)
+}
+
+func TestSecretStoreRefreshDynamic(t *testing.T) {
+	defer func() { unlinkedSecrets = make([]*Secret, 0) }()
+
+	cfg := []byte(
+		`
+[[inputs.mockup_rotation]]
+	secret = "@{mock:secret}"
+`)
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData(cfg))
+	require.Len(t, c.Inputs, 1)
+
+	store := &MockupSecretStore{
+		Secrets: map[string][]byte{"secret": []byte("Ood Bnar")},
+		Dynamic: true,
+	}
+	require.NoError(t, store.Init())
+	c.SecretStores["mock"] = store
+	require.NoError(t, c.LinkSecrets())
+
+	// Plugins are notified about changed dynamic secrets as they might
+	// keep state derived from the secret
+	store.Secrets["secret"] = []byte("Thon")
+	require.Empty(t, c.RefreshSecrets("mock", []string{"secret"}))
+	plugin := c.Inputs[0].Input.(*MockupSecretRotationPlugin)
+	require.Equal(t, 1, plugin.Updated)
+
+	secret, err := plugin.Secret.Get()
+	require.NoError(t, err)
+	require.EqualValues(t, "Thon", secret)
+	ReleaseSecret(secret)
+}
+
+func TestSecretStoreRefreshReconnectsOutput(t *testing.T) {
+	defer func() { unlinkedSecrets = make([]*Secret, 0) }()
+
+	cfg := []byte(
+		`
+[[outputs.mockup_secret]]
+	secret = "@{mock:secret}"
+`)
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData(cfg))
+	require.Len(t, c.Outputs, 1)
+
+	store := &MockupSecretStore{
+		Secrets: map[string][]byte{"secret": []byte("Ood Bnar")},
+	}
+	require.NoError(t, store.Init())
+	c.SecretStores["mock"] = store
+	require.NoError(t, c.LinkSecrets())
+
+	// Outputs not connected use the changed secret when connecting anyway
+	store.Secrets["secret"] = []byte("Thon")
+	require.Empty(t, c.RefreshSecrets("mock", []string{"secret"}))
+	plugin := c.Outputs[0].Output.(*MockupSecretOutput)
+	require.Zero(t, plugin.Connected)
+
+	// Connected outputs are reconnected to use the changed secret
+	require.NoError(t, c.Outputs[0].Connect())
+	store.Secrets["secret"] = []byte("Obi-Wan Kenobi")
+	require.Empty(t, c.RefreshSecrets("mock", []string{"secret"}))
+	require.Equal(t, 2, plugin.Connected)
+	require.Equal(t, 1, plugin.Closed)
+}
+
+func TestSecretRefreshAfterSet(t *testing.T) {
+	defer func() { unlinkedSecrets = make([]*Secret, 0) }()
+
+	cfg := []byte(
+		`
+[[inputs.mockup_rotation]]
+	secret = "@{mock:secret}"
+`)
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData(cfg))
+	require.Len(t, c.Inputs, 1)
+
+	store := &MockupSecretStore{
+		Secrets: map[string][]byte{"secret": []byte("Ood Bnar")},
+	}
+	require.NoError(t, store.Init())
+	c.SecretStores["mock"] = store
+	require.NoError(t, c.LinkSecrets())
+
+	// Secrets overwritten by the plugin are not refreshed anymore
+	plugin := c.Inputs[0].Input.(*MockupSecretRotationPlugin)
+	require.NoError(t, plugin.Secret.Set([]byte("server=Ood Bnar")))
+	store.Secrets["secret"] = []byte("Thon")
+	require.Empty(t, c.RefreshSecrets("mock", []string{"secret"}))
+	require.Zero(t, plugin.Updated)
+
+	secret, err := plugin.Secret.Get()
+	require.NoError(t, err)
+	require.EqualValues(t, "server=Ood Bnar", secret)
+	ReleaseSecret(secret)
+}
+
+func TestSecretUpdateAfterReload(t *testing.T) {
+	defer func() { unlinkedSecrets = make([]*Secret, 0) }()
+
+	store := &MockupSecretStore{
+		Secrets: map[string][]byte{"secret": []byte("Ood Bnar")},
+	}
+	require.NoError(t, store.Init())
+
+	cfg := []byte(
+		`
+[[inputs.mockup_rotation]]
+	secret = "@{mock:secret}"
+`)
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData(cfg))
+	c.SecretStores["mock"] = store
+	require.NoError(t, c.LinkSecrets())
+
+	next := NewConfig()
+	require.NoError(t, next.LoadConfigData(cfg))
+	next.SecretStores["mock"] = store
+	require.NoError(t, next.LinkSecrets())
+
+	// Replace the input by the one of the next configuration
+	removed := c.Inputs[0].Input.(*MockupSecretRotationPlugin)
+	added := next.Inputs[0].Input.(*MockupSecretRotationPlugin)
+	c.Inputs = next.Inputs
+	c.UpdateSecrets(next)
+
+	store.Secrets["secret"] = []byte("Thon")
+	require.Empty(t, c.RefreshSecrets("mock", []string{"secret"}))
+	require.Zero(t, removed.Updated)
+	require.Equal(t, 1, added.Updated)
+}
+
 func TestSecretStoreDeclarationMissingID(t *testing.T) {
 	defer func() { unlinkedSecrets = make([]*Secret, 0) }()
 
@@ -703,6 +909,35 @@ type MockupSecretPlugin struct {
 func (*MockupSecretPlugin) SampleConfig() string                { return "Mockup test secret plugin" }
 func (*MockupSecretPlugin) Gather(_ telegraf.Accumulator) error { return nil }
 
+type MockupSecretRotationPlugin struct {
+	Secret  Secret `toml:"secret"`
+	Updated int
+}
+
+func (*MockupSecretRotationPlugin) SampleConfig() string                { return "Mockup test secret plugin" }
+func (*MockupSecretRotationPlugin) Gather(_ telegraf.Accumulator) error { return nil }
+func (p *MockupSecretRotationPlugin) SecretsUpdated() error {
+	p.Updated++
+	return nil
+}
+
+type MockupSecretOutput struct {
+	Secret    Secret `toml:"secret"`
+	Connected int
+	Closed    int
+}
+
+func (*MockupSecretOutput) SampleConfig() string { return "Mockup test secret plugin" }
+func (o *MockupSecretOutput) Connect() error {
+	o.Connected++
+	return nil
+}
+func (o *MockupSecretOutput) Close() error {
+	o.Closed++
+	return nil
+}
+func (*MockupSecretOutput) Write(_ []telegraf.Metric) error { return nil }
+
 type MockupSecretStore struct {
 	Secrets map[string][]byte
 	Dynamic bool
@@ -746,6 +981,8 @@ func (s *MockupSecretStore) GetResolver(key string) (telegraf.ResolveFunc, error
 func init() {
 	// Register the mockup input plugin for the required names
 	inputs.Add("mockup", func() telegraf.Input { return &MockupSecretPlugin{} })
+	inputs.Add("mockup_rotation", func() telegraf.Input { return &MockupSecretRotationPlugin{} })
+	outputs.Add("mockup_secret", func() telegraf.Output { return &MockupSecretOutput{} })
 	secretstores.Add("mockup", func(id string) telegraf.SecretStore {
 		return &MockupSecretStore{}
 	})
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/influxdata/toml/ast"
+)
+
+// addTemplates registers the named configuration snippets of a [[templates]]
+// section. Templates are available to all plugins of the file defining them
+// and of the files loaded afterwards.
+func (c *Config) addTemplates(val interface{}) error {
+	tables, ok := val.([]*ast.Table)
+	if !ok {
+		return fmt.Errorf("invalid configuration, templates must be an array of tables")
+	}
+
+	for _, tbl := range tables {
+		var name string
+		c.getFieldString(tbl, "name", &name)
+		if name == "" {
+			return fmt.Errorf("line %d: template without name", tbl.Line)
+		}
+		if _, found := c.templates[name]; found {
+			return fmt.Errorf("line %d: template %q is defined more than once", tbl.Line, name)
+		}
+		if _, found := tbl.Fields["use_templates"]; found {
+			return fmt.Errorf("line %d: template %q must not reference other templates", tbl.Line, name)
+		}
+
+		template := &ast.Table{
+			Line:   tbl.Line,
+			Name:   name,
+			Fields: make(map[string]interface{}, len(tbl.Fields)),
+		}
+		for k, v := range tbl.Fields {
+			if k != "name" {
+				template.Fields[k] = v
+			}
+		}
+		c.templates[name] = template
+	}
+	return nil
+}
+
+// addPluginDefaults registers the settings of a [defaults] section applied
+// to all plugins of the given type, e.g. [defaults.outputs.http].
+func (c *Config) addPluginDefaults(val interface{}) error {
+	tbl, ok := val.(*ast.Table)
+	if !ok {
+		return fmt.Errorf("invalid configuration, defaults must be a table")
+	}
+
+	for category, categoryVal := range tbl.Fields {
+		switch category {
+		case "inputs", "outputs", "processors", "aggregators", "secretstores":
+		default:
+			return fmt.Errorf("line %d: invalid plugin type %q for defaults", tbl.Line, category)
+		}
+		categoryTable, ok := categoryVal.(*ast.Table)
+		if !ok {
+			return fmt.Errorf("line %d: defaults of %s must be a table per plugin", tbl.Line, category)
+		}
+
+		for name, pluginVal := range categoryTable.Fields {
+			pluginTable, ok := pluginVal.(*ast.Table)
+			if !ok {
+				return fmt.Errorf("line %d: defaults of %s.%s must be a table", categoryTable.Line, category, name)
+			}
+			key := category + "." + name
+			if _, found := c.pluginDefaults[key]; found {
+				return fmt.Errorf("line %d: defaults of %s are defined more than once", pluginTable.Line, key)
+			}
+			c.pluginDefaults[key] = pluginTable
+		}
+	}
+	return nil
+}
+
+// applyTemplates returns the plugin table with the defaults of the plugin
+// type and the referenced templates applied. Settings of the plugin override
+// those of the templates, later templates override earlier ones, and all of
+// them override the defaults. Sub-tables are merged key by key. The given
+// table is not modified.
+func (c *Config) applyTemplates(category, name string, tbl *ast.Table) (*ast.Table, error) {
+	defaults, hasDefaults := c.pluginDefaults[category+"."+name]
+	if !hasDefaults {
+		if _, found := tbl.Fields["use_templates"]; !found {
+			return tbl, nil
+		}
+	}
+
+	resolved := &ast.Table{
+		Position: tbl.Position,
+		Line:     tbl.Line,
+		Name:     tbl.Name,
+		Fields:   make(map[string]interface{}),
+		Type:     tbl.Type,
+		Data:     tbl.Data,
+	}
+	if hasDefaults {
+		expanded, err := c.expandTemplates(defaults)
+		if err != nil {
+			return nil, fmt.Errorf("defaults of %s.%s: %w", category, name, err)
+		}
+		mergeTable(resolved, expanded)
+	}
+	expanded, err := c.expandTemplates(tbl)
+	if err != nil {
+		return nil, err
+	}
+	mergeTable(resolved, expanded)
+
+	return resolved, nil
+}
+
+// expandTemplates returns the table with the templates referenced by the
+// "use_templates" setting merged in.
+func (c *Config) expandTemplates(tbl *ast.Table) (*ast.Table, error) {
+	node, found := tbl.Fields["use_templates"]
+	if !found {
+		return tbl, nil
+	}
+
+	kv, ok := node.(*ast.KeyValue)
+	if !ok {
+		return nil, fmt.Errorf("line %d: use_templates must be an array of template names", tbl.Line)
+	}
+	ary, ok := kv.Value.(*ast.Array)
+	if !ok {
+		return nil, fmt.Errorf("line %d: use_templates must be an array of template names", kv.Line)
+	}
+
+	expanded := &ast.Table{
+		Line:   tbl.Line,
+		Name:   tbl.Name,
+		Fields: make(map[string]interface{}),
+	}
+	for _, elem := range ary.Value {
+		str, ok := elem.(*ast.String)
+		if !ok {
+			return nil, fmt.Errorf("line %d: use_templates must be an array of template names", kv.Line)
+		}
+		template, found := c.templates[str.Value]
+		if !found {
+			return nil, fmt.Errorf("line %d: template %q not found, available templates: %v",
+				kv.Line, str.Value, c.templateNames())
+		}
+		mergeTable(expanded, template)
+	}
+
+	own := make(map[string]interface{}, len(tbl.Fields))
+	for k, v := range tbl.Fields {
+		if k != "use_templates" {
+			own[k] = v
+		}
+	}
+	mergeTable(expanded, &ast.Table{Fields: own})
+
+	return expanded, nil
+}
+
+func (c *Config) templateNames() []string {
+	names := make([]string, 0, len(c.templates))
+	for name := range c.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mergeTable merges the fields of the overlay into the target table. Values
+// of the overlay replace those of the target except for tables present in
+// both, which are merged recursively. Tables of the overlay are copied so
+// neither the overlay nor the tables previously merged into the target are
+// modified by later merges.
+func mergeTable(target, overlay *ast.Table) {
+	for k, v := range overlay.Fields {
+		sub, isTable := v.(*ast.Table)
+		if !isTable {
+			target.Fields[k] = v
+			continue
+		}
+
+		existing, found := target.Fields[k].(*ast.Table)
+		if !found {
+			existing = &ast.Table{
+				Position: sub.Position,
+				Line:     sub.Line,
+				Name:     sub.Name,
+				Fields:   make(map[string]interface{}, len(sub.Fields)),
+				Type:     sub.Type,
+				Data:     sub.Data,
+			}
+			target.Fields[k] = existing
+		}
+		mergeTable(existing, sub)
+	}
+}
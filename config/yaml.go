@@ -0,0 +1,296 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/toml/ast"
+	"gopkg.in/yaml.v3"
+)
+
+// parseYAMLConfig parses a YAML or JSON configuration and converts it to the
+// AST of the TOML parser, so the configuration is handled exactly like the
+// equivalent TOML configuration, including the reporting of unused fields.
+// Mappings are converted to tables and sequences of mappings to arrays of
+// tables. Environment variables are replaced in the values of the document.
+func parseYAMLConfig(contents []byte) (*ast.Table, error) {
+	contents = trimBOM(contents)
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(contents, &doc); err != nil {
+		return nil, err
+	}
+
+	// An empty document is an empty configuration
+	root := &ast.Table{Line: 1, Fields: make(map[string]interface{})}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return root, nil
+	}
+
+	node := resolveAlias(doc.Content[0])
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: configuration must be a mapping", node.Line)
+	}
+	if err := convertYAMLMapping(root, node); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// convertYAMLMapping adds the key-value pairs of the mapping node to the table.
+func convertYAMLMapping(tbl *ast.Table, node *yaml.Node) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], resolveAlias(node.Content[i+1])
+
+		// Merge keys copy all keys of the referenced mappings not set in
+		// the mapping itself, so collect them after the regular keys
+		if keyNode.ShortTag() == "!!merge" {
+			continue
+		}
+
+		key := keyNode.Value
+		if _, found := tbl.Fields[key]; found {
+			return fmt.Errorf("line %d: key %q is defined more than once", keyNode.Line, key)
+		}
+		field, err := convertYAMLField(key, keyNode.Line, valueNode)
+		if err != nil {
+			return err
+		}
+		tbl.Fields[key] = field
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].ShortTag() != "!!merge" {
+			continue
+		}
+		merged := resolveAlias(node.Content[i+1])
+		sources := []*yaml.Node{merged}
+		if merged.Kind == yaml.SequenceNode {
+			sources = merged.Content
+		}
+		for _, source := range sources {
+			source = resolveAlias(source)
+			if source.Kind != yaml.MappingNode {
+				return fmt.Errorf("line %d: merge key requires a mapping", source.Line)
+			}
+			other := &ast.Table{Fields: make(map[string]interface{})}
+			if err := convertYAMLMapping(other, source); err != nil {
+				return err
+			}
+			for key, field := range other.Fields {
+				if _, found := tbl.Fields[key]; !found {
+					tbl.Fields[key] = field
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// convertYAMLField converts the value of a mapping into a table, an array of
+// tables or a key-value pair.
+func convertYAMLField(key string, line int, node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		tbl := &ast.Table{
+			Line:   line,
+			Name:   key,
+			Fields: make(map[string]interface{}),
+		}
+		if err := convertYAMLMapping(tbl, node); err != nil {
+			return nil, err
+		}
+		return tbl, nil
+	case yaml.SequenceNode:
+		if !isYAMLTableSequence(node) {
+			break
+		}
+		tables := make([]*ast.Table, 0, len(node.Content))
+		for _, item := range node.Content {
+			item = resolveAlias(item)
+			tbl := &ast.Table{
+				Line:   item.Line,
+				Name:   key,
+				Fields: make(map[string]interface{}),
+				Type:   ast.TableTypeArray,
+			}
+			if err := convertYAMLMapping(tbl, item); err != nil {
+				return nil, err
+			}
+			tables = append(tables, tbl)
+		}
+		return tables, nil
+	case yaml.ScalarNode:
+		// TOML requires a value for each key, so reject empty values to not
+		// silently ignore e.g. plugins without settings
+		if node.ShortTag() == "!!null" {
+			return nil, fmt.Errorf("line %d: missing value for %q, use {} for an empty table", line, key)
+		}
+	}
+
+	value, err := convertYAMLValue(node)
+	if err != nil {
+		return nil, err
+	}
+	return &ast.KeyValue{Key: key, Value: value, Line: line}, nil
+}
+
+// convertYAMLValue converts a scalar or sequence node to a TOML value.
+func convertYAMLValue(node *yaml.Node) (ast.Value, error) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		values := make([]ast.Value, 0, len(node.Content))
+		sources := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			value, err := convertYAMLValue(resolveAlias(item))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			sources = append(sources, value.Source())
+		}
+		return &ast.Array{
+			Value: values,
+			Data:  []rune("[" + strings.Join(sources, ", ") + "]"),
+		}, nil
+	case yaml.ScalarNode:
+		return convertYAMLScalar(node)
+	}
+	return nil, fmt.Errorf("line %d: tables are not supported within arrays of values", node.Line)
+}
+
+// convertYAMLScalar converts a scalar node to a TOML value, normalizing the
+// representation of numbers and booleans to the one of TOML.
+func convertYAMLScalar(node *yaml.Node) (ast.Value, error) {
+	tag, value := node.ShortTag(), node.Value
+
+	// Replace environment variables and, for unquoted values, derive the type
+	// from the replaced value as if it was written in the document
+	if replaced := replaceYAMLEnvVars(value); replaced != value {
+		value = replaced
+		if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			var resolved yaml.Node
+			if err := yaml.Unmarshal([]byte(value), &resolved); err == nil && len(resolved.Content) == 1 &&
+				resolved.Content[0].Kind == yaml.ScalarNode {
+				tag = resolved.Content[0].ShortTag()
+			} else {
+				tag = "!!str"
+			}
+		}
+	}
+
+	scalar := &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+	switch tag {
+	case "!!int":
+		var v int64
+		if err := scalar.Decode(&v); err != nil {
+			var u uint64
+			if err := scalar.Decode(&u); err != nil {
+				return nil, fmt.Errorf("line %d: invalid integer %q: %w", node.Line, value, err)
+			}
+			s := strconv.FormatUint(u, 10)
+			return &ast.Integer{Value: s, Data: []rune(s)}, nil
+		}
+		s := strconv.FormatInt(v, 10)
+		return &ast.Integer{Value: s, Data: []rune(s)}, nil
+	case "!!float":
+		var v float64
+		if err := scalar.Decode(&v); err != nil {
+			return nil, fmt.Errorf("line %d: invalid float %q: %w", node.Line, value, err)
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		switch {
+		case math.IsInf(v, 1):
+			s = "inf"
+		case math.IsInf(v, -1):
+			s = "-inf"
+		case math.IsNaN(v):
+			s = "nan"
+		}
+		return &ast.Float{Value: s, Data: []rune(s)}, nil
+	case "!!bool":
+		var v bool
+		if err := scalar.Decode(&v); err != nil {
+			return nil, fmt.Errorf("line %d: invalid boolean %q: %w", node.Line, value, err)
+		}
+		s := strconv.FormatBool(v)
+		return &ast.Boolean{Value: s, Data: []rune(s)}, nil
+	case "!!timestamp":
+		return &ast.Datetime{Value: value, Data: []rune(value)}, nil
+	case "!!null":
+		return nil, fmt.Errorf("line %d: null values are not supported in arrays", node.Line)
+	}
+
+	return &ast.String{Value: value, Data: []rune(quoteTOMLString(value))}, nil
+}
+
+// isYAMLTableSequence returns true if the node is a non-empty sequence of
+// mappings, i.e. an array of tables.
+func isYAMLTableSequence(node *yaml.Node) bool {
+	if len(node.Content) == 0 {
+		return false
+	}
+	for _, item := range node.Content {
+		if resolveAlias(item).Kind != yaml.MappingNode {
+			return false
+		}
+	}
+	return true
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// replaceYAMLEnvVars replaces the environment variables in the value in the
+// same way as in TOML files, but without escaping as the value is already
+// unquoted.
+func replaceYAMLEnvVars(value string) string {
+	return envVarRe.ReplaceAllStringFunc(value, func(match string) string {
+		name := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(match, "$"), "{"), "}")
+		if v, ok := os.LookupEnv(name); ok {
+			return v
+		}
+		return match
+	})
+}
+
+// quoteTOMLString returns the value as TOML basic string, as used in the
+// source of values passed to plugins unmarshalling the TOML source directly.
+func quoteTOMLString(value string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
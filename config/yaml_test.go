@@ -0,0 +1,91 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/toml/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseYAMLConfig(t *testing.T) {
+	t.Setenv("TEST_PORT", "8080")
+	t.Setenv("TEST_COMMAND", `echo "hello"`)
+
+	data := `
+defaults: &defaults
+  timeout: 5s
+  read_timeout: 0x10
+
+inputs:
+  http_listener_v2:
+    - <<: *defaults
+      port: ${TEST_PORT}
+      command: $TEST_COMMAND
+      methods: ["GET", "POST"]
+      max_body_size: 1MiB
+      timeout: 1m
+`
+	tbl, err := parseYAMLConfig([]byte(data))
+	require.NoError(t, err)
+
+	plugins := tbl.Fields["inputs"].(*ast.Table).Fields["http_listener_v2"].([]*ast.Table)
+	require.Len(t, plugins, 1)
+
+	var input MockupInputPlugin
+	c := NewConfig()
+	require.NoError(t, c.toml.UnmarshalTable(plugins[0], &input))
+	require.Equal(t, 8080, input.Port)
+	require.Equal(t, `echo "hello"`, input.Command)
+	require.Equal(t, []string{"GET", "POST"}, input.Methods)
+	require.Equal(t, Size(1024*1024), input.MaxBodySize)
+	require.Equal(t, Duration(time.Minute), input.Timeout)
+	require.Equal(t, Duration(16*time.Second), input.ReadTimeout)
+}
+
+func TestParseYAMLConfigErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{
+			name:     "syntax error",
+			data:     "inputs:\n  cpu: [\n",
+			expected: "yaml: line 2",
+		},
+		{
+			name:     "no mapping",
+			data:     "- inputs\n",
+			expected: "line 1: configuration must be a mapping",
+		},
+		{
+			name:     "missing value",
+			data:     "inputs:\n  cpu:\n",
+			expected: `line 2: missing value for "cpu"`,
+		},
+		{
+			name:     "duplicate key",
+			data:     "inputs:\n  cpu: {}\n  cpu: {}\n",
+			expected: `line 3: key "cpu" is defined more than once`,
+		},
+		{
+			name:     "table in array",
+			data:     "servers: [localhost, {host: example.org}]\n",
+			expected: "line 1: tables are not supported within arrays of values",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseYAMLConfig([]byte(tt.data))
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestQuoteTOMLString(t *testing.T) {
+	require.Equal(t, `"plain"`, quoteTOMLString("plain"))
+	require.Equal(t, `"a \"quoted\" \\ value\n"`, quoteTOMLString("a \"quoted\" \\ value\n"))
+	require.Equal(t, `"\u0001"`, quoteTOMLString("\x01"))
+}
@@ -1,5 +1,7 @@
 package telegraf
 
+import "context"
+
 type Input interface {
 	PluginDescriber
 
@@ -8,6 +10,16 @@ type Input interface {
 	Gather(Accumulator) error
 }
 
+// ContextInput is an Input supporting the cancellation of gathering, e.g. on
+// exceeding the gather timeout or on shutdown.
+type ContextInput interface {
+	Input
+
+	// GatherContext is called instead of Gather and should return as soon
+	// as possible once the context is done.
+	GatherContext(context.Context, Accumulator) error
+}
+
 type ServiceInput interface {
 	Input
 
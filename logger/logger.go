@@ -7,12 +7,15 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/wlog"
 
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal/rotate"
+	"github.com/influxdata/telegraf/models"
 )
 
 var prefixRegex = regexp.MustCompile("^[DIWE]!")
@@ -22,6 +25,12 @@ const (
 	LogTargetStderr = "stderr"
 )
 
+const (
+	LogFormatText   = "text"
+	LogFormatJSON   = "json"
+	LogFormatLogfmt = "logfmt"
+)
+
 // LogConfig contains the log configuration settings
 type LogConfig struct {
 	// will set the log level to DEBUG
@@ -42,6 +51,8 @@ type LogConfig struct {
 	RotationMaxArchives int
 	// pick a timezone to use when logging. or type 'local' for local time.
 	LogWithTimezone string
+	// text, json or logfmt; empty string is interpreted as text
+	LogFormat string
 }
 
 type creator interface {
@@ -61,9 +72,25 @@ type telegrafLog struct {
 	writer         io.Writer
 	internalWriter io.Writer
 	timezone       *time.Location
+	format         string
+
+	// mu serializes the writes of plugin messages bypassing the standard
+	// logger
+	mu sync.Mutex
 }
 
 func (t *telegrafLog) Write(b []byte) (n int, err error) {
+	if t.format != LogFormatText {
+		level, attrs, msg := parseLine(b)
+		if wlog.Levels[level] < wlog.LogLevel() {
+			return len(b), nil
+		}
+		if err := t.writeEntry(level, attrs, msg); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
 	var line []byte
 	timeToPrint := time.Now().In(t.timezone)
 
@@ -103,10 +130,19 @@ func newTelegrafWriter(w io.Writer, c LogConfig) (io.Writer, error) {
 		return nil, errors.New("error while setting logging timezone: " + err.Error())
 	}
 
+	format := c.LogFormat
+	if format == "" {
+		format = LogFormatText
+	}
+	if err := validateFormat(format); err != nil {
+		return nil, err
+	}
+
 	return &telegrafLog{
 		writer:         wlog.NewWriter(w),
 		internalWriter: w,
 		timezone:       tz,
+		format:         format,
 	}, nil
 }
 
@@ -149,7 +185,33 @@ func (t *telegrafLogCreator) CreateLogger(cfg LogConfig) (io.Writer, error) {
 // It allows closing previous writer if re-set and have possibility to test what is actually set
 var actualLogger io.Writer
 
+// configuredLevel is the global log level set by the last logging setup
+var configuredLevel atomic.Int32
+
+// ToggleDebug switches the global log level between debug and the configured
+// level and returns true if debug logging got enabled. Plugins with their own
+// log level are not affected.
+func ToggleDebug() bool {
+	if wlog.LogLevel() != wlog.DEBUG {
+		wlog.SetLevel(wlog.DEBUG)
+		return true
+	}
+
+	level := wlog.Level(configuredLevel.Load())
+	if level == 0 || level == wlog.DEBUG {
+		level = wlog.INFO
+	}
+	wlog.SetLevel(level)
+	return false
+}
+
 func newLogWriter(cfg LogConfig) (io.Writer, error) {
+	if cfg.LogFormat != "" {
+		if err := validateFormat(cfg.LogFormat); err != nil {
+			return nil, err
+		}
+	}
+
 	log.SetFlags(0)
 	if cfg.Debug {
 		wlog.SetLevel(wlog.DEBUG)
@@ -160,6 +222,7 @@ func newLogWriter(cfg LogConfig) (io.Writer, error) {
 	if !cfg.Debug && !cfg.Quiet {
 		wlog.SetLevel(wlog.INFO)
 	}
+	configuredLevel.Store(int32(wlog.LogLevel()))
 	var logWriter io.Writer
 	if logCreator, ok := loggerRegistry[cfg.LogTarget]; ok {
 		logWriter, _ = logCreator.CreateLogger(cfg)
@@ -177,6 +240,17 @@ func newLogWriter(cfg LogConfig) (io.Writer, error) {
 	log.SetOutput(logWriter)
 	actualLogger = logWriter
 
+	// Pass plugin messages directly to the writer as they are filtered by the
+	// plugin's log level and must not be dropped by the global level
+	if tl, ok := logWriter.(*telegrafLog); ok {
+		models.SetLogOutput(func(level byte, attrs models.LogAttributes, msg string) {
+			// There is nowhere left to report failing log writes to
+			_ = tl.writeEntry(level, attrs, msg)
+		})
+	} else {
+		models.SetLogOutput(nil)
+	}
+
 	return logWriter, nil
 }
 
@@ -6,11 +6,15 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 
+	"github.com/influxdata/wlog"
 	"github.com/stretchr/testify/require"
 
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
 )
 
 func TestWriteLogToFile(t *testing.T) {
@@ -145,6 +149,96 @@ func TestLogTargetSettings(t *testing.T) {
 	require.Equal(t, logger.internalWriter, os.Stderr)
 }
 
+func TestStructuredLogFormats(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected []string
+	}{
+		{
+			format: LogFormatJSON,
+			expected: []string{
+				`{"timestamp":"TS","level":"info","message":"plain message"}`,
+				`{"timestamp":"TS","level":"warn","category":"agent","message":"agent <message>"}`,
+				`{"timestamp":"TS","level":"error","category":"inputs","plugin":"cpu","alias":"mycpu","message":"parsed message"}`,
+				`{"timestamp":"TS","level":"error","category":"outputs","plugin":"file","alias":"out","id":"abc","message":"plugin message 42"}`,
+			},
+		},
+		{
+			format: LogFormatLogfmt,
+			expected: []string{
+				`timestamp=TS level=info message="plain message"`,
+				`timestamp=TS level=warn category=agent message="agent <message>"`,
+				`timestamp=TS level=error category=inputs plugin=cpu alias=mycpu message="parsed message"`,
+				`timestamp=TS level=error category=outputs plugin=file alias=out id=abc message="plugin message 42"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			// Restoring the default logging closes the log file
+			defer func() { require.NoError(t, SetupLogging(LogConfig{})) }()
+
+			filename := filepath.Join(t.TempDir(), "test.log")
+			cfg := createBasicLogConfig(filename)
+			cfg.LogFormat = tt.format
+			cfg.LogWithTimezone = "UTC"
+			require.NoError(t, SetupLogging(cfg))
+
+			log.Printf("plain message")
+			log.Printf("W! [agent] agent <message>")
+			log.Printf("D! [agent] debug messages are ignored")
+			log.Printf("E! [inputs.cpu::mycpu] parsed message")
+
+			logger := models.NewLogger("outputs", "file", "out")
+			logger.Attributes.ID = "abc"
+			logger.Errorf("plugin message %d", 42)
+			logger.Debug("debug messages are ignored")
+
+			buf, err := os.ReadFile(filename)
+			require.NoError(t, err)
+			lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+			timestamp := regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z`)
+			for i := range lines {
+				lines[i] = timestamp.ReplaceAllString(lines[i], "TS")
+			}
+			require.Equal(t, tt.expected, lines)
+		})
+	}
+}
+
+func TestInvalidLogFormat(t *testing.T) {
+	err := SetupLogging(LogConfig{LogFormat: "xml"})
+	require.ErrorContains(t, err, `invalid log format "xml"`)
+}
+
+func TestToggleDebug(t *testing.T) {
+	cfg := LogConfig{Quiet: true}
+	require.NoError(t, SetupLogging(cfg))
+	defer func() { require.NoError(t, SetupLogging(LogConfig{})) }()
+
+	require.True(t, ToggleDebug())
+	require.Equal(t, wlog.DEBUG, wlog.LogLevel())
+	require.False(t, ToggleDebug())
+	require.Equal(t, wlog.ERROR, wlog.LogLevel())
+}
+
+func TestPluginLogLevel(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.log")
+	require.NoError(t, SetupLogging(createBasicLogConfig(filename)))
+	defer func() { require.NoError(t, SetupLogging(LogConfig{})) }()
+
+	// Debug messages of plugins with their own level bypass the global level
+	logger := models.NewLogger("inputs", "snmp", "")
+	require.NoError(t, logger.SetLevel("debug"))
+	logger.Debug("TEST")
+	models.NewLogger("inputs", "cpu", "").Debug("IGNORED")
+
+	f, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "Z D! [inputs.snmp] TEST\n", string(f[19:]))
+}
+
 func BenchmarkTelegrafLogWrite(b *testing.B) {
 	var msg = []byte("test")
 	var buf bytes.Buffer
@@ -0,0 +1,151 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+
+	"github.com/influxdata/telegraf/models"
+)
+
+var levelNames = map[byte]string{
+	'D': "debug",
+	'I': "info",
+	'W': "warn",
+	'E': "error",
+}
+
+// pluginCategories are the sources of log messages, which are followed by the
+// name of a plugin.
+var pluginCategories = map[string]bool{
+	"inputs":       true,
+	"outputs":      true,
+	"processors":   true,
+	"aggregators":  true,
+	"parsers":      true,
+	"serializers":  true,
+	"secretstores": true,
+}
+
+type entry struct {
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level"`
+	Category  string `json:"category,omitempty"`
+	Plugin    string `json:"plugin,omitempty"`
+	Alias     string `json:"alias,omitempty"`
+	ID        string `json:"id,omitempty"`
+	Message   string `json:"message"`
+}
+
+func validateFormat(format string) error {
+	switch format {
+	case LogFormatText, LogFormatJSON, LogFormatLogfmt:
+		return nil
+	}
+	return fmt.Errorf("invalid log format %q", format)
+}
+
+// parseLine splits a line written to the standard logger, e.g.
+// "W! [inputs.cpu::alias] message", into its level, source and message.
+func parseLine(b []byte) (byte, models.LogAttributes, string) {
+	var attrs models.LogAttributes
+
+	line := strings.TrimRight(string(b), "\n")
+	level := byte('I')
+	if prefixRegex.MatchString(line) {
+		level = line[0]
+		line = strings.TrimLeft(line[2:], " ")
+	}
+
+	if !strings.HasPrefix(line, "[") {
+		return level, attrs, line
+	}
+	source, msg, found := strings.Cut(line[1:], "] ")
+	if !found || strings.ContainsAny(source, " []") {
+		return level, attrs, line
+	}
+
+	category, name, found := strings.Cut(source, ".")
+	if !found || !pluginCategories[category] {
+		attrs.Category = source
+		return level, attrs, msg
+	}
+	attrs.Category = category
+	attrs.Name, attrs.Alias, _ = strings.Cut(name, "::")
+
+	return level, attrs, msg
+}
+
+// logSource returns the source of the message in the same way as printed by
+// the plugin loggers, e.g. "inputs.cpu::alias".
+func logSource(attrs models.LogAttributes) string {
+	source := attrs.Category
+	if attrs.Name != "" {
+		source += "." + attrs.Name
+	}
+	if attrs.Alias != "" {
+		source += "::" + attrs.Alias
+	}
+	return source
+}
+
+// writeEntry writes a message in the configured format regardless of the
+// global log level.
+func (t *telegrafLog) writeEntry(level byte, attrs models.LogAttributes, msg string) error {
+	timestamp := time.Now().In(t.timezone).Format(time.RFC3339)
+	e := entry{
+		Timestamp: timestamp,
+		Level:     levelNames[level],
+		Category:  attrs.Category,
+		Plugin:    attrs.Name,
+		Alias:     attrs.Alias,
+		ID:        attrs.ID,
+		Message:   strings.TrimRight(msg, "\n"),
+	}
+
+	var buf bytes.Buffer
+	switch t.format {
+	case LogFormatText:
+		buf.WriteString(timestamp + " " + string(level) + "! ")
+		if source := logSource(attrs); source != "" {
+			buf.WriteString("[" + source + "] ")
+		}
+		buf.WriteString(e.Message + "\n")
+	case LogFormatJSON:
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	case LogFormatLogfmt:
+		keyvals := []interface{}{"timestamp", e.Timestamp, "level", e.Level}
+		for _, kv := range [][2]string{
+			{"category", e.Category},
+			{"plugin", e.Plugin},
+			{"alias", e.Alias},
+			{"id", e.ID},
+		} {
+			if kv[1] != "" {
+				keyvals = append(keyvals, kv[0], kv[1])
+			}
+		}
+		keyvals = append(keyvals, "message", e.Message)
+
+		enc := logfmt.NewEncoder(&buf)
+		if err := enc.EncodeKeyvals(keyvals...); err != nil {
+			return err
+		}
+		if err := enc.EndRecord(); err != nil {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := t.internalWriter.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,67 @@
+package metric
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// serializedMetric is the on-disk representation of a metric. Tags and fields
+// are kept as lists to preserve their order.
+type serializedMetric struct {
+	Name   string
+	Tags   []telegraf.Tag
+	Fields []telegraf.Field
+	Time   time.Time
+	Type   telegraf.ValueType
+}
+
+// ToBytes serializes the given metric into a self-contained binary
+// representation. Any tracking information is not serialized.
+func ToBytes(m telegraf.Metric) ([]byte, error) {
+	sm := serializedMetric{
+		Name:   m.Name(),
+		Tags:   make([]telegraf.Tag, 0, len(m.TagList())),
+		Fields: make([]telegraf.Field, 0, len(m.FieldList())),
+		Time:   m.Time(),
+		Type:   m.Type(),
+	}
+	for _, tag := range m.TagList() {
+		sm.Tags = append(sm.Tags, *tag)
+	}
+	for _, field := range m.FieldList() {
+		sm.Fields = append(sm.Fields, *field)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&sm); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FromBytes creates a metric from the binary representation produced by
+// ToBytes.
+func FromBytes(data []byte) (telegraf.Metric, error) {
+	var sm serializedMetric
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&sm); err != nil {
+		return nil, err
+	}
+
+	m := &metric{
+		name:   sm.Name,
+		tags:   make([]*telegraf.Tag, 0, len(sm.Tags)),
+		fields: make([]*telegraf.Field, 0, len(sm.Fields)),
+		tm:     sm.Time,
+		tp:     sm.Type,
+	}
+	for i := range sm.Tags {
+		m.tags = append(m.tags, &sm.Tags[i])
+	}
+	for i := range sm.Fields {
+		m.fields = append(m.fields, &sm.Fields[i])
+	}
+	return m, nil
+}
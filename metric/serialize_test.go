@@ -0,0 +1,45 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+)
+
+func TestSerializeRoundtrip(t *testing.T) {
+	m := New(
+		"cpu",
+		map[string]string{
+			"host": "localhost",
+			"cpu":  "cpu0",
+		},
+		map[string]interface{}{
+			"usage_idle": float64(99),
+			"count":      int64(-42),
+			"counter":    uint64(42),
+			"state":      "ok",
+			"active":     true,
+		},
+		time.Unix(1683000000, 123),
+		telegraf.Counter,
+	)
+
+	buf, err := ToBytes(m)
+	require.NoError(t, err)
+
+	actual, err := FromBytes(buf)
+	require.NoError(t, err)
+	require.Equal(t, m.Name(), actual.Name())
+	require.Equal(t, m.TagList(), actual.TagList())
+	require.Equal(t, m.FieldList(), actual.FieldList())
+	require.True(t, m.Time().Equal(actual.Time()))
+	require.Equal(t, m.Type(), actual.Type())
+}
+
+func TestSerializeInvalid(t *testing.T) {
+	_, err := FromBytes([]byte("garbage"))
+	require.Error(t, err)
+}
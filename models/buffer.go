@@ -12,39 +12,63 @@ var (
 	AgentMetricsDropped = selfstat.Register("agent", "metrics_dropped", map[string]string{})
 )
 
-// Buffer stores metrics in a circular buffer.
-type Buffer struct {
-	sync.Mutex
-	buf   []telegraf.Metric
-	first int // index of the first/oldest metric
-	last  int // one after the index of the last/newest metric
-	size  int // number of metrics currently in the buffer
-	cap   int // the capacity of the buffer
+// MetricBuffer is the storage used by a RunningOutput to queue metrics until
+// they are written by the output.
+type MetricBuffer interface {
+	// Len returns the number of metrics currently in the buffer.
+	Len() int
+
+	// Add adds metrics to the buffer and returns number of dropped metrics.
+	Add(metrics ...telegraf.Metric) int
+
+	// Batch returns a slice containing up to batchSize of the oldest metrics
+	// not yet dropped or handed out in another batch. Several batches can be
+	// outstanding at the same time, each one has to be passed to exactly one
+	// of Accept, Reject or Drop.
+	Batch(batchSize int) []telegraf.Metric
+
+	// Accept marks the batch, acquired from Batch(), as successfully written.
+	Accept(batch []telegraf.Metric)
+
+	// Reject returns the batch, acquired from Batch(), to the buffer and marks
+	// it as unsent. Rejected metrics are put in front of the buffer, so
+	// rejecting concurrent batches in another order than they were acquired
+	// changes the order of the metrics in the buffer.
+	Reject(batch []telegraf.Metric)
+
+	// Drop removes the batch, acquired from Batch(), from the buffer and marks
+	// it as dropped for the given reason and error.
+	Drop(batch []telegraf.Metric, reason string, err error)
+
+	// Close releases all resources held by the buffer.
+	Close() error
+
+	setDropHandler(handler DropHandler)
+}
 
-	batchFirst int // index of the first metric in the batch
-	batchSize  int // number of metrics currently in the batch
+// DropHandler receives each metric dropped by a buffer together with the
+// reason and, if any, the error causing the drop. It is called with the
+// buffer locked and before the metric is released.
+type DropHandler func(metric telegraf.Metric, reason string, err error)
 
+// BufferStats holds the internal statistics shared by all buffer strategies.
+type BufferStats struct {
 	MetricsAdded   selfstat.Stat
 	MetricsWritten selfstat.Stat
 	MetricsDropped selfstat.Stat
 	BufferSize     selfstat.Stat
 	BufferLimit    selfstat.Stat
+
+	dropHandler DropHandler
 }
 
-// NewBuffer returns a new empty Buffer with the given capacity.
-func NewBuffer(name string, alias string, capacity int) *Buffer {
+func newBufferStats(name string, alias string, capacity int) BufferStats {
 	tags := map[string]string{"output": name}
 	if alias != "" {
 		tags["alias"] = alias
 	}
 
-	b := &Buffer{
-		buf:   make([]telegraf.Metric, capacity),
-		first: 0,
-		last:  0,
-		size:  0,
-		cap:   capacity,
-
+	stats := BufferStats{
 		MetricsAdded: selfstat.Register(
 			"write",
 			"metrics_added",
@@ -71,8 +95,65 @@ func NewBuffer(name string, alias string, capacity int) *Buffer {
 			tags,
 		),
 	}
-	b.BufferSize.Set(int64(0))
-	b.BufferLimit.Set(int64(capacity))
+	stats.BufferSize.Set(int64(0))
+	stats.BufferLimit.Set(int64(capacity))
+	return stats
+}
+
+func (s *BufferStats) metricAdded() {
+	s.MetricsAdded.Incr(1)
+}
+
+func (s *BufferStats) metricWritten(metric telegraf.Metric) {
+	AgentMetricsWritten.Incr(1)
+	s.MetricsWritten.Incr(1)
+	metric.Accept()
+}
+
+func (s *BufferStats) metricDropped(metric telegraf.Metric, reason string, err error) {
+	AgentMetricsDropped.Incr(1)
+	s.MetricsDropped.Incr(1)
+	if s.dropHandler != nil {
+		s.dropHandler(metric, reason, err)
+	}
+	metric.Reject()
+}
+
+func (s *BufferStats) setDropHandler(handler DropHandler) {
+	s.dropHandler = handler
+}
+
+// Buffer stores metrics in a circular buffer.
+type Buffer struct {
+	sync.Mutex
+	buf   []telegraf.Metric
+	first int // index of the first/oldest metric
+	last  int // one after the index of the last/newest metric
+	size  int // number of metrics currently in the buffer
+	cap   int // the capacity of the buffer
+
+	// Slots reserved for the outstanding batches, identified by the address
+	// of their first metric. Newer metrics reuse the reserved slots if the
+	// buffer is full.
+	batches   map[*telegraf.Metric]int
+	batchSize int // total number of reserved slots
+
+	BufferStats
+}
+
+// NewBuffer returns a new empty Buffer with the given capacity.
+func NewBuffer(name string, alias string, capacity int) *Buffer {
+	b := &Buffer{
+		buf:   make([]telegraf.Metric, capacity),
+		first: 0,
+		last:  0,
+		size:  0,
+		cap:   capacity,
+
+		batches: make(map[*telegraf.Metric]int),
+
+		BufferStats: newBufferStats(name, alias, capacity),
+	}
 	return b
 }
 
@@ -88,33 +169,14 @@ func (b *Buffer) length() int {
 	return min(b.size+b.batchSize, b.cap)
 }
 
-func (b *Buffer) metricAdded() {
-	b.MetricsAdded.Incr(1)
-}
-
-func (b *Buffer) metricWritten(metric telegraf.Metric) {
-	AgentMetricsWritten.Incr(1)
-	b.MetricsWritten.Incr(1)
-	metric.Accept()
-}
-
-func (b *Buffer) metricDropped(metric telegraf.Metric) {
-	AgentMetricsDropped.Incr(1)
-	b.MetricsDropped.Incr(1)
-	metric.Reject()
-}
-
 func (b *Buffer) addMetric(m telegraf.Metric) int {
 	dropped := 0
 	// Check if Buffer is full
 	if b.size == b.cap {
-		b.metricDropped(b.buf[b.last])
+		b.metricDropped(b.buf[b.last], DropReasonBufferFull, nil)
 		dropped++
 
-		if b.batchSize > 0 {
-			b.batchSize--
-			b.batchFirst = b.next(b.batchFirst)
-		}
+		b.releaseSlot()
 	}
 
 	b.metricAdded()
@@ -159,17 +221,17 @@ func (b *Buffer) Batch(batchSize int) []telegraf.Metric {
 		return out
 	}
 
-	b.batchFirst = b.first
-	b.batchSize = outLen
+	b.batches[&out[0]] = outLen
+	b.batchSize += outLen
 
-	batchIndex := b.batchFirst
+	batchIndex := b.first
 	for i := range out {
 		out[i] = b.buf[batchIndex]
 		b.buf[batchIndex] = nil
 		batchIndex = b.next(batchIndex)
 	}
 
-	b.first = b.nextby(b.first, b.batchSize)
+	b.first = b.nextby(b.first, outLen)
 	b.size -= outLen
 	return out
 }
@@ -183,7 +245,7 @@ func (b *Buffer) Accept(batch []telegraf.Metric) {
 		b.metricWritten(m)
 	}
 
-	b.resetBatch()
+	b.endBatch(batch)
 	b.BufferSize.Set(int64(b.length()))
 }
 
@@ -209,17 +271,55 @@ func (b *Buffer) Reject(batch []telegraf.Metric) {
 	// Copy metrics from the batch back into the buffer
 	for i := range batch {
 		if i < skip {
-			b.metricDropped(batch[i])
+			b.metricDropped(batch[i], DropReasonBufferFull, nil)
 		} else {
 			b.buf[re] = batch[i]
 			re = b.next(re)
 		}
 	}
 
-	b.resetBatch()
+	b.endBatch(batch)
 	b.BufferSize.Set(int64(b.length()))
 }
 
+// Drop removes the batch, acquired from Batch(), from the buffer and marks it
+// as dropped.
+func (b *Buffer) Drop(batch []telegraf.Metric, reason string, err error) {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, m := range batch {
+		b.metricDropped(m, reason, err)
+	}
+
+	b.endBatch(batch)
+	b.BufferSize.Set(int64(b.length()))
+}
+
+// drain removes and returns all metrics not handed out in a batch without
+// marking them as written or dropped, e.g. to move them to another buffer.
+func (b *Buffer) drain() []telegraf.Metric {
+	b.Lock()
+	defer b.Unlock()
+
+	out := make([]telegraf.Metric, 0, b.size)
+	for i, index := 0, b.first; i < b.size; i++ {
+		out = append(out, b.buf[index])
+		b.buf[index] = nil
+		index = b.next(index)
+	}
+	b.first = b.last
+	b.size = 0
+
+	b.BufferSize.Set(int64(b.length()))
+	return out
+}
+
+// Close is a no-op for the in-memory buffer.
+func (b *Buffer) Close() error {
+	return nil
+}
+
 // next returns the next index with wrapping.
 func (b *Buffer) next(index int) int {
 	index++
@@ -247,9 +347,28 @@ func (b *Buffer) prevby(index, count int) int {
 	return index
 }
 
-func (b *Buffer) resetBatch() {
-	b.batchFirst = 0
-	b.batchSize = 0
+// releaseSlot releases a slot reserved for an outstanding batch as it was
+// reused by a newer metric.
+func (b *Buffer) releaseSlot() {
+	for first, reserved := range b.batches {
+		if reserved > 0 {
+			b.batches[first] = reserved - 1
+			b.batchSize--
+			return
+		}
+	}
+}
+
+// endBatch releases the remaining slots of a batch no longer outstanding.
+// Batches already ended do not release any slots.
+func (b *Buffer) endBatch(batch []telegraf.Metric) {
+	if len(batch) == 0 {
+		return
+	}
+	if reserved, found := b.batches[&batch[0]]; found {
+		delete(b.batches, &batch[0])
+		b.batchSize -= reserved
+	}
 }
 
 func min(a, b int) int {
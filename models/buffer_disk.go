@@ -0,0 +1,645 @@
+package models
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+const (
+	// Extension of the segment files of the disk buffer
+	diskBufferSegmentExt = ".seg"
+
+	// Name of the file keeping the sequence number of the oldest unsent metric
+	diskBufferHeadFile = "head"
+
+	// Size of the record header consisting of the sequence number, the
+	// payload length and the payload checksum
+	diskBufferHeaderSize = 8 + 4 + 4
+)
+
+// Maximum size of a single segment file before starting a new segment
+var diskBufferSegmentSize int64 = 8 * 1024 * 1024
+
+// diskEntry locates a single serialized metric in the segment files.
+type diskEntry struct {
+	seq     uint64
+	segment uint64
+	offset  int64
+	size    int64
+}
+
+// DiskBuffer stores metrics in segmented files in a directory so that unsent
+// metrics survive a restart of Telegraf. Metrics are removed from disk once
+// they are accepted by the output. Tracking metrics are accepted as soon as
+// they are written to the segment. Segments are synced to disk when handing
+// out a batch, when starting a new segment and when closing the buffer
+// instead of on every write.
+type DiskBuffer struct {
+	sync.Mutex
+	path      string
+	cap       int   // maximum number of metrics
+	sizeLimit int64 // maximum number of bytes, zero for no limit
+
+	entries []diskEntry // unsent metrics from oldest to newest
+	bytes   int64       // number of bytes used by the entries
+	nextSeq uint64      // sequence number of the next metric added
+
+	segments    []uint64 // ids of all segments on disk in ascending order
+	current     *os.File // segment currently written to
+	currentSize int64
+	dirty       bool // current segment written since the last sync
+
+	// Metrics of outstanding batches stay on disk until accepted and are
+	// skipped when handing out the next batch
+	pending map[uint64]telegraf.Metric // batched metrics by sequence number
+	batched map[telegraf.Metric]uint64 // sequence numbers by batched metric
+
+	BufferStats
+}
+
+// NewDiskBuffer opens the disk buffer located in the given directory,
+// restoring all metrics not yet written by a previous run. The buffer keeps at
+// most capacity metrics and sizeLimit bytes, a sizeLimit of zero disables the
+// size based limit.
+func NewDiskBuffer(name, alias, path string, capacity int, sizeLimit int64) (*DiskBuffer, error) {
+	if err := os.MkdirAll(path, 0750); err != nil {
+		return nil, fmt.Errorf("creating buffer directory failed: %w", err)
+	}
+
+	b := &DiskBuffer{
+		path:        path,
+		cap:         capacity,
+		sizeLimit:   sizeLimit,
+		pending:     make(map[uint64]telegraf.Metric),
+		batched:     make(map[telegraf.Metric]uint64),
+		BufferStats: newBufferStats(name, alias, capacity),
+	}
+
+	if err := b.restore(); err != nil {
+		return nil, err
+	}
+	if err := b.openSegment(); err != nil {
+		return nil, err
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	if b.enforceLimits() > 0 {
+		if err := b.cleanup(); err != nil {
+			return nil, err
+		}
+	}
+	b.BufferSize.Set(int64(len(b.entries)))
+
+	return b, nil
+}
+
+// Len returns the number of metrics currently in the buffer.
+func (b *DiskBuffer) Len() int {
+	b.Lock()
+	defer b.Unlock()
+
+	return len(b.entries)
+}
+
+// Add adds metrics to the buffer and returns number of dropped metrics.
+func (b *DiskBuffer) Add(metrics ...telegraf.Metric) int {
+	b.Lock()
+	defer b.Unlock()
+
+	dropped := 0
+	for _, m := range metrics {
+		if err := b.addMetric(m); err != nil {
+			b.metricDropped(m, DropReasonBufferError, err)
+			dropped++
+			continue
+		}
+		b.metricAdded()
+		m.Accept()
+	}
+
+	if n := b.enforceLimits(); n > 0 {
+		dropped += n
+		// Failing to clean up only leaves stale data on disk which is
+		// removed on the next successful cleanup
+		_ = b.cleanup()
+	}
+
+	b.BufferSize.Set(int64(len(b.entries)))
+	return dropped
+}
+
+// Batch returns a slice containing up to batchSize of the oldest metrics not
+// yet dropped or handed out in another batch.  Metrics are ordered from oldest
+// to newest in the batch.  The batch must not be modified by the client.
+func (b *DiskBuffer) Batch(batchSize int) []telegraf.Metric {
+	b.Lock()
+	defer b.Unlock()
+
+	// Errors on sync will show up on the next write to the segment
+	_ = b.sync()
+
+	out := make([]telegraf.Metric, 0, min(len(b.entries)-len(b.pending), batchSize))
+	files := make(map[uint64]*os.File)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	var corrupt bool
+	for i := 0; i < len(b.entries) && len(out) < batchSize; {
+		if _, found := b.pending[b.entries[i].seq]; found {
+			i++
+			continue
+		}
+		m, err := b.readEntry(files, b.entries[i])
+		if err != nil {
+			// Remove unreadable metrics from the buffer
+			AgentMetricsDropped.Incr(1)
+			b.MetricsDropped.Incr(1)
+			b.bytes -= b.entries[i].size
+			b.entries = append(b.entries[:i], b.entries[i+1:]...)
+			corrupt = true
+			continue
+		}
+		out = append(out, m)
+		b.pending[b.entries[i].seq] = m
+		b.batched[m] = b.entries[i].seq
+		i++
+	}
+
+	if corrupt {
+		b.BufferSize.Set(int64(len(b.entries)))
+	}
+	return out
+}
+
+// Accept marks the batch, acquired from Batch(), as successfully written.
+// Metrics of the batch dropped in the meantime to enforce the buffer limits
+// are not counted again.
+func (b *DiskBuffer) Accept(batch []telegraf.Metric) {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, m := range b.removeBatch(batch) {
+		b.metricWritten(m)
+	}
+
+	// Failing to clean up only leaves stale data on disk which is removed on
+	// the next successful cleanup
+	_ = b.cleanup()
+
+	b.BufferSize.Set(int64(len(b.entries)))
+}
+
+// Reject returns the batch, acquired from Batch(), to the buffer and marks it
+// as unsent.
+func (b *DiskBuffer) Reject(batch []telegraf.Metric) {
+	b.Lock()
+	defer b.Unlock()
+
+	// The metrics of the batch are still on disk so we only need to forget
+	// about the batch.
+	for _, m := range batch {
+		if seq, found := b.batched[m]; found {
+			delete(b.batched, m)
+			delete(b.pending, seq)
+		}
+	}
+}
+
+// Drop removes the batch, acquired from Batch(), from the buffer and marks it
+// as dropped. Metrics of the batch already dropped in the meantime to enforce
+// the buffer limits are not counted again.
+func (b *DiskBuffer) Drop(batch []telegraf.Metric, reason string, err error) {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, m := range b.removeBatch(batch) {
+		b.metricDropped(m, reason, err)
+	}
+
+	// Failing to clean up only leaves stale data on disk which is removed on
+	// the next successful cleanup
+	_ = b.cleanup()
+
+	b.BufferSize.Set(int64(len(b.entries)))
+}
+
+// Close persists the position of the oldest unsent metric and closes all
+// open segment files.
+func (b *DiskBuffer) Close() error {
+	b.Lock()
+	defer b.Unlock()
+
+	if err := b.writeHead(); err != nil {
+		return err
+	}
+	if err := b.sync(); err != nil {
+		return err
+	}
+	return b.current.Close()
+}
+
+// sync flushes the current segment to disk if it was written to.
+func (b *DiskBuffer) sync() error {
+	if !b.dirty {
+		return nil
+	}
+	if err := b.current.Sync(); err != nil {
+		return fmt.Errorf("syncing segment failed: %w", err)
+	}
+	b.dirty = false
+	return nil
+}
+
+func (b *DiskBuffer) addMetric(m telegraf.Metric) error {
+	payload, err := metric.ToBytes(m)
+	if err != nil {
+		return fmt.Errorf("serializing metric failed: %w", err)
+	}
+
+	if b.currentSize >= diskBufferSegmentSize {
+		if err := b.sync(); err != nil {
+			return err
+		}
+		if err := b.current.Close(); err != nil {
+			return err
+		}
+		if err := b.openSegment(); err != nil {
+			return err
+		}
+	}
+
+	record := make([]byte, diskBufferHeaderSize+len(payload))
+	binary.BigEndian.PutUint64(record[0:8], b.nextSeq)
+	binary.BigEndian.PutUint32(record[8:12], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[12:16], crc32.ChecksumIEEE(payload))
+	copy(record[diskBufferHeaderSize:], payload)
+
+	if _, err := b.current.Write(record); err != nil {
+		return fmt.Errorf("writing metric to segment failed: %w", err)
+	}
+	b.dirty = true
+
+	b.entries = append(b.entries, diskEntry{
+		seq:     b.nextSeq,
+		segment: b.segments[len(b.segments)-1],
+		offset:  b.currentSize,
+		size:    int64(len(record)),
+	})
+	b.bytes += int64(len(record))
+	b.currentSize += int64(len(record))
+	b.nextSeq++
+
+	return nil
+}
+
+// enforceLimits drops the oldest metrics until the buffer is within its
+// count and size limits and returns the number of dropped metrics.
+func (b *DiskBuffer) enforceLimits() int {
+	files := make(map[uint64]*os.File)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	var dropped int
+	for len(b.entries) > b.cap || (b.sizeLimit > 0 && b.bytes > b.sizeLimit && len(b.entries) > 0) {
+		AgentMetricsDropped.Incr(1)
+		b.MetricsDropped.Incr(1)
+		// Metrics of outstanding batches might still be written, unreadable
+		// metrics cannot be handed out anyway. Dropped metrics of outstanding
+		// batches are forgotten so they are not counted again when the batch
+		// is accepted or dropped.
+		seq := b.entries[0].seq
+		if m, found := b.pending[seq]; found {
+			delete(b.batched, m)
+			delete(b.pending, seq)
+		} else if b.dropHandler != nil {
+			if m, err := b.readEntry(files, b.entries[0]); err == nil {
+				b.dropHandler(m, DropReasonBufferFull, nil)
+			}
+		}
+		b.removeOldest(1)
+		dropped++
+	}
+	return dropped
+}
+
+// removeBatch removes the entries of the batch's metrics still present in
+// the buffer and returns those metrics.
+func (b *DiskBuffer) removeBatch(batch []telegraf.Metric) []telegraf.Metric {
+	remove := make(map[uint64]bool, len(batch))
+	removed := make([]telegraf.Metric, 0, len(batch))
+	for _, m := range batch {
+		if seq, found := b.batched[m]; found {
+			delete(b.batched, m)
+			delete(b.pending, seq)
+			remove[seq] = true
+			removed = append(removed, m)
+		}
+	}
+
+	// Batches are usually handed out and completed in order, so avoid
+	// copying the entries in this case
+	n := 0
+	for n < len(b.entries) && remove[b.entries[n].seq] {
+		n++
+	}
+	b.removeOldest(n)
+	if n == len(remove) {
+		return removed
+	}
+
+	entries := b.entries[:0]
+	for _, e := range b.entries {
+		if remove[e.seq] {
+			b.bytes -= e.size
+			continue
+		}
+		entries = append(entries, e)
+	}
+	b.entries = entries
+	return removed
+}
+
+func (b *DiskBuffer) removeOldest(n int) {
+	n = min(n, len(b.entries))
+	for _, e := range b.entries[:n] {
+		b.bytes -= e.size
+	}
+	b.entries = b.entries[n:]
+}
+
+// cleanup persists the position of the oldest unsent metric and removes all
+// segments not containing any unsent metrics.
+func (b *DiskBuffer) cleanup() error {
+	if err := b.writeHead(); err != nil {
+		return err
+	}
+
+	active := b.segments[len(b.segments)-1]
+	if len(b.entries) > 0 {
+		active = b.entries[0].segment
+	}
+
+	for len(b.segments) > 1 && b.segments[0] < active {
+		if err := os.Remove(b.segmentPath(b.segments[0])); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing segment failed: %w", err)
+		}
+		b.segments = b.segments[1:]
+	}
+	return nil
+}
+
+func (b *DiskBuffer) readEntry(files map[uint64]*os.File, e diskEntry) (telegraf.Metric, error) {
+	f, found := files[e.segment]
+	if !found {
+		var err error
+		f, err = os.Open(b.segmentPath(e.segment))
+		if err != nil {
+			return nil, err
+		}
+		files[e.segment] = f
+	}
+
+	record := make([]byte, e.size)
+	if _, err := f.ReadAt(record, e.offset); err != nil {
+		return nil, err
+	}
+	payload := record[diskBufferHeaderSize:]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(record[12:16]) {
+		return nil, errors.New("checksum mismatch")
+	}
+	return metric.FromBytes(payload)
+}
+
+// restore reads the head position and rebuilds the index of all unsent
+// metrics from the segments found on disk.
+func (b *DiskBuffer) restore() error {
+	head, err := b.readHead()
+	if err != nil {
+		return err
+	}
+	b.nextSeq = head
+
+	dirEntries, err := os.ReadDir(b.path)
+	if err != nil {
+		return fmt.Errorf("reading buffer directory failed: %w", err)
+	}
+	for _, de := range dirEntries {
+		name := de.Name()
+		if de.IsDir() || !strings.HasSuffix(name, diskBufferSegmentExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, diskBufferSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		b.segments = append(b.segments, id)
+	}
+	sort.Slice(b.segments, func(i, j int) bool { return b.segments[i] < b.segments[j] })
+
+	for _, id := range b.segments {
+		if err := b.scanSegment(id, head); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanSegment adds all metrics of the segment with a sequence number equal or
+// larger than head to the index. Scanning stops at the first incomplete or
+// corrupt record, e.g. caused by a crash while writing.
+func (b *DiskBuffer) scanSegment(id, head uint64) error {
+	f, err := os.Open(b.segmentPath(id))
+	if err != nil {
+		return fmt.Errorf("opening segment failed: %w", err)
+	}
+	defer f.Close()
+
+	var offset int64
+	header := make([]byte, diskBufferHeaderSize)
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			return nil
+		}
+		seq := binary.BigEndian.Uint64(header[0:8])
+		size := int64(binary.BigEndian.Uint32(header[8:12]))
+
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(f, payload); err != nil {
+			return nil
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[12:16]) {
+			return nil
+		}
+
+		if seq >= head {
+			b.entries = append(b.entries, diskEntry{
+				seq:     seq,
+				segment: id,
+				offset:  offset,
+				size:    diskBufferHeaderSize + size,
+			})
+			b.bytes += diskBufferHeaderSize + size
+		}
+		if seq >= b.nextSeq {
+			b.nextSeq = seq + 1
+		}
+		offset += diskBufferHeaderSize + size
+	}
+}
+
+// openSegment starts a new segment for writing.
+func (b *DiskBuffer) openSegment() error {
+	var id uint64
+	if len(b.segments) > 0 {
+		id = b.segments[len(b.segments)-1] + 1
+	}
+
+	f, err := os.OpenFile(b.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return fmt.Errorf("creating segment failed: %w", err)
+	}
+	b.segments = append(b.segments, id)
+	b.current = f
+	b.currentSize = 0
+
+	return nil
+}
+
+func (b *DiskBuffer) segmentPath(id uint64) string {
+	return filepath.Join(b.path, fmt.Sprintf("%020d%s", id, diskBufferSegmentExt))
+}
+
+func (b *DiskBuffer) readHead() (uint64, error) {
+	buf, err := os.ReadFile(filepath.Join(b.path, diskBufferHeadFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("reading buffer head failed: %w", err)
+	}
+	head, err := strconv.ParseUint(strings.TrimSpace(string(buf)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing buffer head failed: %w", err)
+	}
+	return head, nil
+}
+
+// writeHead atomically stores the sequence number of the oldest unsent metric.
+func (b *DiskBuffer) writeHead() error {
+	head := b.nextSeq
+	if len(b.entries) > 0 {
+		head = b.entries[0].seq
+	}
+
+	filename := filepath.Join(b.path, diskBufferHeadFile)
+	tmpfile := filename + ".tmp"
+	if err := os.WriteFile(tmpfile, []byte(strconv.FormatUint(head, 10)), 0640); err != nil {
+		return fmt.Errorf("writing buffer head failed: %w", err)
+	}
+	if err := os.Rename(tmpfile, filename); err != nil {
+		return fmt.Errorf("replacing buffer head failed: %w", err)
+	}
+	return nil
+}
+
+// BufferKey returns the name of the directory below the buffer directory
+// holding the disk buffer of the output. The key only depends on the plugin
+// name, the alias and the `buffer_id` setting so that the buffer, and all
+// unsent metrics, survive changes to any other setting of the output.
+func (c *OutputConfig) BufferKey() string {
+	if c.BufferID != "" {
+		return sanitizeBufferKey(c.BufferID)
+	}
+	if c.Alias != "" {
+		return sanitizeBufferKey(c.Name + "-" + c.Alias)
+	}
+	return sanitizeBufferKey(c.Name)
+}
+
+func sanitizeBufferKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// CheckDiskBuffers returns an error if several outputs would share the same
+// disk buffer, e.g. two instances of a plugin without an alias.
+func CheckDiskBuffers(outputs []*RunningOutput) error {
+	seen := make(map[string]*RunningOutput)
+	for _, output := range outputs {
+		path := output.DiskBufferPath()
+		if path == "" {
+			continue
+		}
+		if other, found := seen[path]; found {
+			return fmt.Errorf("outputs %s and %s use the same disk buffer %q, set a distinct alias or buffer_id",
+				other.LogName(), output.LogName(), path)
+		}
+		seen[path] = output
+	}
+	return nil
+}
+
+// OrphanedDiskBuffers returns the disk buffers found in the buffer directories
+// of the outputs which are not used by any of the outputs. Those buffers might
+// contain metrics never sent, e.g. after renaming an output.
+func OrphanedDiskBuffers(outputs []*RunningOutput) []string {
+	used := make(map[string]bool)
+	seen := make(map[string]bool)
+	directories := make([]string, 0)
+	for _, output := range outputs {
+		dir := output.Config.BufferDirectory
+		if dir == "" {
+			continue
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			directories = append(directories, dir)
+		}
+		if path := output.DiskBufferPath(); path != "" {
+			used[path] = true
+		}
+	}
+
+	var orphaned []string
+	for _, dir := range directories {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if !entry.IsDir() || used[path] {
+				continue
+			}
+			segments, err := filepath.Glob(filepath.Join(path, "*"+diskBufferSegmentExt))
+			if err == nil && len(segments) > 0 {
+				orphaned = append(orphaned, path)
+			}
+		}
+	}
+	return orphaned
+}
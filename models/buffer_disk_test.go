@@ -0,0 +1,268 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func newTestDiskBuffer(t *testing.T, path string, capacity int, sizeLimit int64) *DiskBuffer {
+	b, err := NewDiskBuffer("test", "", path, capacity, sizeLimit)
+	require.NoError(t, err)
+	b.MetricsAdded.Set(0)
+	b.MetricsWritten.Set(0)
+	b.MetricsDropped.Set(0)
+	return b
+}
+
+func TestDiskBuffer_BatchAccept(t *testing.T) {
+	b := newTestDiskBuffer(t, t.TempDir(), 5, 0)
+	defer b.Close()
+
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+	require.Equal(t, 3, b.Len())
+
+	batch := b.Batch(2)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+			MetricTime(2),
+		}, batch)
+	require.Equal(t, 3, b.Len())
+
+	b.Accept(batch)
+	require.Equal(t, 1, b.Len())
+	require.Equal(t, int64(2), b.MetricsWritten.Get())
+
+	batch = b.Batch(2)
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{MetricTime(3)}, batch)
+}
+
+func TestDiskBuffer_Reject(t *testing.T) {
+	b := newTestDiskBuffer(t, t.TempDir(), 5, 0)
+	defer b.Close()
+
+	b.Add(MetricTime(1), MetricTime(2))
+	batch := b.Batch(2)
+	b.Add(MetricTime(3))
+	b.Reject(batch)
+
+	require.Equal(t, int64(0), b.MetricsDropped.Get())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+			MetricTime(2),
+			MetricTime(3),
+		}, b.Batch(5))
+}
+
+func TestDiskBuffer_Drop(t *testing.T) {
+	b := newTestDiskBuffer(t, t.TempDir(), 5, 0)
+	defer b.Close()
+
+	b.Add(MetricTime(1), MetricTime(2))
+	batch := b.Batch(2)
+	b.Add(MetricTime(3))
+	b.Drop(batch, DropReasonPermanentError, nil)
+
+	require.Equal(t, int64(2), b.MetricsDropped.Get())
+	require.Equal(t, int64(0), b.MetricsWritten.Get())
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{MetricTime(3)}, b.Batch(5))
+}
+
+func TestDiskBuffer_ConcurrentBatches(t *testing.T) {
+	b := newTestDiskBuffer(t, t.TempDir(), 5, 0)
+	defer b.Close()
+
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3), MetricTime(4), MetricTime(5))
+	first := b.Batch(2)
+	second := b.Batch(2)
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{MetricTime(1), MetricTime(2)}, first)
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{MetricTime(3), MetricTime(4)}, second)
+
+	b.Accept(second)
+	require.Equal(t, 3, b.Len())
+	require.Equal(t, int64(2), b.MetricsWritten.Get())
+
+	b.Reject(first)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+			MetricTime(2),
+			MetricTime(5),
+		}, b.Batch(5))
+}
+
+func TestDiskBuffer_CapacityDropsOldest(t *testing.T) {
+	b := newTestDiskBuffer(t, t.TempDir(), 3, 0)
+	defer b.Close()
+
+	dropped := b.Add(MetricTime(1), MetricTime(2), MetricTime(3), MetricTime(4), MetricTime(5))
+	require.Equal(t, 2, dropped)
+	require.Equal(t, int64(2), b.MetricsDropped.Get())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+			MetricTime(4),
+			MetricTime(5),
+		}, b.Batch(5))
+}
+
+func TestDiskBuffer_CapacityExceededWithOutstandingBatch(t *testing.T) {
+	b := newTestDiskBuffer(t, t.TempDir(), 3, 0)
+	defer b.Close()
+
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+	batch := b.Batch(2)
+
+	// Exceeding the limit drops the oldest metric of the outstanding batch
+	require.Equal(t, 1, b.Add(MetricTime(4)))
+	require.Equal(t, int64(1), b.MetricsDropped.Get())
+
+	// Accepting the batch only counts the metric still in the buffer
+	b.Accept(batch)
+	require.Equal(t, int64(1), b.MetricsWritten.Get())
+	require.Equal(t, int64(1), b.MetricsDropped.Get())
+	require.Equal(t, 2, b.Len())
+
+	// Same for dropping the batch
+	batch = b.Batch(1)
+	require.Equal(t, 1, b.Add(MetricTime(5), MetricTime(6)))
+	b.Drop(batch, DropReasonPermanentError, nil)
+	require.Equal(t, int64(1), b.MetricsWritten.Get())
+	require.Equal(t, int64(2), b.MetricsDropped.Get())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(4),
+			MetricTime(5),
+			MetricTime(6),
+		}, b.Batch(5))
+}
+
+func TestDiskBuffer_SizeLimit(t *testing.T) {
+	m := MetricTime(1)
+	payload, err := metric.ToBytes(m)
+	require.NoError(t, err)
+	recordSize := int64(diskBufferHeaderSize + len(payload))
+
+	b := newTestDiskBuffer(t, t.TempDir(), 100, 2*recordSize)
+	defer b.Close()
+
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+	require.Equal(t, 2, b.Len())
+	require.Equal(t, int64(1), b.MetricsDropped.Get())
+}
+
+func TestDiskBuffer_Restore(t *testing.T) {
+	path := t.TempDir()
+
+	b := newTestDiskBuffer(t, path, 10, 0)
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+	b.Accept(b.Batch(1))
+	require.NoError(t, b.Close())
+
+	b = newTestDiskBuffer(t, path, 10, 0)
+	defer b.Close()
+	require.Equal(t, 2, b.Len())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(2),
+			MetricTime(3),
+		}, b.Batch(5))
+}
+
+func TestDiskBuffer_RestoreTruncatedSegment(t *testing.T) {
+	path := t.TempDir()
+
+	b := newTestDiskBuffer(t, path, 10, 0)
+	b.Add(MetricTime(1), MetricTime(2))
+	require.NoError(t, b.Close())
+
+	// Simulate a crash while writing the last record
+	filename := b.segmentPath(0)
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(filename, info.Size()-3))
+
+	b = newTestDiskBuffer(t, path, 10, 0)
+	defer b.Close()
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{MetricTime(1)}, b.Batch(5))
+}
+
+func TestDiskBuffer_SegmentsRemoved(t *testing.T) {
+	defer func(size int64) { diskBufferSegmentSize = size }(diskBufferSegmentSize)
+	diskBufferSegmentSize = 1
+
+	path := t.TempDir()
+	b := newTestDiskBuffer(t, path, 10, 0)
+	defer b.Close()
+
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+	segments, err := filepath.Glob(filepath.Join(path, "*"+diskBufferSegmentExt))
+	require.NoError(t, err)
+	require.Len(t, segments, 3)
+
+	b.Accept(b.Batch(2))
+	segments, err = filepath.Glob(filepath.Join(path, "*"+diskBufferSegmentExt))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+}
+
+func TestDiskBuffer_SyncOnBatch(t *testing.T) {
+	b := newTestDiskBuffer(t, t.TempDir(), 5, 0)
+	defer b.Close()
+
+	// Adding metrics does not sync the segment on every call
+	b.Add(MetricTime(1))
+	b.Add(MetricTime(2))
+	require.True(t, b.dirty)
+
+	b.Batch(1)
+	require.False(t, b.dirty)
+}
+
+func TestDiskBuffer_AcceptsTrackingMetricOnAdd(t *testing.T) {
+	b := newTestDiskBuffer(t, t.TempDir(), 5, 0)
+	defer b.Close()
+
+	var accepted bool
+	mm := &MockMetric{
+		Metric:  Metric(),
+		AcceptF: func() { accepted = true },
+	}
+	b.Add(mm)
+	require.True(t, accepted)
+}
+
+func TestDiskBuffer_CheckSharedBuffer(t *testing.T) {
+	dir := t.TempDir()
+	outputs := []*RunningOutput{
+		NewRunningOutput(&mockOutput{}, &OutputConfig{Name: "file", BufferStrategy: "disk", BufferDirectory: dir}, 0, 0),
+		NewRunningOutput(&mockOutput{}, &OutputConfig{Name: "file", BufferStrategy: "disk", BufferDirectory: dir}, 0, 0),
+	}
+	require.ErrorContains(t, CheckDiskBuffers(outputs), "use the same disk buffer")
+
+	outputs[1].Config.Alias = "second"
+	require.NoError(t, CheckDiskBuffers(outputs))
+}
+
+func TestDiskBuffer_Orphaned(t *testing.T) {
+	dir := t.TempDir()
+	for _, key := range []string{"file", "file-old", "empty"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, key), 0750))
+	}
+	for _, key := range []string{"file", "file-old"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, key, "1"+diskBufferSegmentExt), nil, 0640))
+	}
+
+	outputs := []*RunningOutput{
+		NewRunningOutput(&mockOutput{}, &OutputConfig{Name: "file", BufferStrategy: "disk", BufferDirectory: dir}, 0, 0),
+	}
+	require.Equal(t, []string{filepath.Join(dir, "file-old")}, OrphanedDiskBuffers(outputs))
+}
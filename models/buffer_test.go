@@ -228,6 +228,108 @@ func TestBuffer_RejectWithRoom(t *testing.T) {
 		}, batch)
 }
 
+func TestBuffer_Drop(t *testing.T) {
+	b := setup(NewBuffer("test", "", 5))
+	b.Add(MetricTime(1))
+	b.Add(MetricTime(2))
+	b.Add(MetricTime(3))
+	batch := b.Batch(2)
+	b.Add(MetricTime(4))
+	b.Drop(batch, DropReasonPermanentError, nil)
+
+	require.Equal(t, int64(2), b.MetricsDropped.Get())
+	require.Equal(t, int64(0), b.MetricsWritten.Get())
+	require.Equal(t, 2, b.Len())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+			MetricTime(4),
+		}, b.Batch(5))
+}
+
+func TestBuffer_ConcurrentBatches(t *testing.T) {
+	b := setup(NewBuffer("test", "", 5))
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3), MetricTime(4))
+
+	first := b.Batch(2)
+	second := b.Batch(2)
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{MetricTime(1), MetricTime(2)}, first)
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{MetricTime(3), MetricTime(4)}, second)
+	require.Empty(t, b.Batch(2))
+	require.Equal(t, 4, b.Len())
+
+	b.Accept(second)
+	require.Equal(t, 2, b.Len())
+	require.Equal(t, int64(2), b.MetricsWritten.Get())
+
+	b.Add(MetricTime(5))
+	b.Reject(first)
+	require.Equal(t, 3, b.Len())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+			MetricTime(2),
+			MetricTime(5),
+		}, b.Batch(5))
+}
+
+func TestBuffer_ConcurrentBatchesRejectOverflow(t *testing.T) {
+	b := setup(NewBuffer("test", "", 4))
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3), MetricTime(4))
+
+	first := b.Batch(2)
+	second := b.Batch(2)
+	b.Add(MetricTime(5), MetricTime(6), MetricTime(7))
+
+	// Only one slot is left for the rejected metrics
+	b.Reject(second)
+	b.Reject(first)
+	require.Equal(t, int64(3), b.MetricsDropped.Get())
+	require.Equal(t, 4, b.Len())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(4),
+			MetricTime(5),
+			MetricTime(6),
+			MetricTime(7),
+		}, b.Batch(5))
+}
+
+func TestBuffer_ConcurrentBatchesEndedTwice(t *testing.T) {
+	b := setup(NewBuffer("test", "", 5))
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3), MetricTime(4))
+
+	first := b.Batch(2)
+	second := b.Batch(2)
+	b.Reject(first)
+	require.Equal(t, 4, b.Len())
+
+	// Ending a batch twice does not release the slots of the other batch
+	b.Accept(first)
+	require.Equal(t, 4, b.Len())
+	b.Accept(second)
+	require.Equal(t, 2, b.Len())
+}
+
+func TestBuffer_ConcurrentBatchesRejectReorders(t *testing.T) {
+	b := setup(NewBuffer("test", "", 5))
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3), MetricTime(4))
+
+	// Rejected metrics are put in front of the buffer
+	first := b.Batch(2)
+	second := b.Batch(2)
+	b.Reject(first)
+	b.Reject(second)
+	require.Equal(t, 4, b.Len())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+			MetricTime(4),
+			MetricTime(1),
+			MetricTime(2),
+		}, b.Batch(5))
+}
+
 func TestBuffer_RejectNothingNewFull(t *testing.T) {
 	b := setup(NewBuffer("test", "", 5))
 	b.Add(MetricTime(1))
@@ -0,0 +1,314 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// Default value of the tags folded by the cardinality limiter
+const defaultCardinalityFoldValue = "other"
+
+const (
+	// Maximum number of measurements tracked separately, further
+	// measurements share a single series limit
+	maxCardinalityMeasurements = 1000
+	// Maximum number of tag keys per measurement with tracked values
+	maxCardinalityTagKeys = 64
+	// Maximum number of internal metrics registered per limiter
+	maxCardinalityStats = 1000
+	// Measurement and tag key of the internal metrics exceeding the caps
+	cardinalityOtherLabel = "*"
+)
+
+// CardinalityConfig limits the number of distinct series per measurement.
+type CardinalityConfig struct {
+	// Limit is the maximum number of series per measurement, zero disables
+	// the limiter
+	Limit int
+	// Action for metrics of new series exceeding the limit, either "drop"
+	// or "fold"
+	Action string
+	// FoldValue replaces the value of the folded tag
+	FoldValue string
+	// ResetInterval is the interval for forgetting all series, zero keeps
+	// them forever
+	ResetInterval time.Duration
+}
+
+// Validate checks the settings and applies the defaults if the limiter is
+// enabled.
+func (c *CardinalityConfig) Validate() error {
+	if c.Limit < 0 {
+		return errors.New("cardinality limit must not be negative")
+	}
+	if c.ResetInterval < 0 {
+		return errors.New("cardinality reset interval must not be negative")
+	}
+
+	switch c.Action {
+	case "", "drop", "fold":
+	default:
+		return fmt.Errorf("invalid cardinality action %q", c.Action)
+	}
+
+	if c.Limit == 0 {
+		return nil
+	}
+	if c.Action == "" {
+		c.Action = "drop"
+	}
+	if c.FoldValue == "" {
+		c.FoldValue = defaultCardinalityFoldValue
+	}
+	return nil
+}
+
+// CardinalityLimiter tracks the series of each measurement and limits their
+// number. The memory used per measurement is bounded by the limit, as only
+// the hashes of admitted series are kept, and a fixed-size sketch per tag key
+// estimating the number of distinct tag values. The number of measurements and
+// tag keys tracked as well as the internal metrics registered are capped to
+// bound the memory used in total.
+type CardinalityLimiter struct {
+	config CardinalityConfig
+	log    telegraf.Logger
+	tags   map[string]string
+
+	sync.Mutex
+	measurements map[string]*measurementSeries
+	other        *measurementSeries // shared by measurements beyond the cap
+	stats        map[string]selfstat.Stat
+	lastReset    time.Time
+}
+
+type measurementSeries struct {
+	series map[uint64]bool         // admitted series, true if folded
+	folded int                     // number of admitted folded series
+	values map[string]*hyperLogLog // distinct values per tag key
+	warned bool                    // the limit was reported
+}
+
+// NewCardinalityLimiter returns a limiter for the validated configuration.
+// The given tags are added to the internal metrics of the limiter to identify
+// the plugin.
+func NewCardinalityLimiter(config CardinalityConfig, log telegraf.Logger, tags map[string]string) *CardinalityLimiter {
+	return &CardinalityLimiter{
+		config:       config,
+		log:          log,
+		tags:         tags,
+		measurements: make(map[string]*measurementSeries),
+		stats:        make(map[string]selfstat.Stat),
+		lastReset:    time.Now(),
+	}
+}
+
+// Apply checks the series of the metric against the limit of its measurement.
+// Metrics of new series beyond the limit have the tag with the most distinct
+// values folded or, if folding is disabled or does not help, are rejected by
+// returning false.
+func (l *CardinalityLimiter) Apply(m telegraf.Metric) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.config.ResetInterval > 0 && time.Since(l.lastReset) >= l.config.ResetInterval {
+		l.measurements = make(map[string]*measurementSeries)
+		l.other = nil
+		l.lastReset = time.Now()
+	}
+
+	name, state := l.measurement(m.Name())
+	for _, tag := range m.TagList() {
+		sketch, found := state.values[tag.Key]
+		if !found {
+			if len(state.values) >= maxCardinalityTagKeys {
+				continue
+			}
+			sketch = &hyperLogLog{}
+			state.values[tag.Key] = sketch
+		}
+		sketch.add(tag.Value)
+	}
+
+	id := m.HashID()
+	if _, found := state.series[id]; found {
+		return true
+	}
+	if len(state.series)-state.folded < l.config.Limit {
+		state.series[id] = false
+		return true
+	}
+
+	// The limit is exceeded, blame the tag with the most distinct values
+	key := state.offendingKey(m, l.config.FoldValue)
+	if !state.warned {
+		state.warned = true
+		if state == l.other {
+			l.log.Warnf("Limit of %d series reached for the measurements beyond the first %d, "+
+				"tag %q has the most distinct values", l.config.Limit, maxCardinalityMeasurements, key)
+		} else {
+			l.log.Warnf("Limit of %d series reached for measurement %q, tag %q has the most distinct values",
+				l.config.Limit, name, key)
+		}
+	}
+
+	if l.config.Action == "fold" && key != "" {
+		m.AddTag(key, l.config.FoldValue)
+		id = m.HashID()
+		if _, found := state.series[id]; found {
+			l.stat("series_folded", name, state.label(key)).Incr(1)
+			return true
+		}
+		// Allow as many folded series as regular ones
+		if state.folded < l.config.Limit {
+			state.series[id] = true
+			state.folded++
+			l.stat("series_folded", name, state.label(key)).Incr(1)
+			return true
+		}
+	}
+
+	l.stat("series_dropped", name, state.label(key)).Incr(1)
+	return false
+}
+
+// measurement returns the name used in the internal metrics and the state of
+// the given measurement. Measurements beyond the cap share a single state.
+func (l *CardinalityLimiter) measurement(name string) (string, *measurementSeries) {
+	if state, found := l.measurements[name]; found {
+		return name, state
+	}
+	if len(l.measurements) < maxCardinalityMeasurements {
+		state := newMeasurementSeries()
+		l.measurements[name] = state
+		return name, state
+	}
+	if l.other == nil {
+		l.other = newMeasurementSeries()
+	}
+	return cardinalityOtherLabel, l.other
+}
+
+// stat returns the internal metric of the given measurement and tag key. Once
+// the cap of registered metrics is reached, further measurements and tag keys
+// share a single metric.
+func (l *CardinalityLimiter) stat(field, measurement, key string) selfstat.Stat {
+	id := field + "\x00" + measurement + "\x00" + key
+	if stat, found := l.stats[id]; found {
+		return stat
+	}
+	if len(l.stats) >= maxCardinalityStats {
+		measurement, key = cardinalityOtherLabel, cardinalityOtherLabel
+		id = field + "\x00" + measurement + "\x00" + key
+		if stat, found := l.stats[id]; found {
+			return stat
+		}
+	}
+
+	tags := make(map[string]string, len(l.tags)+2)
+	for k, v := range l.tags {
+		tags[k] = v
+	}
+	tags["measurement"] = measurement
+	tags["tag_key"] = key
+	stat := selfstat.Register("cardinality", field, tags)
+	l.stats[id] = stat
+	return stat
+}
+
+func newMeasurementSeries() *measurementSeries {
+	return &measurementSeries{
+		series: make(map[uint64]bool),
+		values: make(map[string]*hyperLogLog),
+	}
+}
+
+// offendingKey returns the tag key of the metric with the largest number of
+// distinct values, skipping already folded tags. Tag keys beyond the cap have
+// no tracked values and are only chosen if no other key is available.
+func (s *measurementSeries) offendingKey(m telegraf.Metric, foldValue string) string {
+	var key string
+	var highest uint64
+	for _, tag := range m.TagList() {
+		if tag.Value == foldValue {
+			continue
+		}
+		var n uint64
+		if sketch, found := s.values[tag.Key]; found {
+			n = sketch.estimate()
+		}
+		if key == "" || n > highest {
+			key, highest = tag.Key, n
+		}
+	}
+	return key
+}
+
+// label returns the tag key used in the internal metrics, folding the keys
+// without tracked values.
+func (s *measurementSeries) label(key string) string {
+	if _, found := s.values[key]; !found && key != "" {
+		return cardinalityOtherLabel
+	}
+	return key
+}
+
+// Number of registers of the HyperLogLog sketch as a power of two, resulting
+// in a standard error of about 6.5%
+const hyperLogLogPrecision = 8
+
+// hyperLogLog estimates the number of distinct values added using a fixed
+// amount of memory.
+type hyperLogLog struct {
+	registers [1 << hyperLogLogPrecision]uint8
+}
+
+func (h *hyperLogLog) add(value string) {
+	hash := fnv.New64a()
+	hash.Write([]byte(value))
+	x := mix64(hash.Sum64())
+
+	index := x >> (64 - hyperLogLogPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hyperLogLogPrecision|1<<(hyperLogLogPrecision-1)) + 1)
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+func (h *hyperLogLog) estimate() uint64 {
+	const m = float64(1 << hyperLogLogPrecision)
+
+	var sum float64
+	var zeros int
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// mix64 spreads the bits of FNV hashes of short, similar values, e.g.
+// sequential IDs, over the whole word.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
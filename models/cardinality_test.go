@@ -0,0 +1,148 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func newSeries(name string, tags map[string]string) telegraf.Metric {
+	return metric.New(name, tags, map[string]interface{}{"value": 42}, time.Unix(0, 0))
+}
+
+func TestCardinalityLimiterDrop(t *testing.T) {
+	cfg := CardinalityConfig{Limit: 10}
+	require.NoError(t, cfg.Validate())
+	limiter := NewCardinalityLimiter(cfg, testutil.Logger{}, map[string]string{"test": t.Name()})
+
+	for i := 0; i < 20; i++ {
+		m := newSeries("cpu", map[string]string{"host": "a", "id": strconv.Itoa(i)})
+		require.Equal(t, i < 10, limiter.Apply(m), "series %d", i)
+	}
+
+	// Known series and other measurements are not affected
+	require.True(t, limiter.Apply(newSeries("cpu", map[string]string{"host": "a", "id": "3"})))
+	require.True(t, limiter.Apply(newSeries("mem", map[string]string{"host": "a", "id": "42"})))
+
+	stat := selfstat.Register("cardinality", "series_dropped", map[string]string{
+		"test":        t.Name(),
+		"measurement": "cpu",
+		"tag_key":     "id",
+	})
+	require.Equal(t, int64(10), stat.Get())
+}
+
+func TestCardinalityLimiterFold(t *testing.T) {
+	cfg := CardinalityConfig{Limit: 2, Action: "fold"}
+	require.NoError(t, cfg.Validate())
+	limiter := NewCardinalityLimiter(cfg, testutil.Logger{}, map[string]string{"test": t.Name()})
+
+	for i := 0; i < 20; i++ {
+		m := newSeries("cpu", map[string]string{"host": strconv.Itoa(i % 2), "id": strconv.Itoa(i)})
+		require.True(t, limiter.Apply(m), "series %d", i)
+		if i >= 2 {
+			require.Equal(t, "other", m.Tags()["id"])
+		}
+	}
+
+	// The folded series are limited as well
+	m := newSeries("cpu", map[string]string{"host": "3", "id": "42"})
+	require.False(t, limiter.Apply(m))
+
+	stat := selfstat.Register("cardinality", "series_folded", map[string]string{
+		"test":        t.Name(),
+		"measurement": "cpu",
+		"tag_key":     "id",
+	})
+	require.Equal(t, int64(18), stat.Get())
+}
+
+func TestCardinalityLimiterReset(t *testing.T) {
+	cfg := CardinalityConfig{Limit: 1, ResetInterval: 50 * time.Millisecond}
+	require.NoError(t, cfg.Validate())
+	limiter := NewCardinalityLimiter(cfg, testutil.Logger{}, map[string]string{"test": t.Name()})
+
+	require.True(t, limiter.Apply(newSeries("cpu", map[string]string{"id": "1"})))
+	require.False(t, limiter.Apply(newSeries("cpu", map[string]string{"id": "2"})))
+	time.Sleep(100 * time.Millisecond)
+	require.True(t, limiter.Apply(newSeries("cpu", map[string]string{"id": "2"})))
+}
+
+func TestCardinalityLimiterCaps(t *testing.T) {
+	cfg := CardinalityConfig{Limit: 1}
+	require.NoError(t, cfg.Validate())
+	limiter := NewCardinalityLimiter(cfg, testutil.Logger{}, map[string]string{"test": t.Name()})
+
+	// Measurements beyond the cap share a single series limit
+	for i := 0; i < maxCardinalityMeasurements; i++ {
+		require.True(t, limiter.Apply(newSeries("m"+strconv.Itoa(i), map[string]string{"id": "0"})))
+	}
+	require.True(t, limiter.Apply(newSeries("late1", map[string]string{"id": "0"})))
+	require.False(t, limiter.Apply(newSeries("late2", map[string]string{"id": "0"})))
+	require.Len(t, limiter.measurements, maxCardinalityMeasurements)
+	stat := selfstat.Register("cardinality", "series_dropped", map[string]string{
+		"test":        t.Name(),
+		"measurement": "*",
+		"tag_key":     "id",
+	})
+	require.Equal(t, int64(1), stat.Get())
+
+	// Tag keys beyond the cap are not tracked
+	tags := make(map[string]string, maxCardinalityTagKeys+10)
+	for i := 0; i < maxCardinalityTagKeys+10; i++ {
+		tags["k"+strconv.Itoa(i)] = "v"
+	}
+	require.False(t, limiter.Apply(newSeries("m0", tags)))
+	require.Len(t, limiter.measurements["m0"].values, maxCardinalityTagKeys)
+
+	// The internal metrics beyond the cap are shared
+	for i := 0; i < maxCardinalityMeasurements; i++ {
+		require.False(t, limiter.Apply(newSeries("m"+strconv.Itoa(i), map[string]string{"id": "1"})))
+	}
+	require.LessOrEqual(t, len(limiter.stats), maxCardinalityStats+2)
+	stat = selfstat.Register("cardinality", "series_dropped", map[string]string{
+		"test":        t.Name(),
+		"measurement": "*",
+		"tag_key":     "*",
+	})
+	require.Positive(t, stat.Get())
+}
+
+func TestCardinalityConfigValidate(t *testing.T) {
+	// Defaults are only applied if the limiter is enabled
+	cfg := CardinalityConfig{}
+	require.NoError(t, cfg.Validate())
+	require.Empty(t, cfg.Action)
+
+	cfg = CardinalityConfig{Limit: 10}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, "drop", cfg.Action)
+	require.Equal(t, "other", cfg.FoldValue)
+
+	cfg = CardinalityConfig{Action: "foo"}
+	require.ErrorContains(t, cfg.Validate(), `invalid cardinality action "foo"`)
+
+	cfg = CardinalityConfig{Limit: -1}
+	require.ErrorContains(t, cfg.Validate(), "must not be negative")
+}
+
+func TestHyperLogLog(t *testing.T) {
+	for _, n := range []int{1, 10, 100, 1000, 100000} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			sketch := &hyperLogLog{}
+			for i := 0; i < n; i++ {
+				sketch.add("value-" + strconv.Itoa(i))
+				sketch.add("value-" + strconv.Itoa(i))
+			}
+			require.InEpsilon(t, n, sketch.estimate(), 0.2)
+		})
+	}
+}
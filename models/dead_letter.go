@@ -0,0 +1,104 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// Reasons for dropping metrics as reported to the dead-letter output
+const (
+	// DropReasonBufferFull marks metrics pushed out of a full buffer
+	DropReasonBufferFull = "buffer_full"
+	// DropReasonBufferError marks metrics the buffer failed to store
+	DropReasonBufferError = "buffer_error"
+	// DropReasonPermanentError marks metrics the output failed to write with
+	// an error classified as permanent
+	DropReasonPermanentError = "permanent_error"
+	// DropReasonMaxAttempts marks metrics the output failed to write within
+	// the maximum number of attempts
+	DropReasonMaxAttempts = "max_attempts"
+)
+
+// SetDeadLetter sets the output receiving the metrics dropped by this output,
+// nil disables forwarding dropped metrics.
+func (r *RunningOutput) SetDeadLetter(output *RunningOutput) {
+	r.deadLetter.Store(output)
+}
+
+// sendDeadLetter forwards a copy of the dropped metric, annotated with this
+// output and the reason for dropping it, to the dead-letter output if any.
+func (r *RunningOutput) sendDeadLetter(m telegraf.Metric, reason string, err error) {
+	target := r.deadLetter.Load()
+	if target == nil {
+		return
+	}
+
+	// Tracking information is removed as the metric is already considered
+	// undelivered
+	letter := metric.FromMetric(m)
+	letter.AddTag("dead_letter_output", r.Config.Name)
+	if r.Config.Alias != "" {
+		letter.AddTag("dead_letter_alias", r.Config.Alias)
+	}
+	letter.AddTag("dead_letter_reason", reason)
+	if err != nil {
+		letter.AddField("dead_letter_error", err.Error())
+	}
+	target.AddMetric(letter)
+}
+
+// LinkDeadLetters connects the outputs with a dead-letter setting to the
+// output with the referenced alias. Chains of dead-letter outputs must not
+// form a cycle as a dropping output holds its buffer lock while forwarding
+// the metrics. Standby outputs of failover groups cannot be targets as they
+// never write their own buffer.
+func LinkDeadLetters(outputs []*RunningOutput) error {
+	standbys := failoverStandbys(outputs)
+	byAlias := make(map[string]*RunningOutput, len(outputs))
+	duplicates := make(map[string]bool)
+	for _, output := range outputs {
+		if output.Config.Alias == "" {
+			continue
+		}
+		if _, found := byAlias[output.Config.Alias]; found {
+			duplicates[output.Config.Alias] = true
+		}
+		byAlias[output.Config.Alias] = output
+	}
+
+	targets := make(map[*RunningOutput]*RunningOutput, len(outputs))
+	for _, output := range outputs {
+		alias := output.Config.DeadLetter
+		if alias == "" {
+			continue
+		}
+		if duplicates[alias] {
+			return fmt.Errorf("dead-letter output of %s is ambiguous, multiple outputs with alias %q", output.LogName(), alias)
+		}
+		target, found := byAlias[alias]
+		if !found {
+			return fmt.Errorf("dead-letter output %q of %s not found", alias, output.LogName())
+		}
+		if group, found := standbys[target]; found {
+			return fmt.Errorf("dead-letter output %q of %s must not be a standby of failover group %q", alias, output.LogName(), group)
+		}
+		targets[output] = target
+	}
+
+	for output := range targets {
+		next := targets[output]
+		for i := 0; next != nil && i < len(outputs); i++ {
+			if next == output {
+				return fmt.Errorf("dead-letter outputs of %s form a cycle", output.LogName())
+			}
+			next = targets[next]
+		}
+	}
+
+	for _, output := range outputs {
+		output.SetDeadLetter(targets[output])
+	}
+	return nil
+}
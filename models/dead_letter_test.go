@@ -0,0 +1,130 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestDeadLetter(t *testing.T) {
+	source := &classifyingOutput{}
+	source.failWrite = true
+	source.permanent = true
+	ro := NewRunningOutput(source, &OutputConfig{Name: "source", Alias: "dl_source"}, 2, 3)
+	require.NoError(t, ro.Init())
+
+	target := &mockOutput{}
+	dl := NewRunningOutput(target, &OutputConfig{Name: "target"}, 10, 10)
+	ro.SetDeadLetter(dl)
+
+	m := metric.New("cpu", map[string]string{"host": "a"}, map[string]interface{}{"value": 42}, time.Unix(0, 0))
+	for i := 0; i < 4; i++ {
+		ro.AddMetric(m)
+	}
+
+	// The oldest metric is pushed out of the buffer and the first batch
+	// fails permanently
+	require.Error(t, ro.WriteBatch())
+	require.NoError(t, dl.Write())
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{
+				"host":               "a",
+				"dead_letter_output": "source",
+				"dead_letter_alias":  "dl_source",
+				"dead_letter_reason": DropReasonBufferFull,
+			},
+			map[string]interface{}{"value": 42},
+			time.Unix(0, 0),
+		),
+	}
+	for i := 0; i < 2; i++ {
+		expected = append(expected, metric.New(
+			"cpu",
+			map[string]string{
+				"host":               "a",
+				"dead_letter_output": "source",
+				"dead_letter_alias":  "dl_source",
+				"dead_letter_reason": DropReasonPermanentError,
+			},
+			map[string]interface{}{"value": 42, "dead_letter_error": "failed write"},
+			time.Unix(0, 0),
+		))
+	}
+	testutil.RequireMetricsEqual(t, expected, target.Metrics())
+
+	// Without a dead-letter output the metrics are dropped
+	ro.SetDeadLetter(nil)
+	require.Error(t, ro.WriteBatch())
+	require.Equal(t, 0, dl.BufferLength())
+}
+
+func TestDeadLetterTracking(t *testing.T) {
+	source := &classifyingOutput{}
+	source.failWrite = true
+	source.permanent = true
+	ro := NewRunningOutput(source, &OutputConfig{Name: "source"}, 10, 10)
+	require.NoError(t, ro.Init())
+
+	target := &mockOutput{}
+	dl := NewRunningOutput(target, &OutputConfig{Name: "target"}, 10, 10)
+	ro.SetDeadLetter(dl)
+
+	var delivered telegraf.DeliveryInfo
+	m, _ := metric.WithTracking(
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 42}, time.Unix(0, 0)),
+		func(info telegraf.DeliveryInfo) { delivered = info },
+	)
+	ro.AddMetric(m)
+
+	// The original metric is not delivered even if the dead letter is
+	require.Error(t, ro.WriteBatch())
+	require.NoError(t, dl.Write())
+	require.Len(t, target.Metrics(), 1)
+	require.NotNil(t, delivered)
+	require.False(t, delivered.Delivered())
+}
+
+func TestLinkDeadLetters(t *testing.T) {
+	newOutput := func(alias, deadLetter string) *RunningOutput {
+		return NewRunningOutput(&mockOutput{}, &OutputConfig{Name: "test", Alias: alias, DeadLetter: deadLetter}, 10, 10)
+	}
+
+	a := newOutput("a", "dlq")
+	b := newOutput("b", "dlq")
+	dlq := newOutput("dlq", "")
+	require.NoError(t, LinkDeadLetters([]*RunningOutput{a, b, dlq}))
+	require.Equal(t, dlq, a.deadLetter.Load())
+	require.Equal(t, dlq, b.deadLetter.Load())
+	require.Nil(t, dlq.deadLetter.Load())
+
+	err := LinkDeadLetters([]*RunningOutput{newOutput("a", "unknown")})
+	require.ErrorContains(t, err, `dead-letter output "unknown" of outputs.test::a not found`)
+
+	err = LinkDeadLetters([]*RunningOutput{newOutput("a", "b"), newOutput("b", "c"), newOutput("c", "a")})
+	require.ErrorContains(t, err, "form a cycle")
+
+	err = LinkDeadLetters([]*RunningOutput{newOutput("a", "a")})
+	require.ErrorContains(t, err, "form a cycle")
+
+	err = LinkDeadLetters([]*RunningOutput{newOutput("a", "dlq"), newOutput("dlq", ""), newOutput("dlq", "")})
+	require.ErrorContains(t, err, "ambiguous")
+
+	require.NoError(t, LinkDeadLetters([]*RunningOutput{newOutput("a", "b"), newOutput("b", "c"), newOutput("c", "")}))
+
+	// Only the first output of a failover group can be a dead-letter output
+	primary := newOutput("primary", "")
+	primary.Config.FailoverGroup = "group"
+	standby := newOutput("standby", "")
+	standby.Config.FailoverGroup = "group"
+	err = LinkDeadLetters([]*RunningOutput{newOutput("a", "standby"), primary, standby})
+	require.ErrorContains(t, err, `dead-letter output "standby" of outputs.test::a must not be a standby of failover group "group"`)
+	require.NoError(t, LinkDeadLetters([]*RunningOutput{newOutput("a", "primary"), primary, standby}))
+}
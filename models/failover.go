@@ -0,0 +1,180 @@
+package models
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// failoverGroup is an ordered list of outputs writing the metrics of a shared
+// buffer. Each batch is written to the first available member, falling over
+// to the next member on errors. The buffer of the first member, the leader,
+// is shared by the group, so the leader's batch and buffer settings apply to
+// the whole group.
+type failoverGroup struct {
+	name    string
+	members []*RunningOutput
+	active  atomic.Pointer[RunningOutput]
+}
+
+// failoverStandbys returns the outputs configured as standby of a failover
+// group, i.e. all but the first output with the same failover group setting.
+func failoverStandbys(outputs []*RunningOutput) map[*RunningOutput]string {
+	seen := make(map[string]bool)
+	standbys := make(map[*RunningOutput]string)
+	for _, output := range outputs {
+		name := output.Config.FailoverGroup
+		if name == "" {
+			continue
+		}
+		if seen[name] {
+			standbys[output] = name
+		}
+		seen[name] = true
+	}
+	return standbys
+}
+
+// LinkFailoverGroups combines the outputs with the same failover group
+// setting into groups in the order of the given outputs. Outputs not
+// belonging to a group are unlinked.
+func LinkFailoverGroups(outputs []*RunningOutput) error {
+	groups := make(map[string]*failoverGroup)
+	order := make([]*failoverGroup, 0)
+	for _, output := range outputs {
+		name := output.Config.FailoverGroup
+		if name == "" {
+			continue
+		}
+		group, found := groups[name]
+		if !found {
+			group = &failoverGroup{name: name}
+			groups[name] = group
+			order = append(order, group)
+		}
+		group.members = append(group.members, output)
+	}
+
+	for _, group := range order {
+		if len(group.members) < 2 {
+			return fmt.Errorf("failover group %q requires at least two outputs", group.name)
+		}
+		if group.members[0].Config.Concurrency > 1 {
+			return fmt.Errorf("failover group %q does not support concurrent writes", group.name)
+		}
+		if group.members[0].Config.StartupErrorBehavior == "ignore" {
+			return fmt.Errorf("first output %s of failover group %q must not ignore startup errors",
+				group.members[0].LogName(), group.name)
+		}
+		for _, member := range group.members[1:] {
+			if member.Config.Route.IsActive() {
+				return fmt.Errorf("output %s of failover group %q must not set a route, only the first output can",
+					member.LogName(), group.name)
+			}
+		}
+	}
+
+	for _, output := range outputs {
+		if group, found := groups[output.Config.FailoverGroup]; found {
+			output.failover.Store(group)
+		} else {
+			output.failover.Store(nil)
+		}
+	}
+	return nil
+}
+
+// FailoverGroup returns the name of the failover group the output belongs to,
+// if any.
+func (r *RunningOutput) FailoverGroup() string {
+	if group := r.failover.Load(); group != nil {
+		return group.name
+	}
+	return ""
+}
+
+// IsFailoverStandby returns true if the output is a member of a failover
+// group other than the first one. Standby outputs do not receive metrics as
+// the group writes the metrics of the first output's buffer.
+func (r *RunningOutput) IsFailoverStandby() bool {
+	group := r.failover.Load()
+	return group != nil && group.members[0] != r
+}
+
+// available returns true if any member of the group may be written to.
+func (g *failoverGroup) available(now time.Time) bool {
+	for _, member := range g.members {
+		if member.Connected() && member.retry.wait(now) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// write writes the batch, taken from the leader's buffer, to the first
+// member accepting it. Failed members are skipped until their retry policy
+// allows writing again, so the group fails back to a recovered member on the
+// next batch.
+func (g *failoverGroup) write(batch []telegraf.Metric) error {
+	leader := g.members[0]
+	now := time.Now()
+
+	var err error
+	for _, member := range g.members {
+		if !member.Connected() || member.retry.wait(now) > 0 {
+			continue
+		}
+
+		err = member.writeMetrics(batch)
+		if err == nil {
+			if member.retry.success() {
+				member.log.Info("Circuit breaker closed, resuming writes")
+			}
+			g.activate(member)
+			leader.buffer.Accept(batch)
+			return nil
+		}
+
+		if member.isPermanentError(err) {
+			member.log.Errorf("Dropping batch of %d metrics due to permanent error", len(batch))
+			leader.buffer.Drop(batch, DropReasonPermanentError, err)
+			return err
+		}
+
+		// Batches are kept in the shared buffer until written, so the
+		// maximum number of attempts does not apply to group members
+		if member.retry.memberFailure(now) {
+			member.log.Warnf("Circuit breaker opened after %d consecutive failed writes, pausing writes for %s",
+				member.retry.policy.BreakerThreshold, member.retry.policy.BreakerTimeout)
+		}
+		member.log.Errorf("Writing to failover group %q failed: %v", g.name, err)
+	}
+
+	leader.buffer.Reject(batch)
+	if err == nil {
+		return fmt.Errorf("no output of failover group %q available", g.name)
+	}
+	return fmt.Errorf("writing to all outputs of failover group %q failed, last error: %w", g.name, err)
+}
+
+// activate records the member successfully written to and logs switches
+// between the members.
+func (g *failoverGroup) activate(member *RunningOutput) {
+	previous := g.active.Swap(member)
+	if previous == nil || previous == member {
+		return
+	}
+
+	for _, m := range g.members {
+		switch m {
+		case member:
+			member.log.Infof("Failing back from %s in failover group %q", previous.LogName(), g.name)
+			return
+		case previous:
+			member.log.Warnf("Failing over from %s in failover group %q", previous.LogName(), g.name)
+			return
+		}
+	}
+}
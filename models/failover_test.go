@@ -0,0 +1,120 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/metric"
+)
+
+func TestFailoverGroup(t *testing.T) {
+	primary := &mockOutput{}
+	standby := &mockOutput{}
+	newOutput := func(output *mockOutput, alias string) *RunningOutput {
+		ro := NewRunningOutput(output, &OutputConfig{Name: "test", Alias: alias, FailoverGroup: "influx"}, 2, 10)
+		require.NoError(t, ro.Init())
+		require.NoError(t, ro.Connect())
+		return ro
+	}
+	first := newOutput(primary, "primary")
+	second := newOutput(standby, "standby")
+	outputs := []*RunningOutput{first, second}
+	require.NoError(t, LinkFailoverGroups(outputs))
+	require.Equal(t, "influx", first.FailoverGroup())
+	require.False(t, first.IsFailoverStandby())
+	require.True(t, second.IsFailoverStandby())
+
+	// Only the first output receives the metrics
+	m := metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 42}, time.Unix(0, 0))
+	require.Equal(t, []*RunningOutput{first}, RouteMetric(outputs, m))
+	for i := 0; i < 3; i++ {
+		first.AddMetric(m)
+	}
+
+	// Write to the standby output while the first output fails
+	primary.failWrite = true
+	require.NoError(t, first.Write())
+	require.Empty(t, primary.Metrics())
+	require.Len(t, standby.Metrics(), 3)
+	require.Equal(t, second, first.failover.Load().active.Load())
+
+	// Fail back once the first output recovers
+	primary.failWrite = false
+	first.AddMetric(m)
+	require.NoError(t, first.Write())
+	require.Len(t, primary.Metrics(), 1)
+	require.Equal(t, first, first.failover.Load().active.Load())
+
+	// Keep the metrics in the shared buffer if all outputs fail
+	primary.failWrite = true
+	standby.failWrite = true
+	first.AddMetric(m)
+	require.ErrorContains(t, first.Write(), `writing to all outputs of failover group "influx" failed`)
+	require.Equal(t, 1, first.BufferLength())
+
+	standby.failWrite = false
+	require.NoError(t, first.Write())
+	require.Len(t, standby.Metrics(), 4)
+	require.Equal(t, 0, first.BufferLength())
+}
+
+func TestFailoverGroupRetryPolicy(t *testing.T) {
+	primary := &mockOutput{failWrite: true}
+	standby := &mockOutput{}
+	first := NewRunningOutput(primary, &OutputConfig{
+		Name:          "test",
+		FailoverGroup: "influx",
+		RetryPolicy:   RetryPolicy{Backoff: time.Hour},
+	}, 10, 10)
+	second := NewRunningOutput(standby, &OutputConfig{Name: "test", FailoverGroup: "influx"}, 10, 10)
+	for _, ro := range []*RunningOutput{first, second} {
+		require.NoError(t, ro.Init())
+		require.NoError(t, ro.Connect())
+	}
+	require.NoError(t, LinkFailoverGroups([]*RunningOutput{first, second}))
+
+	m := metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 42}, time.Unix(0, 0))
+	first.AddMetric(m)
+	require.NoError(t, first.Write())
+	require.Len(t, standby.Metrics(), 1)
+
+	// The failed output is skipped during its backoff
+	primary.failWrite = false
+	first.AddMetric(m)
+	require.NoError(t, first.Write())
+	require.Empty(t, primary.Metrics())
+	require.Len(t, standby.Metrics(), 2)
+}
+
+func TestLinkFailoverGroups(t *testing.T) {
+	newOutput := func(group string, cfg OutputConfig) *RunningOutput {
+		cfg.Name = "test"
+		cfg.FailoverGroup = group
+		return NewRunningOutput(&mockOutput{}, &cfg, 10, 10)
+	}
+
+	err := LinkFailoverGroups([]*RunningOutput{newOutput("a", OutputConfig{}), newOutput("b", OutputConfig{})})
+	require.ErrorContains(t, err, `failover group "a" requires at least two outputs`)
+
+	err = LinkFailoverGroups([]*RunningOutput{
+		newOutput("a", OutputConfig{}),
+		newOutput("a", OutputConfig{Route: Route{Default: true}}),
+	})
+	require.ErrorContains(t, err, "must not set a route")
+
+	err = LinkFailoverGroups([]*RunningOutput{
+		newOutput("a", OutputConfig{StartupErrorBehavior: "ignore"}),
+		newOutput("a", OutputConfig{}),
+	})
+	require.ErrorContains(t, err, "must not ignore startup errors")
+
+	// Outputs leaving a group are unlinked
+	output := newOutput("a", OutputConfig{})
+	require.NoError(t, LinkFailoverGroups([]*RunningOutput{output, newOutput("a", OutputConfig{})}))
+	require.Equal(t, "a", output.FailoverGroup())
+	output.Config.FailoverGroup = ""
+	require.NoError(t, LinkFailoverGroups([]*RunningOutput{output}))
+	require.Empty(t, output.FailoverGroup())
+}
@@ -141,19 +141,7 @@ func (f *Filter) Select(metric telegraf.Metric) (bool, error) {
 	}
 
 	if f.metricFilter != nil {
-		result, _, err := f.metricFilter.Eval(map[string]interface{}{
-			"name":   metric.Name(),
-			"tags":   metric.Tags(),
-			"fields": metric.Fields(),
-			"time":   metric.Time(),
-		})
-		if err != nil {
-			return true, err
-		}
-		if r, ok := result.Value().(bool); ok {
-			return r, nil
-		}
-		return true, fmt.Errorf("invalid result type %T", result.Value())
+		return evalMetricExpression(f.metricFilter, metric)
 	}
 
 	return true, nil
@@ -251,12 +239,15 @@ func (f *Filter) filterTags(metric telegraf.Metric) {
 
 // Compile the metric filter
 func (f *Filter) compileMetricFilter() error {
-	// Reset internal state
-	f.metricFilter = nil
-
-	// Initialize the expression
-	expression := f.MetricPass
+	var err error
+	f.metricFilter, err = compileMetricExpression(f.MetricPass)
+	return err
+}
 
+// compileMetricExpression compiles the given boolean CEL expression operating
+// on the name, tags, fields and time of a metric. An empty expression results
+// in a nil program.
+func compileMetricExpression(expression string) (cel.Program, error) {
 	// Replace python-like logic-operators
 	expression = regexp.MustCompile(`\bnot\b`).ReplaceAllString(expression, "!")
 	expression = regexp.MustCompile(`\band\b`).ReplaceAllString(expression, "&&")
@@ -264,7 +255,7 @@ func (f *Filter) compileMetricFilter() error {
 
 	// Check if we need to call into CEL at all and quit early
 	if expression == "" {
-		return nil
+		return nil, nil
 	}
 
 	// Declare the computation environment for the filter including custom functions
@@ -285,25 +276,42 @@ func (f *Filter) compileMetricFilter() error {
 		ext.Strings(),
 	)
 	if err != nil {
-		return fmt.Errorf("creating environment failed: %w", err)
+		return nil, fmt.Errorf("creating environment failed: %w", err)
 	}
 
 	// Compile the program
 	ast, issues := env.Compile(expression)
 	if issues.Err() != nil {
-		return issues.Err()
+		return nil, issues.Err()
 	}
 	// Check if we got a boolean expression needed for filtering
 	if ast.OutputType() != cel.BoolType {
-		return errors.New("expression needs to return a boolean")
+		return nil, errors.New("expression needs to return a boolean")
 	}
 
 	// Get the final program
 	options := cel.EvalOptions(
 		cel.OptOptimize,
 	)
-	f.metricFilter, err = env.Program(ast, options)
-	return err
+	return env.Program(ast, options)
+}
+
+// evalMetricExpression evaluates the compiled expression for the given metric.
+// In case of an error true is returned alongside the error.
+func evalMetricExpression(program cel.Program, metric telegraf.Metric) (bool, error) {
+	result, _, err := program.Eval(map[string]interface{}{
+		"name":   metric.Name(),
+		"tags":   metric.Tags(),
+		"fields": metric.Fields(),
+		"time":   metric.Time(),
+	})
+	if err != nil {
+		return true, err
+	}
+	if r, ok := result.Value().(bool); ok {
+		return r, nil
+	}
+	return true, fmt.Errorf("invalid result type %T", result.Value())
 }
 
 func ShouldPassFilters(include filter.Filter, exclude filter.Filter, key string) bool {
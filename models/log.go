@@ -1,26 +1,114 @@
 package models
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"reflect"
+	"strings"
+	"sync/atomic"
 
 	"github.com/fatih/color"
+	"github.com/influxdata/wlog"
+
 	"github.com/influxdata/telegraf"
 )
 
+// LogAttributes identify the plugin emitting a log message in structured
+// log output.
+type LogAttributes struct {
+	Category string
+	Name     string
+	Alias    string
+	ID       string
+}
+
+// LogOutput receives the messages of plugin loggers including the plugin
+// attributes. The level is one of 'D', 'I', 'W' or 'E'. Messages are passed
+// after checking the plugin's log level and must not be filtered again.
+type LogOutput func(level byte, attrs LogAttributes, msg string)
+
+var levelNames = map[wlog.Level]string{
+	wlog.DEBUG: "debug",
+	wlog.INFO:  "info",
+	wlog.WARN:  "warn",
+	wlog.ERROR: "error",
+}
+
+// logOutput is used instead of the standard logger if set
+var logOutput atomic.Pointer[LogOutput]
+
+// SetLogOutput directs the messages of all plugin loggers to the given
+// output. Passing nil restores logging via the standard logger.
+func SetLogOutput(out LogOutput) {
+	if out == nil {
+		logOutput.Store(nil)
+		return
+	}
+	logOutput.Store(&out)
+}
+
 // Logger defines a logging structure for plugins.
 type Logger struct {
-	OnErrs []func()
-	Name   string // Name is the plugin name, will be printed in the `[]`.
+	OnErrs     []func()
+	Name       string // Name is the plugin name, will be printed in the `[]`.
+	Attributes LogAttributes
+
+	// level overrides the global log level if set
+	level atomic.Int32
+}
+
+// ValidateLogLevel checks if the given name is a valid plugin log level.
+// An empty name is valid and refers to the global log level.
+func ValidateLogLevel(name string) error {
+	_, err := parseLogLevel(name)
+	return err
+}
+
+func parseLogLevel(name string) (wlog.Level, error) {
+	if name == "" {
+		return 0, nil
+	}
+	switch level := wlog.StringToLevel[strings.ToUpper(name)]; level {
+	case wlog.DEBUG, wlog.INFO, wlog.WARN, wlog.ERROR:
+		return level, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q, must be one of \"debug\", \"info\", \"warn\" or \"error\"", name)
 }
 
 // NewLogger creates a new logger instance
 func NewLogger(pluginType, name, alias string) *Logger {
 	return &Logger{
 		Name: logName(pluginType, name, alias),
+		Attributes: LogAttributes{
+			Category: pluginType,
+			Name:     name,
+			Alias:    alias,
+		},
 	}
 }
 
+// SetLevel sets the minimum level of the messages logged by the plugin
+// independent of the global log level. An empty level restores the global
+// log level.
+func (l *Logger) SetLevel(name string) error {
+	level, err := parseLogLevel(name)
+	if err != nil {
+		return err
+	}
+	l.level.Store(int32(level))
+	return nil
+}
+
+// Level returns the name of the log level set for the plugin or an empty
+// string if the global log level is used.
+func (l *Logger) Level() string {
+	if level := wlog.Level(l.level.Load()); level > 0 {
+		return levelNames[level]
+	}
+	return ""
+}
+
 // OnErr defines a callback that triggers only when errors are about to be written to the log
 func (l *Logger) OnErr(f func()) {
 	l.OnErrs = append(l.OnErrs, f)
@@ -31,7 +119,7 @@ func (l *Logger) Errorf(format string, args ...interface{}) {
 	for _, f := range l.OnErrs {
 		f()
 	}
-	log.Printf("E! ["+l.Name+"] "+format, args...)
+	l.print('E', fmt.Sprintf(format, args...))
 }
 
 // Error logs an error message, patterned after log.Print.
@@ -39,37 +127,62 @@ func (l *Logger) Error(args ...interface{}) {
 	for _, f := range l.OnErrs {
 		f()
 	}
-	log.Print(append([]interface{}{"E! [" + l.Name + "] "}, args...)...)
+	l.print('E', fmt.Sprint(args...))
 }
 
 // Debugf logs a debug message, patterned after log.Printf.
 func (l *Logger) Debugf(format string, args ...interface{}) {
-	log.Printf("D! ["+l.Name+"] "+format, args...)
+	l.print('D', fmt.Sprintf(format, args...))
 }
 
 // Debug logs a debug message, patterned after log.Print.
 func (l *Logger) Debug(args ...interface{}) {
-	log.Print(append([]interface{}{"D! [" + l.Name + "] "}, args...)...)
+	l.print('D', fmt.Sprint(args...))
 }
 
 // Warnf logs a warning message, patterned after log.Printf.
 func (l *Logger) Warnf(format string, args ...interface{}) {
-	log.Printf("W! ["+l.Name+"] "+format, args...)
+	l.print('W', fmt.Sprintf(format, args...))
 }
 
 // Warn logs a warning message, patterned after log.Print.
 func (l *Logger) Warn(args ...interface{}) {
-	log.Print(append([]interface{}{"W! [" + l.Name + "] "}, args...)...)
+	l.print('W', fmt.Sprint(args...))
 }
 
 // Infof logs an information message, patterned after log.Printf.
 func (l *Logger) Infof(format string, args ...interface{}) {
-	log.Printf("I! ["+l.Name+"] "+format, args...)
+	l.print('I', fmt.Sprintf(format, args...))
 }
 
 // Info logs an information message, patterned after log.Print.
 func (l *Logger) Info(args ...interface{}) {
-	log.Print(append([]interface{}{"I! [" + l.Name + "] "}, args...)...)
+	l.print('I', fmt.Sprint(args...))
+}
+
+func (l *Logger) print(level byte, msg string) {
+	minimum := wlog.Level(l.level.Load())
+	if minimum == 0 {
+		minimum = wlog.LogLevel()
+	}
+	if wlog.Levels[level] < minimum {
+		return
+	}
+
+	if out := logOutput.Load(); out != nil {
+		(*out)(level, l.Attributes, msg)
+		return
+	}
+	log.Print(string(level) + "! [" + l.Name + "] " + msg)
+}
+
+// setLogLevel changes the log level of the given plugin logger
+func setLogLevel(l telegraf.Logger, name string) error {
+	logger, ok := l.(*Logger)
+	if !ok {
+		return errors.New("log level not supported by logger")
+	}
+	return logger.SetLevel(name)
 }
 
 // logName returns the log-friendly name/type.
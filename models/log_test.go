@@ -8,9 +8,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/influxdata/wlog"
+	"github.com/stretchr/testify/require"
+
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/selfstat"
-	"github.com/stretchr/testify/require"
 )
 
 func TestErrorCounting(t *testing.T) {
@@ -29,6 +31,43 @@ func TestErrorCounting(t *testing.T) {
 	require.Equal(t, int64(2), reg.Get())
 }
 
+func TestLoggerLevel(t *testing.T) {
+	var messages []string
+	SetLogOutput(func(level byte, attrs LogAttributes, msg string) {
+		messages = append(messages, string(level)+" "+attrs.Category+"."+attrs.Name+" "+msg)
+	})
+	defer SetLogOutput(nil)
+
+	previous := wlog.LogLevel()
+	wlog.SetLevel(wlog.INFO)
+	defer wlog.SetLevel(previous)
+
+	// Use the global log level by default
+	l := NewLogger("inputs", "test", "")
+	require.Empty(t, l.Level())
+	l.Debug("dropped")
+	l.Info("info")
+
+	// Log debug messages of this plugin only
+	require.NoError(t, l.SetLevel("debug"))
+	require.Equal(t, "debug", l.Level())
+	l.Debugf("debug %d", 1)
+	NewLogger("inputs", "other", "").Debug("dropped")
+
+	require.NoError(t, l.SetLevel("ERROR"))
+	l.Warn("dropped")
+	l.Error("error")
+
+	require.ErrorContains(t, l.SetLevel("verbose"), `invalid log level "verbose"`)
+	require.Equal(t, "error", l.Level())
+
+	require.Equal(t, []string{
+		"I inputs.test info",
+		"D inputs.test debug 1",
+		"E inputs.test error",
+	}, messages)
+}
+
 func TestPluginDeprecation(t *testing.T) {
 	info := telegraf.DeprecationInfo{
 		Since:     "1.23.0",
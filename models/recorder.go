@@ -0,0 +1,168 @@
+package models
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/rotate"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// RecorderConfig configures the capture file of the recorder.
+type RecorderConfig struct {
+	// Filename of the capture file
+	Filename string
+	// All enables recording for all parsers, otherwise only the data of
+	// parsers with recording enabled in their configuration are recorded
+	All bool
+	// Rotation settings of the capture file, see rotate.NewFileWriter
+	RotationInterval    time.Duration
+	RotationMaxSize     int64
+	RotationMaxArchives int
+}
+
+// CaptureRecord is an entry of the capture file describing the data passed to
+// a parser. The capture file contains one record per line in JSON format.
+type CaptureRecord struct {
+	Time       time.Time         `json:"time"`
+	Plugin     string            `json:"plugin"`
+	DataFormat string            `json:"data_format"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Data       []byte            `json:"data"`
+}
+
+// Recorder writes the raw data passed to the parsers to a rotating capture
+// file, e.g. to reproduce parser issues offline. Recording is disabled until
+// the recorder is opened.
+type Recorder struct {
+	sync.Mutex
+	config  RecorderConfig
+	writer  io.WriteCloser
+	encoder *json.Encoder
+	log     telegraf.Logger
+	failed  bool
+
+	// Recorder to pass the records to instead of writing them
+	redirect atomic.Pointer[Recorder]
+
+	RecordsWritten selfstat.Stat
+	RecordsFailed  selfstat.Stat
+}
+
+// NewRecorder returns a disabled recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		log: NewLogger("agent", "recorder", ""),
+	}
+}
+
+// Open creates the capture file and enables recording.
+func (r *Recorder) Open(config RecorderConfig) error {
+	if config.Filename == "" {
+		return errors.New("no capture file given")
+	}
+
+	w, err := rotate.NewFileWriter(config.Filename, config.RotationInterval, config.RotationMaxSize, config.RotationMaxArchives)
+	if err != nil {
+		return fmt.Errorf("opening capture file failed: %w", err)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.config = config
+	r.RecordsWritten = selfstat.Register("recorder", "records_written", map[string]string{})
+	r.RecordsFailed = selfstat.Register("recorder", "records_failed", map[string]string{})
+	r.writer = w
+	r.encoder = json.NewEncoder(w)
+	r.failed = false
+	return nil
+}
+
+// Close disables recording and closes the capture file.
+func (r *Recorder) Close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.writer == nil {
+		return nil
+	}
+	err := r.writer.Close()
+	r.writer = nil
+	r.encoder = nil
+	return err
+}
+
+// Redirect passes all subsequent records to the given recorder, e.g. to
+// record the data of plugins added to a running agent to its capture file.
+func (r *Recorder) Redirect(to *Recorder) {
+	if to != r {
+		r.redirect.Store(to)
+	}
+}
+
+// Record writes the data passed to the parser to the capture file if the
+// recorder is open and recording is enabled for the parser.
+func (r *Recorder) Record(config *ParserConfig, metadata map[string]string, data []byte) {
+	if r == nil {
+		return
+	}
+	if to := r.redirect.Load(); to != nil {
+		to.Record(config, metadata, data)
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if r.encoder == nil || !(r.config.All || config.Record) {
+		return
+	}
+
+	record := CaptureRecord{
+		Time:       time.Now(),
+		Plugin:     config.Parent,
+		DataFormat: config.DataFormat,
+		Metadata:   metadata,
+		Data:       data,
+	}
+	if err := r.encoder.Encode(record); err != nil {
+		r.RecordsFailed.Incr(1)
+		// Only report the first error of a series to not flood the log
+		if !r.failed {
+			r.log.Errorf("Writing capture record failed: %v", err)
+		}
+		r.failed = true
+		return
+	}
+	r.failed = false
+	r.RecordsWritten.Incr(1)
+}
+
+// ReadCaptureRecords reads the records of a capture file.
+func ReadCaptureRecords(reader io.Reader) ([]CaptureRecord, error) {
+	var records []CaptureRecord
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d: invalid capture record: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+)
+
+type mockRecordedParser struct {
+	parsed [][]byte
+}
+
+func (p *mockRecordedParser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	p.parsed = append(p.parsed, buf)
+	return nil, nil
+}
+
+func (p *mockRecordedParser) ParseLine(line string) (telegraf.Metric, error) {
+	p.parsed = append(p.parsed, []byte(line))
+	return nil, nil
+}
+
+func (*mockRecordedParser) SetDefaultTags(map[string]string) {}
+
+func TestRecorder(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "capture.jsonl")
+
+	recorder := NewRecorder()
+	recorded := NewRunningParser(&mockRecordedParser{}, &ParserConfig{Parent: "mqtt_consumer", DataFormat: "influx", Record: true})
+	recorded.Recorder = recorder
+	ignored := NewRunningParser(&mockRecordedParser{}, &ParserConfig{Parent: "http", DataFormat: "json"})
+	ignored.Recorder = recorder
+
+	// Nothing is recorded before opening the recorder
+	_, err := recorded.Parse([]byte("cpu value=1"))
+	require.NoError(t, err)
+
+	require.NoError(t, recorder.Open(RecorderConfig{Filename: filename}))
+	_, err = recorded.ParseWithMetadata([]byte("cpu value=2\n\x00binary"), map[string]string{"topic": "sensors/cpu"})
+	require.NoError(t, err)
+	_, err = recorded.ParseLine("cpu value=3")
+	require.NoError(t, err)
+	_, err = ignored.Parse([]byte(`{"value": 4}`))
+	require.NoError(t, err)
+
+	// Plugins added on reload record to the running recorder
+	next := NewRecorder()
+	next.Redirect(recorder)
+	added := NewRunningParser(&mockRecordedParser{}, &ParserConfig{Parent: "tail", DataFormat: "grok", Record: true})
+	added.Recorder = next
+	_, err = added.ParseWithMetadata([]byte("line"), map[string]string{"path": "/var/log/app.log"})
+	require.NoError(t, err)
+	require.NoError(t, recorder.Close())
+
+	f, err := os.Open(filename)
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := ReadCaptureRecords(f)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+
+	require.Equal(t, "mqtt_consumer", records[0].Plugin)
+	require.Equal(t, "influx", records[0].DataFormat)
+	require.Equal(t, map[string]string{"topic": "sensors/cpu"}, records[0].Metadata)
+	require.Equal(t, []byte("cpu value=2\n\x00binary"), records[0].Data)
+	require.False(t, records[0].Time.IsZero())
+
+	require.Nil(t, records[1].Metadata)
+	require.Equal(t, []byte("cpu value=3"), records[1].Data)
+
+	require.Equal(t, "tail", records[2].Plugin)
+	require.Equal(t, map[string]string{"path": "/var/log/app.log"}, records[2].Metadata)
+}
+
+func TestRecorderAll(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "capture.jsonl")
+
+	recorder := NewRecorder()
+	require.NoError(t, recorder.Open(RecorderConfig{Filename: filename, All: true}))
+	parser := NewRunningParser(&mockRecordedParser{}, &ParserConfig{Parent: "http", DataFormat: "json"})
+	parser.Recorder = recorder
+	_, err := parser.Parse([]byte(`{"value": 4}`))
+	require.NoError(t, err)
+	require.NoError(t, recorder.Close())
+
+	f, err := os.Open(filename)
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := ReadCaptureRecords(f)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, []byte(`{"value": 4}`), records[0].Data)
+}
+
+func TestReadCaptureRecordsInvalid(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "capture")
+	require.NoError(t, err)
+	_, err = f.WriteString("{\"plugin\": \"http\", \"data\": \"e30=\"}\n\nnot json\n")
+	require.NoError(t, err)
+	_, err = f.Seek(0, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = ReadCaptureRecords(f)
+	require.ErrorContains(t, err, "line 3: invalid capture record")
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetryBackoffMax       = 5 * time.Minute
+	defaultCircuitBreakerTimeout = time.Minute
+)
+
+// RetryPolicy controls how writes of an output are retried after a failure.
+// The zero value retries on every flush.
+type RetryPolicy struct {
+	// MaxAttempts is the number of failed writes after which a batch is
+	// dropped, zero retries forever
+	MaxAttempts int
+	// Backoff is the initial delay between failed writes, doubled on each
+	// consecutive failure up to BackoffMax
+	Backoff    time.Duration
+	BackoffMax time.Duration
+	// BreakerThreshold is the number of consecutive failed writes after which
+	// no writes are attempted until BreakerTimeout elapsed
+	BreakerThreshold int
+	BreakerTimeout   time.Duration
+}
+
+// Validate checks the settings and applies the defaults.
+func (p *RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 {
+		return errors.New("retry_max_attempts must not be negative")
+	}
+	if p.Backoff < 0 || p.BackoffMax < 0 {
+		return errors.New("retry backoff must not be negative")
+	}
+	if p.BreakerThreshold < 0 || p.BreakerTimeout < 0 {
+		return errors.New("circuit breaker settings must not be negative")
+	}
+
+	if p.Backoff > 0 && p.BackoffMax == 0 {
+		p.BackoffMax = defaultRetryBackoffMax
+		if p.Backoff > p.BackoffMax {
+			p.BackoffMax = p.Backoff
+		}
+	}
+	if p.BackoffMax < p.Backoff {
+		return fmt.Errorf("retry_backoff_max %s must not be shorter than retry_backoff %s", p.BackoffMax, p.Backoff)
+	}
+	if p.BreakerThreshold > 0 && p.BreakerTimeout == 0 {
+		p.BreakerTimeout = defaultCircuitBreakerTimeout
+	}
+	return nil
+}
+
+// retryState keeps track of the failed writes of an output according to the
+// retry policy.
+type retryState struct {
+	policy RetryPolicy
+
+	sync.Mutex
+	failures int       // consecutive failed writes
+	attempts int       // failed writes of the batch retried, see failure()
+	next     time.Time // earliest time of the next write
+	open     bool      // state of the circuit breaker
+}
+
+// wait returns the remaining time until the next write may be attempted.
+func (s *retryState) wait(now time.Time) time.Duration {
+	s.Lock()
+	defer s.Unlock()
+
+	if now.Before(s.next) {
+		return s.next.Sub(now)
+	}
+	return 0
+}
+
+// success records a successful write and returns true if the circuit breaker
+// was closed by it.
+func (s *retryState) success() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	closed := s.open
+	s.failures = 0
+	s.attempts = 0
+	s.next = time.Time{}
+	s.open = false
+	return closed
+}
+
+// failure records a failed write. It returns true if the batch exceeded the
+// maximum number of attempts and should be dropped, and if the circuit
+// breaker was opened by the failure. Attempts are counted per output, which
+// equals the attempts of the batch as a rejected batch is retried first and
+// the maximum number of attempts cannot be used with concurrent writes.
+func (s *retryState) failure(now time.Time) (drop, opened bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.attempts++
+	if s.policy.MaxAttempts > 0 && s.attempts >= s.policy.MaxAttempts {
+		drop = true
+		s.attempts = 0
+	}
+	return drop, s.recordFailure(now)
+}
+
+// memberFailure records a failed write of a failover group member and returns
+// true if the circuit breaker was opened by the failure. Batches are kept in
+// the buffer of the group until written, so no attempts are counted.
+func (s *retryState) memberFailure(now time.Time) (opened bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.recordFailure(now)
+}
+
+// recordFailure delays the next write after a failure and returns true if the
+// circuit breaker was opened. The lock must be held by the caller.
+func (s *retryState) recordFailure(now time.Time) (opened bool) {
+	s.failures++
+	if s.policy.BreakerThreshold > 0 && s.failures >= s.policy.BreakerThreshold {
+		// A failure while half-open keeps the breaker open
+		opened = !s.open
+		s.open = true
+		s.next = now.Add(s.policy.BreakerTimeout)
+		return opened
+	}
+
+	if s.policy.Backoff > 0 {
+		s.next = now.Add(s.backoff())
+	}
+	return false
+}
+
+// backoff returns the delay after the current number of consecutive failures
+// randomized by up to half of the delay.
+func (s *retryState) backoff() time.Duration {
+	delay := s.policy.Backoff
+	for i := 1; i < s.failures && delay < s.policy.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > s.policy.BackoffMax {
+		delay = s.policy.BackoffMax
+	}
+
+	//nolint:gosec // G404: not used for cryptographic purposes
+	jitter := time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	return delay - jitter
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyValidate(t *testing.T) {
+	policy := RetryPolicy{Backoff: time.Second, BreakerThreshold: 3}
+	require.NoError(t, policy.Validate())
+	require.Equal(t, defaultRetryBackoffMax, policy.BackoffMax)
+	require.Equal(t, defaultCircuitBreakerTimeout, policy.BreakerTimeout)
+
+	policy = RetryPolicy{Backoff: time.Minute, BackoffMax: time.Second}
+	require.ErrorContains(t, policy.Validate(), "must not be shorter than retry_backoff")
+
+	policy = RetryPolicy{MaxAttempts: -1}
+	require.ErrorContains(t, policy.Validate(), "must not be negative")
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{Backoff: time.Second, BackoffMax: 4 * time.Second}
+	require.NoError(t, policy.Validate())
+	state := &retryState{policy: policy}
+
+	now := time.Now()
+	require.Zero(t, state.wait(now))
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		drop, opened := state.failure(now)
+		require.False(t, drop)
+		require.False(t, opened)
+
+		wait := state.wait(now)
+		require.LessOrEqual(t, wait, expected)
+		require.GreaterOrEqual(t, wait, expected/2)
+	}
+
+	require.False(t, state.success())
+	require.Zero(t, state.wait(now))
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	state := &retryState{policy: RetryPolicy{MaxAttempts: 2}}
+
+	now := time.Now()
+	drop, _ := state.failure(now)
+	require.False(t, drop)
+	drop, _ = state.failure(now)
+	require.True(t, drop)
+
+	// The attempts start over for the next batch
+	drop, _ = state.failure(now)
+	require.False(t, drop)
+	require.Zero(t, state.wait(now))
+
+	// Failed writes of failover group members are no attempts of the batch
+	state.memberFailure(now)
+	state.memberFailure(now)
+	drop, _ = state.failure(now)
+	require.True(t, drop)
+}
+
+func TestRetryCircuitBreaker(t *testing.T) {
+	policy := RetryPolicy{BreakerThreshold: 2, BreakerTimeout: time.Minute}
+	state := &retryState{policy: policy}
+
+	now := time.Now()
+	_, opened := state.failure(now)
+	require.False(t, opened)
+	require.Zero(t, state.wait(now))
+
+	_, opened = state.failure(now)
+	require.True(t, opened)
+	require.Equal(t, time.Minute, state.wait(now))
+
+	// A failed write after the timeout keeps the breaker open
+	now = now.Add(time.Minute)
+	require.Zero(t, state.wait(now))
+	_, opened = state.failure(now)
+	require.False(t, opened)
+	require.Equal(t, time.Minute, state.wait(now))
+
+	// A successful write after the timeout closes the breaker
+	now = now.Add(time.Minute)
+	require.True(t, state.success())
+	require.Zero(t, state.wait(now))
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// AgentMetricsUnrouted counts the metrics not matching the route of any output
+// while no output is configured as default route.
+var AgentMetricsUnrouted = selfstat.Register("agent", "metrics_unrouted", map[string]string{})
+
+// ErrRouteMissingKey is returned if the route condition references a tag or
+// field missing in the metric.
+var ErrRouteMissingKey = errors.New("missing key")
+
+// Minimum time between log messages about routes missing metric keys
+const routeMissingLogInterval = time.Minute
+
+// Route selects the metrics an output receives if the output takes part in
+// routing. Each metric is sent to exactly one of the outputs with a route.
+type Route struct {
+	// Condition is a CEL expression selecting the metrics of the output
+	Condition string
+	// Default marks the output receiving the metrics not selected by any
+	// condition
+	Default bool
+
+	program cel.Program
+}
+
+// Compile the route condition
+func (r *Route) Compile() error {
+	program, err := compileMetricExpression(r.Condition)
+	if err != nil {
+		return fmt.Errorf("compiling route failed: %w", err)
+	}
+	r.program = program
+	return nil
+}
+
+// IsActive returns true if the output takes part in routing
+func (r *Route) IsActive() bool {
+	return r.Condition != "" || r.Default
+}
+
+// Match returns true if the route condition selects the metric. Conditions
+// referencing a tag or field missing in the metric do not match and return
+// an error wrapping ErrRouteMissingKey.
+func (r *Route) Match(metric telegraf.Metric) (bool, error) {
+	if r.program == nil {
+		return false, nil
+	}
+	ok, err := evalMetricExpression(r.program, metric)
+	if err != nil {
+		// CEL does not provide typed errors for missing map keys
+		if strings.HasPrefix(err.Error(), "no such key") {
+			return false, fmt.Errorf("%w: %w", ErrRouteMissingKey, err)
+		}
+		return false, err
+	}
+	return ok, nil
+}
+
+// RouteMetric returns the outputs receiving the given metric. Outputs without
+// a route receive all metrics. Of the outputs with a route, only the first one
+// with a matching condition receives the metric or, if no condition matches,
+// the first default route. If neither exists, the metric is counted as
+// unrouted. Standby outputs of failover groups never receive metrics.
+func RouteMetric(outputs []*RunningOutput, metric telegraf.Metric) []*RunningOutput {
+	var target, fallback *RunningOutput
+	var routing, standby bool
+	for _, output := range outputs {
+		if output.IsFailoverStandby() {
+			standby = true
+			continue
+		}
+
+		route := &output.Config.Route
+		if !route.IsActive() {
+			continue
+		}
+		routing = true
+
+		if target == nil {
+			ok, err := route.Match(metric)
+			switch {
+			case errors.Is(err, ErrRouteMissingKey):
+				output.logRouteMissingKey(err)
+			case err != nil:
+				output.log.Errorf("evaluating route failed: %v", err)
+			case ok:
+				target = output
+			}
+		}
+		if fallback == nil && route.Default {
+			fallback = output
+		}
+	}
+	if !routing && !standby {
+		return outputs
+	}
+
+	if target == nil {
+		target = fallback
+	}
+	if routing && target == nil {
+		AgentMetricsUnrouted.Incr(1)
+	}
+
+	selected := make([]*RunningOutput, 0, len(outputs))
+	for _, output := range outputs {
+		if output.IsFailoverStandby() {
+			continue
+		}
+		if output == target || !output.Config.Route.IsActive() {
+			selected = append(selected, output)
+		}
+	}
+	return selected
+}
+
+// logRouteMissingKey logs that the route of the output does not match metrics
+// lacking the referenced keys. The message is logged at most once per
+// routeMissingLogInterval to not flood the log.
+func (r *RunningOutput) logRouteMissingKey(err error) {
+	now := time.Now().UnixNano()
+	last := r.routeMissingLogged.Load()
+	if now-last < int64(routeMissingLogInterval) || !r.routeMissingLogged.CompareAndSwap(last, now) {
+		return
+	}
+	r.log.Debugf("route does not match metric: %v", err)
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+func TestRouteMetric(t *testing.T) {
+	newOutput := func(alias string, route Route) *RunningOutput {
+		require.NoError(t, route.Compile())
+		return NewRunningOutput(&mockOutput{}, &OutputConfig{Name: "test", Alias: alias, Route: route}, 1000, 10000)
+	}
+	all := newOutput("all", Route{})
+	tenantA := newOutput("a", Route{Condition: `tags.tenant == "a"`})
+	tenantAB := newOutput("ab", Route{Condition: `tags.tenant in ["a", "b"]`})
+	fallback := newOutput("default", Route{Default: true})
+
+	newMetric := func(tenant string) telegraf.Metric {
+		return metric.New("test", map[string]string{"tenant": tenant}, map[string]interface{}{"value": 42}, time.Unix(0, 0))
+	}
+
+	// Without routes all outputs receive the metric
+	outputs := []*RunningOutput{all, newOutput("other", Route{})}
+	require.Equal(t, outputs, RouteMetric(outputs, newMetric("a")))
+
+	// The first matching route wins, outputs without route receive all metrics
+	outputs = []*RunningOutput{tenantA, all, tenantAB, fallback}
+	require.Equal(t, []*RunningOutput{tenantA, all}, RouteMetric(outputs, newMetric("a")))
+	require.Equal(t, []*RunningOutput{all, tenantAB}, RouteMetric(outputs, newMetric("b")))
+	require.Equal(t, []*RunningOutput{all, fallback}, RouteMetric(outputs, newMetric("c")))
+
+	// Metrics are counted as unrouted without a default route
+	unrouted := AgentMetricsUnrouted.Get()
+	outputs = []*RunningOutput{tenantA, all, tenantAB}
+	require.Equal(t, []*RunningOutput{all}, RouteMetric(outputs, newMetric("c")))
+	require.Equal(t, unrouted+1, AgentMetricsUnrouted.Get())
+}
+
+func TestRouteMetricMissingKey(t *testing.T) {
+	route := Route{Condition: `tags.tenant == "a"`}
+	require.NoError(t, route.Compile())
+	tenantA := NewRunningOutput(&mockOutput{}, &OutputConfig{Name: "test", Alias: "missing", Route: route}, 1000, 10000)
+	fallback := NewRunningOutput(&mockOutput{}, &OutputConfig{Name: "test", Alias: "fallback", Route: Route{Default: true}}, 1000, 10000)
+	writeErrors := selfstat.Register("write", "errors", map[string]string{"output": "test", "alias": "missing"})
+
+	// Metrics without the tag referenced by the route do not match the route
+	m := metric.New("test", map[string]string{}, map[string]interface{}{"value": 42}, time.Unix(0, 0))
+	ok, err := route.Match(m)
+	require.ErrorIs(t, err, ErrRouteMissingKey)
+	require.False(t, ok)
+
+	// Missing keys are no errors of the output
+	outputs := []*RunningOutput{tenantA, fallback}
+	require.Equal(t, []*RunningOutput{fallback}, RouteMetric(outputs, m))
+	require.Equal(t, []*RunningOutput{fallback}, RouteMetric(outputs, m))
+	require.Zero(t, writeErrors.Get())
+
+	// Checking the existence of the key avoids the error
+	route = Route{Condition: `"tenant" in tags && tags.tenant == "a"`}
+	require.NoError(t, route.Compile())
+	ok, err = route.Match(m)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestRouteCompile(t *testing.T) {
+	route := Route{Condition: `tags.tenant`}
+	require.ErrorContains(t, route.Compile(), "expression needs to return a boolean")
+
+	route = Route{}
+	require.NoError(t, route.Compile())
+	require.False(t, route.IsActive())
+}
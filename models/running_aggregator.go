@@ -31,6 +31,10 @@ func NewRunningAggregator(aggregator telegraf.Aggregator, config *AggregatorConf
 
 	aggErrorsRegister := selfstat.Register("aggregate", "errors", tags)
 	logger := NewLogger("aggregators", config.Name, config.Alias)
+	logger.Attributes.ID = config.ID
+	if err := logger.SetLevel(config.LogLevel); err != nil {
+		logger.Warnf("Using global log level: %v", err)
+	}
 	logger.OnErr(func() {
 		aggErrorsRegister.Incr(1)
 	})
@@ -68,6 +72,7 @@ func NewRunningAggregator(aggregator telegraf.Aggregator, config *AggregatorConf
 type AggregatorConfig struct {
 	Name         string
 	Alias        string
+	LogLevel     string
 	ID           string
 	DropOriginal bool
 	Period       time.Duration
@@ -85,6 +90,22 @@ func (r *RunningAggregator) LogName() string {
 	return logName("aggregators", r.Config.Name, r.Config.Alias)
 }
 
+// LogLevel returns the log level configured for the plugin or an empty string
+// if the global log level is used.
+func (r *RunningAggregator) LogLevel() string {
+	return r.Config.LogLevel
+}
+
+// SetLogLevel changes the log level of the plugin at runtime. An empty level
+// uses the global log level.
+func (r *RunningAggregator) SetLogLevel(level string) error {
+	if err := setLogLevel(r.log, level); err != nil {
+		return err
+	}
+	r.Config.LogLevel = level
+	return nil
+}
+
 func (r *RunningAggregator) Init() error {
 	if p, ok := r.Aggregator.(telegraf.Initializer); ok {
 		err := p.Init()
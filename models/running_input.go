@@ -1,6 +1,10 @@
 package models
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/influxdata/telegraf"
@@ -10,6 +14,7 @@ import (
 var (
 	GlobalMetricsGathered = selfstat.Register("agent", "metrics_gathered", map[string]string{})
 	GlobalGatherErrors    = selfstat.Register("agent", "gather_errors", map[string]string{})
+	GlobalGatherTimeouts  = selfstat.Register("agent", "gather_timeouts", map[string]string{})
 )
 
 type RunningInput struct {
@@ -18,9 +23,30 @@ type RunningInput struct {
 
 	log         telegraf.Logger
 	defaultTags map[string]string
+	cardinality *CardinalityLimiter
 
 	MetricsGathered selfstat.Stat
 	GatherTime      selfstat.Stat
+	GatherTimeouts  selfstat.Stat
+
+	// Serialize gathering as plugins do not expect concurrent calls, e.g.
+	// when gathering on request in addition to the interval
+	gatherMu sync.Mutex
+	// Result of a gather of an input not supporting cancellation which is
+	// still running after exceeding the timeout and the accumulator used by
+	// this gather
+	pending    chan error
+	pendingAcc *discardingAccumulator
+
+	statusMu   sync.Mutex
+	lastGather GatherStatus
+}
+
+// GatherStatus describes the outcome of the last gather of an input.
+type GatherStatus struct {
+	Time     time.Time
+	Duration time.Duration
+	Err      error
 }
 
 func NewRunningInput(input telegraf.Input, config *InputConfig) *RunningInput {
@@ -31,15 +57,25 @@ func NewRunningInput(input telegraf.Input, config *InputConfig) *RunningInput {
 
 	inputErrorsRegister := selfstat.Register("gather", "errors", tags)
 	logger := NewLogger("inputs", config.Name, config.Alias)
+	logger.Attributes.ID = config.ID
+	if err := logger.SetLevel(config.LogLevel); err != nil {
+		logger.Warnf("Using global log level: %v", err)
+	}
 	logger.OnErr(func() {
 		inputErrorsRegister.Incr(1)
 		GlobalGatherErrors.Incr(1)
 	})
 	SetLoggerOnPlugin(input, logger)
 
+	var cardinality *CardinalityLimiter
+	if config.Cardinality.Limit > 0 {
+		cardinality = NewCardinalityLimiter(config.Cardinality, logger, tags)
+	}
+
 	return &RunningInput{
-		Input:  input,
-		Config: config,
+		Input:       input,
+		Config:      config,
+		cardinality: cardinality,
 		MetricsGathered: selfstat.Register(
 			"gather",
 			"metrics_gathered",
@@ -50,6 +86,11 @@ func NewRunningInput(input telegraf.Input, config *InputConfig) *RunningInput {
 			"gather_time_ns",
 			tags,
 		),
+		GatherTimeouts: selfstat.Register(
+			"gather",
+			"gather_timeouts",
+			tags,
+		),
 		log: logger,
 	}
 }
@@ -58,11 +99,15 @@ func NewRunningInput(input telegraf.Input, config *InputConfig) *RunningInput {
 type InputConfig struct {
 	Name             string
 	Alias            string
+	LogLevel         string
 	ID               string
 	Interval         time.Duration
 	CollectionJitter time.Duration
 	CollectionOffset time.Duration
 	Precision        time.Duration
+	GatherTimeout    time.Duration
+	Cardinality      CardinalityConfig
+	Record           bool
 
 	NameOverride      string
 	MeasurementPrefix string
@@ -79,6 +124,22 @@ func (r *RunningInput) LogName() string {
 	return logName("inputs", r.Config.Name, r.Config.Alias)
 }
 
+// LogLevel returns the log level configured for the plugin or an empty string
+// if the global log level is used.
+func (r *RunningInput) LogLevel() string {
+	return r.Config.LogLevel
+}
+
+// SetLogLevel changes the log level of the plugin at runtime. An empty level
+// uses the global log level.
+func (r *RunningInput) SetLogLevel(level string) error {
+	if err := setLogLevel(r.log, level); err != nil {
+		return err
+	}
+	r.Config.LogLevel = level
+	return nil
+}
+
 func (r *RunningInput) Init() error {
 	if p, ok := r.Input.(telegraf.Initializer); ok {
 		err := p.Init()
@@ -119,19 +180,158 @@ func (r *RunningInput) MakeMetric(metric telegraf.Metric) telegraf.Metric {
 		return nil
 	}
 
+	if r.cardinality != nil && !r.cardinality.Apply(m) {
+		m.Drop()
+		return nil
+	}
+
 	r.MetricsGathered.Incr(1)
 	GlobalMetricsGathered.Incr(1)
 	return m
 }
 
 func (r *RunningInput) Gather(acc telegraf.Accumulator) error {
+	return r.GatherContext(context.Background(), acc)
+}
+
+// GatherContext gathers the input until the context is done or the gather
+// timeout of the input is exceeded.
+func (r *RunningInput) GatherContext(ctx context.Context, acc telegraf.Accumulator) error {
+	r.gatherMu.Lock()
+	defer r.gatherMu.Unlock()
+
+	// Keep track of the errors added to the accumulator during gathering
+	tracker := &errorTrackingAccumulator{Accumulator: acc}
+
 	start := time.Now()
-	err := r.Input.Gather(acc)
+	err := r.gather(ctx, tracker)
 	elapsed := time.Since(start)
 	r.GatherTime.Incr(elapsed.Nanoseconds())
+
+	status := GatherStatus{Time: start, Duration: elapsed, Err: err}
+	if status.Err == nil {
+		status.Err = tracker.err()
+	}
+	r.statusMu.Lock()
+	r.lastGather = status
+	r.statusMu.Unlock()
+
 	return err
 }
 
+func (r *RunningInput) gather(ctx context.Context, acc telegraf.Accumulator) error {
+	// Do not start another gather while a timed out one is still running
+	if r.pending != nil {
+		select {
+		case <-r.pending:
+			r.pending = nil
+			r.pendingAcc = nil
+		default:
+			return errors.New("previous gather still running after exceeding the timeout")
+		}
+	}
+
+	timeout := r.Config.GatherTimeout
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if input, ok := r.Input.(telegraf.ContextInput); ok {
+		err := input.GatherContext(ctx, acc)
+		if timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			r.gatherTimedOut()
+			if err == nil {
+				err = ctx.Err()
+			}
+			return fmt.Errorf("gather not complete after %s: %w", timeout, err)
+		}
+		return err
+	}
+
+	if timeout <= 0 {
+		return r.Input.Gather(acc)
+	}
+
+	// Inputs not supporting cancellation keep running in the background, so
+	// their metrics can be discarded when the input is stopped
+	discarding := newDiscardingAccumulator(acc)
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Input.Gather(discarding)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			r.gatherTimedOut()
+		}
+		r.pending = done
+		r.pendingAcc = discarding
+		return fmt.Errorf("gather not complete after %s: %w", timeout, ctx.Err())
+	}
+}
+
+// DiscardPending discards all metrics added by a gather still running in the
+// background after exceeding the timeout. Additions in progress are awaited,
+// so the accumulator's channel can be closed afterwards.
+func (r *RunningInput) DiscardPending() {
+	r.gatherMu.Lock()
+	defer r.gatherMu.Unlock()
+
+	if r.pendingAcc == nil {
+		return
+	}
+	select {
+	case <-r.pending:
+	default:
+		r.log.Warn("Discarding metrics of the gather still running after exceeding the timeout")
+	}
+	r.pendingAcc.discard()
+	r.pending = nil
+	r.pendingAcc = nil
+}
+
+func (r *RunningInput) gatherTimedOut() {
+	r.GatherTimeouts.Incr(1)
+	GlobalGatherTimeouts.Incr(1)
+}
+
+// LastGather returns the status of the last gather of the input.
+func (r *RunningInput) LastGather() GatherStatus {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	return r.lastGather
+}
+
+// errorTrackingAccumulator remembers the first error added.
+type errorTrackingAccumulator struct {
+	telegraf.Accumulator
+
+	sync.Mutex
+	first error
+}
+
+func (a *errorTrackingAccumulator) AddError(err error) {
+	if err != nil {
+		a.Lock()
+		if a.first == nil {
+			a.first = err
+		}
+		a.Unlock()
+	}
+	a.Accumulator.AddError(err)
+}
+
+func (a *errorTrackingAccumulator) err() error {
+	a.Lock()
+	defer a.Unlock()
+	return a.first
+}
+
 func (r *RunningInput) SetDefaultTags(tags map[string]string) {
 	r.defaultTags = tags
 }
@@ -139,3 +339,104 @@ func (r *RunningInput) SetDefaultTags(tags map[string]string) {
 func (r *RunningInput) Log() telegraf.Logger {
 	return r.log
 }
+
+// discardingAccumulator forwards to the accumulator until discarding, e.g.
+// for gathers running in the background after exceeding their timeout.
+type discardingAccumulator struct {
+	telegraf.Accumulator
+	gate *discardGate
+}
+
+// discardGate is shared by an accumulator and its tracking accumulators.
+// Additions hold the read-lock so discarding waits for them to complete.
+type discardGate struct {
+	sync.RWMutex
+	discarded bool
+}
+
+func newDiscardingAccumulator(acc telegraf.Accumulator) *discardingAccumulator {
+	return &discardingAccumulator{Accumulator: acc, gate: &discardGate{}}
+}
+
+func (a *discardingAccumulator) discard() {
+	a.gate.Lock()
+	a.gate.discarded = true
+	a.gate.Unlock()
+}
+
+// forward calls the function unless discarding and returns true if called.
+func (a *discardingAccumulator) forward(f func()) bool {
+	a.gate.RLock()
+	defer a.gate.RUnlock()
+
+	if a.gate.discarded {
+		return false
+	}
+	f()
+	return true
+}
+
+func (a *discardingAccumulator) AddFields(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.forward(func() { a.Accumulator.AddFields(measurement, fields, tags, t...) })
+}
+
+func (a *discardingAccumulator) AddGauge(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.forward(func() { a.Accumulator.AddGauge(measurement, fields, tags, t...) })
+}
+
+func (a *discardingAccumulator) AddCounter(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.forward(func() { a.Accumulator.AddCounter(measurement, fields, tags, t...) })
+}
+
+func (a *discardingAccumulator) AddSummary(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.forward(func() { a.Accumulator.AddSummary(measurement, fields, tags, t...) })
+}
+
+func (a *discardingAccumulator) AddHistogram(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.forward(func() { a.Accumulator.AddHistogram(measurement, fields, tags, t...) })
+}
+
+func (a *discardingAccumulator) AddMetric(m telegraf.Metric) {
+	if !a.forward(func() { a.Accumulator.AddMetric(m) }) {
+		m.Drop()
+	}
+}
+
+func (a *discardingAccumulator) AddError(err error) {
+	a.forward(func() { a.Accumulator.AddError(err) })
+}
+
+func (a *discardingAccumulator) WithTracking(maxTracked int) telegraf.TrackingAccumulator {
+	tracking := a.Accumulator.WithTracking(maxTracked)
+	return &discardingTrackingAccumulator{
+		discardingAccumulator: &discardingAccumulator{Accumulator: tracking, gate: a.gate},
+		tracking:              tracking,
+	}
+}
+
+type discardingTrackingAccumulator struct {
+	*discardingAccumulator
+	tracking telegraf.TrackingAccumulator
+}
+
+func (a *discardingTrackingAccumulator) AddTrackingMetric(m telegraf.Metric) telegraf.TrackingID {
+	var id telegraf.TrackingID
+	if !a.forward(func() { id = a.tracking.AddTrackingMetric(m) }) {
+		m.Drop()
+	}
+	return id
+}
+
+func (a *discardingTrackingAccumulator) AddTrackingMetricGroup(group []telegraf.Metric) telegraf.TrackingID {
+	var id telegraf.TrackingID
+	if !a.forward(func() { id = a.tracking.AddTrackingMetricGroup(group) }) {
+		for _, m := range group {
+			m.Drop()
+		}
+	}
+	return id
+}
+
+func (a *discardingTrackingAccumulator) Delivered() <-chan telegraf.DeliveryInfo {
+	return a.tracking.Delivered()
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -44,6 +47,23 @@ func TestMakeMetricFilterAfterApplyingGlobalTags(t *testing.T) {
 	testutil.RequireMetricEqual(t, expected, actual)
 }
 
+func TestMakeMetricCardinalityLimit(t *testing.T) {
+	cfg := &InputConfig{Name: "cardinality", Cardinality: CardinalityConfig{Limit: 2}}
+	require.NoError(t, cfg.Cardinality.Validate())
+	ri := NewRunningInput(&testInput{}, cfg)
+	ri.SetDefaultTags(map[string]string{"host": "a"})
+
+	for i, expected := range []bool{true, true, false, true} {
+		m := metric.New("cpu",
+			map[string]string{"id": fmt.Sprint(i % 3)},
+			map[string]interface{}{
+				"value": 42,
+			},
+			time.Now())
+		require.Equal(t, expected, ri.MakeMetric(m) != nil, "metric %d", i)
+	}
+}
+
 func TestMakeMetricNoFields(t *testing.T) {
 	now := time.Now()
 	ri := NewRunningInput(&testInput{}, &InputConfig{
@@ -273,6 +293,101 @@ func TestMetricErrorCounters(t *testing.T) {
 	require.GreaterOrEqual(t, int64(1), GlobalGatherErrors.Get())
 }
 
+func TestGatherTimeout(t *testing.T) {
+	// Inputs supporting cancellation are canceled
+	contextInput := &blockingInput{release: make(chan struct{})}
+	ri := NewRunningInput(contextInput, &InputConfig{
+		Name:          "TestGatherTimeout",
+		GatherTimeout: 10 * time.Millisecond,
+	})
+	acc := testutil.Accumulator{}
+	require.ErrorIs(t, ri.GatherContext(context.Background(), &acc), context.DeadlineExceeded)
+	require.EqualValues(t, 1, ri.GatherTimeouts.Get())
+
+	// Other inputs keep running but further gathers are skipped until they
+	// complete
+	input := &blockingInput{release: make(chan struct{})}
+	ri = NewRunningInput(&plainInput{input}, &InputConfig{
+		Name:          "TestGatherTimeoutPlain",
+		GatherTimeout: 10 * time.Millisecond,
+	})
+	require.ErrorContains(t, ri.Gather(&acc), "gather not complete after 10ms")
+	require.EqualValues(t, 1, ri.GatherTimeouts.Get())
+	require.ErrorContains(t, ri.Gather(&acc), "previous gather still running")
+
+	close(input.release)
+	require.Eventually(t, func() bool {
+		return ri.Gather(&acc) == nil
+	}, time.Second, 10*time.Millisecond)
+	require.EqualValues(t, 1, ri.GatherTimeouts.Get())
+}
+
+func TestGatherTimeoutDiscardPending(t *testing.T) {
+	input := &addingInput{release: make(chan struct{}), done: make(chan struct{})}
+	ri := NewRunningInput(input, &InputConfig{
+		Name:          "TestGatherTimeoutDiscardPending",
+		GatherTimeout: 10 * time.Millisecond,
+	})
+	acc := testutil.Accumulator{}
+	require.ErrorContains(t, ri.Gather(&acc), "gather not complete after 10ms")
+
+	// Metrics added after discarding the pending gather are dropped
+	ri.DiscardPending()
+	close(input.release)
+	<-input.done
+	require.Empty(t, acc.GetTelegrafMetrics())
+
+	// Discarding does not affect the following gathers
+	require.NoError(t, ri.Gather(&acc))
+	require.Len(t, acc.GetTelegrafMetrics(), 1)
+}
+
+type blockingInput struct {
+	release chan struct{}
+}
+
+func (*blockingInput) SampleConfig() string { return "" }
+
+func (i *blockingInput) Gather(acc telegraf.Accumulator) error {
+	return i.GatherContext(context.Background(), acc)
+}
+
+func (i *blockingInput) GatherContext(ctx context.Context, _ telegraf.Accumulator) error {
+	select {
+	case <-i.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// plainInput hides the GatherContext function of the wrapped input
+type plainInput struct {
+	input *blockingInput
+}
+
+func (*plainInput) SampleConfig() string { return "" }
+
+func (i *plainInput) Gather(acc telegraf.Accumulator) error {
+	return i.input.Gather(acc)
+}
+
+// addingInput adds a metric after being released
+type addingInput struct {
+	release chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+func (*addingInput) SampleConfig() string { return "" }
+
+func (i *addingInput) Gather(acc telegraf.Accumulator) error {
+	<-i.release
+	acc.AddFields("test", map[string]interface{}{"value": 42}, nil)
+	i.once.Do(func() { close(i.done) })
+	return nil
+}
+
 type testInput struct{}
 
 func (t *testInput) Description() string                 { return "" }
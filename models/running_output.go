@@ -1,6 +1,9 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,19 +22,33 @@ const (
 
 // OutputConfig containing name and filter
 type OutputConfig struct {
-	Name   string
-	Alias  string
-	ID     string
-	Filter Filter
+	Name     string
+	Alias    string
+	LogLevel string
+	ID       string
+	Filter   Filter
+	Route    Route
 
 	FlushInterval     time.Duration
 	FlushJitter       time.Duration
 	MetricBufferLimit int
 	MetricBatchSize   int
 
+	BufferStrategy  string
+	BufferDirectory string
+	BufferSizeLimit int64
+	BufferID        string
+
 	NameOverride string
 	NamePrefix   string
 	NameSuffix   string
+
+	StartupErrorBehavior string
+	RetryPolicy          RetryPolicy
+	DeadLetter           string
+	FailoverGroup        string
+	Concurrency          int
+	Cardinality          CardinalityConfig
 }
 
 // RunningOutput contains the output configuration
@@ -50,10 +67,41 @@ type RunningOutput struct {
 
 	BatchReady chan time.Time
 
-	buffer *Buffer
-	log    telegraf.Logger
+	// The buffer is replaced when opening the disk buffer, all other accesses
+	// hold the read-lock while using it
+	buffer         MetricBuffer
+	bufferMu       sync.RWMutex
+	bufferOpened   bool
+	bufferDeferred bool
+	connected      atomic.Bool
+	closed         atomic.Bool
+	retry          *retryState
+	deadLetter     atomic.Pointer[RunningOutput]
+	failover       atomic.Pointer[failoverGroup]
+	cardinality    *CardinalityLimiter
+	log            telegraf.Logger
+
+	// Time of the last log message about a route missing metric keys
+	routeMissingLogged atomic.Int64
 
 	aggMutex sync.Mutex
+
+	// Writes hold the read-lock of connMu and reconnecting the plugin holds
+	// the write-lock. If reconnecting failed, the next write connects again.
+	connMu      sync.RWMutex
+	reconnectMu sync.Mutex
+	reconnect   atomic.Bool
+
+	statusMu  sync.Mutex
+	lastWrite WriteStatus
+}
+
+// WriteStatus describes the outcome of the last write of an output.
+type WriteStatus struct {
+	Time     time.Time
+	Duration time.Duration
+	Metrics  int
+	Err      error
 }
 
 func NewRunningOutput(
@@ -69,6 +117,10 @@ func NewRunningOutput(
 
 	writeErrorsRegister := selfstat.Register("write", "errors", tags)
 	logger := NewLogger("outputs", config.Name, config.Alias)
+	logger.Attributes.ID = config.ID
+	if err := logger.SetLevel(config.LogLevel); err != nil {
+		logger.Warnf("Using global log level: %v", err)
+	}
 	logger.OnErr(func() {
 		writeErrorsRegister.Incr(1)
 	})
@@ -90,6 +142,7 @@ func NewRunningOutput(
 	ro := &RunningOutput{
 		buffer:            NewBuffer(config.Name, config.Alias, bufferLimit),
 		BatchReady:        make(chan time.Time, 1),
+		retry:             &retryState{policy: config.RetryPolicy},
 		Output:            output,
 		Config:            config,
 		MetricBufferLimit: bufferLimit,
@@ -106,6 +159,10 @@ func NewRunningOutput(
 		),
 		log: logger,
 	}
+	ro.buffer.setDropHandler(ro.sendDeadLetter)
+	if config.Cardinality.Limit > 0 {
+		ro.cardinality = NewCardinalityLimiter(config.Cardinality, logger, tags)
+	}
 
 	return ro
 }
@@ -114,6 +171,22 @@ func (r *RunningOutput) LogName() string {
 	return logName("outputs", r.Config.Name, r.Config.Alias)
 }
 
+// LogLevel returns the log level configured for the plugin or an empty string
+// if the global log level is used.
+func (r *RunningOutput) LogLevel() string {
+	return r.Config.LogLevel
+}
+
+// SetLogLevel changes the log level of the plugin at runtime. An empty level
+// uses the global log level.
+func (r *RunningOutput) SetLogLevel(level string) error {
+	if err := setLogLevel(r.log, level); err != nil {
+		return err
+	}
+	r.Config.LogLevel = level
+	return nil
+}
+
 func (r *RunningOutput) metricFiltered(metric telegraf.Metric) {
 	r.MetricsFiltered.Incr(1)
 	metric.Drop()
@@ -126,6 +199,129 @@ func (r *RunningOutput) Init() error {
 			return err
 		}
 	}
+
+	switch r.Config.BufferStrategy {
+	case "", "memory":
+	case "disk":
+		if r.Config.BufferDirectory == "" {
+			return errors.New("disk buffer strategy requires a buffer directory")
+		}
+	default:
+		return fmt.Errorf("invalid buffer strategy %q", r.Config.BufferStrategy)
+	}
+
+	switch r.Config.StartupErrorBehavior {
+	case "", "error", "retry", "ignore":
+	default:
+		return fmt.Errorf("invalid startup error behavior %q", r.Config.StartupErrorBehavior)
+	}
+
+	if err := r.Config.RetryPolicy.Validate(); err != nil {
+		return err
+	}
+	r.retry.policy = r.Config.RetryPolicy
+
+	if r.Config.Concurrency < 0 {
+		return errors.New("concurrency must not be negative")
+	}
+	if r.Config.Concurrency > 1 {
+		if p, ok := r.Output.(telegraf.ConcurrentOutput); !ok || !p.SupportsConcurrentWrites() {
+			return errors.New("output does not support concurrent writes")
+		}
+		// Failed writes of concurrent batches cannot be told apart, so a
+		// batch might be dropped before it failed the given number of times
+		if r.Config.RetryPolicy.MaxAttempts > 0 {
+			return errors.New("retry_max_attempts cannot be used with a concurrency larger than one")
+		}
+	}
+
+	return nil
+}
+
+// Connect opens the buffer, unless deferred via DeferBuffer, and connects the
+// output plugin. The disk buffer is opened here instead of in Init as a new
+// instance of the output might be initialized for validation while the
+// previous instance is still using the buffer, e.g. on configuration reload.
+func (r *RunningOutput) Connect() error {
+	if err := r.openBuffer(); err != nil {
+		return err
+	}
+	if err := r.Output.Connect(); err != nil {
+		return err
+	}
+	r.connected.Store(true)
+	return nil
+}
+
+// Connected returns true if the output plugin connected successfully.
+func (r *RunningOutput) Connected() bool {
+	return r.connected.Load()
+}
+
+// DeferBuffer keeps the metrics in memory when connecting until OpenBuffer is
+// called. This allows to connect a new instance of an output while the
+// replaced instance still uses the disk buffer, e.g. on configuration reload.
+// Opening the disk buffer twice at the same time corrupts the buffer.
+func (r *RunningOutput) DeferBuffer() {
+	r.bufferMu.Lock()
+	defer r.bufferMu.Unlock()
+
+	r.bufferDeferred = true
+}
+
+// OpenBuffer opens the disk buffer deferred via DeferBuffer. The instance
+// previously using the buffer must be closed before.
+func (r *RunningOutput) OpenBuffer() error {
+	r.bufferMu.Lock()
+	r.bufferDeferred = false
+	r.bufferMu.Unlock()
+
+	return r.openBuffer()
+}
+
+// DiskBufferPath returns the directory of the disk buffer used by the output
+// or an empty string if the output buffers in memory.
+func (r *RunningOutput) DiskBufferPath() string {
+	if r.Config.BufferStrategy != "disk" {
+		return ""
+	}
+	return filepath.Join(r.Config.BufferDirectory, r.Config.BufferKey())
+}
+
+// openBuffer replaces the default in-memory buffer by the disk buffer if
+// requested. Metrics buffered in memory in the meantime, e.g. while connecting
+// in the background, are moved to the disk buffer.
+func (r *RunningOutput) openBuffer() error {
+	path := r.DiskBufferPath()
+	if path == "" {
+		return nil
+	}
+
+	r.bufferMu.Lock()
+	defer r.bufferMu.Unlock()
+
+	if r.bufferOpened || r.bufferDeferred {
+		return nil
+	}
+
+	buffer, err := NewDiskBuffer(r.Config.Name, r.Config.Alias, path, r.MetricBufferLimit, r.Config.BufferSizeLimit)
+	if err != nil {
+		return fmt.Errorf("opening disk buffer failed: %w", err)
+	}
+	if n := buffer.Len(); n > 0 {
+		r.log.Infof("Restored %d unsent metrics from disk buffer", n)
+	}
+	buffer.setDropHandler(r.sendDeadLetter)
+
+	if memory, ok := r.buffer.(*Buffer); ok {
+		if metrics := memory.drain(); len(metrics) > 0 {
+			dropped := buffer.Add(metrics...)
+			atomic.AddInt64(&r.droppedMetrics, int64(dropped))
+		}
+	}
+	r.buffer = buffer
+	r.bufferOpened = true
+
 	return nil
 }
 
@@ -153,6 +349,11 @@ func (r *RunningOutput) AddMetric(metric telegraf.Metric) {
 		return
 	}
 
+	if r.cardinality != nil && !r.cardinality.Apply(metric) {
+		metric.Drop()
+		return
+	}
+
 	if output, ok := r.Output.(telegraf.AggregatingOutput); ok {
 		r.aggMutex.Lock()
 		output.Add(metric)
@@ -172,7 +373,9 @@ func (r *RunningOutput) AddMetric(metric telegraf.Metric) {
 		metric.AddSuffix(r.Config.NameSuffix)
 	}
 
+	r.bufferMu.RLock()
 	dropped := r.buffer.Add(metric)
+	r.bufferMu.RUnlock()
 	atomic.AddInt64(&r.droppedMetrics, int64(dropped))
 
 	count := atomic.AddInt64(&r.newMetricsCount, 1)
@@ -188,6 +391,53 @@ func (r *RunningOutput) AddMetric(metric telegraf.Metric) {
 // Write writes all metrics to the output, stopping when all have been sent on
 // or error.
 func (r *RunningOutput) Write() error {
+	r.bufferMu.RLock()
+	defer r.bufferMu.RUnlock()
+
+	r.pushAggregated()
+	atomic.StoreInt64(&r.newMetricsCount, 0)
+
+	if r.postponeWrite() {
+		return nil
+	}
+
+	// Only process the metrics in the buffer now.  Metrics added while we are
+	// writing will be sent on the next call.
+	nBuffer := r.buffer.Len()
+	nBatches := nBuffer/r.MetricBatchSize + 1
+	return r.writeBatches(nBatches)
+}
+
+// WriteFinal writes all buffered metrics one last time on shutdown. Writes
+// postponed by the backoff or the open circuit breaker of the retry policy
+// are attempted anyway as there is no later chance to write the metrics.
+func (r *RunningOutput) WriteFinal() error {
+	r.bufferMu.RLock()
+	defer r.bufferMu.RUnlock()
+
+	r.pushAggregated()
+	atomic.StoreInt64(&r.newMetricsCount, 0)
+
+	if group := r.failover.Load(); group != nil && !group.available(time.Now()) {
+		r.log.Warnf("No output of the failover group is available on shutdown, %d buffered metrics were not written",
+			r.buffer.Len())
+		return nil
+	}
+	if wait := r.retry.wait(time.Now()); wait > 0 {
+		r.log.Debug("Attempting final write despite previous failed writes")
+	}
+
+	nBatches := r.buffer.Len()/r.MetricBatchSize + 1
+	if err := r.writeBatches(nBatches); err != nil {
+		r.log.Warnf("Final write failed, %d buffered metrics were not written", r.buffer.Len())
+		return err
+	}
+	return nil
+}
+
+// pushAggregated adds the metrics of aggregating outputs to the buffer. The
+// read-lock of the buffer must be held by the caller.
+func (r *RunningOutput) pushAggregated() {
 	if output, ok := r.Output.(telegraf.AggregatingOutput); ok {
 		r.aggMutex.Lock()
 		metrics := output.Push()
@@ -195,51 +445,202 @@ func (r *RunningOutput) Write() error {
 		output.Reset()
 		r.aggMutex.Unlock()
 	}
+}
 
-	atomic.StoreInt64(&r.newMetricsCount, 0)
+// WriteBatch writes a single batch of metrics to the output or, with
+// concurrent writes enabled, as many full batches as writers are available.
+func (r *RunningOutput) WriteBatch() error {
+	r.bufferMu.RLock()
+	defer r.bufferMu.RUnlock()
 
-	// Only process the metrics in the buffer now.  Metrics added while we are
-	// writing will be sent on the next call.
-	nBuffer := r.buffer.Len()
-	nBatches := nBuffer/r.MetricBatchSize + 1
-	for i := 0; i < nBatches; i++ {
-		batch := r.buffer.Batch(r.MetricBatchSize)
-		if len(batch) == 0 {
-			break
+	if r.postponeWrite() {
+		return nil
+	}
+
+	nBatches := r.buffer.Len() / r.MetricBatchSize
+	if nBatches > r.Config.Concurrency {
+		nBatches = r.Config.Concurrency
+	}
+	if nBatches < 1 {
+		nBatches = 1
+	}
+	return r.writeBatches(nBatches)
+}
+
+// writeBatches writes up to the given number of batches from the buffer,
+// using up to the configured number of concurrent writers. No new batches are
+// started after a write failed and the first error is returned.
+func (r *RunningOutput) writeBatches(nBatches int) error {
+	writers := r.Config.Concurrency
+	if writers > nBatches {
+		writers = nBatches
+	}
+	if writers <= 1 {
+		for i := 0; i < nBatches; i++ {
+			batch := r.buffer.Batch(r.MetricBatchSize)
+			if len(batch) == 0 {
+				break
+			}
+
+			if err := r.writeBatch(batch); err != nil {
+				return err
+			}
 		}
+		return nil
+	}
 
-		err := r.writeMetrics(batch)
-		if err != nil {
-			r.buffer.Reject(batch)
-			return err
+	var remaining atomic.Int64
+	remaining.Store(int64(nBatches))
+
+	var once sync.Once
+	var firstErr error
+	var failed atomic.Bool
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !failed.Load() && remaining.Add(-1) >= 0 {
+				batch := r.buffer.Batch(r.MetricBatchSize)
+				if len(batch) == 0 {
+					return
+				}
+
+				if err := r.writeBatch(batch); err != nil {
+					failed.Store(true)
+					once.Do(func() { firstErr = err })
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// isPermanentError returns true if the output classifies the error as
+// permanent.
+func (r *RunningOutput) isPermanentError(err error) bool {
+	classifier, ok := r.Output.(telegraf.ErrorClassifier)
+	return ok && classifier.IsPermanentError(err)
+}
+
+// postponeWrite returns true if no write should be attempted yet due to the
+// backoff or the circuit breaker of the retry policy.
+func (r *RunningOutput) postponeWrite() bool {
+	if group := r.failover.Load(); group != nil {
+		if !group.available(time.Now()) {
+			r.log.Debug("Postponing write as no output of the failover group is available")
+			return true
 		}
-		r.buffer.Accept(batch)
+		return false
 	}
-	return nil
+
+	if wait := r.retry.wait(time.Now()); wait > 0 {
+		r.log.Debugf("Postponing write for %s after failed writes", wait.Round(time.Millisecond))
+		return true
+	}
+	return false
 }
 
-// WriteBatch writes a single batch of metrics to the output.
-func (r *RunningOutput) WriteBatch() error {
-	batch := r.buffer.Batch(r.MetricBatchSize)
-	if len(batch) == 0 {
-		return nil
+// writeBatch writes the batch and accepts, rejects or drops it depending on
+// the outcome and the retry policy.
+func (r *RunningOutput) writeBatch(batch []telegraf.Metric) error {
+	if group := r.failover.Load(); group != nil {
+		return group.write(batch)
 	}
 
 	err := r.writeMetrics(batch)
-	if err != nil {
-		r.buffer.Reject(batch)
+	if err == nil {
+		if r.retry.success() {
+			r.log.Info("Circuit breaker closed, resuming writes")
+		}
+		r.buffer.Accept(batch)
+		return nil
+	}
+
+	if r.isPermanentError(err) {
+		r.log.Errorf("Dropping batch of %d metrics due to permanent error", len(batch))
+		r.buffer.Drop(batch, DropReasonPermanentError, err)
 		return err
 	}
-	r.buffer.Accept(batch)
 
+	drop, opened := r.retry.failure(time.Now())
+	if opened {
+		r.log.Warnf("Circuit breaker opened after %d consecutive failed writes, pausing writes for %s",
+			r.retry.policy.BreakerThreshold, r.retry.policy.BreakerTimeout)
+	}
+	if drop {
+		r.log.Errorf("Dropping batch of %d metrics after %d failed attempts", len(batch), r.retry.policy.MaxAttempts)
+		r.buffer.Drop(batch, DropReasonMaxAttempts, err)
+		return err
+	}
+	r.buffer.Reject(batch)
+	return err
+}
+
+// Reconnect closes and connects the output plugin again, e.g. to apply
+// changed credentials. Writes wait until the plugin is connected. If
+// connecting fails, the next write tries to connect the plugin again. Plugins
+// not connected yet are left alone as they use the current settings when
+// connecting anyway.
+func (r *RunningOutput) Reconnect() error {
+	r.connMu.Lock()
+	defer r.connMu.Unlock()
+
+	if !r.Connected() || r.reconnect.Load() || r.closed.Load() {
+		return nil
+	}
+	if err := r.Output.Close(); err != nil {
+		r.log.Errorf("Error closing output: %v", err)
+	}
+	if err := r.Output.Connect(); err != nil {
+		r.reconnect.Store(true)
+		return err
+	}
 	return nil
 }
 
-// Close closes the output
+// restoreConnection connects the output plugin if reconnecting it failed
+// before. The read-lock of connMu must be held by the caller.
+func (r *RunningOutput) restoreConnection() error {
+	r.reconnectMu.Lock()
+	defer r.reconnectMu.Unlock()
+
+	if !r.reconnect.Load() {
+		return nil
+	}
+	if err := r.Output.Connect(); err != nil {
+		return fmt.Errorf("reconnecting failed: %w", err)
+	}
+	r.reconnect.Store(false)
+	r.log.Info("Reconnected output")
+	return nil
+}
+
+// Close closes the output plugin, if it was connected, and the buffer.
+// Plugins never connected are not closed as they might not be able to handle
+// this. Closing the output more than once has no effect.
 func (r *RunningOutput) Close() {
-	err := r.Output.Close()
-	if err != nil {
-		r.log.Errorf("Error closing output: %v", err)
+	r.connMu.Lock()
+	defer r.connMu.Unlock()
+
+	if r.closed.Swap(true) {
+		return
+	}
+
+	if r.Connected() && !r.reconnect.Load() {
+		if err := r.Output.Close(); err != nil {
+			r.log.Errorf("Error closing output: %v", err)
+		}
+	}
+
+	r.bufferMu.RLock()
+	defer r.bufferMu.RUnlock()
+	if err := r.buffer.Close(); err != nil {
+		r.log.Errorf("Error closing buffer: %v", err)
 	}
 }
 
@@ -250,11 +651,21 @@ func (r *RunningOutput) writeMetrics(metrics []telegraf.Metric) error {
 		atomic.StoreInt64(&r.droppedMetrics, 0)
 	}
 
+	r.connMu.RLock()
+	defer r.connMu.RUnlock()
+	if err := r.restoreConnection(); err != nil {
+		return err
+	}
+
 	start := time.Now()
 	err := r.Output.Write(metrics)
 	elapsed := time.Since(start)
 	r.WriteTime.Incr(elapsed.Nanoseconds())
 
+	r.statusMu.Lock()
+	r.lastWrite = WriteStatus{Time: start, Duration: elapsed, Metrics: len(metrics), Err: err}
+	r.statusMu.Unlock()
+
 	if err == nil {
 		r.log.Debugf("Wrote batch of %d metrics in %s", len(metrics), elapsed)
 	}
@@ -262,7 +673,7 @@ func (r *RunningOutput) writeMetrics(metrics []telegraf.Metric) error {
 }
 
 func (r *RunningOutput) LogBufferStatus() {
-	nBuffer := r.buffer.Len()
+	nBuffer := r.BufferLength()
 	r.log.Debugf("Buffer fullness: %d / %d metrics", nBuffer, r.MetricBufferLimit)
 }
 
@@ -271,5 +682,14 @@ func (r *RunningOutput) Log() telegraf.Logger {
 }
 
 func (r *RunningOutput) BufferLength() int {
+	r.bufferMu.RLock()
+	defer r.bufferMu.RUnlock()
 	return r.buffer.Len()
 }
+
+// LastWrite returns the status of the last write attempt of the output.
+func (r *RunningOutput) LastWrite() WriteStatus {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	return r.lastWrite
+}
@@ -1,8 +1,13 @@
 package models
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -460,6 +465,341 @@ func TestRunningOutputWriteFailOrder3(t *testing.T) {
 	require.Equal(t, expected, m.Metrics())
 }
 
+func TestRunningOutputRetryPolicy(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:      Filter{},
+		RetryPolicy: RetryPolicy{MaxAttempts: 2, BreakerThreshold: 3},
+	}
+
+	m := &mockOutput{}
+	m.failWrite = true
+	ro := NewRunningOutput(m, conf, 4, 12)
+	require.NoError(t, ro.Init())
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+	dropped := AgentMetricsDropped.Get()
+
+	// The first batch is dropped after the second attempt
+	require.Error(t, ro.WriteBatch())
+	require.Equal(t, 5, ro.buffer.Len())
+	require.Error(t, ro.WriteBatch())
+	require.Equal(t, 1, ro.buffer.Len())
+	require.Equal(t, dropped+4, AgentMetricsDropped.Get())
+
+	// The third consecutive failure opens the circuit breaker
+	require.Error(t, ro.WriteBatch())
+	require.Positive(t, ro.retry.wait(time.Now()))
+	m.failWrite = false
+	require.NoError(t, ro.Write())
+	require.Empty(t, m.Metrics())
+	require.Equal(t, 1, ro.buffer.Len())
+
+	// The final write on shutdown is attempted despite the open breaker
+	require.NoError(t, ro.WriteFinal())
+	require.Len(t, m.Metrics(), 1)
+	require.Zero(t, ro.buffer.Len())
+}
+
+func TestRunningOutputPermanentError(t *testing.T) {
+	conf := &OutputConfig{
+		Filter: Filter{},
+	}
+
+	m := &classifyingOutput{}
+	m.failWrite = true
+	ro := NewRunningOutput(m, conf, 4, 12)
+	require.NoError(t, ro.Init())
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+	dropped := AgentMetricsDropped.Get()
+
+	// Transient errors keep the metrics
+	require.Error(t, ro.Write())
+	require.Equal(t, 5, ro.buffer.Len())
+
+	// Permanent errors drop the batch
+	m.permanent = true
+	require.Error(t, ro.Write())
+	require.Equal(t, 1, ro.buffer.Len())
+	require.Equal(t, dropped+4, AgentMetricsDropped.Get())
+
+	m.failWrite = false
+	require.NoError(t, ro.Write())
+	require.Len(t, m.Metrics(), 1)
+}
+
+func TestRunningOutputConcurrency(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:      Filter{},
+		Concurrency: 3,
+	}
+
+	m := &concurrentOutput{release: make(chan struct{})}
+	ro := NewRunningOutput(m, conf, 2, 100)
+	require.NoError(t, ro.Init())
+
+	for i := 0; i < 10; i++ {
+		ro.AddMetric(testutil.TestMetric(i))
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ro.Write()
+	}()
+
+	// Three batches are written in parallel
+	require.Eventually(t, func() bool {
+		return m.inflight.Load() == 3
+	}, time.Second, 10*time.Millisecond)
+	close(m.release)
+	require.NoError(t, <-done)
+
+	require.Len(t, m.Metrics(), 10)
+	require.Equal(t, 0, ro.BufferLength())
+	require.Equal(t, int32(3), m.maxInflight.Load())
+}
+
+func TestRunningOutputConcurrencyUnsupported(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:      Filter{},
+		Concurrency: 2,
+	}
+
+	ro := NewRunningOutput(&mockOutput{}, conf, 2, 100)
+	require.ErrorContains(t, ro.Init(), "output does not support concurrent writes")
+}
+
+func TestRunningOutputConcurrencyMaxAttempts(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:      Filter{},
+		Concurrency: 2,
+		RetryPolicy: RetryPolicy{MaxAttempts: 3},
+	}
+
+	ro := NewRunningOutput(&concurrentOutput{}, conf, 2, 100)
+	require.ErrorContains(t, ro.Init(), "retry_max_attempts cannot be used with a concurrency larger than one")
+}
+
+func TestRunningOutputCardinalityLimit(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:      Filter{},
+		Cardinality: CardinalityConfig{Limit: 1},
+	}
+	require.NoError(t, conf.Cardinality.Validate())
+
+	m := &mockOutput{}
+	ro := NewRunningOutput(m, conf, 1000, 10000)
+
+	ro.AddMetric(testutil.TestMetric(1, "metric1"))
+	ro.AddMetric(testutil.TestMetric(2, "metric1"))
+	ro.AddMetric(testutil.TestMetric(3, "metric2"))
+	ro.AddMetric(testutil.MustMetric("metric1", map[string]string{"tag1": "other"}, map[string]interface{}{"value": 1}, time.Now()))
+	require.NoError(t, ro.Write())
+	require.Len(t, m.Metrics(), 3)
+}
+
+func TestRunningOutputDiskBufferSurvivesRestart(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:          Filter{},
+		ID:              "test",
+		BufferStrategy:  "disk",
+		BufferDirectory: t.TempDir(),
+	}
+
+	m := &mockOutput{failWrite: true}
+	ro := NewRunningOutput(m, conf, 1000, 10000)
+	require.NoError(t, ro.Init())
+	require.NoError(t, ro.Connect())
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+	require.Error(t, ro.Write())
+	ro.Close()
+
+	// Restart with a working output
+	m = &mockOutput{}
+	ro = NewRunningOutput(m, conf, 1000, 10000)
+	require.NoError(t, ro.Init())
+	require.Equal(t, 0, ro.BufferLength())
+	require.NoError(t, ro.Connect())
+	defer ro.Close()
+	require.Equal(t, 5, ro.BufferLength())
+
+	require.NoError(t, ro.Write())
+	testutil.RequireMetricsEqual(t, first5, m.Metrics())
+	require.Equal(t, 0, ro.BufferLength())
+}
+
+func TestRunningOutputDiskBufferKeptOnConfigChange(t *testing.T) {
+	conf := &OutputConfig{
+		Name:            "test",
+		Filter:          Filter{},
+		ID:              "first",
+		BufferStrategy:  "disk",
+		BufferDirectory: t.TempDir(),
+	}
+
+	ro := NewRunningOutput(&mockOutput{failWrite: true}, conf, 1000, 10000)
+	require.NoError(t, ro.Init())
+	require.NoError(t, ro.Connect())
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+	ro.Close()
+
+	// Changing the settings changes the plugin ID but not the buffer
+	changed := *conf
+	changed.ID = "second"
+	changed.FlushInterval = time.Minute
+	m := &mockOutput{}
+	ro = NewRunningOutput(m, &changed, 1000, 10000)
+	require.NoError(t, ro.Init())
+	require.NoError(t, ro.Connect())
+	defer ro.Close()
+
+	require.NoError(t, ro.Write())
+	testutil.RequireMetricsEqual(t, first5, m.Metrics())
+}
+
+func TestRunningOutputDiskBufferMovesMemoryMetrics(t *testing.T) {
+	conf := &OutputConfig{
+		Name:            "test",
+		Filter:          Filter{},
+		BufferStrategy:  "disk",
+		BufferDirectory: filepath.Join(t.TempDir(), "missing"),
+	}
+
+	m := &mockOutput{}
+	ro := NewRunningOutput(m, conf, 1000, 10000)
+	require.NoError(t, ro.Init())
+
+	// Metrics are kept in memory until the disk buffer is opened
+	require.NoError(t, os.WriteFile(conf.BufferDirectory, nil, 0640))
+	require.ErrorContains(t, ro.Connect(), "opening disk buffer failed")
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+	require.Equal(t, 5, ro.BufferLength())
+
+	require.NoError(t, os.Remove(conf.BufferDirectory))
+	require.NoError(t, ro.Connect())
+	defer ro.Close()
+	_, ok := ro.buffer.(*DiskBuffer)
+	require.True(t, ok)
+	require.Equal(t, 5, ro.BufferLength())
+
+	require.NoError(t, ro.Write())
+	testutil.RequireMetricsEqual(t, first5, m.Metrics())
+}
+
+func TestRunningOutputCloseNotConnected(t *testing.T) {
+	conf := &OutputConfig{
+		Name:            "test",
+		Filter:          Filter{},
+		BufferStrategy:  "disk",
+		BufferDirectory: t.TempDir(),
+	}
+
+	ro := NewRunningOutput(&unreachableOutput{}, conf, 1000, 10000)
+	require.NoError(t, ro.Init())
+	require.Error(t, ro.Connect())
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+
+	// The plugin is not closed but the buffer is
+	require.NotPanics(t, ro.Close)
+	require.NotPanics(t, ro.Close)
+
+	m := &mockOutput{}
+	ro = NewRunningOutput(m, conf, 1000, 10000)
+	require.NoError(t, ro.Init())
+	require.NoError(t, ro.Connect())
+	defer ro.Close()
+	require.NoError(t, ro.Write())
+	testutil.RequireMetricsEqual(t, first5, m.Metrics())
+}
+
+func TestRunningOutputReconnect(t *testing.T) {
+	conf := &OutputConfig{
+		Filter: Filter{},
+	}
+
+	m := &reconnectingOutput{}
+	ro := NewRunningOutput(m, conf, 1000, 10000)
+	require.NoError(t, ro.Init())
+	defer ro.Close()
+
+	// Outputs not connected yet are left alone
+	require.NoError(t, ro.Reconnect())
+	require.Zero(t, m.connects)
+	require.Zero(t, m.closes)
+
+	require.NoError(t, ro.Connect())
+	require.NoError(t, ro.Reconnect())
+	require.Equal(t, 2, m.connects)
+	require.Equal(t, 1, m.closes)
+
+	// Failing to reconnect is retried on the next write
+	m.failConnect = true
+	require.ErrorContains(t, ro.Reconnect(), "connection refused")
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+	require.ErrorContains(t, ro.Write(), "reconnecting failed")
+	require.Empty(t, m.Metrics())
+
+	m.failConnect = false
+	require.NoError(t, ro.Write())
+	require.Equal(t, 3, m.connects)
+	require.Equal(t, 2, m.closes)
+	testutil.RequireMetricsEqual(t, first5, m.Metrics())
+}
+
+func TestRunningOutputDeferBuffer(t *testing.T) {
+	conf := &OutputConfig{
+		Name:            "test",
+		Filter:          Filter{},
+		BufferStrategy:  "disk",
+		BufferDirectory: t.TempDir(),
+	}
+
+	m := &mockOutput{}
+	ro := NewRunningOutput(m, conf, 1000, 10000)
+	require.NoError(t, ro.Init())
+	ro.DeferBuffer()
+	require.NoError(t, ro.Connect())
+	defer ro.Close()
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+
+	// The disk buffer is not touched until opened explicitly
+	_, err := os.Stat(ro.DiskBufferPath())
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	// Metrics buffered in memory are moved to the disk buffer
+	require.NoError(t, ro.OpenBuffer())
+	require.DirExists(t, ro.DiskBufferPath())
+	require.Equal(t, 5, ro.BufferLength())
+	require.NoError(t, ro.Write())
+	testutil.RequireMetricsEqual(t, first5, m.Metrics())
+}
+
+func TestRunningOutputInvalidBufferStrategy(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:         Filter{},
+		BufferStrategy: "foo",
+	}
+
+	ro := NewRunningOutput(&mockOutput{}, conf, 1000, 10000)
+	require.ErrorContains(t, ro.Init(), "invalid buffer strategy")
+}
+
 func TestInternalMetrics(t *testing.T) {
 	_ = NewRunningOutput(
 		&mockOutput{},
@@ -549,6 +889,43 @@ func (m *mockOutput) Metrics() []telegraf.Metric {
 	return m.metrics
 }
 
+type classifyingOutput struct {
+	mockOutput
+
+	// if true, write failures are permanent
+	permanent bool
+}
+
+func (m *classifyingOutput) IsPermanentError(error) bool {
+	return m.permanent
+}
+
+type concurrentOutput struct {
+	mockOutput
+
+	release     chan struct{}
+	inflight    atomic.Int32
+	maxInflight atomic.Int32
+}
+
+func (*concurrentOutput) SupportsConcurrentWrites() bool {
+	return true
+}
+
+func (m *concurrentOutput) Write(metrics []telegraf.Metric) error {
+	n := m.inflight.Add(1)
+	defer m.inflight.Add(-1)
+	for {
+		current := m.maxInflight.Load()
+		if n <= current || m.maxInflight.CompareAndSwap(current, n) {
+			break
+		}
+	}
+
+	<-m.release
+	return m.mockOutput.Write(metrics)
+}
+
 type perfOutput struct {
 	// if true, mock write failure
 	failWrite bool
@@ -576,3 +953,38 @@ func (m *perfOutput) Write(_ []telegraf.Metric) error {
 	}
 	return nil
 }
+
+// unreachableOutput fails to connect and cannot be closed without connection
+type unreachableOutput struct {
+	mockOutput
+	conn io.Closer
+}
+
+func (*unreachableOutput) Connect() error {
+	return errors.New("connection refused")
+}
+
+func (o *unreachableOutput) Close() error {
+	return o.conn.Close()
+}
+
+type reconnectingOutput struct {
+	mockOutput
+
+	connects    int
+	closes      int
+	failConnect bool
+}
+
+func (o *reconnectingOutput) Connect() error {
+	if o.failConnect {
+		return errors.New("connection refused")
+	}
+	o.connects++
+	return nil
+}
+
+func (o *reconnectingOutput) Close() error {
+	o.closes++
+	return nil
+}
@@ -8,9 +8,10 @@ import (
 )
 
 type RunningParser struct {
-	Parser telegraf.Parser
-	Config *ParserConfig
-	log    telegraf.Logger
+	Parser   telegraf.Parser
+	Config   *ParserConfig
+	Recorder *Recorder
+	log      telegraf.Logger
 
 	MetricsParsed selfstat.Stat
 	ParseTime     selfstat.Stat
@@ -52,6 +53,8 @@ type ParserConfig struct {
 	Alias       string
 	DataFormat  string
 	DefaultTags map[string]string
+	// Record the data passed to the parser if the recorder is open
+	Record bool
 }
 
 func (r *RunningParser) LogName() string {
@@ -69,6 +72,15 @@ func (r *RunningParser) Init() error {
 }
 
 func (r *RunningParser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	return r.ParseWithMetadata(buf, nil)
+}
+
+// ParseWithMetadata parses the data like Parse. The metadata describes the
+// origin of the data, e.g. the MQTT topic, and is recorded along with the
+// data if recording is enabled.
+func (r *RunningParser) ParseWithMetadata(buf []byte, metadata map[string]string) ([]telegraf.Metric, error) {
+	r.Recorder.Record(r.Config, metadata, buf)
+
 	start := time.Now()
 	m, err := r.Parser.Parse(buf)
 	elapsed := time.Since(start)
@@ -79,6 +91,8 @@ func (r *RunningParser) Parse(buf []byte) ([]telegraf.Metric, error) {
 }
 
 func (r *RunningParser) ParseLine(line string) (telegraf.Metric, error) {
+	r.Recorder.Record(r.Config, nil, []byte(line))
+
 	start := time.Now()
 	m, err := r.Parser.ParseLine(line)
 	elapsed := time.Since(start)
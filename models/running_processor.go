@@ -22,11 +22,12 @@ func (rp RunningProcessors) Less(i, j int) bool { return rp[i].Config.Order < rp
 
 // ProcessorConfig containing a name and filter
 type ProcessorConfig struct {
-	Name   string
-	Alias  string
-	ID     string
-	Order  int64
-	Filter Filter
+	Name     string
+	Alias    string
+	LogLevel string
+	ID       string
+	Order    int64
+	Filter   Filter
 }
 
 func NewRunningProcessor(processor telegraf.StreamingProcessor, config *ProcessorConfig) *RunningProcessor {
@@ -37,6 +38,10 @@ func NewRunningProcessor(processor telegraf.StreamingProcessor, config *Processo
 
 	processErrorsRegister := selfstat.Register("process", "errors", tags)
 	logger := NewLogger("processors", config.Name, config.Alias)
+	logger.Attributes.ID = config.ID
+	if err := logger.SetLevel(config.LogLevel); err != nil {
+		logger.Warnf("Using global log level: %v", err)
+	}
 	logger.OnErr(func() {
 		processErrorsRegister.Incr(1)
 	})
@@ -78,6 +83,22 @@ func (rp *RunningProcessor) LogName() string {
 	return logName("processors", rp.Config.Name, rp.Config.Alias)
 }
 
+// LogLevel returns the log level configured for the plugin or an empty string
+// if the global log level is used.
+func (rp *RunningProcessor) LogLevel() string {
+	return rp.Config.LogLevel
+}
+
+// SetLogLevel changes the log level of the plugin at runtime. An empty level
+// uses the global log level.
+func (rp *RunningProcessor) SetLogLevel(level string) error {
+	if err := setLogLevel(rp.log, level); err != nil {
+		return err
+	}
+	rp.Config.LogLevel = level
+	return nil
+}
+
 func (rp *RunningProcessor) MakeMetric(metric telegraf.Metric) telegraf.Metric {
 	return metric
 }
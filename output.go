@@ -27,3 +27,22 @@ type AggregatingOutput interface {
 	// Reset signals that the aggregator period is completed.
 	Reset()
 }
+
+// ErrorClassifier can be implemented by outputs to classify the errors
+// returned by Write. Metrics failing with a permanent error are dropped
+// instead of being retried.
+type ErrorClassifier interface {
+	// IsPermanentError returns true if writing the metrics failed with an
+	// error that cannot be resolved by retrying, e.g. if the metrics were
+	// rejected as invalid.
+	IsPermanentError(err error) bool
+}
+
+// ConcurrentOutput can be implemented by outputs supporting concurrent calls
+// of Write, allowing to write several batches in parallel. Batches written
+// concurrently might be stored out of order by the service.
+type ConcurrentOutput interface {
+	// SupportsConcurrentWrites returns true if Write is safe to call
+	// concurrently with the current configuration.
+	SupportsConcurrentWrites() bool
+}
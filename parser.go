@@ -22,6 +22,13 @@ type Parser interface {
 	SetDefaultTags(tags map[string]string)
 }
 
+// MetadataParser is an optional interface for parsers accepting metadata
+// about the origin of the data, e.g. the MQTT topic or the URL, for example
+// to record the data along with its origin.
+type MetadataParser interface {
+	ParseWithMetadata(buf []byte, metadata map[string]string) ([]Metric, error)
+}
+
 type ParserFunc func() (Parser, error)
 
 // ParserPlugin is an interface for plugins that are able to parse
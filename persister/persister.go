@@ -1,46 +1,120 @@
 package persister
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"reflect"
+	"sync"
+	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/selfstat"
 )
 
 type Persister struct {
 	Filename string
 
+	// Interval for periodically storing the states, zero disables periodic
+	// checkpoints so the states are only stored on termination.
+	Interval time.Duration
+
 	register map[string]telegraf.StatefulPlugin
+
+	mu             sync.Mutex
+	requests       chan struct{}
+	started        time.Time
+	lastCheckpoint time.Time
+
+	checkpoints      selfstat.Stat
+	checkpointErrors selfstat.Stat
+	checkpointAge    selfstat.Stat
+	checkpointTime   selfstat.Stat
 }
 
 func (p *Persister) Init() error {
 	p.register = make(map[string]telegraf.StatefulPlugin)
+	p.requests = make(chan struct{}, 1)
+	p.started = time.Now()
+
+	tags := map[string]string{}
+	p.checkpoints = selfstat.Register("persister", "checkpoints", tags)
+	p.checkpointErrors = selfstat.Register("persister", "checkpoint_errors", tags)
+	p.checkpointAge = selfstat.RegisterFunc("persister", "checkpoint_age_ns", tags, p.age)
+	p.checkpointTime = selfstat.RegisterTiming("persister", "checkpoint_time_ns", tags)
 
 	return nil
 }
 
 func (p *Persister) Register(id string, plugin telegraf.StatefulPlugin) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	if _, found := p.register[id]; found {
 		return fmt.Errorf("plugin with ID %q already registered", id)
 	}
 	p.register[id] = plugin
+	p.setPersisterOnPlugin(plugin)
 
 	return nil
 }
 
-func (p *Persister) Load() error {
-	// Read the states from disk
-	in, err := os.ReadFile(p.Filename)
-	if err != nil {
-		return fmt.Errorf("reading states file failed: %w", err)
+// Unregister removes the plugin with the given ID, e.g. if the plugin was
+// removed from the configuration while running.
+func (p *Persister) Unregister(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.register, id)
+}
+
+// Checkpoint requests storing the states of all plugins as soon as possible.
+// The function does not block and multiple requests issued while a checkpoint
+// is pending are merged.
+func (p *Persister) Checkpoint() {
+	select {
+	case p.requests <- struct{}{}:
+	default:
 	}
+}
 
-	// Unmarshal the id to serialized states map
-	var states map[string][]byte
-	if err := json.Unmarshal(in, &states); err != nil {
-		return fmt.Errorf("unmarshalling states failed: %w", err)
+// Run stores the states of all plugins periodically and on request until the
+// context is done.
+func (p *Persister) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if p.Interval > 0 {
+		ticker := time.NewTicker(p.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-p.requests:
+		}
+		if err := p.Store(); err != nil {
+			log.Printf("E! [persister] Checkpointing plugin states failed: %v", err)
+		}
+	}
+}
+
+func (p *Persister) Load() error {
+	// Read the states from disk and fall back to the backup in case the
+	// current file is missing or broken, e.g. due to a crash while storing
+	states, err := p.read(p.Filename)
+	if err != nil {
+		var berr error
+		states, berr = p.read(p.backupFilename())
+		if berr != nil {
+			return err
+		}
+		log.Printf("W! [persister] Restoring states from backup as %v", err)
 	}
 
 	// Get the initialized state as blueprint for unmarshalling
@@ -70,6 +144,34 @@ func (p *Persister) Load() error {
 }
 
 func (p *Persister) Store() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	start := time.Now()
+	if err := p.store(); err != nil {
+		p.checkpointErrors.Incr(1)
+		return err
+	}
+	p.lastCheckpoint = time.Now()
+	p.checkpointTime.Incr(time.Since(start).Nanoseconds())
+	p.checkpoints.Incr(1)
+
+	return nil
+}
+
+// age returns the time passed since the last successful checkpoint or, if
+// there was none yet, since initializing the persister.
+func (p *Persister) age() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.lastCheckpoint.IsZero() {
+		return time.Since(p.started).Nanoseconds()
+	}
+	return time.Since(p.lastCheckpoint).Nanoseconds()
+}
+
+func (p *Persister) store() error {
 	states := make(map[string][]byte)
 
 	// Collect the states and serialize the individual data chunks
@@ -88,16 +190,70 @@ func (p *Persister) Store() error {
 		return fmt.Errorf("marshalling states failed: %w", err)
 	}
 
-	// Write the states to disk
-	f, err := os.Create(p.Filename)
+	// Write the states to a temporary file first to not corrupt the
+	// existing states in case of a crash
+	tmpfile := p.Filename + ".tmp"
+	f, err := os.Create(tmpfile)
 	if err != nil {
-		return fmt.Errorf("creating states file %q failed: %w", p.Filename, err)
+		return fmt.Errorf("creating states file %q failed: %w", tmpfile, err)
 	}
-	defer f.Close()
-
 	if _, err := f.Write(serialized); err != nil {
+		f.Close()
 		return fmt.Errorf("writing states failed: %w", err)
 	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("syncing states failed: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing states file failed: %w", err)
+	}
+
+	// Keep the previous generation as backup and move the new states in place
+	if err := os.Rename(p.Filename, p.backupFilename()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("creating backup of states file failed: %w", err)
+	}
+	if err := os.Rename(tmpfile, p.Filename); err != nil {
+		return fmt.Errorf("replacing states file failed: %w", err)
+	}
 
 	return nil
 }
+
+func (p *Persister) read(filename string) (map[string][]byte, error) {
+	in, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading states file failed: %w", err)
+	}
+
+	// Unmarshal the id to serialized states map
+	var states map[string][]byte
+	if err := json.Unmarshal(in, &states); err != nil {
+		return nil, fmt.Errorf("unmarshalling states failed: %w", err)
+	}
+	return states, nil
+}
+
+func (p *Persister) backupFilename() string {
+	return p.Filename + ".bak"
+}
+
+// setPersisterOnPlugin injects the persister into the plugin's "Persister"
+// field if it exists to allow the plugin to request checkpoints.
+func (p *Persister) setPersisterOnPlugin(plugin interface{}) {
+	valI := reflect.ValueOf(plugin)
+	if valI.Type().Kind() != reflect.Ptr {
+		return
+	}
+	if valI.Elem().Kind() != reflect.Struct {
+		return
+	}
+
+	field := valI.Elem().FieldByName("Persister")
+	if !field.IsValid() || !field.CanSet() {
+		return
+	}
+	if field.Type() == reflect.TypeOf((*telegraf.StatePersister)(nil)).Elem() {
+		field.Set(reflect.ValueOf(p))
+	}
+}
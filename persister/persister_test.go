@@ -0,0 +1,188 @@
+package persister
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+)
+
+type mockupStatePlugin struct {
+	Persister telegraf.StatePersister `toml:"-"`
+
+	sync.Mutex
+	state map[string]int64
+}
+
+func (m *mockupStatePlugin) GetState() interface{} {
+	m.Lock()
+	defer m.Unlock()
+
+	state := make(map[string]int64, len(m.state))
+	for k, v := range m.state {
+		state[k] = v
+	}
+	return state
+}
+
+func (m *mockupStatePlugin) SetState(state interface{}) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.state = state.(map[string]int64)
+	return nil
+}
+
+func (m *mockupStatePlugin) set(key string, value int64) {
+	m.Lock()
+	defer m.Unlock()
+	m.state[key] = value
+}
+
+func newPersister(t *testing.T, filename string, plugin *mockupStatePlugin) *Persister {
+	p := &Persister{Filename: filename}
+	require.NoError(t, p.Init())
+	require.NoError(t, p.Register("test", plugin))
+
+	// Reset the statistics shared between all persister instances
+	p.checkpoints.Set(0)
+	p.checkpointErrors.Set(0)
+	return p
+}
+
+func TestStoreKeepsBackup(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "states.json")
+
+	plugin := &mockupStatePlugin{state: map[string]int64{"a": 1}}
+	p := newPersister(t, filename, plugin)
+	require.NoError(t, p.Store())
+	require.NoFileExists(t, filename+".bak")
+
+	plugin.set("a", 2)
+	require.NoError(t, p.Store())
+	require.FileExists(t, filename+".bak")
+	require.NoFileExists(t, filename+".tmp")
+
+	// The backup must hold the previous generation
+	restored := &mockupStatePlugin{state: map[string]int64{}}
+	require.NoError(t, newPersister(t, filename+".bak", restored).Load())
+	require.Equal(t, map[string]int64{"a": 1}, restored.state)
+
+	restored = &mockupStatePlugin{state: map[string]int64{}}
+	require.NoError(t, newPersister(t, filename, restored).Load())
+	require.Equal(t, map[string]int64{"a": 2}, restored.state)
+}
+
+func TestLoadFallsBackToBackup(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "states.json")
+
+	plugin := &mockupStatePlugin{state: map[string]int64{"a": 1}}
+	p := newPersister(t, filename, plugin)
+	require.NoError(t, p.Store())
+	plugin.set("a", 2)
+	require.NoError(t, p.Store())
+
+	// Simulate a broken statefile
+	require.NoError(t, os.WriteFile(filename, []byte("{broken"), 0600))
+
+	restored := &mockupStatePlugin{state: map[string]int64{}}
+	require.NoError(t, newPersister(t, filename, restored).Load())
+	require.Equal(t, map[string]int64{"a": 1}, restored.state)
+}
+
+func TestLoadNotExisting(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "states.json")
+
+	p := newPersister(t, filename, &mockupStatePlugin{state: map[string]int64{}})
+	require.ErrorIs(t, p.Load(), os.ErrNotExist)
+}
+
+func TestCheckpointOnRequest(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "states.json")
+
+	plugin := &mockupStatePlugin{state: map[string]int64{"a": 1}}
+	p := newPersister(t, filename, plugin)
+	require.NotNil(t, plugin.Persister)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.Run(ctx)
+	}()
+	defer wg.Wait()
+	defer cancel()
+
+	plugin.Persister.Checkpoint()
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filename)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, int64(0), p.checkpointErrors.Get())
+	require.GreaterOrEqual(t, p.checkpoints.Get(), int64(1))
+}
+
+func TestCheckpointPeriodically(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "states.json")
+
+	plugin := &mockupStatePlugin{state: map[string]int64{"a": 1}}
+	p := newPersister(t, filename, plugin)
+	p.Interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.Run(ctx)
+	}()
+	defer wg.Wait()
+	defer cancel()
+
+	plugin.set("a", 42)
+	require.Eventually(t, func() bool {
+		restored := &mockupStatePlugin{state: map[string]int64{}}
+		if err := newPersister(t, filename, restored).Load(); err != nil {
+			return false
+		}
+		return restored.GetState().(map[string]int64)["a"] == 42
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestCheckpointFailure(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "missing", "states.json")
+
+	p := newPersister(t, filename, &mockupStatePlugin{state: map[string]int64{}})
+	require.Error(t, p.Store())
+	require.Equal(t, int64(1), p.checkpointErrors.Get())
+}
+
+func TestCheckpointAge(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "states.json")
+
+	p := newPersister(t, filename, &mockupStatePlugin{state: map[string]int64{}})
+	require.NoError(t, p.Store())
+
+	// The age grows between checkpoints without storing the states
+	time.Sleep(10 * time.Millisecond)
+	age := p.checkpointAge.Get()
+	require.GreaterOrEqual(t, age, (10 * time.Millisecond).Nanoseconds())
+	time.Sleep(10 * time.Millisecond)
+	require.Greater(t, p.checkpointAge.Get(), age)
+
+	// A failing checkpoint keeps the age of the last successful one
+	p.Filename = filepath.Join(t.TempDir(), "missing", "states.json")
+	require.Error(t, p.Store())
+	require.GreaterOrEqual(t, p.checkpointAge.Get(), (20 * time.Millisecond).Nanoseconds())
+
+	p.Filename = filename
+	require.NoError(t, p.Store())
+	require.Less(t, p.checkpointAge.Get(), (20 * time.Millisecond).Nanoseconds())
+}
@@ -81,6 +81,16 @@ type StatefulPlugin interface {
 	SetState(state interface{}) error
 }
 
+// StatePersister allows stateful plugins to request storing the states of
+// all plugins on demand, e.g. after committing a critical position.
+// The persister is injected into a plugin field of this type named
+// "Persister" when the plugin is registered.
+type StatePersister interface {
+	// Checkpoint requests storing the current states as soon as possible.
+	// The call does not block.
+	Checkpoint()
+}
+
 // Logger defines an plugin-related interface for logging.
 type Logger interface {
 	// Errorf logs an error message, patterned after log.Printf.
@@ -0,0 +1,195 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+)
+
+var (
+	// ErrFull is returned if the maximum number of undelivered messages is
+	// reached.
+	ErrFull = errors.New("too many undelivered messages")
+
+	// ErrNotDelivered is returned if the outputs did not deliver all metrics
+	// of a message, e.g. because they were rejected or dropped.
+	ErrNotDelivered = errors.New("metrics not delivered")
+
+	// ErrTimeout is returned if the metrics were not delivered within the
+	// delivery timeout.
+	ErrTimeout = errors.New("timeout waiting for delivery")
+
+	// ErrStopped is returned if the tracker is stopped before the metrics
+	// were delivered.
+	ErrStopped = errors.New("tracker stopped")
+)
+
+// Config contains the options for waiting for the delivery of the metrics
+// of a message by the outputs before acknowledging the message.
+type Config struct {
+	WaitForDelivery        bool            `toml:"wait_for_delivery"`
+	MaxUndeliveredMessages int             `toml:"max_undelivered_messages"`
+	DeliveryTimeout        config.Duration `toml:"delivery_timeout"`
+}
+
+const (
+	defaultMaxUndeliveredMessages = 1000
+	defaultDeliveryTimeout        = config.Duration(5 * time.Second)
+)
+
+// Tracker adds the metrics of messages as tracked groups to the accumulator
+// and waits for their delivery.
+type Tracker struct {
+	acc     telegraf.TrackingAccumulator
+	timeout time.Duration
+
+	// slots limits the number of undelivered messages
+	slots chan struct{}
+
+	mu      sync.Mutex
+	pending map[telegraf.TrackingID]chan bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// ValidateDelivery applies the default settings and checks that the delivery
+// timeout is shorter than the write timeout of the server acknowledging the
+// messages. A zero write timeout skips the check.
+func (c *Config) ValidateDelivery(writeTimeout time.Duration) error {
+	c.setDefaults()
+	if c.WaitForDelivery && writeTimeout > 0 && time.Duration(c.DeliveryTimeout) >= writeTimeout {
+		return fmt.Errorf("delivery_timeout %s must be shorter than the write timeout %s",
+			time.Duration(c.DeliveryTimeout), writeTimeout)
+	}
+	return nil
+}
+
+// DeliveryWait returns the time to wait for the delivery of the metrics and
+// whether waiting for delivery is enabled.
+func (c *Config) DeliveryWait() (timeout time.Duration, enabled bool) {
+	return time.Duration(c.DeliveryTimeout), c.WaitForDelivery
+}
+
+func (c *Config) setDefaults() {
+	if c.MaxUndeliveredMessages <= 0 {
+		c.MaxUndeliveredMessages = defaultMaxUndeliveredMessages
+	}
+	if c.DeliveryTimeout <= 0 {
+		c.DeliveryTimeout = defaultDeliveryTimeout
+	}
+}
+
+// NewTracker returns a running tracker for the given accumulator.
+func (c *Config) NewTracker(acc telegraf.Accumulator) *Tracker {
+	c.setDefaults()
+
+	t := &Tracker{
+		acc:     acc.WithTracking(c.MaxUndeliveredMessages),
+		timeout: time.Duration(c.DeliveryTimeout),
+		slots:   make(chan struct{}, c.MaxUndeliveredMessages),
+		pending: make(map[telegraf.TrackingID]chan bool),
+		done:    make(chan struct{}),
+	}
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		t.run()
+	}()
+
+	return t
+}
+
+func (t *Tracker) run() {
+	for {
+		select {
+		case <-t.done:
+			return
+		case info := <-t.acc.Delivered():
+			t.mu.Lock()
+			result, found := t.pending[info.ID()]
+			delete(t.pending, info.ID())
+			t.mu.Unlock()
+
+			if found {
+				result <- info.Delivered()
+				<-t.slots
+			}
+		}
+	}
+}
+
+// Deliver adds the metrics to the accumulator and waits until they are
+// delivered by the outputs. If the maximum number of undelivered messages is
+// reached, Deliver either waits for a free slot or, if block is false,
+// returns ErrFull without adding the metrics.
+func (t *Tracker) Deliver(ctx context.Context, metrics []telegraf.Metric, block bool) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	if block {
+		select {
+		case t.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.done:
+			return ErrStopped
+		}
+	} else {
+		select {
+		case t.slots <- struct{}{}:
+		default:
+			return ErrFull
+		}
+	}
+
+	// Register the group before the delivery can be reported
+	result := make(chan bool, 1)
+	t.mu.Lock()
+	id := t.acc.AddTrackingMetricGroup(metrics)
+	t.pending[id] = result
+	t.mu.Unlock()
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+
+	select {
+	case delivered := <-result:
+		if !delivered {
+			return ErrNotDelivered
+		}
+		return nil
+	case <-timer.C:
+		return ErrTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.done:
+		return ErrStopped
+	}
+}
+
+// Stop stops the tracker and releases all waiting callers.
+func (t *Tracker) Stop() {
+	close(t.done)
+	t.wg.Wait()
+}
+
+// HTTPStatus returns the HTTP status code to answer a request with if
+// delivering its metrics failed with the given error.
+func HTTPStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrNotDelivered):
+		return http.StatusInternalServerError
+	}
+	return http.StatusServiceUnavailable
+}
@@ -0,0 +1,115 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestDeliver(t *testing.T) {
+	acc := &testutil.Accumulator{}
+	cfg := &Config{MaxUndeliveredMessages: 1, DeliveryTimeout: config.Duration(time.Second)}
+	tracker := cfg.NewTracker(acc)
+	defer tracker.Stop()
+
+	metrics := []telegraf.Metric{
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 42}, time.Unix(0, 0)),
+	}
+
+	// Nothing to wait for without metrics
+	require.NoError(t, tracker.Deliver(context.Background(), nil, false))
+
+	for _, delivered := range []bool{true, false} {
+		result := make(chan error, 1)
+		go func() {
+			result <- tracker.Deliver(context.Background(), metrics, false)
+		}()
+		require.Eventually(t, func() bool {
+			return acc.NMetrics() > 0
+		}, time.Second, 10*time.Millisecond)
+
+		// The second message exceeds the limit of undelivered messages
+		require.ErrorIs(t, tracker.Deliver(context.Background(), metrics, false), ErrFull)
+
+		require.Equal(t, 1, acc.Deliver(delivered))
+		if delivered {
+			require.NoError(t, <-result)
+		} else {
+			require.ErrorIs(t, <-result, ErrNotDelivered)
+		}
+		acc.ClearMetrics()
+	}
+}
+
+func TestDeliverTimeout(t *testing.T) {
+	acc := &testutil.Accumulator{}
+	cfg := &Config{MaxUndeliveredMessages: 1, DeliveryTimeout: config.Duration(50 * time.Millisecond)}
+	tracker := cfg.NewTracker(acc)
+	defer tracker.Stop()
+
+	metrics := []telegraf.Metric{
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 42}, time.Unix(0, 0)),
+	}
+	require.ErrorIs(t, tracker.Deliver(context.Background(), metrics, false), ErrTimeout)
+
+	// The message still counts as undelivered until the outputs are done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, tracker.Deliver(ctx, metrics, true), context.DeadlineExceeded)
+
+	require.Equal(t, 1, acc.Deliver(true))
+	require.Eventually(t, func() bool {
+		return !errors.Is(tracker.Deliver(context.Background(), metrics, false), ErrFull)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDeliverStopped(t *testing.T) {
+	acc := &testutil.Accumulator{}
+	cfg := &Config{}
+	tracker := cfg.NewTracker(acc)
+	require.Equal(t, defaultMaxUndeliveredMessages, cfg.MaxUndeliveredMessages)
+	require.Equal(t, defaultDeliveryTimeout, cfg.DeliveryTimeout)
+
+	result := make(chan error, 1)
+	go func() {
+		metrics := []telegraf.Metric{
+			metric.New("test", map[string]string{}, map[string]interface{}{"value": 42}, time.Unix(0, 0)),
+		}
+		result <- tracker.Deliver(context.Background(), metrics, true)
+	}()
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() > 0
+	}, time.Second, 10*time.Millisecond)
+
+	tracker.Stop()
+	require.ErrorIs(t, <-result, ErrStopped)
+}
+
+func TestValidateDelivery(t *testing.T) {
+	cfg := &Config{WaitForDelivery: true}
+	require.NoError(t, cfg.ValidateDelivery(10*time.Second))
+	require.Equal(t, defaultDeliveryTimeout, cfg.DeliveryTimeout)
+	require.NoError(t, cfg.ValidateDelivery(0))
+
+	cfg.DeliveryTimeout = config.Duration(10 * time.Second)
+	require.ErrorContains(t, cfg.ValidateDelivery(10*time.Second), "must be shorter than the write timeout")
+
+	cfg.WaitForDelivery = false
+	require.NoError(t, cfg.ValidateDelivery(10*time.Second))
+}
+
+func TestHTTPStatus(t *testing.T) {
+	require.Equal(t, http.StatusServiceUnavailable, HTTPStatus(ErrFull))
+	require.Equal(t, http.StatusServiceUnavailable, HTTPStatus(ErrStopped))
+	require.Equal(t, http.StatusGatewayTimeout, HTTPStatus(ErrTimeout))
+	require.Equal(t, http.StatusInternalServerError, HTTPStatus(ErrNotDelivered))
+}
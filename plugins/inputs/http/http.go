@@ -16,6 +16,7 @@ import (
 	"github.com/influxdata/telegraf/internal"
 	httpconfig "github.com/influxdata/telegraf/plugins/common/http"
 	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/parsers"
 )
 
 //go:embed sample.conf
@@ -69,12 +70,18 @@ func (h *HTTP) Init() error {
 // Gather takes in an accumulator and adds the metrics that the Input
 // gathers. This is called every "interval"
 func (h *HTTP) Gather(acc telegraf.Accumulator) error {
+	return h.GatherContext(context.Background(), acc)
+}
+
+// GatherContext gathers all URLs and aborts pending requests once the context
+// is done.
+func (h *HTTP) GatherContext(ctx context.Context, acc telegraf.Accumulator) error {
 	var wg sync.WaitGroup
 	for _, u := range h.URLs {
 		wg.Add(1)
 		go func(url string) {
 			defer wg.Done()
-			if err := h.gatherURL(acc, url); err != nil {
+			if err := h.gatherURL(ctx, acc, url); err != nil {
 				acc.AddError(fmt.Errorf("[url=%s]: %w", url, err))
 			}
 		}(u)
@@ -93,6 +100,7 @@ func (h *HTTP) SetParserFunc(fn telegraf.ParserFunc) {
 // Gathers data from a particular URL
 // Parameters:
 //
+//	ctx    : context aborting the request
 //	acc    : The telegraf Accumulator to use
 //	url    : endpoint to send request to
 //
@@ -100,11 +108,12 @@ func (h *HTTP) SetParserFunc(fn telegraf.ParserFunc) {
 //
 //	error: Any error that may have occurred
 func (h *HTTP) gatherURL(
+	ctx context.Context,
 	acc telegraf.Accumulator,
 	url string,
 ) error {
 	body := makeRequestBodyReader(h.ContentEncoding, h.Body)
-	request, err := http.NewRequest(h.Method, url, body)
+	request, err := http.NewRequestWithContext(ctx, h.Method, url, body)
 	if err != nil {
 		return err
 	}
@@ -165,7 +174,7 @@ func (h *HTTP) gatherURL(
 	if err != nil {
 		return fmt.Errorf("instantiating parser failed: %w", err)
 	}
-	metrics, err := parser.Parse(b)
+	metrics, err := parsers.ParseWithMetadata(parser, b, map[string]string{"url": url})
 	if err != nil {
 		return fmt.Errorf("parsing metrics failed: %w", err)
 	}
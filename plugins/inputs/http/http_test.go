@@ -2,6 +2,7 @@ package http_test
 
 import (
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -60,6 +61,39 @@ func TestHTTPWithJSONFormat(t *testing.T) {
 	require.Equal(t, acc.Metrics[0].Tags["url"], address)
 }
 
+func TestGatherContextCanceled(t *testing.T) {
+	release := make(chan struct{})
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		_, _ = w.Write([]byte(simpleJSON))
+	}))
+	defer fakeServer.Close()
+	defer close(release)
+
+	plugin := &httpplugin.HTTP{
+		URLs: []string{fakeServer.URL + "/endpoint"},
+		Log:  testutil.Logger{},
+	}
+	plugin.SetParserFunc(func() (telegraf.Parser, error) {
+		p := &json.Parser{MetricName: "metricName"}
+		err := p.Init()
+		return p, err
+	})
+	require.NoError(t, plugin.Init())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.GatherContext(ctx, &acc))
+	require.Len(t, acc.Errors, 1)
+	require.ErrorIs(t, acc.Errors[0], context.DeadlineExceeded)
+	require.Empty(t, acc.Metrics)
+}
+
 func TestHTTPHeaders(t *testing.T) {
 	header := "X-Special-Header"
 	headerValue := "Special-Value"
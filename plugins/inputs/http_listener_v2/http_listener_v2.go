@@ -7,6 +7,7 @@ import (
 	"crypto/tls"
 	_ "embed"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -20,6 +21,7 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	tlsint "github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
@@ -62,6 +64,9 @@ type HTTPListenerV2 struct {
 	tlsint.ServerConfig
 	tlsConf *tls.Config
 
+	delivery.Config
+	tracker *delivery.Tracker
+
 	TimeFunc
 	Log telegraf.Logger
 
@@ -98,6 +103,9 @@ func (h *HTTPListenerV2) Start(acc telegraf.Accumulator) error {
 	if h.WriteTimeout < config.Duration(time.Second) {
 		h.WriteTimeout = config.Duration(time.Second * 10)
 	}
+	if err := h.ValidateDelivery(time.Duration(h.WriteTimeout)); err != nil {
+		return err
+	}
 
 	// Append h.Path to h.Paths
 	if h.Path != "" && !choice.Contains(h.Path, h.Paths) {
@@ -105,6 +113,9 @@ func (h *HTTPListenerV2) Start(acc telegraf.Accumulator) error {
 	}
 
 	h.acc = acc
+	if h.WaitForDelivery {
+		h.tracker = h.NewTracker(acc)
+	}
 
 	server := h.createHTTPServer()
 
@@ -139,6 +150,10 @@ func (h *HTTPListenerV2) Stop() {
 	if h.listener != nil {
 		h.listener.Close()
 	}
+	// Release the requests waiting for delivery
+	if h.tracker != nil {
+		h.tracker.Stop()
+	}
 	h.wg.Wait()
 }
 
@@ -243,8 +258,20 @@ func (h *HTTPListenerV2) serveWrite(res http.ResponseWriter, req *http.Request)
 		if h.PathTag {
 			m.AddTag(pathTag, req.URL.Path)
 		}
+	}
 
-		h.acc.AddMetric(m)
+	if h.tracker != nil {
+		if err := h.tracker.Deliver(req.Context(), metrics, false); err != nil {
+			h.Log.Debugf("Delivering metrics failed: %v", err)
+			if err := deliveryFailed(res, err); err != nil {
+				h.Log.Debugf("error in delivery-failed: %v", err)
+			}
+			return
+		}
+	} else {
+		for _, m := range metrics {
+			h.acc.AddMetric(m)
+		}
 	}
 
 	res.WriteHeader(http.StatusNoContent)
@@ -343,6 +370,13 @@ func badRequest(res http.ResponseWriter) error {
 	return err
 }
 
+func deliveryFailed(res http.ResponseWriter, deliveryErr error) error {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(delivery.HTTPStatus(deliveryErr))
+	_, err := res.Write([]byte(fmt.Sprintf(`{"error":%q}`, deliveryErr.Error())))
+	return err
+}
+
 func (h *HTTPListenerV2) authenticateIfSet(handler http.HandlerFunc, res http.ResponseWriter, req *http.Request) {
 	if h.BasicUsername != "" && h.BasicPassword != "" {
 		reqUsername, reqPassword, ok := req.BasicAuth()
@@ -253,6 +253,49 @@ func TestWriteHTTP(t *testing.T) {
 	)
 }
 
+func TestWriteHTTPWaitForDelivery(t *testing.T) {
+	listener, err := newTestHTTPListenerV2()
+	require.NoError(t, err)
+	listener.WaitForDelivery = true
+	listener.MaxUndeliveredMessages = 1
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	for _, tt := range []struct {
+		delivered bool
+		expected  int
+	}{
+		{delivered: true, expected: http.StatusNoContent},
+		{delivered: false, expected: http.StatusInternalServerError},
+	} {
+		// The response is held back until the metrics are delivered
+		result := make(chan int, 1)
+		go func() {
+			resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+			if err != nil {
+				result <- 0
+				return
+			}
+			resp.Body.Close()
+			result <- resp.StatusCode
+		}()
+		acc.Wait(1)
+
+		// Further requests are rejected while the message is undelivered
+		resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.EqualValues(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+		require.Equal(t, 1, acc.Deliver(tt.delivered))
+		require.Equal(t, tt.expected, <-result)
+		acc.ClearMetrics()
+	}
+}
+
 // http listener should add request path as configured path_tag
 func TestWriteHTTPWithPathTag(t *testing.T) {
 	listener, err := newTestHTTPListenerV2()
@@ -16,6 +16,7 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	tlsint "github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers/influx"
@@ -47,6 +48,9 @@ type InfluxDBListener struct {
 	RetentionPolicyTag string          `toml:"retention_policy_tag"`
 	ParserType         string          `toml:"parser_type"`
 
+	delivery.Config
+	tracker *delivery.Tracker
+
 	timeFunc influx.TimeFunc
 
 	listener net.Listener
@@ -116,12 +120,15 @@ func (h *InfluxDBListener) Init() error {
 		h.WriteTimeout = config.Duration(time.Second * 10)
 	}
 
-	return nil
+	return h.ValidateDelivery(time.Duration(h.WriteTimeout))
 }
 
 // Start starts the InfluxDB listener service.
 func (h *InfluxDBListener) Start(acc telegraf.Accumulator) error {
 	h.acc = acc
+	if h.WaitForDelivery {
+		h.tracker = h.NewTracker(acc)
+	}
 
 	tlsConf, err := h.ServerConfig.TLSConfig()
 	if err != nil {
@@ -165,6 +172,10 @@ func (h *InfluxDBListener) Start(acc telegraf.Accumulator) error {
 
 // Stop cleans up all resources
 func (h *InfluxDBListener) Stop() {
+	// Release the requests waiting for delivery
+	if h.tracker != nil {
+		h.tracker.Stop()
+	}
 	err := h.server.Shutdown(context.Background())
 	if err != nil {
 		h.Log.Infof("Error shutting down HTTP server: %v", err.Error())
@@ -268,6 +279,7 @@ func (h *InfluxDBListener) handleWriteInternalParser(res http.ResponseWriter, re
 	}
 
 	var m telegraf.Metric
+	var metrics []telegraf.Metric
 	var err error
 	var parseErrorCount int
 	var lastPos int
@@ -309,7 +321,11 @@ func (h *InfluxDBListener) handleWriteInternalParser(res http.ResponseWriter, re
 			m.AddTag(h.RetentionPolicyTag, rp)
 		}
 
-		h.acc.AddMetric(m)
+		if h.tracker != nil {
+			metrics = append(metrics, m)
+		} else {
+			h.acc.AddMetric(m)
+		}
 	}
 	if !errors.Is(err, influx.EOF) {
 		h.Log.Debugf("Error parsing the request body: %v", err.Error())
@@ -318,6 +334,9 @@ func (h *InfluxDBListener) handleWriteInternalParser(res http.ResponseWriter, re
 		}
 		return
 	}
+	if !h.deliver(res, req, metrics) {
+		return
+	}
 	if parseErrorCount > 0 {
 		var partialErrorString string
 		switch parseErrorCount {
@@ -388,6 +407,7 @@ func (h *InfluxDBListener) handleWriteUpstreamParser(res http.ResponseWriter, re
 	}
 
 	var m telegraf.Metric
+	var metrics []telegraf.Metric
 	var err error
 	var parseErrorCount int
 	var firstParseErrorStr string
@@ -425,7 +445,11 @@ func (h *InfluxDBListener) handleWriteUpstreamParser(res http.ResponseWriter, re
 			m.AddTag(h.RetentionPolicyTag, rp)
 		}
 
-		h.acc.AddMetric(m)
+		if h.tracker != nil {
+			metrics = append(metrics, m)
+		} else {
+			h.acc.AddMetric(m)
+		}
 	}
 	if !errors.Is(err, influx_upstream.ErrEOF) {
 		h.Log.Debugf("Error parsing the request body: %v", err.Error())
@@ -434,6 +458,9 @@ func (h *InfluxDBListener) handleWriteUpstreamParser(res http.ResponseWriter, re
 		}
 		return
 	}
+	if !h.deliver(res, req, metrics) {
+		return
+	}
 	if parseErrorCount > 0 {
 		var partialErrorString string
 		switch parseErrorCount {
@@ -454,6 +481,24 @@ func (h *InfluxDBListener) handleWriteUpstreamParser(res http.ResponseWriter, re
 	res.WriteHeader(http.StatusNoContent)
 }
 
+// deliver waits for the delivery of the metrics if requested and answers the
+// request if the delivery failed.
+func (h *InfluxDBListener) deliver(res http.ResponseWriter, req *http.Request, metrics []telegraf.Metric) bool {
+	if h.tracker == nil {
+		return true
+	}
+
+	err := h.tracker.Deliver(req.Context(), metrics, false)
+	if err == nil {
+		return true
+	}
+	h.Log.Debugf("Delivering metrics failed: %v", err)
+	if err := deliveryFailed(res, err); err != nil {
+		h.Log.Debugf("error in delivery-failed: %v", err)
+	}
+	return false
+}
+
 func tooLarge(res http.ResponseWriter) error {
 	res.Header().Set("Content-Type", "application/json")
 	res.Header().Set("X-Influxdb-Version", "1.0")
@@ -484,6 +529,15 @@ func partialWrite(res http.ResponseWriter, errString string) error {
 	return err
 }
 
+func deliveryFailed(res http.ResponseWriter, deliveryErr error) error {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Influxdb-Version", "1.0")
+	res.Header().Set("X-Influxdb-Error", deliveryErr.Error())
+	res.WriteHeader(delivery.HTTPStatus(deliveryErr))
+	_, err := res.Write([]byte(fmt.Sprintf(`{"error":%q}`, deliveryErr.Error())))
+	return err
+}
+
 func getPrecisionMultiplier(precision string) time.Duration {
 	// Influxdb defaults silently to nanoseconds if precision isn't
 	// one of the following:
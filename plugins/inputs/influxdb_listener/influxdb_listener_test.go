@@ -163,6 +163,47 @@ func TestWriteBasicAuth(t *testing.T) {
 	require.EqualValues(t, http.StatusNoContent, resp.StatusCode)
 }
 
+func TestWriteWaitForDelivery(t *testing.T) {
+	for _, tc := range parserTestCases {
+		t.Run(fmt.Sprintf("parser %s", tc.parser), func(t *testing.T) {
+			listener := newTestListener()
+			listener.ParserType = tc.parser
+			listener.WaitForDelivery = true
+			listener.MaxUndeliveredMessages = 1
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			// The response is held back until the metrics are delivered
+			result := make(chan *http.Response, 1)
+			go func() {
+				resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+				if err != nil {
+					result <- nil
+					return
+				}
+				resp.Body.Close()
+				result <- resp
+			}()
+			acc.Wait(1)
+
+			// Further requests are rejected while the message is undelivered
+			resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.EqualValues(t, http.StatusServiceUnavailable, resp.StatusCode)
+			require.NotEmpty(t, resp.Header.Get("X-Influxdb-Error"))
+
+			require.Equal(t, 1, acc.Deliver(false))
+			resp = <-result
+			require.NotNil(t, resp)
+			require.EqualValues(t, http.StatusInternalServerError, resp.StatusCode)
+		})
+	}
+}
+
 func TestWriteKeepDatabase(t *testing.T) {
 	testMsgWithDB := "cpu_load_short,host=server01,database=wrongdb value=12.0 1422568543702900257\n"
 
@@ -17,6 +17,7 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	tlsint "github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers/influx"
@@ -44,6 +45,7 @@ type BadRequestCode string
 const (
 	InternalError BadRequestCode = "internal error"
 	Invalid       BadRequestCode = "invalid"
+	Unavailable   BadRequestCode = "unavailable"
 )
 
 type InfluxDBV2Listener struct {
@@ -58,6 +60,9 @@ type InfluxDBV2Listener struct {
 	BucketTag    string          `toml:"bucket_tag"`
 	ParserType   string          `toml:"parser_type"`
 
+	delivery.Config
+	tracker *delivery.Tracker
+
 	timeFunc influx.TimeFunc
 
 	listener net.Listener
@@ -128,12 +133,15 @@ func (h *InfluxDBV2Listener) Init() error {
 		h.WriteTimeout = config.Duration(defaultWriteTimeout)
 	}
 
-	return nil
+	return h.ValidateDelivery(time.Duration(h.WriteTimeout))
 }
 
 // Start starts the InfluxDB listener service.
 func (h *InfluxDBV2Listener) Start(acc telegraf.Accumulator) error {
 	h.acc = acc
+	if h.WaitForDelivery {
+		h.tracker = h.NewTracker(acc)
+	}
 
 	tlsConf, err := h.ServerConfig.TLSConfig()
 	if err != nil {
@@ -179,6 +187,10 @@ func (h *InfluxDBV2Listener) Start(acc telegraf.Accumulator) error {
 
 // Stop cleans up all resources
 func (h *InfluxDBV2Listener) Stop() {
+	// Release the requests waiting for delivery
+	if h.tracker != nil {
+		h.tracker.Stop()
+	}
 	err := h.server.Shutdown(context.Background())
 	if err != nil {
 		h.Log.Infof("Error shutting down HTTP server: %v", err.Error())
@@ -305,8 +317,20 @@ func (h *InfluxDBV2Listener) handleWrite() http.HandlerFunc {
 			if h.BucketTag != "" && bucket != "" {
 				m.AddTag(h.BucketTag, bucket)
 			}
+		}
 
-			h.acc.AddMetric(m)
+		if h.tracker != nil {
+			if err := h.tracker.Deliver(req.Context(), metrics, false); err != nil {
+				h.Log.Debugf("Delivering metrics failed: %v", err)
+				if err := deliveryFailed(res, err); err != nil {
+					h.Log.Debugf("error in delivery-failed: %v", err)
+				}
+				return
+			}
+		} else {
+			for _, m := range metrics {
+				h.acc.AddMetric(m)
+			}
 		}
 
 		// http request success
@@ -343,6 +367,26 @@ func badRequest(res http.ResponseWriter, code BadRequestCode, errString string)
 	return err
 }
 
+func deliveryFailed(res http.ResponseWriter, deliveryErr error) error {
+	status := delivery.HTTPStatus(deliveryErr)
+	code := Unavailable
+	if status == http.StatusInternalServerError {
+		code = InternalError
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Influxdb-Error", deliveryErr.Error())
+	res.WriteHeader(status)
+	b, _ := json.Marshal(map[string]string{
+		"code":    fmt.Sprint(code),
+		"message": deliveryErr.Error(),
+		"op":      "",
+		"err":     deliveryErr.Error(),
+	})
+	_, err := res.Write(b)
+	return err
+}
+
 func getPrecisionMultiplier(precision string) time.Duration {
 	// Influxdb defaults silently to nanoseconds if precision isn't
 	// one of the following:
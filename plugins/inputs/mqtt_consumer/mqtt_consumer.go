@@ -261,7 +261,7 @@ func (m *MQTTConsumer) onMessage(acc telegraf.TrackingAccumulator, msg mqtt.Mess
 	m.payloadSize.Incr(int64(payloadBytes))
 	m.messagesRecv.Incr(1)
 
-	metrics, err := m.parser.Parse(msg.Payload())
+	metrics, err := parsers.ParseWithMetadata(m.parser, msg.Payload(), map[string]string{"topic": msg.Topic()})
 	if err != nil {
 		return err
 	}
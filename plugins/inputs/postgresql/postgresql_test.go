@@ -316,3 +316,22 @@ func TestPostgresqlDatabaseBlacklistTestIntegration(t *testing.T) {
 	require.False(t, foundTemplate0)
 	require.True(t, foundTemplate1)
 }
+
+func TestPostgresqlConnectsWithUpdatedAddress(t *testing.T) {
+	p := &Postgresql{
+		Service: Service{
+			Address: config.NewSecret([]byte("host=localhost port=5432 user=old sslmode=disable")),
+		},
+	}
+
+	var acc testutil.Accumulator
+	require.NoError(t, p.Start(&acc))
+	defer p.Stop()
+
+	require.NoError(t, p.Address.Set([]byte("host=localhost port=5432 user=new sslmode=disable")))
+	require.NoError(t, p.SecretsUpdated())
+
+	connConfig, err := p.connConfig()
+	require.NoError(t, err)
+	require.Equal(t, "new", connConfig.User)
+}
@@ -2,7 +2,9 @@ package postgresql
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"net"
 	"net/url"
@@ -107,19 +109,52 @@ func (p *Service) Start(telegraf.Accumulator) (err error) {
 		return fmt.Errorf("getting address failed: %w", err)
 	}
 	addr := string(addrSecret)
-	defer config.ReleaseSecret(addrSecret)
+	config.ReleaseSecret(addrSecret)
 
 	if p.Address.Empty() || addr == "localhost" {
-		addr = "host=localhost sslmode=disable"
-		if err := p.Address.Set([]byte(addr)); err != nil {
+		if err := p.Address.Set([]byte("host=localhost sslmode=disable")); err != nil {
 			return err
 		}
 	}
 
-	connConfig, err := pgx.ParseConfig(addr)
-	if err != nil {
+	// Check the address early, the connections are configured when opening
+	// them to use the current address after the secret changed.
+	if _, err := p.connConfig(); err != nil {
 		return err
 	}
+	p.DB = sql.OpenDB(&connector{service: p})
+
+	p.DB.SetMaxOpenConns(p.MaxOpen)
+	p.DB.SetMaxIdleConns(p.MaxIdle)
+	p.DB.SetConnMaxLifetime(time.Duration(p.MaxLifetime))
+
+	return nil
+}
+
+// SecretsUpdated drops the idle connections after the address secret
+// changed, new connections use the updated credentials. Connections in use
+// at this time are kept until they exceed max_lifetime.
+func (p *Service) SecretsUpdated() error {
+	if p.DB == nil {
+		return nil
+	}
+	p.DB.SetMaxIdleConns(0)
+	p.DB.SetMaxIdleConns(p.MaxIdle)
+	return nil
+}
+
+// connConfig parses the current address into the connection configuration
+func (p *Service) connConfig() (*pgx.ConnConfig, error) {
+	addr, err := p.Address.Get()
+	if err != nil {
+		return nil, fmt.Errorf("getting address failed: %w", err)
+	}
+	defer config.ReleaseSecret(addr)
+
+	connConfig, err := pgx.ParseConfig(string(addr))
+	if err != nil {
+		return nil, err
+	}
 
 	// Remove the socket name from the path
 	connConfig.Host = socketRegexp.ReplaceAllLiteralString(connConfig.Host, "")
@@ -132,16 +167,24 @@ func (p *Service) Start(telegraf.Accumulator) (err error) {
 		connConfig.PreferSimpleProtocol = true
 	}
 
-	connectionString := stdlib.RegisterConnConfig(connConfig)
-	if p.DB, err = sql.Open("pgx", connectionString); err != nil {
-		return err
-	}
+	return connConfig, nil
+}
 
-	p.DB.SetMaxOpenConns(p.MaxOpen)
-	p.DB.SetMaxIdleConns(p.MaxIdle)
-	p.DB.SetConnMaxLifetime(time.Duration(p.MaxLifetime))
+// connector opens connections using the current address of the service
+type connector struct {
+	service *Service
+}
 
-	return nil
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	connConfig, err := c.service.connConfig()
+	if err != nil {
+		return nil, err
+	}
+	return stdlib.GetConnector(*connConfig).Connect(ctx)
+}
+
+func (*connector) Driver() driver.Driver {
+	return stdlib.GetDefaultDriver()
 }
 
 // Stop stops the services and closes any necessary channels and connections
@@ -338,6 +338,12 @@ func (p *Prometheus) GetAllURLs() (map[string]URLAndAddress, error) {
 // Reads stats from all configured servers accumulates stats.
 // Returns one of the errors encountered while gather stats (if any).
 func (p *Prometheus) Gather(acc telegraf.Accumulator) error {
+	return p.GatherContext(context.Background(), acc)
+}
+
+// GatherContext reads stats from all configured servers and aborts pending
+// requests once the context is done.
+func (p *Prometheus) GatherContext(ctx context.Context, acc telegraf.Accumulator) error {
 	var wg sync.WaitGroup
 
 	allURLs, err := p.GetAllURLs()
@@ -348,7 +354,7 @@ func (p *Prometheus) Gather(acc telegraf.Accumulator) error {
 		wg.Add(1)
 		go func(serviceURL URLAndAddress) {
 			defer wg.Done()
-			acc.AddError(p.gatherURL(serviceURL, acc))
+			acc.AddError(p.gatherURL(ctx, serviceURL, acc))
 		}(URL)
 	}
 
@@ -357,7 +363,7 @@ func (p *Prometheus) Gather(acc telegraf.Accumulator) error {
 	return nil
 }
 
-func (p *Prometheus) gatherURL(u URLAndAddress, acc telegraf.Accumulator) error {
+func (p *Prometheus) gatherURL(ctx context.Context, u URLAndAddress, acc telegraf.Accumulator) error {
 	var req *http.Request
 	var err error
 	var uClient *http.Client
@@ -368,7 +374,7 @@ func (p *Prometheus) gatherURL(u URLAndAddress, acc telegraf.Accumulator) error
 			path = "/metrics"
 		}
 		addr := "http://localhost" + path
-		req, err = http.NewRequest("GET", addr, nil)
+		req, err = http.NewRequestWithContext(ctx, "GET", addr, nil)
 		if err != nil {
 			return fmt.Errorf("unable to create new request %q: %w", addr, err)
 		}
@@ -392,7 +398,7 @@ func (p *Prometheus) gatherURL(u URLAndAddress, acc telegraf.Accumulator) error
 		if u.URL.Path == "" {
 			u.URL.Path = "/metrics"
 		}
-		req, err = http.NewRequest("GET", u.URL.String(), nil)
+		req, err = http.NewRequestWithContext(ctx, "GET", u.URL.String(), nil)
 		if err != nil {
 			return fmt.Errorf("unable to create new request %q: %w", u.URL.String(), err)
 		}
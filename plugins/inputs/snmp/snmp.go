@@ -2,6 +2,7 @@
 package snmp
 
 import (
+	"context"
 	_ "embed"
 	"encoding/binary"
 	"errors"
@@ -310,6 +311,12 @@ func (e *walkError) Unwrap() error {
 // Any error encountered does not halt the process. The errors are accumulated
 // and returned at the end.
 func (s *Snmp) Gather(acc telegraf.Accumulator) error {
+	return s.GatherContext(context.Background(), acc)
+}
+
+// GatherContext retrieves all the configured fields and tables like Gather
+// but aborts pending requests once the context is done.
+func (s *Snmp) GatherContext(ctx context.Context, acc telegraf.Accumulator) error {
 	var wg sync.WaitGroup
 	for i, agent := range s.Agents {
 		wg.Add(1)
@@ -320,6 +327,9 @@ func (s *Snmp) Gather(acc telegraf.Accumulator) error {
 				acc.AddError(fmt.Errorf("agent %s: %w", agent, err))
 				return
 			}
+			if wrapper, ok := gs.(snmp.GosnmpWrapper); ok {
+				wrapper.Context = ctx
+			}
 
 			// First is the top-level fields. We treat the fields as table prefixes with an empty index.
 			t := Table{
@@ -333,6 +343,10 @@ func (s *Snmp) Gather(acc telegraf.Accumulator) error {
 
 			// Now is the real tables.
 			for _, t := range s.Tables {
+				if ctx.Err() != nil {
+					acc.AddError(fmt.Errorf("agent %s: %w", agent, ctx.Err()))
+					return
+				}
 				if err := s.gatherTable(acc, gs, t, topTags, true); err != nil {
 					acc.AddError(fmt.Errorf("agent %s: gathering table %s: %w", agent, t.Name, err))
 				}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/parsers"
 )
 
 type packetListener struct {
@@ -29,7 +30,7 @@ type packetListener struct {
 func (l *packetListener) listen(acc telegraf.Accumulator) {
 	buf := make([]byte, 64*1024) // 64kb - maximum size of IP packet
 	for {
-		n, _, err := l.conn.ReadFrom(buf)
+		n, src, err := l.conn.ReadFrom(buf)
 		if err != nil {
 			if !strings.HasSuffix(err.Error(), ": use of closed network connection") {
 				acc.AddError(err)
@@ -42,7 +43,11 @@ func (l *packetListener) listen(acc telegraf.Accumulator) {
 			acc.AddError(fmt.Errorf("unable to decode incoming packet: %w", err))
 		}
 
-		metrics, err := l.Parser.Parse(body)
+		var metadata map[string]string
+		if src != nil {
+			metadata = map[string]string{"source": src.String()}
+		}
+		metrics, err := parsers.ParseWithMetadata(l.Parser, body, metadata)
 		if err != nil {
 			acc.AddError(fmt.Errorf("unable to parse incoming packet: %w", err))
 			// TODO rate limit
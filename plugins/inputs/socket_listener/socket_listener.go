@@ -16,6 +16,7 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	tlsint "github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
@@ -51,12 +52,15 @@ type SocketListener struct {
 	SplittingDelimiter   string           `toml:"splitting_delimiter"`
 	SplittingLength      int              `toml:"splitting_length"`
 	SplittingLengthField lengthFieldSpec  `toml:"splitting_length_field"`
+	DeliveryAck          string           `toml:"delivery_ack"`
 	Log                  telegraf.Logger  `toml:"-"`
 	tlsint.ServerConfig
+	delivery.Config
 
 	wg       sync.WaitGroup
 	parser   parsers.Parser
 	splitter bufio.SplitFunc
+	tracker  *delivery.Tracker
 
 	listener listener
 }
@@ -129,7 +133,8 @@ func (sl *SocketListener) Init() error {
 	default:
 		return fmt.Errorf("unknown 'splitting_strategy' %q", sl.SplittingStrategy)
 	}
-	return nil
+
+	return sl.ValidateDelivery(0)
 }
 
 func (sl *SocketListener) Gather(_ telegraf.Accumulator) error {
@@ -165,6 +170,15 @@ func (sl *SocketListener) Start(acc telegraf.Accumulator) error {
 		sl.MaxDecompressionSize = internal.DefaultMaxDecompressionSize
 	}
 
+	if sl.WaitForDelivery {
+		switch u.Scheme {
+		case "tcp", "tcp4", "tcp6", "unix", "unixpacket":
+		default:
+			return fmt.Errorf("waiting for delivery is not supported for %q sockets", u.Scheme)
+		}
+		sl.tracker = sl.NewTracker(acc)
+	}
+
 	switch u.Scheme {
 	case "tcp", "tcp4", "tcp6":
 		ssl := &streamListener{
@@ -175,6 +189,8 @@ func (sl *SocketListener) Start(acc telegraf.Accumulator) error {
 			Encoding:        sl.ContentEncoding,
 			Splitter:        sl.splitter,
 			Parser:          sl.parser,
+			Tracker:         sl.tracker,
+			DeliveryAck:     []byte(sl.DeliveryAck),
 			Log:             sl.Log,
 		}
 
@@ -191,6 +207,8 @@ func (sl *SocketListener) Start(acc telegraf.Accumulator) error {
 			Encoding:        sl.ContentEncoding,
 			Splitter:        sl.splitter,
 			Parser:          sl.parser,
+			Tracker:         sl.tracker,
+			DeliveryAck:     []byte(sl.DeliveryAck),
 			Log:             sl.Log,
 		}
 
@@ -245,6 +263,11 @@ func (sl *SocketListener) Start(acc telegraf.Accumulator) error {
 }
 
 func (sl *SocketListener) Stop() {
+	// Release the connections waiting for delivery
+	if sl.tracker != nil {
+		sl.tracker.Stop()
+		sl.tracker = nil
+	}
 	if sl.listener != nil {
 		// Ignore the returned error as we cannot do anything about it anyway
 		_ = sl.listener.close()
@@ -23,6 +23,7 @@ import (
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	_ "github.com/influxdata/telegraf/plugins/parsers/all"
 	"github.com/influxdata/telegraf/plugins/parsers/influx"
@@ -197,6 +198,66 @@ func TestSocketListener(t *testing.T) {
 	}
 }
 
+func TestSocketListenerWaitForDelivery(t *testing.T) {
+	parser := &influx.Parser{}
+	require.NoError(t, parser.Init())
+
+	// Waiting for delivery requires a stream socket
+	plugin := &SocketListener{
+		Log:            &testutil.Logger{},
+		ServiceAddress: "udp://127.0.0.1:0",
+		Config:         delivery.Config{WaitForDelivery: true},
+	}
+	plugin.SetParser(parser)
+	require.NoError(t, plugin.Init())
+	var acc testutil.Accumulator
+	require.ErrorContains(t, plugin.Start(&acc), "not supported")
+
+	plugin = &SocketListener{
+		Log:            &testutil.Logger{},
+		ServiceAddress: "tcp://127.0.0.1:0",
+		DeliveryAck:    "OK\n",
+		Config:         delivery.Config{WaitForDelivery: true},
+	}
+	plugin.SetParser(parser)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	client, err := createClient(plugin.ServiceAddress, plugin.listener.addr(), nil)
+	require.NoError(t, err)
+	defer client.Close()
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	// The message is acknowledged after delivery
+	_, err = client.Write([]byte("test,foo=bar v=1i 123456789\n"))
+	require.NoError(t, err)
+	acc.Wait(1)
+	require.Equal(t, 1, acc.Deliver(true))
+	buf := make([]byte, 3)
+	_, err = io.ReadFull(client, buf)
+	require.NoError(t, err)
+	require.Equal(t, "OK\n", string(buf))
+
+	// All messages received in one read are delivered and acknowledged
+	// together
+	_, err = client.Write([]byte("test,foo=bar v=2i 123456790\ntest,foo=bar v=3i 123456791\n"))
+	require.NoError(t, err)
+	acc.Wait(3)
+	require.Equal(t, 1, acc.Deliver(true))
+	_, err = io.ReadFull(client, buf)
+	require.NoError(t, err)
+	require.Equal(t, "OK\n", string(buf))
+
+	// The connection is closed if the metrics are not delivered
+	_, err = client.Write([]byte("test,foo=baz v=4i 123456792\n"))
+	require.NoError(t, err)
+	acc.Wait(4)
+	require.Equal(t, 1, acc.Deliver(false))
+	_, err = client.Read(buf)
+	require.ErrorIs(t, err, io.EOF)
+}
+
 func TestCases(t *testing.T) {
 	// Get all directories in testdata
 	folders, err := os.ReadDir("testcases")
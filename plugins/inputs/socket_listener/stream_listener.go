@@ -2,6 +2,7 @@ package socket_listener
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -15,6 +16,8 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
+	"github.com/influxdata/telegraf/plugins/parsers"
 )
 
 type hasSetReadBuffer interface {
@@ -29,6 +32,8 @@ type streamListener struct {
 	KeepAlivePeriod *config.Duration
 	Splitter        bufio.SplitFunc
 	Parser          telegraf.Parser
+	Tracker         *delivery.Tracker
+	DeliveryAck     []byte
 	Log             telegraf.Logger
 
 	listener    net.Listener
@@ -202,44 +207,65 @@ func (l *streamListener) listen(acc telegraf.Accumulator) {
 }
 
 func (l *streamListener) read(acc telegraf.Accumulator, conn net.Conn) error {
-	decoder, err := internal.NewStreamContentDecoder(l.Encoding, conn)
+	// Deliver the metrics of all messages read so far before reading more
+	// data from the connection. This way, all messages received in one read
+	// are delivered and acknowledged together.
+	var pending []telegraf.Metric
+	deliver := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		metrics := pending
+		pending = nil
+		return l.deliver(conn, metrics)
+	}
+	reader := &deliveringReader{
+		conn:    conn,
+		timeout: time.Duration(l.ReadTimeout),
+		deliver: deliver,
+	}
+
+	decoder, err := internal.NewStreamContentDecoder(l.Encoding, reader)
 	if err != nil {
 		return fmt.Errorf("creating decoder failed: %w", err)
 	}
 
-	timeout := time.Duration(l.ReadTimeout)
+	var metadata map[string]string
+	if addr := conn.RemoteAddr(); addr != nil {
+		metadata = map[string]string{"source": addr.String()}
+	}
 
 	scanner := bufio.NewScanner(decoder)
 	scanner.Split(l.Splitter)
-	for {
-		// Set the read deadline, if any, then start reading. The read
-		// will accept the deadline and return if no or insufficient data
-		// arrived in time. We need to set the deadline in every cycle as
-		// it is an ABSOLUTE time and not a timeout.
-		if timeout > 0 {
-			deadline := time.Now().Add(timeout)
-			if err := conn.SetReadDeadline(deadline); err != nil {
-				return fmt.Errorf("setting read deadline failed: %w", err)
-			}
-		}
-		if !scanner.Scan() {
-			// Exit if no data arrived e.g. due to timeout or closed connection
-			break
-		}
-
+	for scanner.Scan() {
 		data := scanner.Bytes()
-		metrics, err := l.Parser.Parse(data)
+		metrics, err := parsers.ParseWithMetadata(l.Parser, data, metadata)
 		if err != nil {
 			acc.AddError(fmt.Errorf("parsing error: %w", err))
 			l.Log.Debugf("invalid data for parser: %v", data)
 			continue
 		}
-		for _, m := range metrics {
-			acc.AddMetric(m)
+		if l.Tracker == nil {
+			for _, m := range metrics {
+				acc.AddMetric(m)
+			}
+			continue
+		}
+		pending = append(pending, metrics...)
+	}
+
+	err = scanner.Err()
+	if err == nil || errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, net.ErrClosed) {
+		// Deliver the messages received before the connection was closed
+		if derr := deliver(); derr != nil {
+			err = derr
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
+	if err != nil {
+		if errors.Is(err, delivery.ErrStopped) {
+			return nil
+		}
 		if errors.Is(err, os.ErrDeadlineExceeded) {
 			// Ignore the timeout and silently close the connection
 			l.Log.Debug(err)
@@ -253,3 +279,43 @@ func (l *streamListener) read(acc telegraf.Accumulator, conn net.Conn) error {
 	}
 	return nil
 }
+
+// deliver waits for the delivery of the given metrics and sends the
+// acknowledgement, if any.
+func (l *streamListener) deliver(conn net.Conn, metrics []telegraf.Metric) error {
+	if err := l.Tracker.Deliver(context.Background(), metrics, true); err != nil {
+		return fmt.Errorf("delivering metrics failed: %w", err)
+	}
+	if len(l.DeliveryAck) > 0 {
+		if _, err := conn.Write(l.DeliveryAck); err != nil {
+			return fmt.Errorf("sending acknowledgement failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// deliveringReader reads from the connection after delivering the pending
+// metrics. This stops reading from the connection until the messages read so
+// far are delivered and closes the connection if this fails.
+type deliveringReader struct {
+	conn    net.Conn
+	timeout time.Duration
+	deliver func() error
+}
+
+func (r *deliveringReader) Read(p []byte) (int, error) {
+	if err := r.deliver(); err != nil {
+		return 0, err
+	}
+
+	// Set the read deadline, if any, then start reading. The read will accept
+	// the deadline and return if no or insufficient data arrived in time. We
+	// need to set the deadline in every cycle as it is an ABSOLUTE time and
+	// not a timeout.
+	if r.timeout > 0 {
+		if err := r.conn.SetReadDeadline(time.Now().Add(r.timeout)); err != nil {
+			return 0, fmt.Errorf("setting read deadline failed: %w", err)
+		}
+	}
+	return r.conn.Read(p)
+}
@@ -425,13 +425,19 @@ func (s *SQL) Stop() {
 }
 
 func (s *SQL) Gather(acc telegraf.Accumulator) error {
+	return s.GatherContext(context.Background(), acc)
+}
+
+// GatherContext executes all queries and cancels them once the context is
+// done or the query timeout is exceeded.
+func (s *SQL) GatherContext(ctx context.Context, acc telegraf.Accumulator) error {
 	var wg sync.WaitGroup
 	tstart := time.Now()
 	for _, query := range s.Queries {
 		wg.Add(1)
 		go func(q Query) {
 			defer wg.Done()
-			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.Timeout))
+			ctx, cancel := context.WithTimeout(ctx, time.Duration(s.Timeout))
 			defer cancel()
 			if err := s.executeQuery(ctx, acc, q, tstart); err != nil {
 				acc.AddError(err)
@@ -51,9 +51,11 @@ type Tail struct {
 	Filters      []string `toml:"filters"`
 	filterColors bool
 
-	Log        telegraf.Logger `toml:"-"`
+	Log        telegraf.Logger         `toml:"-"`
+	Persister  telegraf.StatePersister `toml:"-"`
 	tailers    map[string]*tail.Tail
 	offsets    map[string]int64
+	stateMu    sync.Mutex // protects tailers and offsets
 	parserFunc parsers.ParserFunc
 	wg         sync.WaitGroup
 
@@ -108,7 +110,23 @@ func (t *Tail) Init() error {
 }
 
 func (t *Tail) GetState() interface{} {
-	return t.offsets
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
+
+	// Take a snapshot of the offsets including the current position of all
+	// active tailers as the state might be checkpointed while running.
+	offsets := make(map[string]int64, len(t.offsets)+len(t.tailers))
+	for k, v := range t.offsets {
+		offsets[k] = v
+	}
+	if !t.Pipe && !t.FromBeginning {
+		for _, tailer := range t.tailers {
+			if offset, err := tailer.Tell(); err == nil {
+				offsets[tailer.Filename] = offset
+			}
+		}
+	}
+	return offsets
 }
 
 func (t *Tail) SetState(state interface{}) error {
@@ -116,6 +134,8 @@ func (t *Tail) SetState(state interface{}) error {
 	if !ok {
 		return errors.New("state has to be of type 'map[string]int64'")
 	}
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
 	for k, v := range offsetsState {
 		t.offsets[k] = v
 	}
@@ -151,7 +171,9 @@ func (t *Tail) Start(acc telegraf.Accumulator) error {
 		return err
 	}
 
+	t.stateMu.Lock()
 	t.tailers = make(map[string]*tail.Tail)
+	t.stateMu.Unlock()
 
 	err = t.tailNewFiles(t.FromBeginning)
 
@@ -170,6 +192,7 @@ func (t *Tail) tailNewFiles(fromBeginning bool) error {
 	}
 
 	// Create a "tailer" for each file
+	var added int
 	for _, filepath := range t.Files {
 		g, err := globpath.Compile(filepath)
 		if err != nil {
@@ -237,17 +260,38 @@ func (t *Tail) tailNewFiles(fromBeginning bool) error {
 				if err := tailer.Err(); err != nil {
 					t.Log.Errorf("Tailing %q: %s", tailer.Filename, err.Error())
 				}
+
+				// Store the final offset of files gone while running
+				if t.ctx.Err() == nil {
+					t.checkpoint()
+				}
 			}()
 
+			t.stateMu.Lock()
 			t.tailers[tailer.Filename] = tailer
+			t.stateMu.Unlock()
+			added++
 		}
 	}
+
+	// Store the offsets of files showing up, e.g. after a rotation, so they
+	// are resumed instead of skipped after a crash
+	if added > 0 {
+		t.checkpoint()
+	}
 	return nil
 }
 
-// ParseLine parses a line of text.
-func parseLine(parser parsers.Parser, line string) ([]telegraf.Metric, error) {
-	m, err := parser.Parse([]byte(line))
+// checkpoint requests storing the offsets if the plugin states are persisted.
+func (t *Tail) checkpoint() {
+	if t.Persister != nil {
+		t.Persister.Checkpoint()
+	}
+}
+
+// ParseLine parses a line of text read from the given file.
+func parseLine(parser parsers.Parser, line, filename string) ([]telegraf.Metric, error) {
+	m, err := parsers.ParseWithMetadata(parser, []byte(line), map[string]string{"path": filename})
 	if err != nil {
 		if errors.Is(err, parsers.ErrEOF) {
 			return nil, nil
@@ -329,7 +373,7 @@ func (t *Tail) receiver(parser parsers.Parser, tailer *tail.Tail) {
 			text = string(out)
 		}
 
-		metrics, err := parseLine(parser, text)
+		metrics, err := parseLine(parser, text, tailer.Filename)
 		if err != nil {
 			t.Log.Errorf("Malformed log line in %q: [%q]: %s",
 				tailer.Filename, text, err.Error())
@@ -365,6 +409,9 @@ func (t *Tail) receiver(parser parsers.Parser, tailer *tail.Tail) {
 }
 
 func (t *Tail) Stop() {
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
+
 	for _, tailer := range t.tailers {
 		if !t.Pipe && !t.FromBeginning {
 			// store offset for resume
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -687,6 +688,41 @@ func TestTailEOF(t *testing.T) {
 	require.NoError(t, err)
 }
 
+type mockPersister struct {
+	checkpoints atomic.Int64
+}
+
+func (p *mockPersister) Checkpoint() {
+	p.checkpoints.Add(1)
+}
+
+func TestCheckpointOnNewFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "first.log"), []byte("cpu usage_idle=100\n"), 0640))
+
+	persister := &mockPersister{}
+	tt := NewTestTail()
+	tt.Log = testutil.Logger{}
+	tt.Persister = persister
+	tt.Files = []string{filepath.Join(dir, "*.log")}
+	tt.SetParserFunc(NewInfluxParser)
+	require.NoError(t, tt.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, tt.Start(&acc))
+	defer tt.Stop()
+	require.Equal(t, int64(1), persister.checkpoints.Load())
+
+	// Nothing changed
+	require.NoError(t, acc.GatherError(tt.Gather))
+	require.Equal(t, int64(1), persister.checkpoints.Load())
+
+	// A new file shows up, e.g. after rotation
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "second.log"), []byte("cpu usage_idle=100\n"), 0640))
+	require.NoError(t, acc.GatherError(tt.Gather))
+	require.Equal(t, int64(2), persister.checkpoints.Load())
+}
+
 func TestCSVBehavior(t *testing.T) {
 	// Prepare the input file
 	input, err := os.CreateTemp("", "")
@@ -7,10 +7,12 @@ import (
 	"context"
 	"crypto/sha256"
 	_ "embed"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	awsV2 "github.com/aws/aws-sdk-go-v2/aws"
@@ -57,6 +59,9 @@ type HTTP struct {
 	client     *http.Client
 	serializer serializers.Serializer
 
+	// mu protects the serializer and the token for concurrent writes
+	mu sync.Mutex
+
 	awsCfg *awsV2.Config
 	internalaws.CredentialConfig
 
@@ -104,12 +109,36 @@ func (h *HTTP) Close() error {
 	return nil
 }
 
+// SupportsConcurrentWrites allows to write several batches in parallel as
+// each request is independent.
+func (*HTTP) SupportsConcurrentWrites() bool {
+	return true
+}
+
+// IsPermanentError classifies client errors as permanent as the server will
+// reject the metrics again. Request timeouts, rate limiting and authentication
+// failures are retried as they might be resolved later, e.g. by refreshing
+// the credentials.
+func (*HTTP) IsPermanentError(err error) bool {
+	var serr *statusError
+	if !errors.As(err, &serr) {
+		return false
+	}
+	switch serr.code {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return serr.code >= 400 && serr.code < 500
+}
+
 func (h *HTTP) Write(metrics []telegraf.Metric) error {
 	var reqBody []byte
 
 	if h.UseBatchFormat {
 		var err error
+		h.mu.Lock()
 		reqBody, err = h.serializer.SerializeBatch(metrics)
+		h.mu.Unlock()
 		if err != nil {
 			return err
 		}
@@ -119,7 +148,9 @@ func (h *HTTP) Write(metrics []telegraf.Metric) error {
 
 	for _, metric := range metrics {
 		var err error
+		h.mu.Lock()
 		reqBody, err = h.serializer.Serialize(metric)
+		h.mu.Unlock()
 		if err != nil {
 			return err
 		}
@@ -234,7 +265,7 @@ func (h *HTTP) writeMetric(reqBody []byte) error {
 			errorLine = scanner.Text()
 		}
 
-		return fmt.Errorf("when writing to [%s] received status code: %d. body: %s", h.URL, resp.StatusCode, errorLine)
+		return &statusError{url: h.URL, code: resp.StatusCode, body: errorLine}
 	}
 
 	_, err = io.ReadAll(resp.Body)
@@ -245,6 +276,17 @@ func (h *HTTP) writeMetric(reqBody []byte) error {
 	return nil
 }
 
+// statusError is returned if the server answers with an unsuccessful status
+type statusError struct {
+	url  string
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("when writing to [%s] received status code: %d. body: %s", e.url, e.code, e.body)
+}
+
 func init() {
 	outputs.Add("http", func() telegraf.Output {
 		return &HTTP{
@@ -256,6 +298,9 @@ func init() {
 }
 
 func (h *HTTP) getAccessToken(ctx context.Context, audience string) (*oauth2.Token, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	if h.oauth2Token.Valid() {
 		return h.oauth2Token, nil
 	}
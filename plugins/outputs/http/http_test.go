@@ -8,6 +8,9 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -248,6 +251,29 @@ func TestStatusCode(t *testing.T) {
 			statusCode: http.StatusBadRequest,
 			errFunc: func(t *testing.T, err error) {
 				require.Error(t, err)
+				require.True(t, (&HTTP{}).IsPermanentError(err))
+			},
+		},
+		{
+			name: "rate limiting is a transient error",
+			plugin: &HTTP{
+				URL: u.String(),
+			},
+			statusCode: http.StatusTooManyRequests,
+			errFunc: func(t *testing.T, err error) {
+				require.Error(t, err)
+				require.False(t, (&HTTP{}).IsPermanentError(err))
+			},
+		},
+		{
+			name: "5xx status is a transient error",
+			plugin: &HTTP{
+				URL: u.String(),
+			},
+			statusCode: http.StatusServiceUnavailable,
+			errFunc: func(t *testing.T, err error) {
+				require.Error(t, err)
+				require.False(t, (&HTTP{}).IsPermanentError(err))
 			},
 		},
 		{
@@ -701,6 +727,43 @@ func TestBatchedUnbatched(t *testing.T) {
 	}
 }
 
+func TestConcurrentWrites(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(r.Body)
+		if err != nil || !strings.Contains(string(payload), "cpu value=42") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:            ts.URL,
+		Method:         defaultMethod,
+		UseBatchFormat: true,
+	}
+	require.True(t, plugin.SupportsConcurrentWrites())
+
+	serializer := &influx.Serializer{}
+	require.NoError(t, serializer.Init())
+	plugin.SetSerializer(serializer)
+	require.NoError(t, plugin.Connect())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, plugin.Write(getMetrics(3)))
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(10), requests.Load())
+}
+
 func TestAwsCredentials(t *testing.T) {
 	ts := httptest.NewServer(http.NotFoundHandler())
 	defer ts.Close()
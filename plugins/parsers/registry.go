@@ -19,6 +19,15 @@ func Add(name string, creator Creator) {
 	Parsers[name] = creator
 }
 
+// ParseWithMetadata parses the data passing the metadata about its origin
+// to parsers supporting it.
+func ParseWithMetadata(parser telegraf.Parser, buf []byte, metadata map[string]string) ([]telegraf.Metric, error) {
+	if p, ok := parser.(telegraf.MetadataParser); ok {
+		return p.ParseWithMetadata(buf, metadata)
+	}
+	return parser.Parse(buf)
+}
+
 type ParserFunc func() (Parser, error)
 
 // ParserInput is an interface for input plugins that are able to parse
@@ -0,0 +1,67 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package cardinality
+
+import (
+	_ "embed"
+	"errors"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Cardinality struct {
+	Limit         int             `toml:"limit"`
+	Action        string          `toml:"action"`
+	FoldValue     string          `toml:"fold_value"`
+	ResetInterval config.Duration `toml:"reset_interval"`
+	Log           telegraf.Logger `toml:"-"`
+
+	limiter *models.CardinalityLimiter
+}
+
+func (*Cardinality) SampleConfig() string {
+	return sampleConfig
+}
+
+func (c *Cardinality) Init() error {
+	if c.Limit <= 0 {
+		return errors.New("limit must be positive")
+	}
+
+	cfg := models.CardinalityConfig{
+		Limit:         c.Limit,
+		Action:        c.Action,
+		FoldValue:     c.FoldValue,
+		ResetInterval: time.Duration(c.ResetInterval),
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	c.limiter = models.NewCardinalityLimiter(cfg, c.Log, map[string]string{"processor": "cardinality"})
+
+	return nil
+}
+
+func (c *Cardinality) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	out := in[:0]
+	for _, m := range in {
+		if !c.limiter.Apply(m) {
+			m.Drop()
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func init() {
+	processors.Add("cardinality", func() telegraf.Processor {
+		return &Cardinality{}
+	})
+}
@@ -0,0 +1,85 @@
+package cardinality
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func newMetric(path, requestID string) telegraf.Metric {
+	return metric.New(
+		"http",
+		map[string]string{"path": path, "request_id": requestID},
+		map[string]interface{}{"duration": 3},
+		time.Unix(0, 0),
+	)
+}
+
+func TestDrop(t *testing.T) {
+	plugin := &Cardinality{Limit: 2, Log: testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+
+	input := []telegraf.Metric{
+		newMetric("/a", "1"),
+		newMetric("/a", "2"),
+		newMetric("/b", "3"),
+		newMetric("/a", "1"),
+	}
+	expected := []telegraf.Metric{
+		newMetric("/a", "1"),
+		newMetric("/a", "2"),
+		newMetric("/a", "1"),
+	}
+	testutil.RequireMetricsEqual(t, expected, plugin.Apply(input...))
+}
+
+func TestFold(t *testing.T) {
+	plugin := &Cardinality{Limit: 2, Action: "fold", Log: testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+
+	input := []telegraf.Metric{
+		newMetric("/a", "1"),
+		newMetric("/a", "2"),
+		newMetric("/b", "3"),
+		newMetric("/b", "4"),
+	}
+	expected := []telegraf.Metric{
+		newMetric("/a", "1"),
+		newMetric("/a", "2"),
+		newMetric("/b", "other"),
+		newMetric("/b", "other"),
+	}
+	testutil.RequireMetricsEqual(t, expected, plugin.Apply(input...))
+}
+
+func TestInvalidConfig(t *testing.T) {
+	plugin := &Cardinality{Log: testutil.Logger{}}
+	require.ErrorContains(t, plugin.Init(), "limit must be positive")
+
+	plugin = &Cardinality{Limit: 1, Action: "foo", Log: testutil.Logger{}}
+	require.ErrorContains(t, plugin.Init(), `invalid cardinality action "foo"`)
+}
+
+func TestTracking(t *testing.T) {
+	var delivered int
+	notify := func(telegraf.DeliveryInfo) {
+		delivered++
+	}
+
+	plugin := &Cardinality{Limit: 1, Log: testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+
+	first, _ := metric.WithTracking(newMetric("/a", "1"), notify)
+	second, _ := metric.WithTracking(newMetric("/a", "2"), notify)
+	out := plugin.Apply(first, second)
+	require.Len(t, out, 1)
+	require.Equal(t, 1, delivered)
+
+	out[0].Accept()
+	require.Equal(t, 2, delivered)
+}
@@ -0,0 +1,5 @@
+//go:build !custom || secretstores || secretstores.env
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/secretstores/env" // register plugin
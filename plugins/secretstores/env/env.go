@@ -0,0 +1,77 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package env
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Env struct {
+	ID     string `toml:"id"`
+	Prefix string `toml:"prefix"`
+}
+
+func (*Env) SampleConfig() string {
+	return sampleConfig
+}
+
+// Init initializes all internals of the secret-store
+func (e *Env) Init() error {
+	if e.ID == "" {
+		return errors.New("id missing")
+	}
+	return nil
+}
+
+// Get searches for the given key and return the secret
+func (e *Env) Get(key string) ([]byte, error) {
+	value, found := os.LookupEnv(e.Prefix + key)
+	if !found {
+		return nil, fmt.Errorf("environment variable %q not set", e.Prefix+key)
+	}
+	return []byte(value), nil
+}
+
+// Set sets the given secret for the given key
+func (*Env) Set(_, _ string) error {
+	return errors.New("secret-store does not support creating secrets")
+}
+
+// List lists all known secret keys
+func (e *Env) List() ([]string, error) {
+	keys := make([]string, 0)
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if key, found := strings.CutPrefix(name, e.Prefix); found && key != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// GetResolver returns a function to resolve the given key.
+func (e *Env) GetResolver(key string) (telegraf.ResolveFunc, error) {
+	resolver := func() ([]byte, bool, error) {
+		s, err := e.Get(key)
+		return s, false, err
+	}
+	return resolver, nil
+}
+
+// Register the secret-store on load.
+func init() {
+	secretstores.Add("env", func(id string) telegraf.SecretStore {
+		return &Env{ID: id, Prefix: "TELEGRAF_SECRET_"}
+	})
+}
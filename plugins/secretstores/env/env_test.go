@@ -0,0 +1,52 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleConfig(t *testing.T) {
+	plugin := &Env{}
+	require.NotEmpty(t, plugin.SampleConfig())
+}
+
+func TestInitFail(t *testing.T) {
+	plugin := &Env{}
+	require.ErrorContains(t, plugin.Init(), "id missing")
+}
+
+func TestListGet(t *testing.T) {
+	t.Setenv("TELEGRAF_TEST_SECRET_password", "I won't tell")
+	t.Setenv("TELEGRAF_TEST_SECRET_token", "abc=def")
+
+	plugin := &Env{
+		ID:     "test",
+		Prefix: "TELEGRAF_TEST_SECRET_",
+	}
+	require.NoError(t, plugin.Init())
+
+	keys, err := plugin.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "token"}, keys)
+
+	secret, err := plugin.Get("token")
+	require.NoError(t, err)
+	require.Equal(t, "abc=def", string(secret))
+
+	resolver, err := plugin.GetResolver("password")
+	require.NoError(t, err)
+	secret, dynamic, err := resolver()
+	require.NoError(t, err)
+	require.False(t, dynamic)
+	require.Equal(t, "I won't tell", string(secret))
+
+	_, err = plugin.Get("foo")
+	require.ErrorContains(t, err, `environment variable "TELEGRAF_TEST_SECRET_foo" not set`)
+}
+
+func TestSetNotAvailable(t *testing.T) {
+	plugin := &Env{ID: "test"}
+	require.NoError(t, plugin.Init())
+	require.ErrorContains(t, plugin.Set("password", "new"), "secret-store does not support creating secrets")
+}
@@ -0,0 +1,326 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/awnumar/memguard"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type File struct {
+	ID      string `toml:"id"`
+	Path    string `toml:"path"`
+	Format  string `toml:"format"`
+	Dynamic bool   `toml:"dynamic"`
+
+	directory bool
+
+	// Fingerprints of the secrets to detect changes
+	key          []byte
+	fingerprints map[string]string
+}
+
+func (*File) SampleConfig() string {
+	return sampleConfig
+}
+
+// Init initializes all internals of the secret-store
+func (f *File) Init() error {
+	if f.ID == "" {
+		return errors.New("id missing")
+	}
+	if f.Path == "" {
+		return errors.New("path missing")
+	}
+
+	path, err := filepath.Abs(f.Path)
+	if err != nil {
+		return err
+	}
+	f.Path = path
+
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return fmt.Errorf("accessing %q failed: %w", f.Path, err)
+	}
+	f.directory = info.IsDir()
+	if f.directory && f.Format != "" {
+		return errors.New("format cannot be used with a directory")
+	}
+	if !f.directory {
+		switch f.Format {
+		case "":
+			f.Format = "keyvalue"
+			if strings.EqualFold(filepath.Ext(f.Path), ".json") {
+				f.Format = "json"
+			}
+		case "keyvalue", "json":
+		default:
+			return fmt.Errorf("invalid format %q", f.Format)
+		}
+	}
+
+	// Remember the current secrets to detect changes, this also reports
+	// unparsable files early
+	f.key = make([]byte, 32)
+	if _, err := rand.Read(f.key); err != nil {
+		return fmt.Errorf("creating fingerprint key failed: %w", err)
+	}
+	f.fingerprints, err = f.fingerprint()
+	return err
+}
+
+// Get searches for the given key and return the secret
+func (f *File) Get(key string) ([]byte, error) {
+	if f.directory {
+		return f.readKeyFile(key)
+	}
+
+	secrets, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	value, found := secrets[key]
+	delete(secrets, key)
+	wipe(secrets)
+	if !found {
+		return nil, fmt.Errorf("key %q not found in %q", key, f.Path)
+	}
+	return value, nil
+}
+
+// Set sets the given secret for the given key
+func (*File) Set(_, _ string) error {
+	return errors.New("secret-store does not support creating secrets")
+}
+
+// List lists all known secret keys
+func (f *File) List() ([]string, error) {
+	if f.directory {
+		entries, err := os.ReadDir(f.Path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read files under the directory: %w", err)
+		}
+		keys := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			// Skip sub-directories and hidden files, e.g. the data
+			// directory of Kubernetes secret volumes
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			keys = append(keys, entry.Name())
+		}
+		return keys, nil
+	}
+
+	secrets, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(secrets)
+
+	keys := make([]string, 0, len(secrets))
+	for k := range secrets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// GetResolver returns a function to resolve the given key.
+func (f *File) GetResolver(key string) (telegraf.ResolveFunc, error) {
+	resolver := func() ([]byte, bool, error) {
+		s, err := f.Get(key)
+		return s, f.Dynamic, err
+	}
+	return resolver, nil
+}
+
+// ChangedKeys returns the keys of the secrets added, changed or removed since
+// the previous call.
+func (f *File) ChangedKeys() ([]string, error) {
+	current, err := f.fingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for k, v := range current {
+		if f.fingerprints[k] != v {
+			changed = append(changed, k)
+		}
+	}
+	for k := range f.fingerprints {
+		if _, found := current[k]; !found {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	f.fingerprints = current
+
+	return changed, nil
+}
+
+// fingerprint returns a keyed hash of each secret
+func (f *File) fingerprint() (map[string]string, error) {
+	var secrets map[string][]byte
+	if f.directory {
+		keys, err := f.List()
+		if err != nil {
+			return nil, err
+		}
+		secrets = make(map[string][]byte, len(keys))
+		for _, k := range keys {
+			value, err := f.readKeyFile(k)
+			if err != nil {
+				wipe(secrets)
+				return nil, err
+			}
+			secrets[k] = value
+		}
+	} else {
+		var err error
+		if secrets, err = f.read(); err != nil {
+			return nil, err
+		}
+	}
+	defer wipe(secrets)
+
+	fingerprints := make(map[string]string, len(secrets))
+	for k, v := range secrets {
+		mac := hmac.New(sha256.New, f.key)
+		mac.Write(v)
+		fingerprints[k] = string(mac.Sum(nil))
+	}
+	return fingerprints, nil
+}
+
+// readKeyFile reads the secret of the key from the file of the same name
+// in the directory
+func (f *File) readKeyFile(key string) ([]byte, error) {
+	filename := filepath.Join(f.Path, key)
+	if filepath.Dir(filename) != f.Path {
+		return nil, fmt.Errorf("directory traversal detected for key %q", key)
+	}
+	value, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the secret's value under the directory: %w", err)
+	}
+
+	// Files created by e.g. "echo" end with a line break not being part
+	// of the secret
+	trimmed := bytes.TrimRight(value, "\r\n")
+	if len(trimmed) == len(value) {
+		return value, nil
+	}
+	secret := append([]byte(nil), trimmed...)
+	memguard.WipeBytes(value)
+	return secret, nil
+}
+
+// read parses all secrets of the file. The file is read on each call so
+// changes are picked up without caching the secrets in unprotected memory.
+func (f *File) read() (map[string][]byte, error) {
+	content, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %q failed: %w", f.Path, err)
+	}
+	defer memguard.WipeBytes(content)
+
+	var secrets map[string][]byte
+	if f.Format == "json" {
+		secrets, err = parseJSON(content)
+	} else {
+		secrets, err = parseKeyValue(content)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %q failed: %w", f.Path, err)
+	}
+	return secrets, nil
+}
+
+// parseKeyValue parses lines of "key=value" pairs, ignoring empty lines and
+// comments starting with "#". Values might be enclosed in quotes.
+func parseKeyValue(content []byte) (map[string][]byte, error) {
+	secrets := make(map[string][]byte)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 || text[0] == '#' {
+			continue
+		}
+		key, value, found := bytes.Cut(text, []byte("="))
+		if !found {
+			wipe(secrets)
+			return nil, fmt.Errorf("line %d: missing \"=\"", line)
+		}
+		key = bytes.TrimSpace(bytes.TrimPrefix(key, []byte("export ")))
+		if len(key) == 0 {
+			wipe(secrets)
+			return nil, fmt.Errorf("line %d: empty key", line)
+		}
+		value = bytes.TrimSpace(value)
+		if n := len(value); n >= 2 && (value[0] == '"' || value[0] == '\'') && value[n-1] == value[0] {
+			value = value[1 : n-1]
+		}
+		secrets[string(key)] = append([]byte(nil), value...)
+	}
+	if err := scanner.Err(); err != nil {
+		wipe(secrets)
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// parseJSON parses a JSON object with the fields being the keys. Values that
+// are no strings are returned in their JSON representation.
+func parseJSON(content []byte) (map[string][]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+
+	secrets := make(map[string][]byte, len(fields))
+	for k, raw := range fields {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			secrets[k] = []byte(s)
+		} else {
+			secrets[k] = raw
+		}
+	}
+	return secrets, nil
+}
+
+// wipe removes the given secrets from memory
+func wipe(secrets map[string][]byte) {
+	for _, v := range secrets {
+		memguard.WipeBytes(v)
+	}
+}
+
+// Register the secret-store on load.
+func init() {
+	secretstores.Add("file", func(id string) telegraf.SecretStore {
+		return &File{ID: id}
+	})
+}
@@ -0,0 +1,213 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleConfig(t *testing.T) {
+	plugin := &File{}
+	require.NotEmpty(t, plugin.SampleConfig())
+}
+
+func TestInitFail(t *testing.T) {
+	invalid := filepath.Join(t.TempDir(), "invalid.json")
+	require.NoError(t, os.WriteFile(invalid, []byte("{"), 0600))
+
+	tests := []struct {
+		name     string
+		plugin   *File
+		expected string
+	}{
+		{
+			name:     "invalid id",
+			plugin:   &File{},
+			expected: "id missing",
+		},
+		{
+			name:     "missing path",
+			plugin:   &File{ID: "test"},
+			expected: "path missing",
+		},
+		{
+			name:     "non-existing path",
+			plugin:   &File{ID: "test", Path: "testdata/non-existing"},
+			expected: "accessing",
+		},
+		{
+			name:     "format for directory",
+			plugin:   &File{ID: "test", Path: "testdata/secrets", Format: "json"},
+			expected: "format cannot be used with a directory",
+		},
+		{
+			name:     "invalid format",
+			plugin:   &File{ID: "test", Path: "testdata/secrets.env", Format: "yaml"},
+			expected: `invalid format "yaml"`,
+		},
+		{
+			name:     "invalid file",
+			plugin:   &File{ID: "test", Path: invalid},
+			expected: "parsing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.plugin.Init()
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestListGet(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected map[string]string
+	}{
+		{
+			name: "directory",
+			path: "testdata/secrets",
+			expected: map[string]string{
+				"password": "IWontTell",
+				"username": "telegraf",
+			},
+		},
+		{
+			name: "keyvalue",
+			path: "testdata/secrets.env",
+			expected: map[string]string{
+				"password": "I won't tell",
+				"token":    "abc=def",
+				"username": "telegraf",
+			},
+		},
+		{
+			name: "json",
+			path: "testdata/secrets.json",
+			expected: map[string]string{
+				"password": "I won't tell",
+				"port":     "5432",
+				"username": "telegraf",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &File{
+				ID:   "test",
+				Path: tt.path,
+			}
+			require.NoError(t, plugin.Init())
+
+			keys, err := plugin.List()
+			require.NoError(t, err)
+			require.Len(t, keys, len(tt.expected))
+
+			for _, k := range keys {
+				value, err := plugin.Get(k)
+				require.NoError(t, err)
+				require.Equal(t, tt.expected[k], string(value))
+			}
+
+			_, err = plugin.Get("foo")
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestDirectoryTraversal(t *testing.T) {
+	plugin := &File{
+		ID:   "test",
+		Path: "testdata/secrets",
+	}
+	require.NoError(t, plugin.Init())
+
+	_, err := plugin.Get("../secrets.env")
+	require.ErrorContains(t, err, "directory traversal detected")
+}
+
+func TestSetNotAvailable(t *testing.T) {
+	plugin := &File{
+		ID:   "test",
+		Path: "testdata/secrets",
+	}
+	require.NoError(t, plugin.Init())
+	require.ErrorContains(t, plugin.Set("password", "new"), "secret-store does not support creating secrets")
+}
+
+func TestDynamicReload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "secrets")
+	require.NoError(t, os.WriteFile(filename, []byte("password=old\n"), 0600))
+
+	plugin := &File{
+		ID:      "test",
+		Path:    filename,
+		Dynamic: true,
+	}
+	require.NoError(t, plugin.Init())
+
+	resolver, err := plugin.GetResolver("password")
+	require.NoError(t, err)
+	secret, dynamic, err := resolver()
+	require.NoError(t, err)
+	require.True(t, dynamic)
+	require.Equal(t, "old", string(secret))
+
+	// Changes to the file are picked up
+	require.NoError(t, os.WriteFile(filename, []byte("password=new\n"), 0600))
+	secret, _, err = resolver()
+	require.NoError(t, err)
+	require.Equal(t, "new", string(secret))
+}
+
+func TestChangedKeys(t *testing.T) {
+	testdir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(testdir, "password"), []byte("old"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(testdir, "username"), []byte("telegraf"), 0600))
+
+	plugin := &File{
+		ID:   "test",
+		Path: testdir,
+	}
+	require.NoError(t, plugin.Init())
+
+	keys, err := plugin.ChangedKeys()
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	// Changed, added and removed secrets are reported once
+	require.NoError(t, os.WriteFile(filepath.Join(testdir, "password"), []byte("new"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(testdir, "token"), []byte("abc"), 0600))
+	require.NoError(t, os.Remove(filepath.Join(testdir, "username")))
+	keys, err = plugin.ChangedKeys()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "token", "username"}, keys)
+
+	keys, err = plugin.ChangedKeys()
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
+
+func TestChangedKeysFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "secrets.json")
+	require.NoError(t, os.WriteFile(filename, []byte(`{"username": "telegraf", "password": "old"}`), 0600))
+
+	plugin := &File{
+		ID:   "test",
+		Path: filename,
+	}
+	require.NoError(t, plugin.Init())
+
+	require.NoError(t, os.WriteFile(filename, []byte(`{"username": "telegraf", "password": "new"}`), 0600))
+	keys, err := plugin.ChangedKeys()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password"}, keys)
+
+	// Unparsable files are reported
+	require.NoError(t, os.WriteFile(filename, []byte(`{`), 0600))
+	_, err = plugin.ChangedKeys()
+	require.ErrorContains(t, err, "parsing")
+}
@@ -0,0 +1,649 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package vault
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	clockutil "github.com/benbjohnson/clock"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// errNotFound is returned if the secret does not exist in Vault
+var errNotFound = errors.New("not found")
+
+type Vault struct {
+	ID        string          `toml:"id"`
+	URL       string          `toml:"url"`
+	Namespace string          `toml:"namespace"`
+	Engine    string          `toml:"engine"`
+	Mount     string          `toml:"mount"`
+	Path      string          `toml:"path"`
+	Dynamic   bool            `toml:"dynamic"`
+	CacheTTL  config.Duration `toml:"cache_ttl"`
+	Timeout   config.Duration `toml:"timeout"`
+
+	AuthMethod          string        `toml:"auth_method"`
+	Token               config.Secret `toml:"token"`
+	AppRoleMount        string        `toml:"approle_mount"`
+	AppRoleRoleID       string        `toml:"approle_role_id"`
+	AppRoleSecretID     config.Secret `toml:"approle_secret_id"`
+	KubernetesMount     string        `toml:"kubernetes_mount"`
+	KubernetesRole      string        `toml:"kubernetes_role"`
+	KubernetesTokenFile string        `toml:"kubernetes_token_file"`
+
+	Log telegraf.Logger `toml:"-"`
+	tls.ClientConfig
+
+	client *http.Client
+	clock  clockutil.Clock
+
+	sync.Mutex
+	login *lease
+	doc   *document
+
+	// Fingerprints of the secret fields to detect changes
+	key          []byte
+	fingerprints map[string]string
+}
+
+// lease describes the validity of a token or secret issued by Vault
+type lease struct {
+	id        string
+	duration  time.Duration
+	renewable bool
+	// Time to renew the lease and time the lease ends, zero for
+	// leases without expiry
+	refresh time.Time
+	expires time.Time
+	// Token obtained by logging in
+	token config.Secret
+}
+
+// document is a cached secret read from Vault with its fields being the
+// secret keys
+type document struct {
+	fields  map[string]json.RawMessage
+	version int
+	lease   lease
+}
+
+// response is the common envelope of Vault's API responses
+type response struct {
+	LeaseID       string          `json:"lease_id"`
+	LeaseDuration int64           `json:"lease_duration"`
+	Renewable     bool            `json:"renewable"`
+	Data          json.RawMessage `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int64  `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+func (*Vault) SampleConfig() string {
+	return sampleConfig
+}
+
+// Init initializes all internals of the secret-store
+func (v *Vault) Init() error {
+	if v.ID == "" {
+		return errors.New("id missing")
+	}
+	if v.URL == "" {
+		return errors.New("url missing")
+	}
+	v.URL = strings.TrimSuffix(v.URL, "/")
+	if v.Path == "" {
+		return errors.New("path missing")
+	}
+	v.Path = strings.Trim(v.Path, "/")
+
+	switch v.Engine {
+	case "":
+		v.Engine = "kv-v2"
+	case "kv-v1", "kv-v2", "generic":
+	default:
+		return fmt.Errorf("invalid engine %q", v.Engine)
+	}
+	if v.Mount == "" {
+		if v.Engine == "generic" {
+			return errors.New("mount missing")
+		}
+		v.Mount = "secret"
+	}
+	v.Mount = strings.Trim(v.Mount, "/")
+
+	switch v.AuthMethod {
+	case "", "token":
+		v.AuthMethod = "token"
+		if v.Token.Empty() {
+			return errors.New("token missing")
+		}
+	case "approle":
+		if v.AppRoleRoleID == "" {
+			return errors.New("approle_role_id missing")
+		}
+		if v.AppRoleMount == "" {
+			v.AppRoleMount = "approle"
+		}
+	case "kubernetes":
+		if v.KubernetesRole == "" {
+			return errors.New("kubernetes_role missing")
+		}
+		if v.KubernetesMount == "" {
+			v.KubernetesMount = "kubernetes"
+		}
+		if v.KubernetesTokenFile == "" {
+			v.KubernetesTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+		}
+	default:
+		return fmt.Errorf("invalid auth_method %q", v.AuthMethod)
+	}
+
+	tlsCfg, err := v.ClientConfig.TLSConfig()
+	if err != nil {
+		return fmt.Errorf("creating TLS configuration failed: %w", err)
+	}
+	timeout := v.Timeout
+	if timeout == 0 {
+		timeout = config.Duration(5 * time.Second)
+	}
+	v.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsCfg,
+		},
+		Timeout: time.Duration(timeout),
+	}
+
+	if v.clock == nil {
+		v.clock = clockutil.New()
+	}
+
+	v.key = make([]byte, 32)
+	if _, err := rand.Read(v.key); err != nil {
+		return fmt.Errorf("creating fingerprint key failed: %w", err)
+	}
+
+	return nil
+}
+
+// Get searches for the given key and return the secret
+func (v *Vault) Get(key string) ([]byte, error) {
+	v.Lock()
+	defer v.Unlock()
+
+	value, _, err := v.get(key)
+	return value, err
+}
+
+// Set sets the given secret for the given key
+func (v *Vault) Set(key, value string) error {
+	if v.Engine == "generic" {
+		return errors.New("secret-store does not support creating secrets for the generic engine")
+	}
+
+	v.Lock()
+	defer v.Unlock()
+
+	// Always modify the latest version of the secret
+	v.doc = nil
+	doc, err := v.read()
+	if errors.Is(err, errNotFound) {
+		doc = &document{fields: make(map[string]json.RawMessage)}
+	} else if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	doc.fields[key] = raw
+
+	// Use check-and-set for KV version 2 to not overwrite concurrent changes
+	var body interface{} = doc.fields
+	if v.Engine == "kv-v2" {
+		body = map[string]interface{}{
+			"data":    doc.fields,
+			"options": map[string]int{"cas": doc.version},
+		}
+	}
+	if err := v.call(http.MethodPost, v.secretPath(), body, nil); err != nil {
+		return fmt.Errorf("writing secret %q failed: %w", v.Path, err)
+	}
+
+	return nil
+}
+
+// List lists all known secret keys
+func (v *Vault) List() ([]string, error) {
+	v.Lock()
+	defer v.Unlock()
+
+	doc, err := v.fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(doc.fields))
+	for k := range doc.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// GetResolver returns a function to resolve the given key. Secrets with a
+// lease, e.g. database credentials, are always resolved dynamically to follow
+// the renewal of the lease.
+func (v *Vault) GetResolver(key string) (telegraf.ResolveFunc, error) {
+	resolver := func() ([]byte, bool, error) {
+		v.Lock()
+		defer v.Unlock()
+
+		value, leased, err := v.get(key)
+		return value, v.Dynamic || leased, err
+	}
+	return resolver, nil
+}
+
+// ChangedKeys returns the keys of the fields added, changed or removed since
+// the previous call, e.g. after the secret was updated or new credentials
+// were created because the lease expired.
+func (v *Vault) ChangedKeys() ([]string, error) {
+	v.Lock()
+	defer v.Unlock()
+
+	doc, err := v.fetch()
+	if err != nil {
+		return nil, err
+	}
+	current := v.fingerprint(doc)
+	previous := v.fingerprints
+	v.fingerprints = current
+
+	var changed []string
+	for k, fp := range current {
+		if previous[k] != fp {
+			changed = append(changed, k)
+		}
+	}
+	for k := range previous {
+		if _, found := current[k]; !found {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// SecretsUpdated logs in again after the credentials of the auth method
+// changed, e.g. if they are referencing another secret-store.
+func (v *Vault) SecretsUpdated() error {
+	v.Lock()
+	defer v.Unlock()
+
+	v.logout()
+	v.doc = nil
+	return nil
+}
+
+// fingerprint returns a keyed hash of each field of the secret
+func (v *Vault) fingerprint(doc *document) map[string]string {
+	fingerprints := make(map[string]string, len(doc.fields))
+	for k, raw := range doc.fields {
+		mac := hmac.New(sha256.New, v.key)
+		mac.Write(raw)
+		fingerprints[k] = string(mac.Sum(nil))
+	}
+	return fingerprints
+}
+
+// get returns the value of the key and if the secret is leased
+func (v *Vault) get(key string) ([]byte, bool, error) {
+	doc, err := v.fetch()
+	if err != nil {
+		return nil, false, err
+	}
+	leased := doc.lease.id != ""
+
+	raw, found := doc.fields[key]
+	if !found {
+		return nil, leased, fmt.Errorf("key %q not found in secret %q", key, v.Path)
+	}
+
+	// Return strings as-is and all other types in their JSON representation
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []byte(s), leased, nil
+	}
+	return append([]byte(nil), raw...), leased, nil
+}
+
+// fetch returns the cached secret, renews its lease or reads it again if
+// required.
+func (v *Vault) fetch() (*document, error) {
+	now := v.clock.Now()
+	if v.doc != nil && now.Before(v.doc.lease.refresh) {
+		return v.doc, nil
+	}
+
+	// Try to extend the lease and fall back to read the secret again, e.g.
+	// creating new credentials, if this fails
+	if v.doc != nil && v.doc.lease.renewable && now.Before(v.doc.lease.expires) {
+		err := v.renewLease(&v.doc.lease)
+		if err == nil {
+			return v.doc, nil
+		}
+		v.Log.Warnf("Renewing lease of secret %q failed, reading it again: %v", v.Path, err)
+	}
+
+	doc, err := v.read()
+	if err != nil {
+		v.doc = nil
+		return nil, err
+	}
+	v.doc = doc
+
+	// Secrets are resolved using the first version read
+	if v.fingerprints == nil {
+		v.fingerprints = v.fingerprint(doc)
+	}
+	return doc, nil
+}
+
+// read reads the secret from Vault
+func (v *Vault) read() (*document, error) {
+	var resp response
+	if err := v.call(http.MethodGet, v.secretPath(), nil, &resp); err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, fmt.Errorf("secret %q %w", v.Path, err)
+		}
+		return nil, fmt.Errorf("reading secret %q failed: %w", v.Path, err)
+	}
+
+	doc := &document{}
+	data := resp.Data
+	if v.Engine == "kv-v2" {
+		var kv struct {
+			Data     map[string]json.RawMessage `json:"data"`
+			Metadata struct {
+				Version int `json:"version"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal(data, &kv); err != nil {
+			return nil, fmt.Errorf("decoding secret %q failed: %w", v.Path, err)
+		}
+		// Deleted versions are returned without data
+		if kv.Data == nil {
+			return nil, fmt.Errorf("secret %q %w", v.Path, errNotFound)
+		}
+		doc.fields = kv.Data
+		doc.version = kv.Metadata.Version
+	} else if err := json.Unmarshal(data, &doc.fields); err != nil {
+		return nil, fmt.Errorf("decoding secret %q failed: %w", v.Path, err)
+	}
+	if doc.fields == nil {
+		doc.fields = make(map[string]json.RawMessage)
+	}
+
+	// KV secrets have no lease but a refresh interval as lease duration,
+	// so use our own cache interval
+	now := v.clock.Now()
+	if resp.LeaseID != "" {
+		doc.lease = lease{
+			id:        resp.LeaseID,
+			duration:  time.Duration(resp.LeaseDuration) * time.Second,
+			renewable: resp.Renewable,
+		}
+		doc.lease.start(now)
+	} else {
+		doc.lease.refresh = now.Add(time.Duration(v.CacheTTL))
+	}
+
+	return doc, nil
+}
+
+// renewLease extends the lease of the secret by its original duration
+func (v *Vault) renewLease(l *lease) error {
+	body := map[string]interface{}{
+		"lease_id":  l.id,
+		"increment": int64(l.duration.Seconds()),
+	}
+	var resp response
+	if err := v.call(http.MethodPut, "sys/leases/renew", body, &resp); err != nil {
+		return err
+	}
+	if resp.LeaseDuration <= 0 {
+		return errors.New("lease reached its maximum TTL")
+	}
+
+	l.duration = time.Duration(resp.LeaseDuration) * time.Second
+	l.renewable = resp.Renewable
+	l.start(v.clock.Now())
+	return nil
+}
+
+// start sets the renewal and expiry time of the lease issued at the given time.
+// Leases are renewed after two thirds of their duration.
+func (l *lease) start(now time.Time) {
+	l.expires = time.Time{}
+	l.refresh = time.Time{}
+	if l.duration > 0 {
+		l.expires = now.Add(l.duration)
+		l.refresh = now.Add(l.duration * 2 / 3)
+	}
+}
+
+// secretPath returns the API path of the secret
+func (v *Vault) secretPath() string {
+	if v.Engine == "kv-v2" {
+		return v.Mount + "/data/" + v.Path
+	}
+	return v.Mount + "/" + v.Path
+}
+
+// token returns the token to authenticate with, logging in or renewing the
+// token if necessary
+func (v *Vault) token() (config.Secret, error) {
+	if v.AuthMethod == "token" {
+		return v.Token, nil
+	}
+
+	now := v.clock.Now()
+	if v.login != nil {
+		if v.login.refresh.IsZero() || now.Before(v.login.refresh) {
+			return v.login.token, nil
+		}
+		if v.login.renewable && now.Before(v.login.expires) {
+			err := v.renewToken()
+			if err == nil {
+				return v.login.token, nil
+			}
+			v.Log.Warnf("Renewing token failed, logging in again: %v", err)
+		}
+		v.logout()
+	}
+
+	if err := v.authenticate(); err != nil {
+		return config.Secret{}, err
+	}
+	return v.login.token, nil
+}
+
+// authenticate logs in using the configured auth method
+func (v *Vault) authenticate() error {
+	var path string
+	var body map[string]string
+	switch v.AuthMethod {
+	case "approle":
+		path = "auth/" + v.AppRoleMount + "/login"
+		body = map[string]string{"role_id": v.AppRoleRoleID}
+		if !v.AppRoleSecretID.Empty() {
+			secretID, err := v.AppRoleSecretID.Get()
+			if err != nil {
+				return fmt.Errorf("getting secret ID failed: %w", err)
+			}
+			body["secret_id"] = string(secretID)
+			config.ReleaseSecret(secretID)
+		}
+	case "kubernetes":
+		// Read the token on each login as Kubernetes rotates the token
+		jwt, err := os.ReadFile(v.KubernetesTokenFile)
+		if err != nil {
+			return fmt.Errorf("reading service account token failed: %w", err)
+		}
+		path = "auth/" + v.KubernetesMount + "/login"
+		body = map[string]string{
+			"role": v.KubernetesRole,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		}
+	}
+
+	var resp response
+	if _, err := v.send(http.MethodPost, path, nil, body, &resp); err != nil {
+		return fmt.Errorf("logging in using %s failed: %w", v.AuthMethod, err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return fmt.Errorf("logging in using %s failed: no token returned", v.AuthMethod)
+	}
+
+	v.login = &lease{
+		duration:  time.Duration(resp.Auth.LeaseDuration) * time.Second,
+		renewable: resp.Auth.Renewable,
+		token:     config.NewSecret([]byte(resp.Auth.ClientToken)),
+	}
+	v.login.start(v.clock.Now())
+	return nil
+}
+
+// renewToken extends the lease of the token obtained by logging in
+func (v *Vault) renewToken() error {
+	var resp response
+	if _, err := v.send(http.MethodPost, "auth/token/renew-self", &v.login.token, nil, &resp); err != nil {
+		return err
+	}
+	if resp.Auth == nil || resp.Auth.LeaseDuration <= 0 {
+		return errors.New("token reached its maximum TTL")
+	}
+
+	v.login.duration = time.Duration(resp.Auth.LeaseDuration) * time.Second
+	v.login.renewable = resp.Auth.Renewable
+	v.login.start(v.clock.Now())
+	return nil
+}
+
+// logout forgets the token obtained by logging in
+func (v *Vault) logout() {
+	if v.login != nil {
+		v.login.token.Destroy()
+		v.login = nil
+	}
+}
+
+// call sends an authenticated request to the API. Tokens obtained by logging
+// in might have been revoked, so we log in again if access is denied.
+func (v *Vault) call(method, path string, body, result interface{}) error {
+	token, err := v.token()
+	if err != nil {
+		return err
+	}
+	status, err := v.send(method, path, &token, body, result)
+	if status != http.StatusForbidden || v.AuthMethod == "token" {
+		return err
+	}
+
+	v.logout()
+	if token, err = v.token(); err != nil {
+		return err
+	}
+	_, err = v.send(method, path, &token, body, result)
+	return err
+}
+
+// send sends a request to the API and decodes the response into result
+func (v *Vault) send(method, path string, token *config.Secret, body, result interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, v.URL+"/v1/"+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+	if token != nil {
+		t, err := token.Get()
+		if err != nil {
+			return 0, fmt.Errorf("getting token failed: %w", err)
+		}
+		req.Header.Set("X-Vault-Token", string(t))
+		config.ReleaseSecret(t)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return resp.StatusCode, errNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Errors []string `json:"errors"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err == nil && len(apiErr.Errors) > 0 {
+			return resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, strings.Join(apiErr.Errors, "; "))
+		}
+		return resp.StatusCode, errors.New(resp.Status)
+	}
+
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return resp.StatusCode, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return resp.StatusCode, fmt.Errorf("decoding response failed: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// Register the secret-store on load.
+func init() {
+	secretstores.Add("vault", func(id string) telegraf.SecretStore {
+		return &Vault{ID: id, CacheTTL: config.Duration(time.Minute)}
+	})
+}
@@ -0,0 +1,575 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	clockutil "github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestSampleConfig(t *testing.T) {
+	plugin := &Vault{}
+	require.NotEmpty(t, plugin.SampleConfig())
+}
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Vault
+		expected string
+	}{
+		{
+			name:     "invalid id",
+			plugin:   &Vault{},
+			expected: "id missing",
+		},
+		{
+			name:     "missing url",
+			plugin:   &Vault{ID: "test"},
+			expected: "url missing",
+		},
+		{
+			name:     "missing path",
+			plugin:   &Vault{ID: "test", URL: "http://localhost"},
+			expected: "path missing",
+		},
+		{
+			name:     "invalid engine",
+			plugin:   &Vault{ID: "test", URL: "http://localhost", Path: "telegraf", Engine: "foo"},
+			expected: `invalid engine "foo"`,
+		},
+		{
+			name:     "generic engine without mount",
+			plugin:   &Vault{ID: "test", URL: "http://localhost", Path: "creds/ro", Engine: "generic"},
+			expected: "mount missing",
+		},
+		{
+			name:     "missing token",
+			plugin:   &Vault{ID: "test", URL: "http://localhost", Path: "telegraf"},
+			expected: "token missing",
+		},
+		{
+			name:     "missing approle role",
+			plugin:   &Vault{ID: "test", URL: "http://localhost", Path: "telegraf", AuthMethod: "approle"},
+			expected: "approle_role_id missing",
+		},
+		{
+			name:     "missing kubernetes role",
+			plugin:   &Vault{ID: "test", URL: "http://localhost", Path: "telegraf", AuthMethod: "kubernetes"},
+			expected: "kubernetes_role missing",
+		},
+		{
+			name:     "invalid auth method",
+			plugin:   &Vault{ID: "test", URL: "http://localhost", Path: "telegraf", AuthMethod: "foo"},
+			expected: `invalid auth_method "foo"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.plugin.Init()
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestKVv2(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+	server.setKV("secret/data/telegraf", map[string]interface{}{
+		"username": "telegraf",
+		"password": "I won't tell",
+		"port":     5432,
+	})
+
+	plugin := &Vault{
+		ID:        "test",
+		URL:       server.URL,
+		Namespace: "ns1",
+		Path:      "telegraf",
+		CacheTTL:  config.Duration(time.Minute),
+		Token:     config.NewSecret([]byte("root")),
+		Log:       testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	keys, err := plugin.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "port", "username"}, keys)
+
+	secret, err := plugin.Get("password")
+	require.NoError(t, err)
+	require.Equal(t, "I won't tell", string(secret))
+
+	resolver, err := plugin.GetResolver("port")
+	require.NoError(t, err)
+	secret, dynamic, err := resolver()
+	require.NoError(t, err)
+	require.False(t, dynamic)
+	require.Equal(t, "5432", string(secret))
+
+	// All keys are served from the cached secret
+	require.Equal(t, 1, server.requests("GET secret/data/telegraf"))
+	require.Equal(t, "ns1", server.namespace)
+
+	_, err = plugin.Get("foo")
+	require.ErrorContains(t, err, `key "foo" not found in secret "telegraf"`)
+}
+
+func TestKVv1(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+	server.setKV("kv/telegraf", map[string]interface{}{"password": "secret"})
+
+	plugin := &Vault{
+		ID:       "test",
+		URL:      server.URL,
+		Engine:   "kv-v1",
+		Mount:    "kv",
+		Path:     "telegraf",
+		Dynamic:  true,
+		CacheTTL: 0,
+		Token:    config.NewSecret([]byte("root")),
+		Log:      testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	resolver, err := plugin.GetResolver("password")
+	require.NoError(t, err)
+	secret, dynamic, err := resolver()
+	require.NoError(t, err)
+	require.True(t, dynamic)
+	require.Equal(t, "secret", string(secret))
+
+	// Without caching, changes are picked up immediately
+	server.setKV("kv/telegraf", map[string]interface{}{"password": "changed"})
+	secret, _, err = resolver()
+	require.NoError(t, err)
+	require.Equal(t, "changed", string(secret))
+}
+
+func TestNotFound(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+
+	plugin := &Vault{
+		ID:    "test",
+		URL:   server.URL,
+		Path:  "missing",
+		Token: config.NewSecret([]byte("root")),
+		Log:   testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	_, err := plugin.Get("password")
+	require.ErrorContains(t, err, `secret "missing" not found`)
+}
+
+func TestInvalidToken(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+	server.setKV("secret/data/telegraf", map[string]interface{}{"password": "secret"})
+
+	plugin := &Vault{
+		ID:    "test",
+		URL:   server.URL,
+		Path:  "telegraf",
+		Token: config.NewSecret([]byte("invalid")),
+		Log:   testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	_, err := plugin.Get("password")
+	require.ErrorContains(t, err, "403 Forbidden: permission denied")
+}
+
+func TestSetKVv2(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+	server.setKV("secret/data/telegraf", map[string]interface{}{"username": "telegraf"})
+
+	plugin := &Vault{
+		ID:    "test",
+		URL:   server.URL,
+		Path:  "telegraf",
+		Token: config.NewSecret([]byte("root")),
+		Log:   testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	require.NoError(t, plugin.Set("password", "new"))
+	require.Equal(t, 2, server.version["secret/data/telegraf"])
+
+	// Existing fields are kept
+	keys, err := plugin.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "username"}, keys)
+	secret, err := plugin.Get("password")
+	require.NoError(t, err)
+	require.Equal(t, "new", string(secret))
+
+	// Writing fails if the secret was modified in-between
+	server.casFailure = true
+	require.ErrorContains(t, plugin.Set("password", "newer"), "check-and-set parameter did not match")
+}
+
+func TestSetGeneric(t *testing.T) {
+	plugin := &Vault{
+		ID:     "test",
+		URL:    "http://localhost",
+		Engine: "generic",
+		Mount:  "database",
+		Path:   "creds/readonly",
+		Token:  config.NewSecret([]byte("root")),
+	}
+	require.NoError(t, plugin.Init())
+	require.ErrorContains(t, plugin.Set("password", "new"), "does not support creating secrets")
+}
+
+func TestGenericLease(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+
+	clock := clockutil.NewMock()
+	plugin := &Vault{
+		ID:     "test",
+		URL:    server.URL,
+		Engine: "generic",
+		Mount:  "database",
+		Path:   "creds/readonly",
+		Token:  config.NewSecret([]byte("root")),
+		Log:    testutil.Logger{},
+		clock:  clock,
+	}
+	require.NoError(t, plugin.Init())
+
+	username, err := plugin.GetResolver("username")
+	require.NoError(t, err)
+	password, err := plugin.GetResolver("password")
+	require.NoError(t, err)
+
+	// Secrets with a lease are dynamic and taken from the same lease
+	user, dynamic, err := username()
+	require.NoError(t, err)
+	require.True(t, dynamic)
+	require.Equal(t, "user-1", string(user))
+	passwd, dynamic, err := password()
+	require.NoError(t, err)
+	require.True(t, dynamic)
+	require.Equal(t, "password-1", string(passwd))
+	require.Equal(t, 1, server.requests("GET database/creds/readonly"))
+
+	// The lease is renewed after two thirds of its duration
+	clock.Add(21 * time.Second)
+	user, _, err = username()
+	require.NoError(t, err)
+	require.Equal(t, "user-1", string(user))
+	require.Equal(t, 1, server.requests("PUT sys/leases/renew"))
+	require.Equal(t, 1, server.requests("GET database/creds/readonly"))
+
+	// New credentials are created once the lease cannot be renewed anymore
+	server.maxTTLReached = true
+	clock.Add(21 * time.Second)
+	user, _, err = username()
+	require.NoError(t, err)
+	require.Equal(t, "user-2", string(user))
+	require.Equal(t, 2, server.requests("PUT sys/leases/renew"))
+	require.Equal(t, 2, server.requests("GET database/creds/readonly"))
+}
+
+func TestChangedKeys(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+	server.setKV("secret/data/telegraf", map[string]interface{}{
+		"username": "telegraf",
+		"password": "old",
+	})
+
+	clock := clockutil.NewMock()
+	plugin := &Vault{
+		ID:       "test",
+		URL:      server.URL,
+		Path:     "telegraf",
+		CacheTTL: config.Duration(time.Minute),
+		Token:    config.NewSecret([]byte("root")),
+		Log:      testutil.Logger{},
+		clock:    clock,
+	}
+	require.NoError(t, plugin.Init())
+
+	secret, err := plugin.Get("password")
+	require.NoError(t, err)
+	require.Equal(t, "old", string(secret))
+
+	// Changes are detected once the cached secret expired
+	server.setKV("secret/data/telegraf", map[string]interface{}{
+		"username": "telegraf",
+		"password": "new",
+		"token":    "abc",
+	})
+	keys, err := plugin.ChangedKeys()
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	clock.Add(time.Minute)
+	keys, err = plugin.ChangedKeys()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "token"}, keys)
+
+	keys, err = plugin.ChangedKeys()
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
+
+func TestChangedKeysLease(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+	server.maxTTLReached = true
+
+	clock := clockutil.NewMock()
+	plugin := &Vault{
+		ID:     "test",
+		URL:    server.URL,
+		Engine: "generic",
+		Mount:  "database",
+		Path:   "creds/readonly",
+		Token:  config.NewSecret([]byte("root")),
+		Log:    testutil.Logger{},
+		clock:  clock,
+	}
+	require.NoError(t, plugin.Init())
+
+	keys, err := plugin.ChangedKeys()
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	// New credentials are created after the lease cannot be renewed
+	clock.Add(21 * time.Second)
+	keys, err = plugin.ChangedKeys()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "username"}, keys)
+}
+
+func TestAppRole(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+	server.setKV("secret/data/telegraf", map[string]interface{}{"password": "secret"})
+
+	clock := clockutil.NewMock()
+	plugin := &Vault{
+		ID:              "test",
+		URL:             server.URL,
+		Path:            "telegraf",
+		CacheTTL:        0,
+		AuthMethod:      "approle",
+		AppRoleRoleID:   "role",
+		AppRoleSecretID: config.NewSecret([]byte("secret-id")),
+		Log:             testutil.Logger{},
+		clock:           clock,
+	}
+	require.NoError(t, plugin.Init())
+
+	secret, err := plugin.Get("password")
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(secret))
+	require.Equal(t, 1, server.requests("POST auth/approle/login"))
+
+	// The token is renewed after two thirds of its time-to-live
+	clock.Add(41 * time.Second)
+	_, err = plugin.Get("password")
+	require.NoError(t, err)
+	require.Equal(t, 1, server.requests("POST auth/token/renew-self"))
+	require.Equal(t, 1, server.requests("POST auth/approle/login"))
+
+	// Revoked tokens cause logging in again
+	server.revokeTokens()
+	_, err = plugin.Get("password")
+	require.NoError(t, err)
+	require.Equal(t, 2, server.requests("POST auth/approle/login"))
+}
+
+func TestKubernetes(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+	server.setKV("secret/data/telegraf", map[string]interface{}{"password": "secret"})
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("service-account-jwt\n"), 0600))
+
+	plugin := &Vault{
+		ID:                  "test",
+		URL:                 server.URL,
+		Path:                "telegraf",
+		AuthMethod:          "kubernetes",
+		KubernetesRole:      "telegraf",
+		KubernetesTokenFile: tokenFile,
+		Log:                 testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	secret, err := plugin.Get("password")
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(secret))
+	require.Equal(t, 1, server.requests("POST auth/kubernetes/login"))
+}
+
+// stubServer emulates the parts of the Vault API used by the plugin
+type stubServer struct {
+	*httptest.Server
+	t *testing.T
+
+	sync.Mutex
+	kv            map[string]map[string]interface{}
+	version       map[string]int
+	tokens        map[string]bool
+	counts        map[string]int
+	namespace     string
+	leases        int
+	casFailure    bool
+	maxTTLReached bool
+}
+
+func newStubServer(t *testing.T) *stubServer {
+	s := &stubServer{
+		t:       t,
+		kv:      make(map[string]map[string]interface{}),
+		version: make(map[string]int),
+		tokens:  map[string]bool{"root": true},
+		counts:  make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *stubServer) setKV(path string, data map[string]interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	s.kv[path] = data
+	s.version[path]++
+}
+
+func (s *stubServer) requests(endpoint string) int {
+	s.Lock()
+	defer s.Unlock()
+	return s.counts[endpoint]
+}
+
+func (s *stubServer) revokeTokens() {
+	s.Lock()
+	defer s.Unlock()
+	s.tokens = map[string]bool{"root": true}
+}
+
+func (s *stubServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	path := r.URL.Path[len("/v1/"):]
+	s.counts[r.Method+" "+path]++
+	if ns := r.Header.Get("X-Vault-Namespace"); ns != "" {
+		s.namespace = ns
+	}
+
+	var body map[string]interface{}
+	if r.Body != nil && r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch path {
+	case "auth/approle/login":
+		if body["role_id"] != "role" || body["secret_id"] != "secret-id" {
+			s.reply(w, http.StatusBadRequest, map[string]interface{}{"errors": []string{"invalid role or secret ID"}})
+			return
+		}
+		s.reply(w, http.StatusOK, s.login())
+		return
+	case "auth/kubernetes/login":
+		if body["role"] != "telegraf" || body["jwt"] != "service-account-jwt" {
+			s.reply(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+		s.reply(w, http.StatusOK, s.login())
+		return
+	}
+
+	if !s.tokens[r.Header.Get("X-Vault-Token")] {
+		s.reply(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+
+	switch {
+	case path == "auth/token/renew-self":
+		s.reply(w, http.StatusOK, map[string]interface{}{
+			"auth": map[string]interface{}{"client_token": r.Header.Get("X-Vault-Token"), "lease_duration": 60, "renewable": true},
+		})
+	case path == "sys/leases/renew":
+		duration := 30
+		if s.maxTTLReached {
+			duration = 0
+		}
+		s.reply(w, http.StatusOK, map[string]interface{}{"lease_id": body["lease_id"], "lease_duration": duration, "renewable": true})
+	case path == "database/creds/readonly":
+		s.leases++
+		s.reply(w, http.StatusOK, map[string]interface{}{
+			"lease_id":       fmt.Sprintf("database/creds/readonly/%d", s.leases),
+			"lease_duration": 30,
+			"renewable":      true,
+			"data": map[string]interface{}{
+				"username": fmt.Sprintf("user-%d", s.leases),
+				"password": fmt.Sprintf("password-%d", s.leases),
+			},
+		})
+	case r.Method == http.MethodGet:
+		data, found := s.kv[path]
+		if !found {
+			s.reply(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+		if len(path) > len("secret/data/") && path[:len("secret/data/")] == "secret/data/" {
+			s.reply(w, http.StatusOK, map[string]interface{}{
+				"data": map[string]interface{}{"data": data, "metadata": map[string]interface{}{"version": s.version[path]}},
+			})
+			return
+		}
+		s.reply(w, http.StatusOK, map[string]interface{}{"lease_duration": 2764800, "data": data})
+	case r.Method == http.MethodPost:
+		options, ok := body["options"].(map[string]interface{})
+		require.True(s.t, ok)
+		if s.casFailure || int(options["cas"].(float64)) != s.version[path] {
+			s.reply(w, http.StatusBadRequest, map[string]interface{}{"errors": []string{"check-and-set parameter did not match the current version"}})
+			return
+		}
+		s.kv[path] = body["data"].(map[string]interface{})
+		s.version[path]++
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *stubServer) login() map[string]interface{} {
+	token := fmt.Sprintf("token-%d", len(s.tokens))
+	s.tokens[token] = true
+	return map[string]interface{}{
+		"auth": map[string]interface{}{"client_token": token, "lease_duration": 60, "renewable": true},
+	}
+}
+
+func (s *stubServer) reply(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	require.NoError(s.t, json.NewEncoder(w).Encode(body))
+}
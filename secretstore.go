@@ -23,3 +23,20 @@ type SecretStore interface {
 // the secret will not change over time, or dynamic (true) to handle
 // secrets that change over time (e.g. TOTP).
 type ResolveFunc func() ([]byte, bool, error)
+
+// SecretStoreNotifier is an optional interface for secret-stores able to
+// signal changes of their secrets, e.g. after credentials were rotated.
+type SecretStoreNotifier interface {
+	// ChangedKeys returns the keys of the secrets changed since the previous
+	// call. The function is called periodically to refresh the secrets
+	// referencing the changed keys.
+	ChangedKeys() ([]string, error)
+}
+
+// SecretUpdateHandler is an optional interface for plugins to be notified
+// about changed secrets while Telegraf is running.
+type SecretUpdateHandler interface {
+	// SecretsUpdated is called after the secrets of the plugin changed,
+	// e.g. to re-read credentials or to reconnect.
+	SecretsUpdated() error
+}
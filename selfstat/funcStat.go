@@ -0,0 +1,44 @@
+package selfstat
+
+import (
+	"sync"
+)
+
+type funcStat struct {
+	measurement string
+	field       string
+	tags        map[string]string
+
+	mu sync.Mutex
+	fn func() int64
+}
+
+// Incr is a no-op as the value is computed on each call to Get.
+func (*funcStat) Incr(int64) {}
+
+// Set is a no-op as the value is computed on each call to Get.
+func (*funcStat) Set(int64) {}
+
+func (s *funcStat) Get() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fn()
+}
+
+func (s *funcStat) Name() string {
+	return s.measurement
+}
+
+func (s *funcStat) FieldName() string {
+	return s.field
+}
+
+// Tags returns a copy of the stat's tags.
+// NOTE this allocates a new map every time it is called.
+func (s *funcStat) Tags() map[string]string {
+	m := make(map[string]string, len(s.tags))
+	for k, v := range s.tags {
+		m[k] = v
+	}
+	return m
+}
@@ -75,6 +75,16 @@ func RegisterTiming(measurement, field string, tags map[string]string) Stat {
 	return registry.registerTiming("internal_"+measurement, field, tags)
 }
 
+// RegisterFunc registers the given measurement, field, and tags in the
+// selfstat registry with the value computed by calling fn on each collection,
+// e.g. for ages depending on the time of collection. If given an identical
+// measurement, the already registered stat uses the given function from now on.
+//
+// Calling Incr() or Set() on the returned Stat has no effect.
+func RegisterFunc(measurement, field string, tags map[string]string, fn func() int64) Stat {
+	return registry.registerFunc("internal_"+measurement, field, tags, fn)
+}
+
 // Metrics returns all registered stats as telegraf metrics.
 func Metrics() []telegraf.Metric {
 	registry.mu.Lock()
@@ -153,6 +163,35 @@ func (r *Registry) registerTiming(measurement, field string, tags map[string]str
 	return s
 }
 
+func (r *Registry) registerFunc(measurement, field string, tags map[string]string, fn func() int64) Stat {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := key(measurement, tags)
+	if stat, ok := registry.get(key, field); ok {
+		if s, ok := stat.(*funcStat); ok {
+			s.mu.Lock()
+			s.fn = fn
+			s.mu.Unlock()
+		}
+		return stat
+	}
+
+	t := make(map[string]string, len(tags))
+	for k, v := range tags {
+		t[k] = v
+	}
+
+	s := &funcStat{
+		measurement: measurement,
+		field:       field,
+		tags:        t,
+		fn:          fn,
+	}
+	registry.set(key, s)
+	return s
+}
+
 func (r *Registry) get(key uint64, field string) (Stat, bool) {
 	if _, ok := r.stats[key]; !ok {
 		return nil, false
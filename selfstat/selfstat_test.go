@@ -108,6 +108,28 @@ func TestRegisterTimingAndIncrAndSet(t *testing.T) {
 	assert.Equal(t, "internal_test", foo.Name())
 }
 
+func TestRegisterFunc(t *testing.T) {
+	testLock.Lock()
+	defer testCleanup()
+	var v int64 = 5
+	s := RegisterFunc("test", "test_field", map[string]string{"test": "foo"}, func() int64 { return v })
+	assert.Equal(t, int64(5), s.Get())
+
+	v = 7
+	assert.Equal(t, int64(7), s.Get())
+
+	// setting has no effect
+	s.Set(12)
+	s.Incr(1)
+	assert.Equal(t, int64(7), s.Get())
+
+	// registering again replaces the function
+	foo := RegisterFunc("test", "test_field", map[string]string{"test": "foo"}, func() int64 { return 42 })
+	assert.Equal(t, int64(42), s.Get())
+	assert.Equal(t, int64(42), foo.Get())
+	assert.Equal(t, "internal_test", foo.Name())
+}
+
 func TestStatKeyConsistency(t *testing.T) {
 	lhs := key("internal_stats", map[string]string{
 		"foo":   "bar",
@@ -47,6 +47,7 @@ type Accumulator struct {
 	Errors    []error
 	debug     bool
 	delivered chan telegraf.DeliveryInfo
+	tracked   []telegraf.TrackingID
 
 	TimeFunc func() time.Time
 }
@@ -202,14 +203,54 @@ func (a *Accumulator) WithTracking(_ int) telegraf.TrackingAccumulator {
 
 func (a *Accumulator) AddTrackingMetric(m telegraf.Metric) telegraf.TrackingID {
 	a.AddMetric(m)
-	return newTrackingID()
+	return a.track()
 }
 
 func (a *Accumulator) AddTrackingMetricGroup(group []telegraf.Metric) telegraf.TrackingID {
 	for _, m := range group {
 		a.AddMetric(m)
 	}
-	return newTrackingID()
+	return a.track()
+}
+
+func (a *Accumulator) track() telegraf.TrackingID {
+	id := newTrackingID()
+	a.Lock()
+	a.tracked = append(a.tracked, id)
+	a.Unlock()
+	return id
+}
+
+// Deliver reports the delivery of all tracked metrics and metric groups added
+// since the last call with the given result and returns their number. The
+// delivery channel must be read for the call to return.
+func (a *Accumulator) Deliver(delivered bool) int {
+	a.Lock()
+	tracked := a.tracked
+	a.tracked = nil
+	if a.delivered == nil {
+		a.delivered = make(chan telegraf.DeliveryInfo)
+	}
+	ch := a.delivered
+	a.Unlock()
+
+	for _, id := range tracked {
+		ch <- &deliveryInfo{id: id, delivered: delivered}
+	}
+	return len(tracked)
+}
+
+type deliveryInfo struct {
+	id        telegraf.TrackingID
+	delivered bool
+}
+
+func (d *deliveryInfo) ID() telegraf.TrackingID {
+	return d.id
+}
+
+func (d *deliveryInfo) Delivered() bool {
+	return d.delivered
 }
 
 func (a *Accumulator) Delivered() <-chan telegraf.DeliveryInfo {